@@ -1,5 +1,7 @@
 import Alpine from "alpinejs";
 import "./css/style.css";
+import "./passkeys";
+import "./live";
 
 import htmx from "htmx.org";
 
@@ -15,6 +17,14 @@ window.Alpine = Alpine;
 
 Alpine.start();
 
+// 🛡️ Send the CSRF token with every htmx request, so forms don't need the hidden _csrf field
+document.body.addEventListener('htmx:configRequest', function(event: any) {
+  const token = document.querySelector('meta[name="csrf-token"]')?.getAttribute('content');
+  if (token) {
+    event.detail.headers['X-CSRF-Token'] = token;
+  }
+});
+
 document.body.addEventListener('htmx:responseError', function(event: any) {
   const target = event.detail.target; // The element specified by hx-target
   const response = event.detail.xhr.responseText; // The HTML response
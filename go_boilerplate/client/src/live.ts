@@ -0,0 +1,55 @@
+// 📡 Live updates: an element with data-ws-otp opens the websocket, joins the room the
+// password grants, and gets "html" events inserted at its top, newest first, or swapped in
+// place of the element they name.
+
+import htmx from "htmx.org";
+
+interface HtmlData {
+  id: string;
+  html: string;
+  swap?: 'outerHTML';
+}
+
+function connect(otp: string) {
+  const scheme = location.protocol === 'https:' ? 'wss' : 'ws';
+  const socket = new WebSocket(`${scheme}://${location.host}/ws`);
+
+  socket.addEventListener('open', () => {
+    socket.send(JSON.stringify({ type: 'sendotp', payload: { otp } }));
+  });
+
+  socket.addEventListener('message', (message) => {
+    const event = JSON.parse(message.data);
+    if (event.type !== 'html') {
+      return;
+    }
+    const data = event.payload as HtmlData;
+    const target = document.getElementById(data.id);
+    if (!target) {
+      return;
+    }
+    if (data.swap === 'outerHTML') {
+      const template = document.createElement('template');
+      template.innerHTML = data.html;
+      const replacement = template.content.firstElementChild as HTMLElement | null;
+      if (replacement) {
+        target.replaceWith(replacement);
+        htmx.process(replacement);
+      }
+      return;
+    }
+    target.insertAdjacentHTML('afterbegin', data.html);
+    htmx.process(target.firstElementChild as HTMLElement);
+
+    // Long sessions would otherwise grow the page without bound
+    const limit = parseInt(target.dataset.wsLimit || '0', 10);
+    while (limit > 0 && target.childElementCount > limit) {
+      target.lastElementChild?.remove();
+    }
+  });
+}
+
+const otp = document.querySelector<HTMLElement>('[data-ws-otp]')?.dataset.wsOtp;
+if (otp) {
+  connect(otp);
+}
@@ -0,0 +1,153 @@
+// 🔑 Passkey ceremonies: the server sends WebAuthn options as JSON with base64url binary
+// fields, the browser API wants ArrayBuffers, and the answer goes back as base64url again.
+
+function toBuffer(value: string): ArrayBuffer {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function toBase64URL(buffer: ArrayBuffer | null): string | undefined {
+  if (!buffer) {
+    return undefined;
+  }
+  let binary = '';
+  for (const byte of new Uint8Array(buffer)) {
+    binary += String.fromCharCode(byte);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function decodeDescriptors(list: any[] | undefined): PublicKeyCredentialDescriptor[] | undefined {
+  return list?.map((credential) => ({ ...credential, id: toBuffer(credential.id) }));
+}
+
+async function post(url: string, csrf: string, body?: unknown): Promise<any> {
+  const response = await fetch(url, {
+    method: 'POST',
+    credentials: 'same-origin',
+    headers: {
+      'Accept': 'application/json',
+      'Content-Type': 'application/json',
+      'X-CSRF-Token': csrf,
+    },
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+
+  const payload = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw new Error(payload.message || 'Something went wrong, please try again');
+  }
+  return payload;
+}
+
+async function register(form: HTMLFormElement, csrf: string): Promise<string> {
+  const options = await post('/account/passkeys/register/begin', csrf);
+  const publicKey = options.publicKey;
+
+  const credential = (await navigator.credentials.create({
+    publicKey: {
+      ...publicKey,
+      challenge: toBuffer(publicKey.challenge),
+      user: { ...publicKey.user, id: toBuffer(publicKey.user.id) },
+      excludeCredentials: decodeDescriptors(publicKey.excludeCredentials),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('No passkey was created');
+  }
+
+  const response = credential.response as AuthenticatorAttestationResponse;
+  const name = (form.querySelector('input[name="name"]') as HTMLInputElement | null)?.value ?? '';
+
+  const result = await post('/account/passkeys/register/finish?name=' + encodeURIComponent(name), csrf, {
+    id: credential.id,
+    rawId: toBase64URL(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+      attestationObject: toBase64URL(response.attestationObject),
+      transports: response.getTransports?.() ?? [],
+    },
+  });
+  return result.redirect;
+}
+
+async function login(csrf: string): Promise<string> {
+  const options = await post('/login/passkey/begin', csrf);
+  const publicKey = options.publicKey;
+
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      ...publicKey,
+      challenge: toBuffer(publicKey.challenge),
+      allowCredentials: decodeDescriptors(publicKey.allowCredentials),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('No passkey was selected');
+  }
+
+  const response = credential.response as AuthenticatorAssertionResponse;
+
+  const result = await post('/login/passkey/finish', csrf, {
+    id: credential.id,
+    rawId: toBase64URL(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+      authenticatorData: toBase64URL(response.authenticatorData),
+      signature: toBase64URL(response.signature),
+      userHandle: toBase64URL(response.userHandle),
+    },
+  });
+  return result.redirect;
+}
+
+function showError(form: HTMLFormElement, message: string) {
+  form.parentNode?.querySelector('.error-message')?.remove();
+
+  const error = document.createElement('div');
+  error.className = 'bg-red-50 border-l-4 border-red-500 p-4 rounded shadow-md error-message mt-4 text-sm text-red-700';
+  error.setAttribute('role', 'alert');
+  error.textContent = message;
+  form.parentNode?.insertBefore(error, form.nextSibling);
+
+  setTimeout(() => error.remove(), 5000);
+}
+
+// Forms opt in with data-passkey="register" or data-passkey="login"
+document.addEventListener('submit', async (event) => {
+  const form = event.target as HTMLFormElement;
+  const mode = form.dataset?.passkey;
+  if (!mode) {
+    return;
+  }
+  event.preventDefault();
+
+  if (!window.PublicKeyCredential) {
+    showError(form, 'This browser does not support passkeys');
+    return;
+  }
+
+  const csrf = (form.querySelector('input[name="_csrf"]') as HTMLInputElement | null)?.value ?? '';
+  const button = form.querySelector('button') as HTMLButtonElement | null;
+  if (button) button.disabled = true;
+
+  try {
+    const redirect = mode === 'register' ? await register(form, csrf) : await login(csrf);
+    window.location.assign(redirect);
+  } catch (err: any) {
+    // The user closing the browser prompt is not worth an error message
+    if (err?.name !== 'NotAllowedError' && err?.name !== 'AbortError') {
+      showError(form, err?.message || 'Passkey request failed');
+    }
+  } finally {
+    if (button) button.disabled = false;
+  }
+});
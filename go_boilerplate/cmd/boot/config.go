@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	//===
 	"regexp"
@@ -37,6 +40,23 @@ func isValidDSN(dsn string) bool {
 	return dsnRegex.MatchString(dsn)
 }
 
+// parseWebhookSecrets reads "provider=secret,provider=secret"
+func parseWebhookSecrets(value string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for entry := range strings.SplitSeq(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		provider, secret, ok := strings.Cut(entry, "=")
+		provider, secret = strings.TrimSpace(provider), strings.TrimSpace(secret)
+		if !ok || provider == "" || secret == "" {
+			return nil, fmt.Errorf("invalid WEBHOOK_SECRETS entry: %q", provider)
+		}
+		secrets[provider] = secret
+	}
+	return secrets, nil
+}
+
 ===//
 
 type Config struct {
@@ -44,13 +64,55 @@ type Config struct {
 	Host  string
 	GoEnv enums.Environment
 	//===
-	DSN string
+	DSN       string
+	SecretKey string
+	// WebhookSecrets maps inbound webhook providers to their signing secret
+	WebhookSecrets map[string]string
+	// SlowQueryThreshold logs queries taking longer, zero disables the log
+	SlowQueryThreshold time.Duration
+	// AuditRetention is how long audit log entries are kept, zero keeps them forever
+	AuditRetention time.Duration
 	===//
-	NTFY         string
-	NTFYToken    string
+	NTFY      string
+	NTFYToken string
+	// NTFYTopic defaults to the project name
+	NTFYTopic string
+	// NotifyRoutes picks the sinks per severity, see notify.ParseRoutes
+	NotifyRoutes     string
+	NotifyWebhookURL string
+	// NotifyEmail lists the addresses the email sink writes to, comma separated
+	NotifyEmail  string
 	URL          string
 	MetricSecret string
-	Prometheus   string
+	// Prometheus lists the hosts allowed to scrape, comma separated
+	Prometheus       string
+	MetricsAllowlist string
+	// MetricsAddr serves /metrics on a separate internal listener instead of the public router
+	MetricsAddr  string
+	MailBackend  string
+	MailFrom     string
+	MailDir      string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// RateLimits overrides or adds rate limit policies, see ratelimit.ParsePolicies
+	RateLimits         string
+	RateLimitAllowlist string
+	RateLimitStore     string
+	// SlowRequestThreshold logs the Server-Timing breakdown of requests taking longer
+	SlowRequestThreshold time.Duration
+	// TrustedProxies lists the CIDR ranges allowed to forward client addresses
+	TrustedProxies string
+	// ProxyHeader is the header they write the client address in, see middlewares.ProxyConfig
+	ProxyHeader string
+	// ReportSinks lists where reported errors go, comma separated among file, stdout and notify
+	ReportSinks string
+	ReportPath  string
+	// ReportMaxSize rotates the report file past this many bytes, read in megabytes
+	ReportMaxSize int64
+	// ReportRotation starts a new report file every interval, ReportRetention drops rotated ones older than it
+	ReportRotation  time.Duration
+	ReportRetention time.Duration
 }
 
 var Environment = &Config{}
@@ -74,11 +136,118 @@ func LoadEnvVariables() error {
 	if !isValidDSN(Environment.DSN) {
 		return fmt.Errorf("invalid DSN: %s", Environment.DSN)
 	}
+	Environment.SecretKey = os.Getenv("SECRET_KEY")
+	if len(Environment.SecretKey) < 32 {
+		return fmt.Errorf("SECRET_KEY must be at least 32 characters long")
+	}
+	webhookSecrets, err := parseWebhookSecrets(os.Getenv("WEBHOOK_SECRETS"))
+	if err != nil {
+		return err
+	}
+	Environment.WebhookSecrets = webhookSecrets
+	Environment.SlowQueryThreshold = 200 * time.Millisecond
+	if value := os.Getenv("SLOW_QUERY_THRESHOLD"); value != "" {
+		if Environment.SlowQueryThreshold, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid SLOW_QUERY_THRESHOLD: %w", err)
+		}
+	}
+	Environment.AuditRetention = 365 * 24 * time.Hour
+	if value := os.Getenv("AUDIT_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			return fmt.Errorf("invalid AUDIT_RETENTION: %s", value)
+		}
+		Environment.AuditRetention = retention
+	}
 	===//
 	Environment.NTFY = os.Getenv("NTFY")
 	Environment.NTFYToken = os.Getenv("NTFY_TOKEN")
-	Environment.MetricSecret = os.Getenv("METRIC_SECRET")
+	Environment.NTFYTopic = os.Getenv("NTFY_TOPIC")
+	Environment.NotifyRoutes = os.Getenv("NOTIFY_ROUTES")
+	Environment.NotifyWebhookURL = os.Getenv("NOTIFY_WEBHOOK_URL")
+	Environment.NotifyEmail = os.Getenv("NOTIFY_EMAIL")
+	Environment.MetricSecret = os.Getenv("METRICS_SECRET")
+	if Environment.MetricSecret == "" {
+		// The name this was read under before, kept working for existing deployments
+		Environment.MetricSecret = os.Getenv("METRIC_SECRET")
+	}
 	Environment.Prometheus = os.Getenv("PROMETHEUS")
+	Environment.MetricsAllowlist = os.Getenv("METRICS_ALLOWLIST")
+	Environment.MetricsAddr = os.Getenv("METRICS_ADDR")
+	Environment.MailBackend = os.Getenv("MAIL_BACKEND")
+	if Environment.MailBackend == "" {
+		Environment.MailBackend = "file"
+	}
+	Environment.MailFrom = os.Getenv("MAIL_FROM")
+	Environment.MailDir = os.Getenv("MAIL_DIR")
+	if Environment.MailDir == "" {
+		Environment.MailDir = "./tmp/mail"
+	}
+	Environment.SMTPAddr = os.Getenv("SMTP_ADDR")
+	Environment.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	Environment.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	if Environment.MailBackend == "smtp" && Environment.SMTPAddr == "" {
+		return fmt.Errorf("SMTP_ADDR is required when MAIL_BACKEND is smtp")
+	}
+	Environment.RateLimits = os.Getenv("RATE_LIMITS")
+	Environment.RateLimitAllowlist = os.Getenv("RATE_LIMIT_ALLOWLIST")
+	Environment.RateLimitStore = os.Getenv("RATE_LIMIT_STORE")
+	if Environment.RateLimitStore == "" {
+		Environment.RateLimitStore = "memory"
+	}
+	if Environment.RateLimitStore != "memory" && Environment.RateLimitStore != "postgres" {
+		return fmt.Errorf("invalid RATE_LIMIT_STORE: %s", Environment.RateLimitStore)
+	}
+	Environment.SlowRequestThreshold = time.Second
+	if value := os.Getenv("SLOW_REQUEST_THRESHOLD"); value != "" {
+		threshold, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid SLOW_REQUEST_THRESHOLD: %w", err)
+		}
+		Environment.SlowRequestThreshold = threshold
+	}
+	Environment.TrustedProxies = os.Getenv("TRUSTED_PROXIES")
+	Environment.ProxyHeader = os.Getenv("PROXY_HEADER")
+	if Environment.ProxyHeader == "" {
+		Environment.ProxyHeader = "X-Forwarded-For"
+	}
+	switch strings.ToLower(Environment.ProxyHeader) {
+	case "x-forwarded-for", "forwarded", "cf-connecting-ip":
+	default:
+		return fmt.Errorf("invalid PROXY_HEADER, expected X-Forwarded-For, Forwarded or CF-Connecting-IP: %s", Environment.ProxyHeader)
+	}
+	Environment.ReportSinks = os.Getenv("REPORT_SINKS")
+	if Environment.ReportSinks == "" {
+		Environment.ReportSinks = "file"
+	}
+	Environment.ReportPath = os.Getenv("REPORT_PATH")
+	if Environment.ReportPath == "" {
+		Environment.ReportPath = "./reports/errors.log"
+	}
+	Environment.ReportMaxSize = 10 << 20
+	if value := os.Getenv("REPORT_MAX_SIZE"); value != "" {
+		megabytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || megabytes < 0 {
+			return fmt.Errorf("invalid REPORT_MAX_SIZE: %s", value)
+		}
+		Environment.ReportMaxSize = megabytes << 20
+	}
+	Environment.ReportRotation = 24 * time.Hour
+	if value := os.Getenv("REPORT_ROTATION"); value != "" {
+		rotation, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid REPORT_ROTATION: %w", err)
+		}
+		Environment.ReportRotation = rotation
+	}
+	Environment.ReportRetention = 30 * 24 * time.Hour
+	if value := os.Getenv("REPORT_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid REPORT_RETENTION: %w", err)
+		}
+		Environment.ReportRetention = retention
+	}
 	if Environment.GoEnv == enums.Environments.DEVELOPMENT {
 		localIP := getLocalIP()
 		Environment.URL = fmt.Sprintf("http://%s:%s", localIP, Environment.Port)
@@ -2,19 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/__username__/go_boilerplate/cmd/boot"
 	"github.com/__username__/go_boilerplate/internal/config"
 
 	//===
+	"github.com/__username__/go_boilerplate/internal/audit"
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/cluster"
 	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/queue"
+	"github.com/__username__/go_boilerplate/internal/ratelimit"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/webhooks"
+	"github.com/google/uuid"
 	===//
+	"github.com/__username__/go_boilerplate/internal/alerting"
 	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/mail"
+	"github.com/__username__/go_boilerplate/internal/notify"
+	"github.com/__username__/go_boilerplate/internal/tools"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -27,6 +44,21 @@ func main() {
 		log.Fatalf("Failed to load Vite manifest: %v", err)
 	}
 
+	if err := mail.Configure(boot.Environment); err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+
+	if err := notify.Configure(boot.Environment); err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+
+	reporter, err := createReporter(boot.Environment)
+	if err != nil {
+		log.Fatalf("Failed to configure the error reporter: %v", err)
+	}
+	helpers.SetDefaultReporter(reporter)
+	defer func() { _ = reporter.Close() }()
+
 	// Create a root ctx and a CancelFunc which can be used to cancel retentionMap goroutine
 	rootCtx := context.Background()
 	ctx, cancel := context.WithCancel(rootCtx)
@@ -34,12 +66,134 @@ func main() {
 
 	port := boot.Environment.Port
 
+	schedulerOptions := []tools.Option{tools.WithSeconds()}
+
 	//===
+	database.SlowQueryThreshold = boot.Environment.SlowQueryThreshold
 	database.Setup(boot.Environment.DSN)
 	defer database.Close()
+	prometheus.MustRegister(database.NewPoolCollector(database.Pool()))
+
+	// Records every run in job_runs, and runs the jobs marked exclusive on a single replica
+	coordinator := cluster.NewCoordinator(repository.New(database.DB()))
+	coordinator.OnOverdue = func(job string, lastSuccess time.Time) {
+		since := "it started"
+		if !lastSuccess.IsZero() {
+			since = lastSuccess.Format(time.RFC3339)
+		}
+		notify.Send(ctx, notify.Notification{
+			Title:    "Scheduled job overdue",
+			Message:  fmt.Sprintf("Job %s has not succeeded since %s", job, since),
+			Severity: notify.SeverityWarning,
+			Tags:     []string{"warning"},
+		})
+	}
+	schedulerOptions = append(schedulerOptions, tools.WithMiddleware(coordinator.History))
+	===//
+	scheduler := tools.NewScheduler(schedulerOptions...)
+
+	//===
+
+	if err := scheduler.AddJob("session-cleanup", "0 0 * * * *", tools.Func(auth.CleanupSessions), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule session cleanup: %v", err)
+	}
+	if err := scheduler.AddJob("email-token-cleanup", "0 30 * * * *", tools.Func(auth.CleanupEmailTokens), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule email token cleanup: %v", err)
+	}
+	if err := scheduler.AddJob("webauthn-ceremony-cleanup", "0 */10 * * * *", tools.Func(auth.CleanupWebAuthnCeremonies), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule passkey ceremony cleanup: %v", err)
+	}
+	if err := scheduler.AddJob("webhook-cleanup", "0 15 3 * * *", tools.Func(webhooks.CleanupWebhookEvents), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule webhook cleanup: %v", err)
+	}
+
+	dispatcher := webhooks.NewDispatcher(repository.New(database.DB()))
+	dispatcher.OnDisabled = func(subscription uuid.UUID, url string, err error) {
+		notify.Send(ctx, notify.Notification{
+			Title:    "Webhook subscription disabled",
+			Message:  fmt.Sprintf("Webhook subscription to %s disabled after repeated failures: %v", url, err),
+			Severity: notify.SeverityWarning,
+			Tags:     []string{"warning"},
+			Click:    boot.Environment.URL + "/admin/webhook-subscriptions/" + subscription.String(),
+		})
+	}
+	if err := scheduler.AddJob("webhook-delivery", "*/10 * * * * *", tools.Func(dispatcher.Job())); err != nil {
+		log.Fatalf("Failed to schedule webhook delivery: %v", err)
+	}
+	if err := scheduler.AddJob("webhook-delivery-cleanup", "0 45 3 * * *", tools.Func(webhooks.CleanupWebhookDeliveries), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule webhook delivery cleanup: %v", err)
+	}
+
+	outbox := mail.NewOutbox(repository.New(database.DB()))
+	outbox.OnDead = func(id uuid.UUID, msg mail.Message, err error) {
+		notify.Send(ctx, notify.Notification{
+			Title:    "Mail dead-lettered",
+			Message:  fmt.Sprintf("Mail %s %q to %v could not be delivered: %v", id, msg.Subject, msg.To, err),
+			Severity: notify.SeverityWarning,
+			Tags:     []string{"warning"},
+		})
+	}
+	if err := scheduler.AddJob("mail-delivery", "*/5 * * * * *", tools.Func(outbox.Job())); err != nil {
+		log.Fatalf("Failed to schedule mail delivery: %v", err)
+	}
+	if err := scheduler.AddJob("mail-outbox-cleanup", "0 50 3 * * *", tools.Func(outbox.Cleanup), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule mail outbox cleanup: %v", err)
+	}
+
+	// Runs the jobs enqueued with queue.Enqueue, register their handlers here with queue.Register
+	worker := queue.NewWorker(repository.New(database.DB()))
+	worker.OnDead = func(job queue.Job, err error) {
+		notify.Send(ctx, notify.Notification{
+			Title:    "Queued job dead-lettered",
+			Message:  fmt.Sprintf("Job %s (%s) failed for good after %d attempts: %v", job.ID, job.Kind, job.Attempt, err),
+			Severity: notify.SeverityWarning,
+			Tags:     []string{"warning"},
+		})
+	}
+	if err := scheduler.AddJob("queue-cleanup", "0 10 4 * * *", tools.Func(worker.Cleanup), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule job queue cleanup: %v", err)
+	}
+
+	if boot.Environment.RateLimitStore == "postgres" {
+		if err := scheduler.AddJob("rate-limit-cleanup", "0 */5 * * * *", tools.Func(ratelimit.CleanupRateLimits), coordinator.Exclusive()); err != nil {
+			log.Fatalf("Failed to schedule rate limit cleanup: %v", err)
+		}
+	}
+	if err := scheduler.AddJob("job-run-cleanup", "0 5 4 * * *", tools.Func(cluster.CleanupJobRuns), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule job run cleanup: %v", err)
+	}
+	if boot.Environment.AuditRetention > 0 {
+		if err := scheduler.AddJob("audit-retention", "0 55 3 * * *", tools.Func(audit.Retention(database.DB(), boot.Environment.AuditRetention)), coordinator.Exclusive()); err != nil {
+			log.Fatalf("Failed to schedule audit log retention: %v", err)
+		}
+	}
 	===//
 
-	e := createRouter(ctx)
+	if err := scheduler.AddJob("report-retention", "0 20 3 * * *", tools.Func(reporter.Cleanup)); err != nil {
+		log.Fatalf("Failed to schedule report retention: %v", err)
+	}
+
+	// Pages through the notifier when the app's own metrics break their thresholds, for deployments without Alertmanager
+	alerts := alerting.NewEngine(prometheus.DefaultGatherer, alerting.DefaultRules(), func(alert alerting.Alert) {
+		notify.Send(ctx, alert.Notification())
+	})
+	if err := scheduler.AddJob("alert-evaluation", "*/30 * * * * *", tools.Func(alerts.Evaluate)); err != nil {
+		log.Fatalf("Failed to schedule alert evaluation: %v", err)
+	}
+
+	//===
+	// The dead man's switch, registered last so it watches every job
+	if err := scheduler.AddJob("job-watchdog", "0 * * * * *", coordinator.Watchdog(scheduler), coordinator.Exclusive()); err != nil {
+		log.Fatalf("Failed to schedule the job watchdog: %v", err)
+	}
+	===//
+	scheduler.Start()
+	//===
+	worker.Start()
+	go worker.Listen(ctx, database.Pool())
+	===//
+
+	e := createRouter(ctx, alerts, scheduler)
 
 	go func() {
 		e.Logger.Infof("Running Server on port %s", port)
@@ -49,14 +203,72 @@ func main() {
 		e.Logger.Fatal(e.Start(":" + port))
 	}()
 
+	var metrics *echo.Echo
+	if boot.Environment.MetricsAddr != "" {
+		metrics = createMetricsServer(metricsAccess())
+		go func() {
+			e.Logger.Infof("Serving metrics on %s", boot.Environment.MetricsAddr)
+			if err := metrics.Start(boot.Environment.MetricsAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				e.Logger.Fatal(err)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
-	helpers.Notify("go_boilerplate", "Server is shutting down")
+	notify.Send(ctx, notify.Notification{Title: "Server is shutting down", Message: "Server is shutting down", Tags: []string{"stop_sign"}})
 	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	// Notifications still queued go out while the servers drain, the last ones are flushed below
+	defer func() {
+		if err := notify.Close(ctx); err != nil {
+			log.Printf("Notifications lost on shutdown: %v", err)
+		}
+	}()
+	if metrics != nil {
+		if err := metrics.Shutdown(ctx); err != nil {
+			e.Logger.Errorf("Metrics server forced to shutdown: %v", err)
+		}
+	}
+	//===
+	if err := worker.Stop(ctx); err != nil {
+		e.Logger.Errorf("Queued jobs still running on shutdown were cancelled: %v", err)
+	}
+	===//
+	if err := scheduler.Stop(ctx); err != nil {
+		e.Logger.Errorf("Jobs still running on shutdown were cancelled: %v", err)
+	}
 	if err := e.Shutdown(ctx); err != nil {
-		helpers.Notify("go_boilerplate", fmt.Sprintf("Server forced to shutdown: %v", err))
+		notify.Send(ctx, notify.Notification{Title: "Server forced to shutdown", Message: fmt.Sprintf("Server forced to shutdown: %v", err), Severity: notify.SeverityCritical})
+		_ = notify.Close(ctx)
 		e.Logger.Fatal(err)
 	}
 }
+
+// createReporter builds the reporter behind apperrors and the router from REPORT_SINKS
+func createReporter(cfg *boot.Config) (*helpers.Reporter, error) {
+	var sinks []helpers.Sink
+	for name := range strings.SplitSeq(cfg.ReportSinks, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "file":
+			sink, err := helpers.NewFileSink(cfg.ReportPath, helpers.RotateOptions{
+				MaxSize:   cfg.ReportMaxSize,
+				Every:     cfg.ReportRotation,
+				Retention: cfg.ReportRetention,
+			})
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "stdout":
+			sinks = append(sinks, helpers.NewWriterSink(os.Stdout))
+		case "notify":
+			sinks = append(sinks, notify.NewReportSink(helpers.SeverityLevels.ERROR))
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown REPORT_SINKS sink %q", name)
+		}
+	}
+	return helpers.NewReporter(sinks...), nil
+}
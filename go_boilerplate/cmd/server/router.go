@@ -11,15 +11,27 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/alerting"
 	"github.com/__username__/go_boilerplate/internal/api"
 	"github.com/__username__/go_boilerplate/internal/config"
 	"github.com/__username__/go_boilerplate/internal/enums"
 	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/ratelimit"
+	"github.com/__username__/go_boilerplate/internal/tools"
 
 	//--
 	"github.com/__username__/go_boilerplate/internal/connections"
+	"github.com/prometheus/client_golang/prometheus"
 	--//
 
+	//===
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/notify"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/webhooks"
+	===//
+
 	"github.com/__username__/go_boilerplate/internal/controllers"
 	"github.com/__username__/go_boilerplate/internal/middlewares"
 	"github.com/__username__/go_boilerplate/views"
@@ -29,11 +41,53 @@ import (
 	"github.com/labstack/gommon/log"
 )
 
-func createRouter(ctx context.Context) *echo.Echo {
+// createMetricsServer serves /metrics alone behind access, meant for an address only the scraper can reach
+func createMetricsServer(access echo.MiddlewareFunc) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	// No proxy stands in front of this listener, so forwarding headers must not pass the allowlist
+	e.IPExtractor = echo.ExtractIPDirect()
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), access)
+	return e
+}
+
+func metricsAccess() echo.MiddlewareFunc {
+	cfg, err := middlewares.DefaultMetricsAccessConfig()
+	if err != nil {
+		log.Fatalf("Failed to configure metrics access: %v", err)
+	}
+	return middlewares.MetricsAccess(cfg)
+}
+
+func createRouter(ctx context.Context, alerts *alerting.Engine, scheduler *tools.Scheduler) *echo.Echo {
 	e := echo.New()
+
+	trustedProxies, err := helpers.ParseCIDRs(boot.Environment.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	// Everything asking for the client address goes through c.RealIP(), logging and rate limits included
+	e.IPExtractor = middlewares.IPExtractor(middlewares.ProxyConfig{
+		Trusted: trustedProxies,
+		Header:  boot.Environment.ProxyHeader,
+	})
+
+	e.Use(middleware.RequestID())
 	e.Use(middleware.RequestLogger())
+	// Panics are reported with their stack and answered by serverErrorHandler
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
+		DisableStackAll: true,
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			log.Errorf("%v\n%s", err, stack)
+			entry := helpers.RequestEntry(c, helpers.SeverityLevels.PANIC, err.Error(), http.StatusInternalServerError)
+			entry.Stack = string(stack)
+			_ = helpers.DefaultReporter().Write(entry)
+			c.Set(reportedKey, true)
+			return err
+		},
+	}))
 	e.Use(middleware.RemoveTrailingSlash())
-	e.Use(middlewares.RateLimiter())
 	// Apply Gzip middleware, but skip it for /metrics
 	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 		Level: 5,
@@ -42,7 +96,39 @@ func createRouter(ctx context.Context) *echo.Echo {
 		},
 	}))
 	e.Use(middlewares.MonitoringMiddleware())
-	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), middlewares.MetricsAccessMiddleware())
+
+	// Everyone sees the breakdown in development, only admins in production
+	serverTiming := middlewares.ServerTimingConfig{
+		Expose:        func(echo.Context) bool { return boot.Environment.GoEnv == enums.Environments.DEVELOPMENT },
+		SlowThreshold: boot.Environment.SlowRequestThreshold,
+	}
+	//===
+	if boot.Environment.GoEnv != enums.Environments.DEVELOPMENT {
+		serverTiming.Expose = func(c echo.Context) bool {
+			// What auth.RequireAdmin lets through, a session still waiting on 2FA sees nothing
+			session, ok := auth.CurrentSession(c)
+			return ok && session.Authenticated() && session.IsAdmin && session.TwoFactor
+		}
+	}
+	===//
+	e.Use(middlewares.ServerTiming(serverTiming))
+
+	// Policies are attached per group below, so assets, health checks and /metrics are never limited
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	//===
+	if boot.Environment.RateLimitStore == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(repository.New(database.DB()))
+	}
+	===//
+	limiter, err := ratelimit.FromConfig(boot.Environment, rateLimitStore)
+	if err != nil {
+		log.Fatalf("Failed to configure rate limits: %v", err)
+	}
+
+	// With METRICS_ADDR set, metrics are only served by the internal listener from createMetricsServer
+	if boot.Environment.MetricsAddr == "" {
+		e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), metricsAccess())
+	}
 	e.GET("/healthcheck", func(c echo.Context) error {
 		time.Sleep(5 * time.Second)
 		return c.JSON(http.StatusOK, "OK")
@@ -100,12 +186,19 @@ Sitemap: %s/sitemap.xml
 	//--
 	wsManager := connections.NewManager(ctx)
 
-	e.GET("/ws", wsManager.ServeWS)
+	var wsMiddlewares []echo.MiddlewareFunc
+	//===
+	// The session names the user SendToUser reaches
+	wsMiddlewares = append(wsMiddlewares, auth.LoadSession())
+	===//
+	e.GET("/ws", wsManager.ServeWS, wsMiddlewares...)
+	prometheus.MustRegister(connections.NewCollector(wsManager))
 	--//
 
 	web := e.Group("")
 
 	web.Use(middlewares.SecurityHeaders())
+	web.Use(limiter.Limit("web"))
 
 	if boot.Environment.GoEnv == enums.Environments.DEVELOPMENT {
 		e.Logger.SetLevel(log.DEBUG)
@@ -119,40 +212,125 @@ Sitemap: %s/sitemap.xml
 
 	}
 
-	web.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-		TokenLookup:    "form:_csrf,header:X-CSRF-Token",
-		CookieName:     "csrf_token",
-		CookiePath:     "/",
-		CookieHTTPOnly: true,
-		CookieSecure:   boot.Environment.GoEnv == enums.Environments.PRODUCTION,
-		CookieSameSite: http.SameSiteLaxMode,
-		Skipper: func(c echo.Context) bool {
-			// Skip CSRF for the /webhook route
-			return c.Path() == "/webhook"
+	csrf := middlewares.DefaultCSRFConfig()
+	// Webhook senders prove themselves with signatures instead
+	csrf.Exempt = []string{"/webhook/*"}
+	web.Use(middlewares.CSRF(csrf))
 
-		},
-	}))
+	web.GET("/csrf", middlewares.CSRFTokenHandler())
+
+	//===
+	web.Use(auth.LoadSession())
+	===//
 
 	//--
 	go wsManager.Run()
 	--//
 
+	//===
+	webhookReceiver := webhooks.NewReceiver(repository.New(database.DB()))
+	for name, secret := range boot.Environment.WebhookSecrets {
+		webhookReceiver.Register(webhooks.ProviderFor(name, secret), webhooks.LogHandler)
+	}
+	webhookReceiver.OnDeadLetter = func(event webhooks.Event, err error) {
+		notify.Send(ctx, notify.Notification{
+			Title:    "Webhook dead-lettered",
+			Message:  fmt.Sprintf("Webhook %s %s dead-lettered: %v", event.Provider, event.EventID, err),
+			Severity: notify.SeverityWarning,
+			Tags:     []string{"warning"},
+		})
+	}
+
+	web.POST("/webhook/:provider", webhookReceiver.Handle())
+	go webhookReceiver.Run(ctx)
+	===//
+
 	web.GET("/", controllers.Index())
 
 	web.GET("/examples", controllers.Examples())
 
-	//===
-	web.GET("/examples/users", controllers.FetchAllUsers())
+	// Mail written by the file backend, the HTML body brings its own CSP
+	if boot.Environment.GoEnv == enums.Environments.DEVELOPMENT && boot.Environment.MailBackend == "file" {
+		web.GET("/dev/mail", controllers.MailPreviews())
+		web.GET("/dev/mail/:name", controllers.MailPreview())
+		e.GET("/dev/mail/:name/html", controllers.MailPreviewHTML())
+	}
 
-	web.POST("/examples/users", controllers.AddNewUser())
-	web.PATCH("/examples/users/:id", controllers.ToggeleUserEmail())
-	web.DELETE("/examples/users/:id", controllers.DeleteUser())
+	//===
+	exampleUserRoutes(web)
+
+	web.GET("/signup", controllers.Signup())
+	web.POST("/signup", controllers.PostSignup(), limiter.Limit("auth"))
+	web.GET("/login", controllers.Login())
+	web.POST("/login", controllers.PostLogin(), limiter.Limit("auth"))
+	web.GET("/login/2fa", controllers.TwoFactorChallenge())
+	web.POST("/login/2fa", controllers.PostTwoFactorChallenge(), limiter.Limit("auth"))
+	web.GET("/login/magic", controllers.MagicLink())
+	web.POST("/login/magic", controllers.PostMagicLink(), limiter.Limit("auth"))
+	web.GET("/login/magic/:token", controllers.MagicLinkConfirm())
+	web.POST("/login/magic/:token", controllers.PostMagicLinkConfirm(), limiter.Limit("auth"))
+	web.POST("/login/passkey/begin", controllers.BeginPasskeyLogin(), limiter.Limit("auth"))
+	web.POST("/login/passkey/finish", controllers.FinishPasskeyLogin(), limiter.Limit("auth"))
+	web.POST("/logout", controllers.Logout())
+	web.GET("/account/verify/:token", controllers.VerifyEmail())
+	web.POST("/account/verify/:token", controllers.PostVerifyEmail(), limiter.Limit("auth"))
+	web.GET("/account/email/confirm/:token", controllers.ConfirmEmailChange())
+	web.POST("/account/email/confirm/:token", controllers.PostConfirmEmailChange(), limiter.Limit("auth"))
+
+	accountgrp := web.Group("/account", auth.RequireUser())
+	accountgrp.GET("", controllers.AccountSettings())
+	accountgrp.POST("/verify", controllers.ResendVerification())
+	accountgrp.POST("/email", controllers.ChangeEmail())
+	accountgrp.GET("/2fa", controllers.TwoFactorSettings())
+	accountgrp.POST("/2fa/confirm", controllers.ConfirmTwoFactor())
+	accountgrp.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes())
+	accountgrp.POST("/2fa/disable", controllers.DisableTwoFactor())
+	accountgrp.GET("/passkeys", controllers.Passkeys())
+	accountgrp.POST("/passkeys/register/begin", controllers.BeginPasskeyRegistration())
+	accountgrp.POST("/passkeys/register/finish", controllers.FinishPasskeyRegistration())
+	accountgrp.DELETE("/passkeys/:id", controllers.DeletePasskey())
+
+	admingrp := web.Group("/admin", auth.RequireAdmin())
+	admingrp.GET("", controllers.AdminDashboard())
+	admingrp.POST("/users/:id/2fa/reset", controllers.ResetUserTwoFactor())
+	admingrp.GET("/webhooks", controllers.AdminWebhooks())
+	admingrp.GET("/webhooks/:id", controllers.AdminWebhookEvent())
+	admingrp.GET("/webhooks/:id/payload", controllers.AdminWebhookPayload())
+	admingrp.POST("/webhooks/:id/replay", controllers.ReplayWebhookEvent())
+	admingrp.GET("/webhook-subscriptions", controllers.AdminWebhookSubscriptions())
+	admingrp.POST("/webhook-subscriptions", controllers.CreateWebhookSubscription())
+	admingrp.GET("/webhook-subscriptions/:id", controllers.AdminWebhookSubscription())
+	admingrp.POST("/webhook-subscriptions/:id/enable", controllers.EnableWebhookSubscription())
+	admingrp.DELETE("/webhook-subscriptions/:id", controllers.DeleteWebhookSubscription())
+	admingrp.POST("/webhook-deliveries/:id/redeliver", controllers.RedeliverWebhook())
+	admingrp.GET("/alerts", controllers.AdminAlerts(alerts))
+	admingrp.POST("/alerts/silences", controllers.SilenceAlert(alerts))
+	admingrp.DELETE("/alerts/silences/:id", controllers.ExpireSilence(alerts))
+	// Without websockets the error log and the jobs are only read on load
+	var liveOTP func() string
+	//--
+	liveOTP = wsManager.GenerateNewOtp
+	helpers.DefaultReporter().AddSink(controllers.ErrorLogTail(wsManager))
+	scheduler.Observe(controllers.JobsLive(wsManager))
+	--//
+	admingrp.GET("/errors", controllers.AdminErrors(liveOTP))
+	admingrp.GET("/errors/:id", controllers.AdminErrorEntry())
+	admingrp.GET("/audit", controllers.AdminAudit())
+	admingrp.GET("/audit.csv", controllers.AdminAuditCSV())
+	admingrp.POST("/audit/verify", controllers.VerifyAudit())
+	admingrp.GET("/jobs", controllers.AdminJobs(scheduler, liveOTP))
+	admingrp.GET("/jobs.json", controllers.AdminJobsJSON(scheduler))
+	admingrp.GET("/jobs/:id", controllers.AdminJob(scheduler, liveOTP))
+	admingrp.POST("/jobs/:id/run", controllers.RunJob(scheduler))
+	admingrp.POST("/jobs/:id/pause", controllers.PauseJob(scheduler))
+	admingrp.POST("/jobs/:id/resume", controllers.ResumeJob(scheduler))
+	admingrp.POST("/jobs/:id/schedule", controllers.RescheduleJob(scheduler))
 	===//
 	web.POST("/errors/below", controllers.BelowFormError())
 	web.POST("/errors/replace", controllers.ReplaceFormError())
 	web.POST("/errors/toast", controllers.ToastFormError())
 
-	apigrp := e.Group("/api")
+	apigrp := e.Group("/api", limiter.Limit("api"))
 
 	apiv1 := apigrp.Group("/v1")
 	apiv1.POST("/cats", api.GetCats())
@@ -162,6 +340,20 @@ Sitemap: %s/sitemap.xml
 	return e
 }
 
+//===
+// exampleUserRoutes manages real accounts, so listing, creating and deleting them is up to admins
+// while anyone signed in can change their own email
+func exampleUserRoutes(web *echo.Group) {
+	web.GET("/examples/users", controllers.FetchAllUsers(), auth.RequireAdmin())
+	web.POST("/examples/users", controllers.AddNewUser(), auth.RequireAdmin())
+	web.PATCH("/examples/users/:id", controllers.ToggeleUserEmail(), auth.RequireUser())
+	web.DELETE("/examples/users/:id", controllers.DeleteUser(), auth.RequireAdmin())
+}
+
+===//
+// reportedKey marks requests whose error was already reported, like a recovered panic
+const reportedKey = "error_reported"
+
 func serverErrorHandler(err error, c echo.Context) {
 
 	if c.Response().Committed {
@@ -177,6 +369,10 @@ func serverErrorHandler(err error, c echo.Context) {
 		message = he.Message
 	}
 
+	if reported, _ := c.Get(reportedKey).(bool); code >= http.StatusInternalServerError && !reported {
+		_ = helpers.DefaultReporter().Write(helpers.RequestEntry(c, helpers.SeverityLevels.ERROR, err.Error(), code))
+	}
+
 	// Check the Accept header to decide the response format
 	if strings.Contains(c.Request().Header.Get("Accept"), "application/json") {
 		// Respond with JSON if the client prefers JSON
@@ -189,7 +385,7 @@ func serverErrorHandler(err error, c echo.Context) {
 		// Prepare data for rendering the error page (HTML)
 		data := config.GetDefaultSite(c.Request())
 
-		html := helpers.MustRenderHTML(views.Error(data, fmt.Sprintf("%d", code), message.(string)))
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), views.Error(data, fmt.Sprintf("%d", code), message.(string)))
 
 		// Respond with HTML (default) if the client prefers HTML
 		_ = c.Blob(code, "text/html; charset=utf-8", html)
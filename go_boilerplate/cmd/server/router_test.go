@@ -0,0 +1,84 @@
+// router_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/__username__/go_boilerplate/internal/middlewares"
+	//===
+	"github.com/labstack/echo/v4"
+	===//
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+func TestMetricsServer_IgnoresForwardingHeaders(t *testing.T) {
+	t.Parallel()
+
+	e := createMetricsServer(middlewares.MetricsAccess(middlewares.MetricsAccessConfig{
+		Secret:    "scrape",
+		Allowlist: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")},
+	}))
+
+	tests := []struct {
+		name   string
+		remote string
+		want   int
+	}{
+		{name: "spoofed headers from elsewhere", remote: "203.0.113.7:4000", want: http.StatusForbidden},
+		{name: "allowlisted peer", remote: "127.0.0.1:4000", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("Authorization", "Bearer scrape")
+			req.Header.Set("X-Real-IP", "127.0.0.1")
+			req.Header.Set("X-Forwarded-For", "127.0.0.1")
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+//===
+
+func TestExampleUserRoutes_RejectAnonymous(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	exampleUserRoutes(e.Group(""))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{name: "list", method: http.MethodGet, path: "/examples/users"},
+		{name: "create", method: http.MethodPost, path: "/examples/users"},
+		{name: "delete", method: http.MethodDelete, path: "/examples/users/8d5c2a6e-4b9f-4f3a-9d1e-2f6b7c8a9e10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", "application/json")
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+}
+===//
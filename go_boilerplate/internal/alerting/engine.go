@@ -0,0 +1,246 @@
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/notify"
+	"github.com/labstack/gommon/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Alert is a rule result that fired, Resolved is set on the notification that ends it
+type Alert struct {
+	Rule     string    `json:"rule"`
+	Labels   string    `json:"labels,omitempty"`
+	Severity Severity  `json:"severity"`
+	Value    float64   `json:"value"`
+	Summary  string    `json:"summary"`
+	Since    time.Time `json:"since"`
+	Resolved time.Time `json:"resolved,omitzero"`
+	Silenced bool      `json:"silenced"`
+
+	notified time.Time
+}
+
+func (a Alert) key() string {
+	return a.Rule + "{" + a.Labels + "}"
+}
+
+func (a Alert) String() string {
+	state := "FIRING " + string(a.Severity)
+	if !a.Resolved.IsZero() {
+		state = "RESOLVED"
+	}
+	name := a.Rule
+	if a.Labels != "" {
+		name += " " + a.Labels
+	}
+	return fmt.Sprintf("[%s] %s: %s", state, name, a.Summary)
+}
+
+// Notification routes the alert by its severity, resolving keeps it so the end reaches whoever got paged
+func (a Alert) Notification() notify.Notification {
+	n := notify.Notification{
+		Title:    a.Rule,
+		Message:  a.String(),
+		Severity: notify.Severity(a.Severity),
+		Tags:     []string{"rotating_light", string(a.Severity)},
+	}
+	if a.Labels != "" {
+		n.Title += " " + a.Labels
+	}
+	if !a.Resolved.IsZero() {
+		n.Title = "Resolved: " + n.Title
+		n.Priority = notify.PriorityDefault
+		n.Tags = []string{"white_check_mark"}
+	}
+	return n
+}
+
+// Silence mutes the alerts of Rule until Until, only those with Labels when set
+type Silence struct {
+	ID      string    `json:"id"`
+	Rule    string    `json:"rule"`
+	Labels  string    `json:"labels,omitempty"`
+	Until   time.Time `json:"until"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+func (s Silence) matches(a Alert, now time.Time) bool {
+	return now.Before(s.Until) && s.Rule == a.Rule && (s.Labels == "" || s.Labels == a.Labels)
+}
+
+// Engine evaluates rules against the app's own metrics, meant for deployments without Alertmanager.
+// Each alert is notified once when it fires and once when it resolves, repeated while it keeps firing
+// after RepeatInterval, and not at all while silenced.
+type Engine struct {
+	rules    []Rule
+	gatherer prometheus.Gatherer
+	notify   func(Alert)
+	wanted   map[string]bool
+
+	// RepeatInterval re-sends alerts that keep firing, zero sends them once
+	RepeatInterval time.Duration
+	Now            func() time.Time
+
+	mu       sync.Mutex
+	history  History
+	active   map[string]*Alert
+	silences []Silence
+}
+
+// NewEngine evaluates rules over gatherer, usually prometheus.DefaultGatherer
+func NewEngine(gatherer prometheus.Gatherer, rules []Rule, notify func(Alert)) *Engine {
+	e := &Engine{
+		rules:          rules,
+		gatherer:       gatherer,
+		notify:         notify,
+		wanted:         make(map[string]bool),
+		RepeatInterval: 4 * time.Hour,
+		Now:            time.Now,
+		active:         make(map[string]*Alert),
+	}
+	for _, rule := range rules {
+		for _, metric := range rule.Metrics() {
+			e.wanted[metric] = true
+		}
+		e.history.retention = max(e.history.retention, rule.Window())
+	}
+	return e
+}
+
+// Evaluate takes a snapshot and runs every rule, meant to be scheduled with tools.Func.
+// Windows are measured between snapshots, so it should run at least every minute.
+func (e *Engine) Evaluate() {
+	now := e.Now()
+	snapshot, err := take(e.gatherer, e.wanted, now)
+	if err != nil {
+		log.Errorf("Failed to gather metrics for alerting: %v", err)
+		return
+	}
+
+	e.mu.Lock()
+	e.history.add(snapshot)
+	notifications := e.evaluate(now)
+	e.mu.Unlock()
+
+	for _, alert := range notifications {
+		e.notify(alert)
+	}
+}
+
+func (e *Engine) evaluate(now time.Time) []Alert {
+	e.silences = slices.DeleteFunc(e.silences, func(s Silence) bool { return !now.Before(s.Until) })
+
+	firing := make(map[string]bool)
+	var notifications []Alert
+	for _, rule := range e.rules {
+		meta := rule.Describe()
+		for _, result := range rule.Evaluate(&e.history) {
+			if !result.Firing {
+				continue
+			}
+
+			candidate := Alert{Rule: meta.Name, Labels: result.Labels, Severity: meta.Severity, Value: result.Value, Summary: result.Summary, Since: now}
+			if result.Severity != "" {
+				candidate.Severity = result.Severity
+			}
+			key := candidate.key()
+			firing[key] = true
+
+			alert, ok := e.active[key]
+			if !ok {
+				alert = &candidate
+				e.active[key] = alert
+			}
+			alert.Value, alert.Summary, alert.Severity = candidate.Value, candidate.Summary, candidate.Severity
+			alert.Silenced = e.silenced(*alert, now)
+
+			due := alert.notified.IsZero() || (e.RepeatInterval > 0 && now.Sub(alert.notified) >= e.RepeatInterval)
+			if due && !alert.Silenced {
+				alert.notified = now
+				notifications = append(notifications, *alert)
+			}
+		}
+	}
+
+	for key, alert := range e.active {
+		if firing[key] {
+			continue
+		}
+		delete(e.active, key)
+		// Nobody heard about alerts that fired while silenced, so their end is not news either
+		if !alert.notified.IsZero() {
+			alert.Resolved = now
+			notifications = append(notifications, *alert)
+		}
+	}
+	return notifications
+}
+
+func (e *Engine) silenced(alert Alert, now time.Time) bool {
+	for _, silence := range e.silences {
+		if silence.matches(alert, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns the alerts firing as of the last evaluation
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.active))
+	for _, alert := range e.active {
+		alerts = append(alerts, *alert)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int { return a.Since.Compare(b.Since) })
+	return alerts
+}
+
+// Silence mutes rule, or only its alerts with labels, for d
+func (e *Engine) Silence(rule string, labels string, d time.Duration, comment string) Silence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	silence := Silence{ID: hex.EncodeToString(id), Rule: rule, Labels: labels, Until: e.Now().Add(d), Comment: comment}
+	e.silences = append(e.silences, silence)
+	return silence
+}
+
+// Silences returns the silences that have not expired yet
+func (e *Engine) Silences() []Silence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.Now()
+	return slices.DeleteFunc(slices.Clone(e.silences), func(s Silence) bool { return !now.Before(s.Until) })
+}
+
+// Expire ends a silence early, alerts still firing are notified on the next evaluation
+func (e *Engine) Expire(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	before := len(e.silences)
+	e.silences = slices.DeleteFunc(e.silences, func(s Silence) bool { return s.ID == id })
+	return len(e.silences) < before
+}
+
+// Rules returns the names of the rules the engine evaluates
+func (e *Engine) Rules() []string {
+	names := make([]string, len(e.rules))
+	for i, rule := range e.rules {
+		names[i] = rule.Describe().Name
+	}
+	return names
+}
@@ -0,0 +1,253 @@
+// engine_test.go
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+// fixture drives an engine over its own registry with a clock advanced by hand
+type fixture struct {
+	engine   *Engine
+	requests *prometheus.CounterVec
+	now      time.Time
+	sent     []Alert
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	f := &fixture{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total"}, []string{"status"}),
+		now:      epoch,
+	}
+	registry.MustRegister(f.requests)
+
+	rule := RatioRule{
+		Meta:      Meta{Name: "HighErrorRate", Severity: SeverityCritical},
+		Errors:    Selector{Metric: "http_requests_total", Where: serverError},
+		Total:     Selector{Metric: "http_requests_total"},
+		Over:      5 * time.Minute,
+		Threshold: 0.05,
+		MinEvents: 20,
+	}
+	f.engine = NewEngine(registry, []Rule{rule}, func(a Alert) { f.sent = append(f.sent, a) })
+	f.engine.RepeatInterval = time.Hour
+	f.engine.Now = func() time.Time { return f.now }
+	return f
+}
+
+// step serves ok and failed requests over the next minute, then evaluates
+func (f *fixture) step(ok, failed int) {
+	f.requests.WithLabelValues("200").Add(float64(ok))
+	f.requests.WithLabelValues("500").Add(float64(failed))
+	f.now = f.now.Add(time.Minute)
+	f.engine.Evaluate()
+}
+
+func TestEngine_FiresOnceAndResolves(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.step(0, 0)
+	f.step(100, 0)
+	assert.Empty(t, f.sent)
+
+	f.step(50, 50)
+	require.Len(t, f.sent, 1)
+	assert.Equal(t, "HighErrorRate", f.sent[0].Rule)
+	assert.Equal(t, SeverityCritical, f.sent[0].Severity)
+	assert.True(t, f.sent[0].Resolved.IsZero())
+	assert.Len(t, f.engine.Active(), 1)
+
+	// Still firing, but it was just notified
+	f.step(50, 50)
+	assert.Len(t, f.sent, 1)
+
+	// Enough clean traffic pushes the errors under the threshold
+	f.step(10000, 0)
+	require.Len(t, f.sent, 2)
+	assert.False(t, f.sent[1].Resolved.IsZero())
+	assert.Equal(t, f.sent[0].Since, f.sent[1].Since)
+	assert.Contains(t, f.sent[1].String(), "[RESOLVED] HighErrorRate")
+	assert.Empty(t, f.engine.Active())
+}
+
+func TestEngine_RepeatsWhileFiring(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.step(0, 0)
+	for range 61 {
+		f.step(50, 50)
+	}
+
+	// Once when it started and once more an hour later
+	assert.Len(t, f.sent, 2)
+}
+
+func TestEngine_Silence(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	silence := f.engine.Silence("HighErrorRate", "", 10*time.Minute, "deploying")
+	assert.Len(t, f.engine.Silences(), 1)
+
+	f.step(0, 0)
+	f.step(50, 50)
+	assert.Empty(t, f.sent)
+	require.Len(t, f.engine.Active(), 1)
+	assert.True(t, f.engine.Active()[0].Silenced)
+
+	// Expiring the silence early notifies what is still firing
+	assert.True(t, f.engine.Expire(silence.ID))
+	assert.False(t, f.engine.Expire(silence.ID))
+	f.step(50, 50)
+	assert.Len(t, f.sent, 1)
+}
+
+func TestEngine_SilencedAlertResolvesQuietly(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.engine.Silence("HighErrorRate", "", time.Hour, "")
+
+	f.step(0, 0)
+	f.step(50, 50)
+	f.step(10000, 0)
+
+	assert.Empty(t, f.sent)
+	assert.Empty(t, f.engine.Active())
+}
+
+func TestEngine_SilenceExpires(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.engine.Silence("HighErrorRate", "", 3*time.Minute, "")
+
+	f.step(0, 0)
+	f.step(50, 50)
+	assert.Empty(t, f.sent)
+
+	f.step(50, 50)
+	assert.Len(t, f.sent, 1)
+	assert.Empty(t, f.engine.Silences())
+}
+
+func TestEngine_NotEnoughTraffic(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.step(0, 0)
+	f.step(5, 5)
+
+	assert.Empty(t, f.sent)
+}
+
+func TestBurnRateRule(t *testing.T) {
+	t.Parallel()
+
+	rule := BurnRateRule{
+		Meta:      Meta{Name: "AvailabilitySLO", Severity: SeverityCritical},
+		Errors:    Selector{Metric: "requests", Where: serverError},
+		Total:     Selector{Metric: "requests"},
+		Objective: 0.99,
+		Windows: []BurnWindow{
+			{Long: time.Hour, Short: 5 * time.Minute, Factor: 10, Severity: SeverityCritical},
+			{Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 2, Severity: SeverityWarning},
+		},
+	}
+
+	ok, failed := Labels{"status": "200"}, Labels{"status": "500"}
+	h := History{retention: rule.Window()}
+	h.add(snapshotOf(0, "requests", Series{Labels: ok, Value: 0}, Series{Labels: failed, Value: 0}))
+	// 3% errors for six hours burns the 1% budget 3 times too fast, a warning but no page
+	h.add(snapshotOf(6*time.Hour-5*time.Minute, "requests", Series{Labels: ok, Value: 9700}, Series{Labels: failed, Value: 300}))
+	h.add(snapshotOf(6*time.Hour, "requests", Series{Labels: ok, Value: 9797}, Series{Labels: failed, Value: 303}))
+
+	results := rule.Evaluate(&h)
+	require.Len(t, results, 2)
+	assert.Equal(t, "1h0m0s", results[0].Labels)
+	assert.False(t, results[0].Firing)
+	assert.Equal(t, "6h0m0s", results[1].Labels)
+	assert.True(t, results[1].Firing)
+	assert.Equal(t, SeverityWarning, results[1].Severity)
+}
+
+func TestQuantileRule(t *testing.T) {
+	t.Parallel()
+
+	bucketsOf := func(path string, fast, slow, total float64) []Series {
+		return []Series{
+			{Labels: Labels{"path": path, "le": "0.1"}, Value: fast},
+			{Labels: Labels{"path": path, "le": "1"}, Value: slow},
+			{Labels: Labels{"path": path, "le": "+Inf"}, Value: total},
+		}
+	}
+	h := History{retention: time.Hour}
+	h.add(snapshotOf(0, "latency_seconds_bucket", append(bucketsOf("/a", 0, 0, 0), bucketsOf("/b,c", 0, 0, 0)...)...))
+	// /a answers everything within 100ms, /b,c needs up to a second for half its requests
+	h.add(snapshotOf(5*time.Minute, "latency_seconds_bucket", append(bucketsOf("/a", 100, 100, 100), bucketsOf("/b,c", 50, 100, 100)...)...))
+
+	tests := []struct {
+		name string
+		by   string
+		want map[string]bool
+	}{
+		{name: "grouped", by: "path", want: map[string]bool{"/a": false, "/b,c": true}},
+		{name: "without by", want: map[string]bool{"": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rule := QuantileRule{
+				Meta:      Meta{Name: "SlowRequests", Severity: SeverityWarning},
+				Histogram: "latency_seconds",
+				Quantile:  0.95,
+				By:        tt.by,
+				Over:      5 * time.Minute,
+				Threshold: 0.5,
+			}
+			got := make(map[string]bool)
+			for _, result := range rule.Evaluate(&h) {
+				got[result.Labels] = result.Firing
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSpikeRule(t *testing.T) {
+	t.Parallel()
+
+	rule := SpikeRule{
+		Meta:     Meta{Name: "WebsocketDisconnectSpike", Severity: SeverityWarning},
+		Counter:  Selector{Metric: "disconnects"},
+		Over:     5 * time.Minute,
+		Baseline: time.Hour,
+		Factor:   3,
+		Min:      20,
+	}
+
+	h := History{retention: rule.Window()}
+	for minute := range 56 {
+		h.add(snapshotOf(time.Duration(minute)*time.Minute, "disconnects", Series{Labels: Labels{}, Value: float64(minute)}))
+	}
+	assert.False(t, rule.Evaluate(&h)[0].Firing)
+
+	// 100 disconnects in five minutes where about five were expected
+	h.add(snapshotOf(60*time.Minute, "disconnects", Series{Labels: Labels{}, Value: 155}))
+	assert.True(t, rule.Evaluate(&h)[0].Firing)
+}
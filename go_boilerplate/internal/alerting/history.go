@@ -0,0 +1,219 @@
+package alerting
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Labels of one series, histogram buckets carry their upper bound as le
+type Labels map[string]string
+
+// Series is one value of a metric family at the time of a snapshot
+type Series struct {
+	Labels Labels
+	Value  float64
+}
+
+// Snapshot holds the families rules asked for, histograms flattened
+// into name_bucket and name_count the way Prometheus exposes them
+type Snapshot struct {
+	At       time.Time
+	Families map[string][]Series
+}
+
+// Selector picks the series of a metric, like 5xx responses out of http_requests_total
+type Selector struct {
+	Metric string
+	// Where filters by labels, nil selects every series
+	Where func(Labels) bool
+}
+
+func (s Selector) matches(labels Labels) bool {
+	return s.Where == nil || s.Where(labels)
+}
+
+// take reads the wanted families from gatherer
+func take(gatherer prometheus.Gatherer, wanted map[string]bool, at time.Time) (Snapshot, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{At: at, Families: make(map[string][]Series)}
+	for _, family := range families {
+		name := family.GetName()
+		if family.GetType() == dto.MetricType_HISTOGRAM {
+			if !wanted[name+"_bucket"] && !wanted[name+"_count"] {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := labelsOf(metric)
+				histogram := metric.GetHistogram()
+				for _, bucket := range histogram.GetBucket() {
+					snapshot.Families[name+"_bucket"] = append(snapshot.Families[name+"_bucket"], Series{
+						Labels: with(labels, "le", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)),
+						Value:  float64(bucket.GetCumulativeCount()),
+					})
+				}
+				count := float64(histogram.GetSampleCount())
+				snapshot.Families[name+"_bucket"] = append(snapshot.Families[name+"_bucket"], Series{Labels: with(labels, "le", "+Inf"), Value: count})
+				snapshot.Families[name+"_count"] = append(snapshot.Families[name+"_count"], Series{Labels: labels, Value: count})
+			}
+			continue
+		}
+
+		if !wanted[name] {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			value := metric.GetCounter().GetValue()
+			if family.GetType() == dto.MetricType_GAUGE {
+				value = metric.GetGauge().GetValue()
+			}
+			snapshot.Families[name] = append(snapshot.Families[name], Series{Labels: labelsOf(metric), Value: value})
+		}
+	}
+	return snapshot, nil
+}
+
+func labelsOf(metric *dto.Metric) Labels {
+	labels := make(Labels, len(metric.GetLabel()))
+	for _, pair := range metric.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+func with(labels Labels, name, value string) Labels {
+	copied := maps.Clone(labels)
+	copied[name] = value
+	return copied
+}
+
+// key identifies a series by all of its labels
+func (l Labels) key() string {
+	names := slices.Sorted(maps.Keys(l))
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "=" + l[name] + ",")
+	}
+	return b.String()
+}
+
+// group joins the values of by, series with the same group are added up
+func (l Labels) group(by []string) string {
+	values := make([]string, len(by))
+	for i, name := range by {
+		values[i] = l[name]
+	}
+	return strings.Join(values, ",")
+}
+
+// History keeps snapshots as far back as the longest rule window
+type History struct {
+	snapshots []Snapshot
+	retention time.Duration
+}
+
+func (h *History) add(snapshot Snapshot) {
+	h.snapshots = append(h.snapshots, snapshot)
+
+	// Keep one snapshot older than the retention, it is the baseline of the longest window
+	cutoff := snapshot.At.Add(-h.retention)
+	drop := 0
+	for drop+1 < len(h.snapshots) && !h.snapshots[drop+1].At.After(cutoff) {
+		drop++
+	}
+	h.snapshots = slices.Delete(h.snapshots, 0, drop)
+}
+
+// Span is how far back the history reaches, windows longer than it are only partially covered
+func (h *History) Span() time.Duration {
+	if len(h.snapshots) < 2 {
+		return 0
+	}
+	return h.snapshots[len(h.snapshots)-1].At.Sub(h.snapshots[0].At)
+}
+
+// Increase adds up how much the selected counters grew over window, grouped by the by labels.
+// Like Prometheus a counter that went down is taken to have restarted from zero.
+func (h *History) Increase(sel Selector, window time.Duration, by ...string) map[string]float64 {
+	if len(h.snapshots) < 2 {
+		return nil
+	}
+	latest := h.snapshots[len(h.snapshots)-1]
+	baseline := h.snapshots[0]
+	for _, snapshot := range h.snapshots {
+		if snapshot.At.After(latest.At.Add(-window)) {
+			break
+		}
+		baseline = snapshot
+	}
+
+	before := make(map[string]float64)
+	for _, series := range baseline.Families[sel.Metric] {
+		before[series.Labels.key()] = series.Value
+	}
+
+	increase := make(map[string]float64)
+	for _, series := range latest.Families[sel.Metric] {
+		if !sel.matches(series.Labels) {
+			continue
+		}
+		delta := series.Value
+		if previous, ok := before[series.Labels.key()]; ok && previous <= series.Value {
+			delta -= previous
+		}
+		increase[series.Labels.group(by)] += delta
+	}
+	return increase
+}
+
+// Total is the increase of the selected counters over window, summed over all series
+func (h *History) Total(sel Selector, window time.Duration) float64 {
+	return h.Increase(sel, window)[""]
+}
+
+type bucket struct {
+	le    float64
+	count float64
+}
+
+// quantile estimates the q-quantile from cumulative bucket counts like histogram_quantile does,
+// interpolating linearly inside the bucket the rank falls into
+func quantile(q float64, buckets []bucket) float64 {
+	if len(buckets) == 0 {
+		return math.NaN()
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+
+	total := buckets[len(buckets)-1].count
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := q * total
+
+	lower, below := 0.0, 0.0
+	for _, b := range buckets {
+		if b.count >= rank {
+			if math.IsInf(b.le, 1) {
+				// Beyond the last finite bucket the best answer is its bound
+				return lower
+			}
+			if b.count == below {
+				return b.le
+			}
+			return lower + (b.le-lower)*(rank-below)/(b.count-below)
+		}
+		lower, below = b.le, b.count
+	}
+	return lower
+}
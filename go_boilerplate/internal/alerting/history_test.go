@@ -0,0 +1,125 @@
+// history_test.go
+package alerting
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func snapshotOf(at time.Duration, metric string, series ...Series) Snapshot {
+	return Snapshot{At: epoch.Add(at), Families: map[string][]Series{metric: series}}
+}
+
+func TestHistory_Increase(t *testing.T) {
+	t.Parallel()
+
+	ok := Labels{"status": "200"}
+	failed := Labels{"status": "500"}
+
+	h := History{retention: time.Hour}
+	h.add(snapshotOf(0, "requests", Series{Labels: ok, Value: 10}))
+	h.add(snapshotOf(5*time.Minute, "requests", Series{Labels: ok, Value: 40}, Series{Labels: failed, Value: 2}))
+	h.add(snapshotOf(10*time.Minute, "requests", Series{Labels: ok, Value: 100}, Series{Labels: failed, Value: 5}))
+
+	tests := []struct {
+		name   string
+		sel    Selector
+		window time.Duration
+		by     []string
+		want   map[string]float64
+	}{
+		{name: "whole history", sel: Selector{Metric: "requests"}, window: time.Hour, want: map[string]float64{"": 95}},
+		{name: "last snapshot only", sel: Selector{Metric: "requests"}, window: 5 * time.Minute, want: map[string]float64{"": 63}},
+		{name: "grouped", sel: Selector{Metric: "requests"}, window: time.Hour, by: []string{"status"}, want: map[string]float64{"200": 90, "500": 5}},
+		{name: "filtered", sel: Selector{Metric: "requests", Where: serverError}, window: time.Hour, want: map[string]float64{"": 5}},
+		{name: "unknown metric", sel: Selector{Metric: "missing"}, window: time.Hour, want: map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, h.Increase(tt.sel, tt.window, tt.by...))
+		})
+	}
+}
+
+func TestHistory_CounterReset(t *testing.T) {
+	t.Parallel()
+
+	h := History{retention: time.Hour}
+	h.add(snapshotOf(0, "requests", Series{Labels: Labels{}, Value: 500}))
+	h.add(snapshotOf(time.Minute, "requests", Series{Labels: Labels{}, Value: 7}))
+
+	// A restarted process counts from zero, so everything it counted since is new
+	assert.Equal(t, 7.0, h.Total(Selector{Metric: "requests"}, time.Hour))
+}
+
+func TestHistory_Retention(t *testing.T) {
+	t.Parallel()
+
+	h := History{retention: 10 * time.Minute}
+	for minute := range 30 {
+		h.add(snapshotOf(time.Duration(minute)*time.Minute, "requests"))
+	}
+
+	assert.Equal(t, 10*time.Minute, h.Span())
+	assert.Len(t, h.snapshots, 11)
+}
+
+func TestTake_FlattensHistograms(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "latency_seconds", Buckets: []float64{0.1, 1}}, []string{"path"})
+	registry.MustRegister(histogram)
+	histogram.WithLabelValues("/").Observe(0.05)
+	histogram.WithLabelValues("/").Observe(0.5)
+	histogram.WithLabelValues("/").Observe(5)
+
+	snapshot, err := take(registry, map[string]bool{"latency_seconds_bucket": true}, epoch)
+	require.NoError(t, err)
+
+	buckets := make(map[string]float64)
+	for _, series := range snapshot.Families["latency_seconds_bucket"] {
+		assert.Equal(t, "/", series.Labels["path"])
+		buckets[series.Labels["le"]] = series.Value
+	}
+	assert.Equal(t, map[string]float64{"0.1": 1, "1": 2, "+Inf": 3}, buckets)
+	assert.Equal(t, 3.0, snapshot.Families["latency_seconds_count"][0].Value)
+}
+
+func TestQuantile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		q       float64
+		buckets []bucket
+		want    float64
+	}{
+		{name: "interpolated", q: 0.5, buckets: []bucket{{le: 1, count: 50}, {le: 2, count: 100}, {le: math.Inf(1), count: 100}}, want: 1},
+		{name: "inside bucket", q: 0.75, buckets: []bucket{{le: 1, count: 50}, {le: 2, count: 100}, {le: math.Inf(1), count: 100}}, want: 1.5},
+		{name: "unsorted", q: 0.25, buckets: []bucket{{le: math.Inf(1), count: 100}, {le: 2, count: 100}, {le: 1, count: 50}}, want: 0.5},
+		{name: "beyond last bound", q: 0.99, buckets: []bucket{{le: 1, count: 10}, {le: math.Inf(1), count: 100}}, want: 1},
+		{name: "no observations", q: 0.5, buckets: []bucket{{le: 1, count: 0}, {le: math.Inf(1), count: 0}}, want: math.NaN()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := quantile(tt.q, tt.buckets)
+			if math.IsNaN(tt.want) {
+				assert.True(t, math.IsNaN(got))
+				return
+			}
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
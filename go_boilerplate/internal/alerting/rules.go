@@ -0,0 +1,282 @@
+package alerting
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Meta names a rule and how urgent its alerts are
+type Meta struct {
+	Name     string
+	Severity Severity
+}
+
+func (m Meta) Describe() Meta {
+	return m
+}
+
+// Result is the state of one alert of a rule, rules evaluating per route return one per route
+type Result struct {
+	// Labels tells alerts of the same rule apart, empty for rules over the whole app
+	Labels  string
+	Firing  bool
+	Value   float64
+	Summary string
+	// Severity overrides the severity of the rule when set
+	Severity Severity
+}
+
+// Rule is evaluated against the metric history on every engine run
+type Rule interface {
+	Describe() Meta
+	// Metrics lists the families to keep, histograms as name_bucket or name_count
+	Metrics() []string
+	// Window is the longest look back the rule needs
+	Window() time.Duration
+	Evaluate(h *History) []Result
+}
+
+// RatioRule fires when the share of Errors in Total over the Over window exceeds Threshold,
+// like 5xx responses out of all responses
+type RatioRule struct {
+	Meta
+	Errors    Selector
+	Total     Selector
+	Over      time.Duration
+	Threshold float64
+	// MinEvents keeps a handful of requests from paging anyone
+	MinEvents float64
+}
+
+func (r RatioRule) Metrics() []string     { return []string{r.Errors.Metric, r.Total.Metric} }
+func (r RatioRule) Window() time.Duration { return r.Over }
+
+func (r RatioRule) Evaluate(h *History) []Result {
+	total := h.Total(r.Total, r.Over)
+	if total < r.MinEvents || total == 0 {
+		return []Result{{Summary: "not enough traffic"}}
+	}
+	ratio := h.Total(r.Errors, r.Over) / total
+	return []Result{{
+		Firing:  ratio > r.Threshold,
+		Value:   ratio,
+		Summary: fmt.Sprintf("%.2f%% of %.0f over %s, threshold %.2f%%", ratio*100, total, r.Over, r.Threshold*100),
+	}}
+}
+
+// QuantileRule fires per By label, like every route whose p95 latency over the Over window exceeds Threshold
+type QuantileRule struct {
+	Meta
+	// Histogram is the family name without the _bucket suffix
+	Histogram string
+	Where     func(Labels) bool
+	Quantile  float64
+	By        string
+	Over      time.Duration
+	Threshold float64
+	MinEvents float64
+}
+
+func (r QuantileRule) Metrics() []string     { return []string{r.Histogram + "_bucket"} }
+func (r QuantileRule) Window() time.Duration { return r.Over }
+
+func (r QuantileRule) Evaluate(h *History) []Result {
+	by := []string{"le"}
+	if r.By != "" {
+		by = []string{r.By, "le"}
+	}
+	increases := h.Increase(Selector{Metric: r.Histogram + "_bucket", Where: r.Where}, r.Over, by...)
+
+	groups := make(map[string][]bucket)
+	for key, count := range increases {
+		// le is the last label of the group and never contains a comma, paths might.
+		// Without By the key is le alone and everything lands in one group
+		group, le := "", key
+		if split := strings.LastIndex(key, ","); split >= 0 {
+			group, le = key[:split], key[split+1:]
+		}
+		bound, err := strconv.ParseFloat(le, 64)
+		if err != nil {
+			continue
+		}
+		groups[group] = append(groups[group], bucket{le: bound, count: count})
+	}
+
+	results := make([]Result, 0, len(groups))
+	for group, buckets := range groups {
+		value := quantile(r.Quantile, buckets)
+		var observed float64
+		for _, b := range buckets {
+			observed = math.Max(observed, b.count)
+		}
+		if observed < r.MinEvents || math.IsNaN(value) {
+			results = append(results, Result{Labels: group, Summary: "not enough traffic"})
+			continue
+		}
+		results = append(results, Result{
+			Labels:  group,
+			Firing:  value > r.Threshold,
+			Value:   value,
+			Summary: fmt.Sprintf("p%.0f of %s is %.3fs over %s, threshold %.3fs", r.Quantile*100, cmp.Or(group, r.Histogram), value, r.Over, r.Threshold),
+		})
+	}
+	return results
+}
+
+// SpikeRule fires when Counter grows Factor times faster over the Over window than over Baseline,
+// like a wave of websocket disconnects after a deploy or network blip
+type SpikeRule struct {
+	Meta
+	Counter  Selector
+	Over     time.Duration
+	Baseline time.Duration
+	Factor   float64
+	// Min is the increase over the Over window needed before anything counts as a spike
+	Min float64
+}
+
+func (r SpikeRule) Metrics() []string     { return []string{r.Counter.Metric} }
+func (r SpikeRule) Window() time.Duration { return r.Baseline }
+
+func (r SpikeRule) Evaluate(h *History) []Result {
+	recent := h.Total(r.Counter, r.Over)
+	// The baseline rate covers what history exists, a fresh process compares against less
+	span := min(r.Baseline, h.Span())
+	if span <= r.Over {
+		return []Result{{Summary: "not enough history"}}
+	}
+	expected := h.Total(r.Counter, span) / span.Seconds() * r.Over.Seconds()
+
+	return []Result{{
+		Firing:  recent >= r.Min && recent > expected*r.Factor,
+		Value:   recent,
+		Summary: fmt.Sprintf("%.0f over %s where %.1f were expected", recent, r.Over, expected),
+	}}
+}
+
+// BurnWindow pages when the error budget burns Factor times faster than sustainable
+// over both Long and Short, the short window resolves the alert quickly once fixed
+type BurnWindow struct {
+	Long     time.Duration
+	Short    time.Duration
+	Factor   float64
+	Severity Severity
+}
+
+// BurnRateRule is a multi-window, multi-burn-rate SLO alert as described in the SRE workbook.
+// Objective is the share of good events, 0.999 leaves an error budget of 0.1%.
+type BurnRateRule struct {
+	Meta
+	Errors    Selector
+	Total     Selector
+	Objective float64
+	Windows   []BurnWindow
+	MinEvents float64
+}
+
+func (r BurnRateRule) Metrics() []string { return []string{r.Errors.Metric, r.Total.Metric} }
+
+func (r BurnRateRule) Window() time.Duration {
+	var longest time.Duration
+	for _, w := range r.Windows {
+		longest = max(longest, w.Long)
+	}
+	return longest
+}
+
+func (r BurnRateRule) burnRate(h *History, window time.Duration) (float64, bool) {
+	total := h.Total(r.Total, window)
+	if total < r.MinEvents || total == 0 {
+		return 0, false
+	}
+	return h.Total(r.Errors, window) / total / (1 - r.Objective), true
+}
+
+// Evaluate returns one result per window pair, labelled with the long window,
+// so a slow burn can warn while a fast one pages
+func (r BurnRateRule) Evaluate(h *History) []Result {
+	results := make([]Result, 0, len(r.Windows))
+	for _, w := range r.Windows {
+		long, enough := r.burnRate(h, w.Long)
+		short, _ := r.burnRate(h, w.Short)
+		results = append(results, Result{
+			Labels:   w.Long.String(),
+			Firing:   enough && long > w.Factor && short > w.Factor,
+			Value:    long,
+			Severity: w.Severity,
+			Summary:  fmt.Sprintf("error budget of %.3g%% burning %.1fx over %s and %.1fx over %s, threshold %.1fx", (1-r.Objective)*100, long, w.Long, short, w.Short, w.Factor),
+		})
+	}
+	return results
+}
+
+func serverError(l Labels) bool {
+	return strings.HasPrefix(l["status"], "5")
+}
+
+// DefaultRules watch the metrics the app exports on its own
+func DefaultRules() []Rule {
+	requests := Selector{Metric: "http_requests_total"}
+	serverErrors := Selector{Metric: "http_requests_total", Where: serverError}
+
+	return []Rule{
+		RatioRule{
+			Meta:      Meta{Name: "HighErrorRate", Severity: SeverityCritical},
+			Errors:    serverErrors,
+			Total:     requests,
+			Over:      5 * time.Minute,
+			Threshold: 0.05,
+			MinEvents: 20,
+		},
+		QuantileRule{
+			Meta:      Meta{Name: "SlowRoute", Severity: SeverityWarning},
+			Histogram: "http_request_duration_seconds",
+			// Websocket connections stay open for their whole life and would always look slow
+			Where:     func(l Labels) bool { return l["path"] != "/ws" },
+			Quantile:  0.95,
+			By:        "path",
+			Over:      10 * time.Minute,
+			Threshold: 1,
+			MinEvents: 20,
+		},
+		RatioRule{
+			Meta:      Meta{Name: "DatabaseErrors", Severity: SeverityCritical},
+			Errors:    Selector{Metric: "db_query_errors_total"},
+			Total:     Selector{Metric: "db_query_duration_seconds_count"},
+			Over:      5 * time.Minute,
+			Threshold: 0.01,
+			MinEvents: 20,
+		},
+		SpikeRule{
+			Meta:     Meta{Name: "WebsocketDisconnectSpike", Severity: SeverityWarning},
+			Counter:  Selector{Metric: "websocket_disconnects_total"},
+			Over:     5 * time.Minute,
+			Baseline: time.Hour,
+			Factor:   3,
+			Min:      20,
+		},
+		BurnRateRule{
+			Meta:      Meta{Name: "AvailabilitySLO", Severity: SeverityCritical},
+			Errors:    serverErrors,
+			Total:     requests,
+			Objective: 0.999,
+			Windows: []BurnWindow{
+				{Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4, Severity: SeverityCritical},
+				{Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 6, Severity: SeverityWarning},
+			},
+			MinEvents: 100,
+		},
+	}
+}
@@ -35,9 +35,7 @@ func SendReturnedGenericJSONError(c echo.Context, err GenericError, r *helpers.R
 	monitoring.RecordError(fmt.Sprintf("%d", err.Code))
 	log.Error(err.Stringify())
 
-	if r != nil {
-		_ = r.Report(helpers.SeverityLevels.ERROR, err.Stringify())
-	}
+	report(c, r, err.Code, err.Stringify())
 
 	return c.JSON(err.Code, models.JSONErrorResponse{Code: err.Code, Message: err.UserMessage, Errors: err.Errors})
 }
@@ -46,11 +44,9 @@ func SendReturnedGenericHTMLError(c echo.Context, err GenericError, r *helpers.R
 	monitoring.RecordError(fmt.Sprintf("%d", err.Code))
 	log.Error(err.Stringify())
 
-	if r != nil {
-		_ = r.Report(helpers.SeverityLevels.ERROR, err.Stringify())
-	}
+	report(c, r, err.Code, err.Stringify())
 
-	html := helpers.MustRenderHTML(views.Error(config.GetDefaultSite(c.Request()), fmt.Sprintf("%d", err.Code), err.UserMessage))
+	html := helpers.MustRenderHTMLContext(c.Request().Context(), views.Error(config.GetDefaultSite(c.Request()), fmt.Sprintf("%d", err.Code), err.UserMessage))
 
 	return c.Blob(err.Code, "text/html", html)
 }
@@ -59,11 +55,22 @@ func SendReturnedHTMLErrorMessage(c echo.Context, err ErrorMessage, r *helpers.R
 	monitoring.RecordError(fmt.Sprintf("%d", err.Error.Code))
 	log.Error(err.Error.Stringify())
 
-	if r != nil {
-		_ = r.Report(helpers.SeverityLevels.ERROR, err.Error.Stringify())
-	}
+	report(c, r, err.Error.Code, err.Error.Stringify())
 
-	html := helpers.MustRenderHTML(components.ErrorMsg(err.Error.UserMessage, err.Box, err.Persistance))
+	html := helpers.MustRenderHTMLContext(c.Request().Context(), components.ErrorMsg(err.Error.UserMessage, err.Box, err.Persistance))
 
 	return c.Blob(err.Error.Code, "text/html", html)
 }
+
+// report writes the error to r, or the default reporter when r is nil. Client errors are
+// only warnings, they say more about the request than about the app.
+func report(c echo.Context, r *helpers.Reporter, code int, message string) {
+	if r == nil {
+		r = helpers.DefaultReporter()
+	}
+	level := helpers.SeverityLevels.ERROR
+	if code < 500 {
+		level = helpers.SeverityLevels.WARN
+	}
+	_ = r.Write(helpers.RequestEntry(c, level, message, code))
+}
@@ -1,14 +1,17 @@
 package apperrors
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
 	"github.com/__username__/go_boilerplate/internal/models"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -70,6 +73,39 @@ func TestSendReturnedGenericJSONError_NoReporter(t *testing.T) {
 	assert.Equal(t, "Something went wrong", resp.Message)
 }
 
+func TestSendReturnedGenericJSONError_Reports(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/users/42", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/:id")
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+	var buf bytes.Buffer
+	r := helpers.NewReporter(helpers.NewWriterSink(&buf))
+
+	assert.NoError(t, SendReturnedGenericJSONError(c, GenericError{Code: 503, Message: "db down"}, r))
+	assert.NoError(t, SendReturnedGenericJSONError(c, GenericError{Code: 422, Message: "bad input"}, r))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var entry helpers.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, helpers.SeverityLevels.ERROR, entry.Level)
+	assert.Equal(t, "[503] db down <-- []", entry.Message)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, "/users/:id", entry.Route)
+	assert.Equal(t, "/users/42", entry.Path)
+	assert.Equal(t, 503, entry.Status)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, helpers.SeverityLevels.WARN, entry.Level)
+}
+
 func TestSendReturnedGenericHTMLError(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// Audited actions, named entity.verb
+const (
+	ActionUserCreated        = "user.created"
+	ActionUserDeleted        = "user.deleted"
+	ActionUserEmailRequested = "user.email_change_requested"
+	ActionUserEmailChanged   = "user.email_changed"
+	ActionUserTwoFactorReset = "user.two_factor_reset"
+)
+
+// Entity types
+const (
+	EntityUser = "user"
+)
+
+// Context is who made a change and from where
+type Context struct {
+	ActorID   string
+	Actor     string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// System is the context of changes made by the app itself, like scheduled jobs
+var System = Context{Actor: "system"}
+
+// FromRequest reads the signed in user, client address and request ID of c, anonymous requests have no actor
+func FromRequest(c echo.Context) Context {
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	ac := Context{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		RequestID: requestID,
+	}
+	if session, ok := auth.CurrentSession(c); ok {
+		ac.ActorID, ac.Actor = session.UserID.String(), session.Username
+	}
+	return ac
+}
+
+// Change is one audited mutation. Before and After are anything that marshals to a JSON object,
+// nil for a creation or a deletion respectively.
+type Change struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     any
+	After      any
+}
+
+// FieldDiff is the value of a field before and after a change, null when the field did not exist
+type FieldDiff struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Diff returns the fields that differ between before and after, keyed by their JSON name
+func Diff(before, after any) (map[string]FieldDiff, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]FieldDiff)
+	for name, value := range from {
+		if other, ok := to[name]; !ok || !reflect.DeepEqual(value, other) {
+			diff[name] = FieldDiff{From: value, To: to[name]}
+		}
+	}
+	for name, value := range to {
+		if _, ok := from[name]; !ok {
+			diff[name] = FieldDiff{To: value}
+		}
+	}
+	return diff, nil
+}
+
+func fields(value any) (map[string]any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, fmt.Errorf("audited values must be JSON objects: %w", err)
+	}
+	return decoded, nil
+}
+
+// Record appends change to the audit log. Pass a repository bound to the caller's transaction, so the
+// entry exists exactly when the change commits. Writers queue on a transaction lock until then.
+func Record(ctx context.Context, repo *repository.Queries, ac Context, change Change) error {
+	diff, err := Diff(change.Before, change.After)
+	if err != nil {
+		return err
+	}
+	// Keys are sorted, so the bytes and their hash are stable
+	encoded, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.LockAuditLog(ctx); err != nil {
+		return err
+	}
+	prev, err := repo.GetLastAuditHash(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	entry := repository.AuditLog{
+		ID: uuid.New(),
+		// Postgres keeps microseconds, the hash must cover what is read back
+		Occurred:   time.Now().UTC().Truncate(time.Microsecond),
+		ActorID:    ac.ActorID,
+		Actor:      ac.Actor,
+		Action:     change.Action,
+		EntityType: change.EntityType,
+		EntityID:   change.EntityID,
+		Diff:       encoded,
+		Ip:         ac.IP,
+		UserAgent:  ac.UserAgent,
+		RequestID:  ac.RequestID,
+		PrevHash:   prev,
+	}
+	entry.Hash = Hash(entry)
+
+	return repo.InsertAuditEntry(ctx, repository.InsertAuditEntryParams{
+		ID:         entry.ID,
+		Occurred:   entry.Occurred,
+		ActorID:    entry.ActorID,
+		Actor:      entry.Actor,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Diff:       entry.Diff,
+		Ip:         entry.Ip,
+		UserAgent:  entry.UserAgent,
+		RequestID:  entry.RequestID,
+		PrevHash:   entry.PrevHash,
+		Hash:       entry.Hash,
+	})
+}
+
+// Hash is the SHA-256 of the previous hash and every field of entry, each prefixed with its length
+func Hash(entry repository.AuditLog) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{
+		entry.PrevHash,
+		[]byte(entry.ID.String()),
+		[]byte(entry.Occurred.UTC().Format(time.RFC3339Nano)),
+		[]byte(entry.ActorID),
+		[]byte(entry.Actor),
+		[]byte(entry.Action),
+		[]byte(entry.EntityType),
+		[]byte(entry.EntityID),
+		entry.Diff,
+		[]byte(entry.Ip),
+		[]byte(entry.UserAgent),
+		[]byte(entry.RequestID),
+	} {
+		_ = binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write(field)
+	}
+	return h.Sum(nil)
+}
+
+// Verification is the outcome of Verify, Broken is the first entry whose hash or link does not hold
+type Verification struct {
+	Checked int
+	Broken  *repository.AuditLog
+}
+
+// Verify walks the chain from the oldest entry kept. That entry's link is taken as given, since
+// retention removed what it pointed to.
+func Verify(ctx context.Context, repo *repository.Queries) (Verification, error) {
+	var result Verification
+	var prev []byte
+	after := int64(0)
+	for {
+		entries, err := repo.ListAuditChain(ctx, repository.ListAuditChainParams{Seq: after, Limit: 500})
+		if err != nil {
+			return result, err
+		}
+		for _, entry := range entries {
+			if (result.Checked > 0 && !bytes.Equal(entry.PrevHash, prev)) || !bytes.Equal(Hash(entry), entry.Hash) {
+				result.Broken = &entry
+				return result, nil
+			}
+			result.Checked++
+			prev, after = entry.Hash, entry.Seq
+		}
+		if len(entries) < 500 {
+			return result, nil
+		}
+	}
+}
+
+// Prune deletes every entry up to the last one that occurred before before. It cuts by sequence,
+// so only the oldest entries go and the chain that remains still verifies.
+func Prune(ctx context.Context, db database.Conn, before time.Time) (removed int64, err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer database.HandleTransaction(ctx, tx, &err)
+
+	repo := repository.New(tx)
+	if err = repo.EnableAuditRetention(ctx); err != nil {
+		return 0, err
+	}
+	return repo.DeleteAuditEntries(ctx, before)
+}
+
+// Retention returns a job pruning entries older than keep, meant to be scheduled with tools.Func
+func Retention(db database.Conn, keep time.Duration) func() {
+	return func() {
+		removed, err := Prune(context.Background(), db, time.Now().UTC().Add(-keep))
+		if err != nil {
+			log.Errorf("Failed to prune the audit log: %v", err)
+			return
+		}
+		log.Debugf("Removed %d audit log entries", removed)
+	}
+}
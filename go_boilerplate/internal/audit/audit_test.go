@@ -0,0 +1,245 @@
+// audit_test.go
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() { log.SetLevel(log.OFF) }
+
+// capture is a pgxmock argument matcher that keeps what it was matched against
+type capture struct{ value *any }
+
+func (c capture) Match(v any) bool {
+	*c.value = v
+	return true
+}
+
+var auditColumns = []string{"seq", "id", "occurred", "actor_id", "actor", "action", "entity_type", "entity_id", "diff", "ip", "user_agent", "request_id", "prev_hash", "hash"}
+
+// chain links n entries the way Record does
+func chain(n int) []repository.AuditLog {
+	var entries []repository.AuditLog
+	prev := []byte("pruned")
+	for i := range n {
+		entry := repository.AuditLog{
+			Seq:        int64(i + 1),
+			ID:         uuid.New(),
+			Occurred:   time.Date(2026, 1, 1, 0, i, 0, 123000, time.UTC),
+			Actor:      "admin",
+			Action:     ActionUserCreated,
+			EntityType: EntityUser,
+			EntityID:   uuid.NewString(),
+			Diff:       []byte(`{"email":{"from":null,"to":"a@x.com"}}`),
+			PrevHash:   prev,
+		}
+		entry.Hash = Hash(entry)
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func chainRows(entries []repository.AuditLog) *pgxmock.Rows {
+	rows := pgxmock.NewRows(auditColumns)
+	for _, e := range entries {
+		rows.AddRow(e.Seq, e.ID, e.Occurred, e.ActorID, e.Actor, e.Action, e.EntityType, e.EntityID, e.Diff, e.Ip, e.UserAgent, e.RequestID, e.PrevHash, e.Hash)
+	}
+	return rows
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   map[string]FieldDiff
+	}{
+		{"created", nil, user{"bob", "b@x.com"}, map[string]FieldDiff{"name": {To: "bob"}, "email": {To: "b@x.com"}}},
+		{"deleted", user{"bob", "b@x.com"}, nil, map[string]FieldDiff{"name": {From: "bob"}, "email": {From: "b@x.com"}}},
+		{"changed field only", user{"bob", "b@x.com"}, user{"bob", "b@x.dev"}, map[string]FieldDiff{"email": {From: "b@x.com", To: "b@x.dev"}}},
+		{"field added", map[string]string{"email": "b@x.com"}, map[string]string{"email": "b@x.com", "pending_email": "n@x.com"}, map[string]FieldDiff{"pending_email": {To: "n@x.com"}}},
+		{"unchanged", user{"bob", "b@x.com"}, user{"bob", "b@x.com"}, map[string]FieldDiff{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			diff, err := Diff(tt.before, tt.after)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, diff)
+		})
+	}
+
+	_, err := Diff(nil, []string{"not", "an", "object"})
+	assert.Error(t, err)
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		last []byte
+	}{
+		{"chains onto the last entry", []byte("previous hash")},
+		{"first entry", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			if tt.last != nil {
+				mock.ExpectQuery("SELECT hash FROM audit_log").WillReturnRows(pgxmock.NewRows([]string{"hash"}).AddRow(tt.last))
+			} else {
+				mock.ExpectQuery("SELECT hash FROM audit_log").WillReturnError(pgx.ErrNoRows)
+			}
+			args := make([]any, 13)
+			matchers := make([]any, 13)
+			for i := range args {
+				matchers[i] = capture{&args[i]}
+			}
+			mock.ExpectExec("INSERT INTO audit_log").WithArgs(matchers...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+			ac := Context{ActorID: "42", Actor: "alice", IP: "203.0.113.7", UserAgent: "curl/8", RequestID: "req-1"}
+			err = Record(context.Background(), repository.New(mock), ac, Change{
+				Action:     ActionUserDeleted,
+				EntityType: EntityUser,
+				EntityID:   "7",
+				Before:     map[string]string{"username": "bob"},
+			})
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			entry := repository.AuditLog{
+				ID:         args[0].(uuid.UUID),
+				Occurred:   args[1].(time.Time),
+				ActorID:    args[2].(string),
+				Actor:      args[3].(string),
+				Action:     args[4].(string),
+				EntityType: args[5].(string),
+				EntityID:   args[6].(string),
+				Diff:       args[7].([]byte),
+				Ip:         args[8].(string),
+				UserAgent:  args[9].(string),
+				RequestID:  args[10].(string),
+				PrevHash:   args[11].([]byte),
+			}
+			assert.Equal(t, tt.last, entry.PrevHash)
+			assert.Equal(t, Hash(entry), args[12])
+			assert.Equal(t, "alice", entry.Actor)
+			assert.Equal(t, "req-1", entry.RequestID)
+			assert.Equal(t, entry.Occurred.Truncate(time.Microsecond), entry.Occurred, "stored as Postgres reads it back")
+
+			var diff map[string]FieldDiff
+			require.NoError(t, json.Unmarshal(entry.Diff, &diff))
+			assert.Equal(t, map[string]FieldDiff{"username": {From: "bob"}}, diff)
+		})
+	}
+}
+
+func TestHash_CoversEveryField(t *testing.T) {
+	t.Parallel()
+
+	entry := chain(1)[0]
+	original := Hash(entry)
+
+	for name, tamper := range map[string]func(e *repository.AuditLog){
+		"actor":     func(e *repository.AuditLog) { e.Actor = "mallory" },
+		"diff":      func(e *repository.AuditLog) { e.Diff = []byte(`{}`) },
+		"occurred":  func(e *repository.AuditLog) { e.Occurred = e.Occurred.Add(time.Microsecond) },
+		"prev hash": func(e *repository.AuditLog) { e.PrevHash = nil },
+		// Length prefixes keep fields from shifting into each other
+		"boundary": func(e *repository.AuditLog) { e.EntityType, e.EntityID = e.EntityType+e.EntityID[:1], e.EntityID[1:] },
+	} {
+		tampered := entry
+		tamper(&tampered)
+		assert.NotEqual(t, original, Hash(tampered), name)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tamper  func(entries []repository.AuditLog)
+		checked int
+		broken  int64
+	}{
+		{"intact", func([]repository.AuditLog) {}, 3, 0},
+		{"edited row", func(entries []repository.AuditLog) { entries[1].Diff = []byte(`{}`) }, 1, 2},
+		{"removed row", func(entries []repository.AuditLog) { entries[1] = entries[2] }, 1, 3},
+		{"rehashed row", func(entries []repository.AuditLog) {
+			entries[1].Actor = "mallory"
+			entries[1].Hash = Hash(entries[1])
+		}, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			entries := chain(3)
+			tt.tamper(entries)
+			mock.ExpectQuery("FROM audit_log").WithArgs(int64(0), int32(500)).WillReturnRows(chainRows(entries))
+
+			result, err := Verify(context.Background(), repository.New(mock))
+			require.NoError(t, err)
+			assert.Equal(t, tt.checked, result.Checked)
+			if tt.broken == 0 {
+				assert.Nil(t, result.Broken)
+			} else {
+				require.NotNil(t, result.Broken)
+				assert.Equal(t, tt.broken, result.Broken.Seq)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config\\('audit.retention'").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("DELETE FROM audit_log\\s+WHERE seq <=").WithArgs(before).WillReturnResult(pgxmock.NewResult("DELETE", 4))
+	mock.ExpectCommit()
+
+	removed, err := Prune(context.Background(), mock, before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), removed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+)
+
+var ErrMalformedCiphertext = errors.New("malformed ciphertext")
+
+// NewToken returns a random URL-safe token with 256 bits of entropy
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token.
+// Only hashes are persisted, so a database leak does not leak live credentials.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns an HMAC-SHA256 of value under SECRET_KEY, bound to purpose so a
+// signature minted for one use cannot be replayed for another
+func Sign(purpose string, value string) string {
+	mac := hmac.New(sha256.New, []byte(boot.Environment.SecretKey))
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports in constant time whether signature was produced by Sign
+func VerifySignature(purpose string, value string, signature string) bool {
+	return hmac.Equal([]byte(Sign(purpose, value)), []byte(signature))
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(boot.Environment.SecretKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext with AES-256-GCM under SECRET_KEY, prefixing the nonce
+func Seal(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a value produced by Seal
+func Open(sealed []byte) ([]byte, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
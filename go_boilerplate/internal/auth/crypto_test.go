@@ -0,0 +1,101 @@
+// crypto_test.go
+package auth
+
+import (
+	"testing"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+	boot.Environment.SecretKey = "test-secret-key-that-is-long-enough-0123"
+}
+
+func TestSealOpen_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte("totp shared secret")
+
+	sealed, err := Seal(plaintext)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), string(plaintext))
+
+	opened, err := Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestSeal_UsesFreshNonce(t *testing.T) {
+	t.Parallel()
+
+	a, err := Seal([]byte("same"))
+	require.NoError(t, err)
+	b, err := Seal([]byte("same"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+}
+
+func TestOpen_RejectsTamperedData(t *testing.T) {
+	t.Parallel()
+
+	sealed, err := Seal([]byte("secret"))
+	require.NoError(t, err)
+
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestOpen_RejectsShortInput(t *testing.T) {
+	t.Parallel()
+
+	_, err := Open([]byte("short"))
+	assert.ErrorIs(t, err, ErrMalformedCiphertext)
+}
+
+func TestNewToken_IsUniqueAndURLSafe(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]struct{})
+	for range 1000 {
+		token, err := NewToken()
+		require.NoError(t, err)
+		assert.Len(t, token, 43)
+		assert.NotContains(t, token, "+")
+		assert.NotContains(t, token, "/")
+
+		_, dup := seen[token]
+		require.False(t, dup)
+		seen[token] = struct{}{}
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, HashToken("abc"), HashToken("abc"))
+	assert.NotEqual(t, HashToken("abc"), HashToken("abd"))
+	assert.Len(t, HashToken("abc"), 64)
+}
+
+func TestHashPassword(t *testing.T) {
+	t.Parallel()
+
+	_, err := HashPassword("short")
+	assert.ErrorIs(t, err, ErrPasswordTooShort)
+
+	_, err = HashPassword(string(make([]byte, MaxPasswordLength+1)))
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+
+	hash, err := HashPassword("correct horse battery")
+	require.NoError(t, err)
+
+	assert.True(t, CheckPassword(hash, "correct horse battery"))
+	assert.False(t, CheckPassword(hash, "wrong horse battery"))
+	assert.False(t, CheckPassword("", "correct horse battery"))
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	TrustedDeviceCookie   = "trusted_device"
+	TrustedDeviceLifetime = 30 * 24 * time.Hour
+)
+
+// TrustDevice remembers this browser so the next logins of userID skip the 2FA challenge
+func TrustDevice(c echo.Context, repo *repository.Queries, userID uuid.UUID) error {
+	token, err := NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = repo.CreateTrustedDevice(c.Request().Context(), repository.CreateTrustedDeviceParams{
+		ID:        HashToken(token),
+		UserID:    userID,
+		UserAgent: c.Request().UserAgent(),
+		Expires:   time.Now().Add(TrustedDeviceLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	setCookie(c, TrustedDeviceCookie, token, int(TrustedDeviceLifetime.Seconds()))
+	return nil
+}
+
+// IsDeviceTrusted reports whether the request carries a live trusted device cookie for userID
+func IsDeviceTrusted(c echo.Context, repo *repository.Queries, userID uuid.UUID) bool {
+	cookie, err := c.Cookie(TrustedDeviceCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	trusted, err := repo.IsTrustedDevice(c.Request().Context(), repository.IsTrustedDeviceParams{
+		ID:     HashToken(cookie.Value),
+		UserID: userID,
+	})
+	if err != nil {
+		log.Errorf("Failed to check trusted device: %v", err)
+		return false
+	}
+
+	return trusted
+}
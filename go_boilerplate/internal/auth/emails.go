@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/mail"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/views/emails"
+	"github.com/a-h/templ"
+)
+
+// SendMagicLink queues a one click sign in link to email. Pass a repo bound to the
+// transaction issuing the token so neither exists without the other.
+func SendMagicLink(ctx context.Context, repo *repository.Queries, email string, token string) error {
+	link := fmt.Sprintf("%s/login/magic/%s", boot.Environment.URL, token)
+	return enqueue(ctx, repo, email, "Your sign in link", emails.MagicLink(link, humanize(PurposeLogin.Lifetime())))
+}
+
+// SendEmailVerification queues the link confirming that the account owner controls email
+func SendEmailVerification(ctx context.Context, repo *repository.Queries, email string, token string) error {
+	link := fmt.Sprintf("%s/account/verify/%s", boot.Environment.URL, token)
+	return enqueue(ctx, repo, email, "Verify your email address", emails.VerifyEmail(link, humanize(PurposeVerifyEmail.Lifetime())))
+}
+
+// SendEmailChange queues the link that applies a pending email change to the new address
+func SendEmailChange(ctx context.Context, repo *repository.Queries, email string, token string) error {
+	link := fmt.Sprintf("%s/account/email/confirm/%s", boot.Environment.URL, token)
+	return enqueue(ctx, repo, email, "Confirm your new email address", emails.ChangeEmail(link, humanize(PurposeChangeEmail.Lifetime())))
+}
+
+func enqueue(ctx context.Context, repo *repository.Queries, email string, subject string, body templ.Component) error {
+	msg, err := mail.Render(ctx, subject, body)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{email}
+	_, err = mail.Enqueue(ctx, repo, msg)
+	return err
+}
+
+// humanize spells a token lifetime the way it reads in a sentence, "15 minutes" rather than "15m0s"
+func humanize(d time.Duration) string {
+	unit, count := "minute", int(d/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, count = "hour", int(d/time.Hour)
+	}
+	if count == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
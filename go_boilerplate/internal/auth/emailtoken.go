@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+)
+
+type TokenPurpose string
+
+const (
+	PurposeLogin       TokenPurpose = "login"
+	PurposeVerifyEmail TokenPurpose = "verify_email"
+	PurposeChangeEmail TokenPurpose = "change_email"
+
+	// At most EmailTokenLimit tokens are mailed to one address per EmailTokenWindow
+	EmailTokenLimit  = 3
+	EmailTokenWindow = 15 * time.Minute
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrRateLimited  = errors.New("too many emails requested for this address")
+)
+
+// Lifetime is how long a token of this purpose stays usable once mailed
+func (p TokenPurpose) Lifetime() time.Duration {
+	switch p {
+	case PurposeLogin:
+		return 15 * time.Minute
+	case PurposeChangeEmail:
+		return time.Hour
+	default:
+		return 48 * time.Hour
+	}
+}
+
+// IssueEmailToken creates a signed single use token for userID that proves control of email.
+// Earlier unused tokens with the same purpose stop working, so only the latest link is valid.
+func IssueEmailToken(ctx context.Context, repo *repository.Queries, userID uuid.UUID, email string, purpose TokenPurpose) (string, error) {
+	recent, err := repo.CountRecentEmailTokens(ctx, repository.CountRecentEmailTokensParams{
+		Email:   email,
+		Created: time.Now().Add(-EmailTokenWindow),
+	})
+	if err != nil {
+		return "", err
+	}
+	if recent >= EmailTokenLimit {
+		return "", ErrRateLimited
+	}
+
+	// Superseded tokens are marked used rather than deleted, so they still count towards the limit
+	if err := repo.RevokeUnusedEmailTokens(ctx, repository.RevokeUnusedEmailTokensParams{
+		UserID:  userID,
+		Purpose: string(purpose),
+	}); err != nil {
+		return "", err
+	}
+
+	raw, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = repo.CreateEmailToken(ctx, repository.CreateEmailTokenParams{
+		ID:      HashToken(raw),
+		UserID:  userID,
+		Purpose: string(purpose),
+		Email:   email,
+		Expires: time.Now().Add(purpose.Lifetime()),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return raw + "." + Sign(string(purpose), raw), nil
+}
+
+// ConsumeEmailToken burns token and returns the user and address it was issued for.
+// Forged, reused, expired and wrong purpose tokens all fail with ErrInvalidToken.
+func ConsumeEmailToken(ctx context.Context, repo *repository.Queries, token string, purpose TokenPurpose) (repository.ConsumeEmailTokenRow, error) {
+	raw, signature, ok := strings.Cut(token, ".")
+	if !ok || !VerifySignature(string(purpose), raw, signature) {
+		return repository.ConsumeEmailTokenRow{}, ErrInvalidToken
+	}
+
+	row, err := repo.ConsumeEmailToken(ctx, repository.ConsumeEmailTokenParams{
+		ID:      HashToken(raw),
+		Purpose: string(purpose),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repository.ConsumeEmailTokenRow{}, ErrInvalidToken
+	}
+	return row, err
+}
+
+// CleanupEmailTokens removes expired email tokens, meant to be scheduled with tools.Func
+func CleanupEmailTokens() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteExpiredEmailTokens(context.Background())
+	if err != nil {
+		log.Errorf("Failed to clean up email tokens: %v", err)
+		return
+	}
+	log.Debugf("Removed %d expired email tokens", removed)
+}
@@ -0,0 +1,134 @@
+// emailtoken_test.go
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueEmailToken(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+
+	mock.ExpectQuery("SELECT COUNT").WithArgs("a@x.com", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectExec("UPDATE email_tokens").WithArgs(userID, string(PurposeLogin)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO email_tokens").
+		WithArgs(pgxmock.AnyArg(), userID, string(PurposeLogin), "a@x.com", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	token, err := IssueEmailToken(context.Background(), repository.New(mock), userID, "a@x.com", PurposeLogin)
+	require.NoError(t, err)
+
+	raw, signature, ok := strings.Cut(token, ".")
+	require.True(t, ok)
+	assert.True(t, VerifySignature(string(PurposeLogin), raw, signature))
+	assert.False(t, VerifySignature(string(PurposeChangeEmail), raw, signature))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIssueEmailToken_RateLimited(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	repo := repository.New(mock)
+
+	// Each token supersedes the one before, which stays in the table and keeps counting
+	for issued := range EmailTokenLimit {
+		mock.ExpectQuery("SELECT COUNT").WithArgs("a@x.com", pgxmock.AnyArg()).
+			WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(issued)))
+		mock.ExpectExec("UPDATE email_tokens").WithArgs(userID, string(PurposeVerifyEmail)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", int64(min(issued, 1))))
+		mock.ExpectExec("INSERT INTO email_tokens").
+			WithArgs(pgxmock.AnyArg(), userID, string(PurposeVerifyEmail), "a@x.com", pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		_, err := IssueEmailToken(context.Background(), repo, userID, "a@x.com", PurposeVerifyEmail)
+		require.NoError(t, err, "token %d", issued+1)
+	}
+
+	mock.ExpectQuery("SELECT COUNT").WithArgs("a@x.com", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(EmailTokenLimit)))
+
+	_, err = IssueEmailToken(context.Background(), repo, userID, "a@x.com", PurposeVerifyEmail)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConsumeEmailToken(t *testing.T) {
+	t.Parallel()
+
+	raw, err := NewToken()
+	require.NoError(t, err)
+	valid := raw + "." + Sign(string(PurposeLogin), raw)
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose TokenPurpose
+		rows    *pgxmock.Rows // nil when the signature check must fail before any query
+		wantErr error
+	}{
+		{
+			name:    "valid token",
+			token:   valid,
+			purpose: PurposeLogin,
+			rows:    pgxmock.NewRows([]string{"user_id", "email"}).AddRow(userID, "a@x.com"),
+		},
+		{
+			name:    "used or expired token",
+			token:   valid,
+			purpose: PurposeLogin,
+			rows:    pgxmock.NewRows([]string{"user_id", "email"}),
+			wantErr: ErrInvalidToken,
+		},
+		{name: "wrong purpose", token: valid, purpose: PurposeChangeEmail, wantErr: ErrInvalidToken},
+		{name: "forged signature", token: raw + ".forged", purpose: PurposeLogin, wantErr: ErrInvalidToken},
+		{name: "unsigned", token: raw, purpose: PurposeLogin, wantErr: ErrInvalidToken},
+		{name: "empty", token: "", purpose: PurposeLogin, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			if tt.rows != nil {
+				mock.ExpectQuery("UPDATE email_tokens").WithArgs(HashToken(raw), string(tt.purpose)).WillReturnRows(tt.rows)
+			}
+
+			row, err := ConsumeEmailToken(context.Background(), repository.New(mock), tt.token, tt.purpose)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.NotErrorIs(t, err, pgx.ErrNoRows)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, userID, row.UserID)
+				assert.Equal(t, "a@x.com", row.Email)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+// RequireUser lets through fully authenticated sessions only.
+// Sessions still waiting on their second factor are sent to the challenge.
+func RequireUser() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, ok := CurrentSession(c)
+			if !ok {
+				return unauthenticated(c, "/login")
+			}
+			if !session.Authenticated() {
+				return unauthenticated(c, "/login/2fa")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireAdmin lets through authenticated admins that have two-factor authentication enabled.
+// Admins without it are sent to enrollment before they can reach any admin page.
+func RequireAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return RequireUser()(func(c echo.Context) error {
+			session, _ := CurrentSession(c)
+			if !session.IsAdmin {
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: "Non admin user " + session.Username + " attempted to reach " + c.Path(), UserMessage: "Resource is not accessible"}, nil)
+			}
+			if !session.TwoFactor {
+				return helpers.Redirect(c, "/account/2fa")
+			}
+
+			return next(c)
+		})
+	}
+}
+
+func unauthenticated(c echo.Context, location string) error {
+	if strings.Contains(c.Request().Header.Get("Accept"), "application/json") {
+		return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusUnauthorized, Message: "Unauthenticated request to " + c.Path(), UserMessage: "Authentication required"}, nil)
+	}
+	return helpers.Redirect(c, location)
+}
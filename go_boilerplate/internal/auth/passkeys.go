@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	CeremonyCookie   = "webauthn_ceremony"
+	CeremonyLifetime = 5 * time.Minute
+	MaxPasskeyName   = 64
+
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var (
+	ErrNoCeremony      = errors.New("no pending passkey ceremony")
+	ErrUnknownPasskey  = errors.New("unknown passkey")
+	ErrPasskeyReplayed = errors.New("passkey sign count did not increase, the authenticator may be cloned")
+)
+
+// PasskeyUser adapts an account and its stored passkeys to webauthn.User.
+// The user handle is the account UUID, which is opaque and carries no personal data.
+type PasskeyUser struct {
+	ID          uuid.UUID
+	Name        string
+	Credentials []webauthn.Credential
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return u.ID[:]
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	return u.Name
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	return u.Name
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.Credentials
+}
+
+// NewRelyingParty configures WebAuthn for rpID, accepting ceremonies from origins.
+// Passkeys must be discoverable and verify the user, so one passkey replaces both factors.
+func NewRelyingParty(rpID string, origins []string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: Issuer,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: CeremonyLifetime, TimeoutUVD: CeremonyLifetime},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: CeremonyLifetime, TimeoutUVD: CeremonyLifetime},
+		},
+	})
+}
+
+var relyingParty = sync.OnceValues(func() (*webauthn.WebAuthn, error) {
+	origins := []string{boot.Environment.URL}
+	// Browsers only expose WebAuthn to secure contexts, which in development means http://localhost
+	if boot.Environment.GoEnv == enums.Environments.DEVELOPMENT {
+		origins = append(origins, "http://localhost:"+boot.Environment.Port)
+	}
+	return NewRelyingParty(boot.Environment.Host, origins)
+})
+
+// RelyingParty returns the application's WebAuthn configuration, built from HOST and URL on first use
+func RelyingParty() (*webauthn.WebAuthn, error) {
+	return relyingParty()
+}
+
+// LoadPasskeyUser returns userID with every passkey registered to it
+func LoadPasskeyUser(ctx context.Context, repo *repository.Queries, userID uuid.UUID, name string) (*PasskeyUser, error) {
+	rows, err := repo.ListUserPasskeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &PasskeyUser{ID: userID, Name: name, Credentials: make([]webauthn.Credential, 0, len(rows))}
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(row.Credential, &credential); err != nil {
+			return nil, err
+		}
+		user.Credentials = append(user.Credentials, credential)
+	}
+	return user, nil
+}
+
+// BeginPasskeyRegistration starts enrolling a new passkey for the signed in user.
+// The returned options are handed to navigator.credentials.create as they are.
+func BeginPasskeyRegistration(c echo.Context, wa *webauthn.WebAuthn, repo *repository.Queries, session *Session) (*protocol.CredentialCreation, error) {
+	user, err := LoadPasskeyUser(c.Request().Context(), repo, session.UserID, session.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Excluding known credentials stops an authenticator from registering twice
+	creation, data, err := wa.BeginRegistration(user, webauthn.WithExclusions(webauthn.Credentials(user.Credentials).CredentialDescriptors()))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveCeremony(c, repo, ceremonyRegistration, data); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// FinishPasskeyRegistration verifies the attestation posted by the browser and stores the new passkey under name
+func FinishPasskeyRegistration(c echo.Context, wa *webauthn.WebAuthn, repo *repository.Queries, session *Session, name string) error {
+	ctx := c.Request().Context()
+
+	data, err := takeCeremony(c, repo, ceremonyRegistration)
+	if err != nil {
+		return err
+	}
+
+	user, err := LoadPasskeyUser(ctx, repo, session.UserID, session.Username)
+	if err != nil {
+		return err
+	}
+
+	credential, err := wa.FinishRegistration(user, data, c.Request())
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	return repo.CreatePasskey(ctx, repository.CreatePasskeyParams{
+		ID:           uuid.New(),
+		UserID:       session.UserID,
+		CredentialID: credential.ID,
+		Name:         passkeyName(name),
+		Credential:   encoded,
+		SignCount:    int64(credential.Authenticator.SignCount),
+	})
+}
+
+// passkeyName trims name to MaxPasskeyName characters, the column counts characters and refuses
+// invalid UTF-8, so a cut must not split one
+func passkeyName(name string) string {
+	name = strings.TrimSpace(strings.ToValidUTF8(name, ""))
+	if name == "" {
+		return "Passkey"
+	}
+	if runes := []rune(name); len(runes) > MaxPasskeyName {
+		name = string(runes[:MaxPasskeyName])
+	}
+	return name
+}
+
+// BeginPasskeyLogin starts a usernameless login, the browser offers every passkey it holds for this site
+func BeginPasskeyLogin(c echo.Context, wa *webauthn.WebAuthn, repo *repository.Queries) (*protocol.CredentialAssertion, error) {
+	assertion, data, err := wa.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveCeremony(c, repo, ceremonyLogin, data); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// FinishPasskeyLogin verifies the assertion posted by the browser and returns the user it belongs to.
+// An assertion whose sign count does not move forward is refused as a possible cloned authenticator.
+func FinishPasskeyLogin(c echo.Context, wa *webauthn.WebAuthn, repo *repository.Queries) (uuid.UUID, error) {
+	ctx := c.Request().Context()
+
+	data, err := takeCeremony(c, repo, ceremonyLogin)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	handler := func(rawID []byte, userHandle []byte) (webauthn.User, error) {
+		owner, err := repo.GetPasskeyOwner(ctx, rawID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrUnknownPasskey
+			}
+			return nil, err
+		}
+		if !bytes.Equal(owner[:], userHandle) {
+			return nil, ErrUnknownPasskey
+		}
+		return LoadPasskeyUser(ctx, repo, owner, "")
+	}
+
+	user, credential, err := wa.FinishPasskeyLogin(handler, data, c.Request())
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return uuid.Nil, ErrPasskeyReplayed
+	}
+
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// The conditional update keeps two racing logins with the same counter from both succeeding
+	updated, err := repo.UpdatePasskeyUsage(ctx, repository.UpdatePasskeyUsageParams{
+		CredentialID: credential.ID,
+		Credential:   encoded,
+		SignCount:    int64(credential.Authenticator.SignCount),
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if updated == 0 {
+		return uuid.Nil, ErrPasskeyReplayed
+	}
+
+	return user.(*PasskeyUser).ID, nil
+}
+
+func saveCeremony(c echo.Context, repo *repository.Queries, purpose string, data *webauthn.SessionData) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = repo.CreateWebAuthnCeremony(c.Request().Context(), repository.CreateWebAuthnCeremonyParams{
+		ID:      HashToken(token),
+		Purpose: purpose,
+		Data:    encoded,
+		Expires: time.Now().Add(CeremonyLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	setCookie(c, CeremonyCookie, token, int(CeremonyLifetime.Seconds()))
+	return nil
+}
+
+// takeCeremony consumes the pending ceremony so a challenge can be answered only once
+func takeCeremony(c echo.Context, repo *repository.Queries, purpose string) (webauthn.SessionData, error) {
+	var data webauthn.SessionData
+
+	cookie, err := c.Cookie(CeremonyCookie)
+	if err != nil || cookie.Value == "" {
+		return data, ErrNoCeremony
+	}
+	clearCookie(c, CeremonyCookie)
+
+	encoded, err := repo.ConsumeWebAuthnCeremony(c.Request().Context(), repository.ConsumeWebAuthnCeremonyParams{
+		ID:      HashToken(cookie.Value),
+		Purpose: purpose,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return data, ErrNoCeremony
+		}
+		return data, err
+	}
+
+	err = json.Unmarshal(encoded, &data)
+	return data, err
+}
+
+// CleanupWebAuthnCeremonies removes abandoned ceremonies, meant to be scheduled with tools.Func
+func CleanupWebAuthnCeremonies() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteExpiredWebAuthnCeremonies(context.Background())
+	if err != nil {
+		log.Errorf("Failed to clean up passkey ceremonies: %v", err)
+		return
+	}
+	log.Debugf("Removed %d expired passkey ceremonies", removed)
+}
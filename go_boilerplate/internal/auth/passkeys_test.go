@@ -0,0 +1,350 @@
+// passkeys_test.go
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+// softAuthenticator is an in-memory platform authenticator holding a single P-256 passkey,
+// it answers ceremonies the way a browser would hand them back to the server
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &softAuthenticator{key: key, credentialID: id}
+}
+
+func (a *softAuthenticator) authData(rpID string, attested []byte) []byte {
+	// UP | UV, plus AT when a credential is attached
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested != nil {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	return append(data, attested...)
+}
+
+func clientData(t *testing.T, typology string, challenge string, origin string) []byte {
+	t.Helper()
+	raw, err := json.Marshal(map[string]string{"type": typology, "challenge": challenge, "origin": origin})
+	require.NoError(t, err)
+	return raw
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// create answers navigator.credentials.create with a "none" attestation
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation, origin string) []byte {
+	t.Helper()
+
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, coseKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(creation.Response.RelyingParty.ID, attested),
+	})
+	require.NoError(t, err)
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData(t, "webauthn.create", creation.Response.Challenge.String(), origin)),
+			"attestationObject": b64(attestation),
+		},
+	})
+	require.NoError(t, err)
+	return body
+}
+
+// get answers navigator.credentials.get, bumping the signature counter first
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion, origin string) []byte {
+	t.Helper()
+
+	a.counter++
+	authData := a.authData(assertion.Response.RelyingPartyID, nil)
+	client := clientData(t, "webauthn.get", assertion.Response.Challenge.String(), origin)
+
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(client),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64(a.userHandle),
+		},
+	})
+	require.NoError(t, err)
+	return body
+}
+
+// capture is a pgxmock argument matcher that keeps the value it was matched against
+type capture struct {
+	value []byte
+}
+
+func (c *capture) Match(v any) bool {
+	b, ok := v.([]byte)
+	c.value = b
+	return ok
+}
+
+// ceremony runs an echo handler step and returns the cookies it set for the next step
+func ceremony(t *testing.T, cookies []*http.Cookie, body []byte, step func(c echo.Context) error) []*http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, step(echo.New().NewContext(req, rec)))
+	return rec.Result().Cookies()
+}
+
+var passkeyColumns = []string{"id", "user_id", "credential_id", "name", "credential", "sign_count", "created", "last_used"}
+
+type passkeyFixture struct {
+	wa            *webauthn.WebAuthn
+	mock          pgxmock.PgxPoolIface
+	repo          *repository.Queries
+	authenticator *softAuthenticator
+	session       *Session
+	credential    []byte
+}
+
+// newPasskeyFixture enrolls the fixture's software authenticator through both registration steps
+func newPasskeyFixture(t *testing.T) *passkeyFixture {
+	t.Helper()
+
+	wa, err := NewRelyingParty(testRPID, []string{testOrigin})
+	require.NoError(t, err)
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	f := &passkeyFixture{
+		wa:            wa,
+		mock:          mock,
+		repo:          repository.New(mock),
+		authenticator: newSoftAuthenticator(t),
+		session:       &Session{UserID: uuid.New(), Username: "alice"},
+	}
+
+	ceremonyData := &capture{}
+	mock.ExpectQuery("SELECT id, user_id, credential_id").WithArgs(f.session.UserID).WillReturnRows(pgxmock.NewRows(passkeyColumns))
+	mock.ExpectExec("INSERT INTO webauthn_ceremonies").WithArgs(pgxmock.AnyArg(), ceremonyRegistration, ceremonyData, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	var creation *protocol.CredentialCreation
+	cookies := ceremony(t, nil, nil, func(c echo.Context) (err error) {
+		creation, err = BeginPasskeyRegistration(c, wa, f.repo, f.session)
+		return err
+	})
+	require.NotNil(t, creation)
+	assert.Equal(t, protocol.VerificationRequired, creation.Response.AuthenticatorSelection.UserVerification)
+
+	credential := &capture{}
+	mock.ExpectQuery("DELETE FROM webauthn_ceremonies").WithArgs(pgxmock.AnyArg(), ceremonyRegistration).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow(ceremonyData.value))
+	mock.ExpectQuery("SELECT id, user_id, credential_id").WithArgs(f.session.UserID).WillReturnRows(pgxmock.NewRows(passkeyColumns))
+	mock.ExpectExec("INSERT INTO passkeys").WithArgs(pgxmock.AnyArg(), f.session.UserID, f.authenticator.credentialID, "Laptop", credential, int64(0)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	ceremony(t, cookies, f.authenticator.create(t, creation, testOrigin), func(c echo.Context) error {
+		return FinishPasskeyRegistration(c, wa, f.repo, f.session, " Laptop ")
+	})
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	f.credential = credential.value
+	return f
+}
+
+// login runs both login steps and returns the result of the second
+func (f *passkeyFixture) login(t *testing.T, origin string, expectUpdate bool) (uuid.UUID, error) {
+	t.Helper()
+
+	ceremonyData := &capture{}
+	f.mock.ExpectExec("INSERT INTO webauthn_ceremonies").WithArgs(pgxmock.AnyArg(), ceremonyLogin, ceremonyData, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	var assertion *protocol.CredentialAssertion
+	cookies := ceremony(t, nil, nil, func(c echo.Context) (err error) {
+		assertion, err = BeginPasskeyLogin(c, f.wa, f.repo)
+		return err
+	})
+
+	f.mock.ExpectQuery("DELETE FROM webauthn_ceremonies").WithArgs(pgxmock.AnyArg(), ceremonyLogin).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow(ceremonyData.value))
+	f.mock.ExpectQuery("SELECT user_id").WithArgs(f.authenticator.credentialID).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(f.session.UserID))
+	f.mock.ExpectQuery("SELECT id, user_id, credential_id").WithArgs(f.session.UserID).
+		WillReturnRows(pgxmock.NewRows(passkeyColumns).AddRow(uuid.New(), f.session.UserID, f.authenticator.credentialID, "Laptop", f.credential, int64(0), time.Now(), pgtype.Timestamp{}))
+
+	updated := &capture{}
+	if expectUpdate {
+		f.mock.ExpectExec("UPDATE passkeys").WithArgs(f.authenticator.credentialID, updated, int64(f.authenticator.counter+1)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	}
+
+	var userID uuid.UUID
+	var err error
+	ceremony(t, cookies, f.authenticator.get(t, assertion, origin), func(c echo.Context) error {
+		userID, err = FinishPasskeyLogin(c, f.wa, f.repo)
+		return nil
+	})
+	if updated.value != nil {
+		f.credential = updated.value
+	}
+	return userID, err
+}
+
+func TestPasskeyName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "kept", in: " Laptop ", want: "Laptop"},
+		{name: "empty", in: "  ", want: "Passkey"},
+		{name: "long", in: strings.Repeat("a", MaxPasskeyName+5), want: strings.Repeat("a", MaxPasskeyName)},
+		{name: "multi-byte characters are not split", in: strings.Repeat("é", MaxPasskeyName+1), want: strings.Repeat("é", MaxPasskeyName)},
+		{name: "invalid utf-8", in: "Key\xff", want: "Key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := passkeyName(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
+
+func TestPasskey_RegisterAndLogin(t *testing.T) {
+	t.Parallel()
+	f := newPasskeyFixture(t)
+
+	var stored webauthn.Credential
+	require.NoError(t, json.Unmarshal(f.credential, &stored))
+	assert.Equal(t, f.authenticator.credentialID, stored.ID)
+	assert.True(t, stored.Flags.UserVerified)
+
+	userID, err := f.login(t, testOrigin, true)
+	require.NoError(t, err)
+	assert.Equal(t, f.session.UserID, userID)
+	require.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestPasskey_ClonedAuthenticatorIsRejected(t *testing.T) {
+	t.Parallel()
+	f := newPasskeyFixture(t)
+
+	_, err := f.login(t, testOrigin, true)
+	require.NoError(t, err)
+
+	// A copy of the key that never saw the first login presents the same counter again
+	f.authenticator.counter--
+	_, err = f.login(t, testOrigin, false)
+	assert.ErrorIs(t, err, ErrPasskeyReplayed)
+	require.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestPasskey_PhishingOriginIsRejected(t *testing.T) {
+	t.Parallel()
+	f := newPasskeyFixture(t)
+
+	_, err := f.login(t, "https://examp1e.com", false)
+	assert.Error(t, err)
+	require.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestFinishPasskeyLogin_WithoutCeremony(t *testing.T) {
+	t.Parallel()
+
+	wa, err := NewRelyingParty(testRPID, []string{testOrigin})
+	require.NoError(t, err)
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	ceremony(t, nil, nil, func(c echo.Context) error {
+		_, err = FinishPasskeyLogin(c, wa, repository.New(mock))
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNoCeremony)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns RecoveryCodeCount single-use codes formatted as xxxxx-xxxxx
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the separators and case users tend to mangle
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// HashRecoveryCode returns the value stored for a recovery code
+func HashRecoveryCode(code string) string {
+	return HashToken(NormalizeRecoveryCode(code))
+}
@@ -0,0 +1,39 @@
+// recovery_test.go
+package auth
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var recoveryFormat = regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := NewRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+
+	seen := make(map[string]struct{})
+	for _, code := range codes {
+		assert.Regexp(t, recoveryFormat, code)
+		_, dup := seen[code]
+		assert.False(t, dup)
+		seen[code] = struct{}{}
+	}
+}
+
+func TestHashRecoveryCode_IgnoresFormatting(t *testing.T) {
+	t.Parallel()
+
+	want := HashRecoveryCode("abcde-fghij")
+
+	for _, input := range []string{"abcdefghij", "ABCDE-FGHIJ", " abcde fghij ", "abc-de-fgh-ij"} {
+		assert.Equal(t, want, HashRecoveryCode(input), "input %q", input)
+	}
+	assert.NotEqual(t, want, HashRecoveryCode("abcde-fghik"))
+}
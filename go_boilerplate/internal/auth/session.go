@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	SessionCookie          = "session"
+	SessionLifetime        = 7 * 24 * time.Hour
+	PendingSessionLifetime = 10 * time.Minute
+	MaxMFAAttempts         = 5
+
+	sessionContextKey = "session"
+)
+
+// Session is the authenticated state attached to a request by LoadSession
+type Session struct {
+	ID            string
+	UserID        uuid.UUID
+	Username      string
+	Email         string
+	EmailVerified bool
+	IsAdmin       bool
+	MFAVerified   bool
+	TwoFactor     bool
+	Expires       time.Time
+}
+
+// Authenticated reports whether every factor the user enrolled has been presented
+func (s *Session) Authenticated() bool {
+	return s.MFAVerified || !s.TwoFactor
+}
+
+// CurrentSession returns the session loaded for this request, if any
+func CurrentSession(c echo.Context) (*Session, bool) {
+	session, ok := c.Get(sessionContextKey).(*Session)
+	return session, ok && session != nil
+}
+
+// StartSession signs userID in, replacing any session the client already had.
+// mfaVerified records that a second factor was presented on this login.
+func StartSession(c echo.Context, repo *repository.Queries, userID uuid.UUID, mfaVerified bool) error {
+	return startSession(c, repo, userID, mfaVerified, SessionLifetime)
+}
+
+// StartPendingSession creates a short lived session that can only complete the 2FA challenge
+func StartPendingSession(c echo.Context, repo *repository.Queries, userID uuid.UUID) error {
+	return startSession(c, repo, userID, false, PendingSessionLifetime)
+}
+
+func startSession(c echo.Context, repo *repository.Queries, userID uuid.UUID, mfaVerified bool, lifetime time.Duration) error {
+	ctx := c.Request().Context()
+
+	// Never reuse an identifier across privilege changes (session fixation)
+	if err := revokeCookieSession(ctx, c, repo); err != nil {
+		return err
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(lifetime)
+	err = repo.CreateSession(ctx, repository.CreateSessionParams{
+		ID:          HashToken(token),
+		UserID:      userID,
+		MfaVerified: mfaVerified,
+		Ip:          c.RealIP(),
+		UserAgent:   c.Request().UserAgent(),
+		Expires:     expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	setCookie(c, SessionCookie, token, int(lifetime.Seconds()))
+	return nil
+}
+
+// EndSession deletes the current session and clears its cookie
+func EndSession(c echo.Context, repo *repository.Queries) error {
+	if err := revokeCookieSession(c.Request().Context(), c, repo); err != nil {
+		return err
+	}
+	clearCookie(c, SessionCookie)
+	return nil
+}
+
+func revokeCookieSession(ctx context.Context, c echo.Context, repo *repository.Queries) error {
+	cookie, err := c.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	return repo.DeleteSession(ctx, HashToken(cookie.Value))
+}
+
+// LoadSession resolves the session cookie and exposes it through CurrentSession
+func LoadSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cookie, err := c.Cookie(SessionCookie)
+			if err != nil || cookie.Value == "" {
+				return next(c)
+			}
+
+			repo := repository.New(database.DB())
+			row, err := repo.GetSession(c.Request().Context(), HashToken(cookie.Value))
+			if err != nil {
+				if !errors.Is(err, pgx.ErrNoRows) {
+					log.Errorf("Failed to load session: %v", err)
+				}
+				clearCookie(c, SessionCookie)
+				return next(c)
+			}
+
+			c.Set(sessionContextKey, &Session{
+				ID:            row.ID,
+				UserID:        row.UserID,
+				Username:      row.Username,
+				Email:         row.Email,
+				EmailVerified: row.EmailVerified,
+				IsAdmin:       row.IsAdmin,
+				MFAVerified:   row.MfaVerified,
+				TwoFactor:     row.TwoFactor,
+				Expires:       row.Expires,
+			})
+			c.Set(helpers.UserKey, row.UserID.String())
+
+			return next(c)
+		}
+	}
+}
+
+// CleanupSessions removes expired sessions, meant to be scheduled with tools.Func
+func CleanupSessions() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteExpiredSessions(context.Background())
+	if err != nil {
+		log.Errorf("Failed to clean up sessions: %v", err)
+		return
+	}
+	log.Debugf("Removed %d expired sessions", removed)
+}
+
+func setCookie(c echo.Context, name string, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   boot.Environment.GoEnv == enums.Environments.PRODUCTION,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearCookie(c echo.Context, name string) {
+	setCookie(c, name, "", -1)
+}
@@ -0,0 +1,130 @@
+// totp_test.go
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B reference secret for HMAC-SHA1
+var rfcSecret = []byte("12345678901234567890")
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	t.Parallel()
+
+	// The RFC lists 8 digit codes, authenticators use the trailing 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+			step := TOTPStep(time.Unix(tt.unix, 0))
+			assert.Equal(t, tt.want, TOTPCode(rfcSecret, step))
+		})
+	}
+}
+
+func TestValidateTOTP_AcceptsAdjacentSteps(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1234567890, 0)
+	current := TOTPStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code := TOTPCode(rfcSecret, current+offset)
+		step, ok := ValidateTOTP(rfcSecret, code, now)
+		require.True(t, ok, "offset %d", offset)
+		assert.Equal(t, current+offset, step)
+	}
+}
+
+func TestValidateTOTP_RejectsDistantSteps(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1234567890, 0)
+	current := TOTPStep(now)
+
+	for _, offset := range []int64{-3, -2, 2, 3} {
+		_, ok := ValidateTOTP(rfcSecret, TOTPCode(rfcSecret, current+offset), now)
+		assert.False(t, ok, "offset %d", offset)
+	}
+}
+
+func TestValidateTOTP_NormalizesInput(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(59, 0)
+
+	_, ok := ValidateTOTP(rfcSecret, " 287 082 ", now)
+	assert.True(t, ok)
+
+	for _, bad := range []string{"", "28708", "2870823", "abcdef"} {
+		_, ok := ValidateTOTP(rfcSecret, bad, now)
+		assert.False(t, ok, "code %q", bad)
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewTOTPSecret()
+	require.NoError(t, err)
+	b, err := NewTOTPSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, a, totpSecretSize)
+	assert.NotEqual(t, a, b)
+	assert.NotContains(t, EncodeTOTPSecret(a), "=")
+}
+
+func TestTOTPURI(t *testing.T) {
+	t.Parallel()
+
+	uri := TOTPURI("My App", "alice@example.com", rfcSecret)
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/"))
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/My App:alice@example.com", parsed.Path)
+	assert.Equal(t, EncodeTOTPSecret(rfcSecret), parsed.Query().Get("secret"))
+	assert.Equal(t, "My App", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+	assert.Equal(t, "30", parsed.Query().Get("period"))
+}
+
+func TestQRCodeSVG(t *testing.T) {
+	t.Parallel()
+
+	svg, err := QRCodeSVG(TOTPURI(Issuer, "alice", rfcSecret))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.True(t, strings.HasSuffix(svg, "</svg>"))
+	assert.Contains(t, svg, "h1v1h-1z")
+}
+
+// ——————— BENCHMARKS ———————
+
+func BenchmarkValidateTOTP(b *testing.B) {
+	now := time.Unix(1234567890, 0)
+	code := TOTPCode(rfcSecret, TOTPStep(now))
+	for b.Loop() {
+		_, _ = ValidateTOTP(rfcSecret, code, now)
+	}
+}
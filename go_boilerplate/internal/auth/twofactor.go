@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Issuer is the name authenticator apps show next to the account
+const Issuer = "go_boilerplate"
+
+var (
+	ErrInvalidCode              = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnrolled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnrolled = errors.New("two-factor authentication is already enabled")
+)
+
+type Enrollment struct {
+	Secret string
+	URI    string
+	QRCode string
+}
+
+// BeginEnrollment returns the pending TOTP secret of a user, creating it on first use.
+// The same secret is returned until it is confirmed, so reloading the page keeps a scanned code valid.
+func BeginEnrollment(ctx context.Context, repo *repository.Queries, userID uuid.UUID, account string) (Enrollment, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	sealed, err := Seal(secret)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	if err := repo.CreatePendingTOTP(ctx, repository.CreatePendingTOTPParams{UserID: userID, Secret: sealed}); err != nil {
+		return Enrollment{}, err
+	}
+
+	totp, err := repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if totp.Confirmed {
+		return Enrollment{}, ErrTwoFactorAlreadyEnrolled
+	}
+
+	secret, err = Open(totp.Secret)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	uri := TOTPURI(Issuer, account, secret)
+	qr, err := QRCodeSVG(uri)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	return Enrollment{Secret: EncodeTOTPSecret(secret), URI: uri, QRCode: qr}, nil
+}
+
+// ConfirmEnrollment activates the pending secret once the user proves they can produce codes
+// and returns a fresh set of recovery codes
+func ConfirmEnrollment(ctx context.Context, repo *repository.Queries, userID uuid.UUID, code string) ([]string, error) {
+	totp, err := repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+	if totp.Confirmed {
+		return nil, ErrTwoFactorAlreadyEnrolled
+	}
+
+	secret, err := Open(totp.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	confirmed, err := repo.ConfirmUserTOTP(ctx, repository.ConfirmUserTOTPParams{UserID: userID, LastStep: step})
+	if err != nil {
+		return nil, err
+	}
+	if confirmed == 0 {
+		return nil, ErrTwoFactorAlreadyEnrolled
+	}
+
+	return RegenerateRecoveryCodes(ctx, repo, userID)
+}
+
+// RegenerateRecoveryCodes invalidates every recovery code of a user and issues new ones.
+// Only hashes are stored, so the returned codes must be shown to the user right away.
+func RegenerateRecoveryCodes(ctx context.Context, repo *repository.Queries, userID uuid.UUID) ([]string, error) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		err := repo.CreateRecoveryCode(ctx, repository.CreateRecoveryCodeParams{
+			ID:       uuid.New(),
+			UserID:   userID,
+			CodeHash: HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code.
+// It reports whether a recovery code was consumed.
+func VerifySecondFactor(ctx context.Context, repo *repository.Queries, userID uuid.UUID, code string) (bool, error) {
+	totp, err := repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrTwoFactorNotEnrolled
+		}
+		return false, err
+	}
+	if !totp.Confirmed {
+		return false, ErrTwoFactorNotEnrolled
+	}
+
+	secret, err := Open(totp.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := ValidateTOTP(secret, code, time.Now()); ok {
+		// Moving last_step forward atomically rejects a code that was already used
+		advanced, err := repo.AdvanceTOTPStep(ctx, repository.AdvanceTOTPStepParams{UserID: userID, LastStep: step})
+		if err != nil {
+			return false, err
+		}
+		if advanced == 0 {
+			return false, ErrInvalidCode
+		}
+		return false, nil
+	}
+
+	used, err := repo.UseRecoveryCode(ctx, repository.UseRecoveryCodeParams{UserID: userID, CodeHash: HashRecoveryCode(code)})
+	if err != nil {
+		return false, err
+	}
+	if used == 0 {
+		return false, ErrInvalidCode
+	}
+
+	return true, nil
+}
+
+// DisableTwoFactor removes the TOTP secret, recovery codes and trusted devices of a user
+func DisableTwoFactor(ctx context.Context, repo *repository.Queries, userID uuid.UUID) error {
+	if err := repo.DeleteUserTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := repo.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	return repo.DeleteTrustedDevices(ctx, userID)
+}
+
+// ResetTwoFactor is the admin flow for users who lost every factor:
+// it disables two-factor authentication and signs the user out everywhere
+func ResetTwoFactor(ctx context.Context, repo *repository.Queries, userID uuid.UUID) error {
+	if err := DisableTwoFactor(ctx, repo, userID); err != nil {
+		return err
+	}
+	return repo.DeleteUserSessions(ctx, userID)
+}
@@ -0,0 +1,194 @@
+// twofactor_test.go
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const selectTOTP = "SELECT user_id, secret, confirmed, last_step"
+
+func sealedSecret(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	secret, err := NewTOTPSecret()
+	require.NoError(t, err)
+	sealed, err := Seal(secret)
+	require.NoError(t, err)
+	return secret, sealed
+}
+
+func totpRows(userID uuid.UUID, sealed []byte, confirmed bool) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"user_id", "secret", "confirmed", "last_step"}).
+		AddRow(userID, sealed, confirmed, int64(0))
+}
+
+func TestVerifySecondFactor_TOTP(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	secret, sealed := sealedSecret(t)
+	step := TOTPStep(time.Now())
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, true))
+	mock.ExpectExec("UPDATE user_totp").WithArgs(userID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	recovery, err := VerifySecondFactor(context.Background(), repository.New(mock), userID, TOTPCode(secret, step))
+	require.NoError(t, err)
+	assert.False(t, recovery)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySecondFactor_ReplayedTOTPIsRejected(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	secret, sealed := sealedSecret(t)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, true))
+	// last_step already at or past this step: the conditional update touches nothing
+	mock.ExpectExec("UPDATE user_totp").WithArgs(userID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	_, err = VerifySecondFactor(context.Background(), repository.New(mock), userID, TOTPCode(secret, TOTPStep(time.Now())))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySecondFactor_RecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	_, sealed := sealedSecret(t)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, true))
+	mock.ExpectExec("UPDATE recovery_codes").WithArgs(userID, HashRecoveryCode("abcde-fghij")).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	recovery, err := VerifySecondFactor(context.Background(), repository.New(mock), userID, "ABCDE-FGHIJ")
+	require.NoError(t, err)
+	assert.True(t, recovery)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySecondFactor_UsedRecoveryCodeIsRejected(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	_, sealed := sealedSecret(t)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, true))
+	mock.ExpectExec("UPDATE recovery_codes").WithArgs(userID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	_, err = VerifySecondFactor(context.Background(), repository.New(mock), userID, "abcde-fghij")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySecondFactor_NotEnrolled(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	_, sealed := sealedSecret(t)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnError(pgx.ErrNoRows)
+	_, err = VerifySecondFactor(context.Background(), repository.New(mock), userID, "123456")
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnrolled)
+
+	// A pending, unconfirmed secret is not a second factor yet
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, false))
+	_, err = VerifySecondFactor(context.Background(), repository.New(mock), userID, "123456")
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnrolled)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmEnrollment_IssuesRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	secret, sealed := sealedSecret(t)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, false))
+	mock.ExpectExec("UPDATE user_totp").WithArgs(userID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	for range RecoveryCodeCount {
+		mock.ExpectExec("INSERT INTO recovery_codes").WithArgs(pgxmock.AnyArg(), userID, pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+
+	codes, err := ConfirmEnrollment(context.Background(), repository.New(mock), userID, TOTPCode(secret, TOTPStep(time.Now())))
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmEnrollment_WrongCode(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	secret, sealed := sealedSecret(t)
+	wrong := TOTPCode(secret, TOTPStep(time.Now())+5)
+
+	mock.ExpectQuery(selectTOTP).WithArgs(userID).WillReturnRows(totpRows(userID, sealed, false))
+
+	_, err = ConfirmEnrollment(context.Background(), repository.New(mock), userID, wrong)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetTwoFactor_RevokesEverything(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	userID := uuid.New()
+	for _, table := range []string{"user_totp", "recovery_codes", "trusted_devices", "sessions"} {
+		mock.ExpectExec("DELETE FROM " + table).WithArgs(userID).
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	}
+
+	require.NoError(t, ResetTwoFactor(context.Background(), repository.New(mock), userID))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
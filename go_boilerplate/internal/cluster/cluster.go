@@ -0,0 +1,271 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/tools"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// ErrLeaseLost cancels a run whose lease could not be renewed, another instance may have taken it over
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Coordinator shares scheduled jobs between the instances of a deployment through Postgres:
+// it runs exclusive jobs on one instance only, keeps the history of every run and watches
+// for jobs that stopped succeeding.
+type Coordinator struct {
+	repo *repository.Queries
+
+	// Instance names this process in leases and run history, defaults to host-pid
+	Instance string
+	// LeaseTTL is how long a lease outlives its last heartbeat, so how long an exclusive job
+	// stays blocked after the instance running it died
+	LeaseTTL time.Duration
+	// Grace is how late past two of its intervals a job may be before it is overdue
+	Grace time.Duration
+	// OnOverdue is called once when a job is found overdue, again only after it succeeded.
+	// lastSuccess is zero when the job never succeeded.
+	OnOverdue func(job string, lastSuccess time.Time)
+	Now       func() time.Time
+
+	mu      sync.Mutex
+	overdue map[string]bool
+}
+
+// NewCoordinator returns a coordinator reading through repo, with default lease settings
+func NewCoordinator(repo *repository.Queries) *Coordinator {
+	return &Coordinator{
+		repo:     repo,
+		Instance: instanceName(),
+		LeaseTTL: time.Minute,
+		Grace:    time.Minute,
+		Now:      time.Now,
+		overdue:  make(map[string]bool),
+	}
+}
+
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Exclusive is a job option running the job on exactly one instance. Each due time is claimed by
+// the first instance to reach it, the others skip it, and no instance starts the job while another
+// one holds its lease.
+func (c *Coordinator) Exclusive() tools.JobOption {
+	return tools.WithJobMiddleware(c.lease)
+}
+
+func (c *Coordinator) lease(ctx context.Context, run tools.Run, next tools.JobFunc) (err error) {
+	_, err = c.repo.AcquireJobLease(ctx, repository.AcquireJobLeaseParams{
+		Job:       run.Job,
+		Instance:  c.Instance,
+		Scheduled: run.Scheduled.UTC(),
+		Ttl:       c.LeaseTTL.Seconds(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return tools.ErrSkipped
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acquire the lease: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopped := make(chan struct{})
+	heartbeat := make(chan struct{})
+	go func() {
+		defer close(heartbeat)
+		c.heartbeat(runCtx, run.Job, stopped, cancel)
+	}()
+
+	// Deferred so a panicking job, recovered further out by the scheduler, gives the lease back too
+	defer func() {
+		close(stopped)
+		<-heartbeat
+
+		if errors.Is(context.Cause(runCtx), ErrLeaseLost) && err != nil {
+			err = fmt.Errorf("%w: %w", ErrLeaseLost, err)
+		}
+		// The run may have been cancelled, the lease is released anyway
+		if releaseErr := c.repo.ReleaseJobLease(context.WithoutCancel(ctx), repository.ReleaseJobLeaseParams{Job: run.Job, Instance: c.Instance}); releaseErr != nil {
+			log.Errorf("Failed to release the lease of job %s: %v", run.Job, releaseErr)
+		}
+	}()
+
+	return next(runCtx)
+}
+
+// heartbeat pushes the lease back until stopped is closed. Once the lease is gone, or could not be
+// renewed for a whole TTL, the run is cancelled.
+func (c *Coordinator) heartbeat(ctx context.Context, job string, stopped <-chan struct{}, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(c.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-stopped:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		rows, err := c.repo.RenewJobLease(ctx, repository.RenewJobLeaseParams{Job: job, Instance: c.Instance, Ttl: c.LeaseTTL.Seconds()})
+		switch {
+		case err == nil && rows == 0:
+			cancel(ErrLeaseLost)
+			return
+		case err == nil:
+			renewed = time.Now()
+		case time.Since(renewed) >= c.LeaseTTL:
+			log.Errorf("Failed to renew the lease of job %s: %v", job, err)
+			cancel(ErrLeaseLost)
+			return
+		default:
+			log.Warnf("Failed to renew the lease of job %s, retrying: %v", job, err)
+		}
+	}
+}
+
+// History is a tools.Middleware recording every run in job_runs, with what it wrote through
+// tools.Logf. Failing to record does not keep the job from running.
+func (c *Coordinator) History(ctx context.Context, run tools.Run, next tools.JobFunc) (err error) {
+	id := uuid.New()
+	if err := c.repo.StartJobRun(ctx, repository.StartJobRunParams{
+		ID:        id,
+		Job:       run.Job,
+		Instance:  c.Instance,
+		Manual:    run.Manual,
+		Scheduled: run.Scheduled.UTC(),
+	}); err != nil {
+		log.Errorf("Failed to record the start of job %s: %v", run.Job, err)
+		return next(ctx)
+	}
+
+	// A timed out or cancelled run is still recorded
+	recordCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			c.finish(recordCtx, id, run.Job, fmt.Errorf("panic: %v", r), tools.RunLog(ctx)+string(debug.Stack()))
+			panic(r)
+		}
+		c.finish(recordCtx, id, run.Job, err, tools.RunLog(ctx))
+	}()
+	return next(ctx)
+}
+
+func (c *Coordinator) finish(ctx context.Context, id uuid.UUID, job string, runErr error, output string) {
+	params := repository.FinishJobRunParams{ID: id, Status: RunSucceeded, Log: output}
+	if runErr != nil {
+		params.Status, params.Error = RunFailed, runErr.Error()
+	}
+	if err := c.repo.FinishJobRun(ctx, params); err != nil {
+		log.Errorf("Failed to record the end of job %s: %v", job, err)
+	}
+}
+
+// Overdue is a job that has not succeeded in time
+type Overdue struct {
+	Job string
+	// LastSuccess is zero when the job never succeeded
+	LastSuccess time.Time
+	Deadline    time.Time
+}
+
+// Overdue returns the jobs of scheduler that have not succeeded within two of their intervals
+// plus Grace, on any instance. Jobs that never succeeded are measured from since.
+func (c *Coordinator) Overdue(ctx context.Context, scheduler *tools.Scheduler, since time.Time) ([]Overdue, error) {
+	successes, err := c.repo.ListJobSuccesses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]time.Time, len(successes))
+	for _, success := range successes {
+		last[success.Job] = success.LastSuccess
+	}
+
+	now := c.Now().UTC()
+	var overdue []Overdue
+	for _, job := range scheduler.List() {
+		if job.Paused || job.Interval == 0 {
+			continue
+		}
+		from, ok := last[job.ID]
+		if !ok {
+			from = since.UTC()
+		}
+		deadline := from.Add(2*job.Interval + c.Grace)
+		if now.After(deadline) {
+			overdue = append(overdue, Overdue{Job: job.ID, LastSuccess: last[job.ID], Deadline: deadline})
+		}
+	}
+	return overdue, nil
+}
+
+// Watchdog returns the dead man's switch of scheduler, a task calling OnOverdue for the jobs that
+// became overdue. Schedule it as an exclusive job so a single instance alerts. Each instance
+// remembers what it alerted on, a job may be reported again when the watchdog moves.
+func (c *Coordinator) Watchdog(scheduler *tools.Scheduler) tools.JobFunc {
+	since := c.Now()
+	return func(ctx context.Context) error {
+		overdue, err := c.Overdue(ctx, scheduler, since)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		late := make(map[string]bool, len(overdue))
+		for _, job := range overdue {
+			late[job.Job] = true
+			if c.overdue[job.Job] {
+				continue
+			}
+			if job.LastSuccess.IsZero() {
+				log.Warnf("Job %s has not succeeded yet", job.Job)
+			} else {
+				log.Warnf("Job %s has not succeeded since %s", job.Job, job.LastSuccess.Format(time.RFC3339))
+			}
+			if c.OnOverdue != nil {
+				c.OnOverdue(job.Job, job.LastSuccess)
+			}
+		}
+		c.overdue = late
+		return nil
+	}
+}
+
+// CleanupJobRuns removes runs older than 7 days, meant to be scheduled with tools.Func.
+// Frequent jobs write a row every few seconds on every instance, so history is kept shorter than
+// the other tables.
+func CleanupJobRuns() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteOldJobRuns(context.Background(), time.Now().UTC().AddDate(0, 0, -7))
+	if err != nil {
+		log.Errorf("Failed to clean up job runs: %v", err)
+		return
+	}
+	log.Debugf("Removed %d job runs", removed)
+}
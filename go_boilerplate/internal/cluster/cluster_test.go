@@ -0,0 +1,232 @@
+// cluster_test.go
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/tools"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() { log.SetLevel(log.OFF) }
+
+// capture is a pgxmock argument matcher that keeps what it was matched against
+type capture struct{ value *any }
+
+func (c capture) Match(v any) bool {
+	*c.value = v
+	return true
+}
+
+var due = time.Date(2026, 1, 1, 3, 15, 0, 0, time.UTC)
+
+func newCoordinator(t *testing.T) (*Coordinator, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	c := NewCoordinator(repository.New(mock))
+	c.Instance = "web-1"
+	return c, mock
+}
+
+func TestExclusive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		claimed bool
+		runErr  error
+	}{
+		{"runs and releases", true, nil},
+		{"failure is returned", true, errors.New("disk full")},
+		{"skips a run claimed elsewhere", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, mock := newCoordinator(t)
+			acquire := mock.ExpectQuery("INSERT INTO job_leases").WithArgs("cleanup", "web-1", due, float64(60))
+			if tt.claimed {
+				acquire.WillReturnRows(pgxmock.NewRows([]string{"expires"}).AddRow(due.Add(time.Minute)))
+				mock.ExpectExec("UPDATE job_leases").WithArgs("cleanup", "web-1").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			} else {
+				acquire.WillReturnError(pgx.ErrNoRows)
+			}
+
+			var ran bool
+			err := c.lease(context.Background(), tools.Run{Job: "cleanup", Scheduled: due}, func(context.Context) error {
+				ran = true
+				return tt.runErr
+			})
+
+			assert.Equal(t, tt.claimed, ran)
+			switch {
+			case !tt.claimed:
+				assert.ErrorIs(t, err, tools.ErrSkipped)
+			case tt.runErr != nil:
+				assert.ErrorIs(t, err, tt.runErr)
+			default:
+				assert.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestExclusive_LostLeaseCancelsRun(t *testing.T) {
+	t.Parallel()
+
+	c, mock := newCoordinator(t)
+	c.LeaseTTL = 30 * time.Millisecond
+	mock.ExpectQuery("INSERT INTO job_leases").WithArgs("cleanup", "web-1", due, c.LeaseTTL.Seconds()).WillReturnRows(pgxmock.NewRows([]string{"expires"}).AddRow(due))
+	mock.ExpectExec("UPDATE job_leases").WithArgs(c.LeaseTTL.Seconds(), "cleanup", "web-1").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectExec("UPDATE job_leases").WithArgs("cleanup", "web-1").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err := c.lease(context.Background(), tools.Run{Job: "cleanup", Scheduled: due}, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	assert.ErrorIs(t, err, ErrLeaseLost)
+	assert.ErrorIs(t, err, context.Canceled)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExclusive_PanicReleasesLease(t *testing.T) {
+	t.Parallel()
+
+	c, mock := newCoordinator(t)
+	mock.ExpectQuery("INSERT INTO job_leases").WithArgs("cleanup", "web-1", due, float64(60)).WillReturnRows(pgxmock.NewRows([]string{"expires"}).AddRow(due.Add(time.Minute)))
+	mock.ExpectExec("UPDATE job_leases").WithArgs("cleanup", "web-1").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	assert.PanicsWithValue(t, "nil map", func() {
+		_ = c.lease(context.Background(), tools.Run{Job: "cleanup", Scheduled: due}, func(context.Context) error {
+			panic("nil map")
+		})
+	})
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		task   tools.JobFunc
+		status string
+		error  string
+		log    string
+	}{
+		{"success", func(ctx context.Context) error { tools.Logf(ctx, "removed 3 rows"); return nil }, RunSucceeded, "", ""},
+		{"failure", func(context.Context) error { return errors.New("disk full") }, RunFailed, "disk full", ""},
+		{"timeout", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }, RunFailed, context.DeadlineExceeded.Error(), ""},
+		{"panic", func(context.Context) error { panic("boom") }, RunFailed, "panic: boom", "runtime/debug.Stack"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, mock := newCoordinator(t)
+			mock.ExpectExec("INSERT INTO job_runs").WithArgs(pgxmock.AnyArg(), "cleanup", "web-1", true, due).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			var output any
+			mock.ExpectExec("UPDATE job_runs").WithArgs(pgxmock.AnyArg(), tt.status, tt.error, capture{&output}).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			run := func() { _ = c.History(ctx, tools.Run{Job: "cleanup", Scheduled: due, Manual: true}, tt.task) }
+			if tt.name == "panic" {
+				assert.Panics(t, run)
+			} else {
+				run()
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Contains(t, output, tt.log)
+		})
+	}
+}
+
+func TestHistory_RunsWhenRecordingFails(t *testing.T) {
+	t.Parallel()
+
+	c, mock := newCoordinator(t)
+	mock.ExpectExec("INSERT INTO job_runs").WithArgs(pgxmock.AnyArg(), "cleanup", "web-1", false, due).WillReturnError(errors.New("connection refused"))
+
+	var ran bool
+	err := c.History(context.Background(), tools.Run{Job: "cleanup", Scheduled: due}, func(context.Context) error {
+		ran = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOverdue(t *testing.T) {
+	t.Parallel()
+
+	c, mock := newCoordinator(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.Now = func() time.Time { return now }
+
+	scheduler := tools.NewScheduler()
+	for _, id := range []string{"recent", "stale", "new", "paused"} {
+		require.NoError(t, scheduler.AddJob(id, "@hourly", tools.Func(func() {})))
+	}
+	require.NoError(t, scheduler.AddJob("daily", "@daily", tools.Func(func() {})))
+	require.NoError(t, scheduler.Pause("paused"))
+
+	mock.ExpectQuery("FROM job_runs").WillReturnRows(pgxmock.NewRows([]string{"job", "last_success"}).
+		AddRow("recent", now.Add(-30*time.Minute)).
+		AddRow("stale", now.Add(-3*time.Hour)).
+		AddRow("paused", now.Add(-5*time.Hour)).
+		AddRow("daily", now.Add(-25*time.Hour)))
+
+	overdue, err := c.Overdue(context.Background(), scheduler, now.Add(-10*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []Overdue{{Job: "stale", LastSuccess: now.Add(-3 * time.Hour), Deadline: now.Add(-time.Hour + time.Minute)}}, overdue)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchdog_AlertsOncePerEpisode(t *testing.T) {
+	t.Parallel()
+
+	c, mock := newCoordinator(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.Now = func() time.Time { return now }
+	var alerts atomic.Int32
+	c.OnOverdue = func(job string, lastSuccess time.Time) {
+		assert.Equal(t, "stale", job)
+		alerts.Add(1)
+	}
+
+	scheduler := tools.NewScheduler()
+	require.NoError(t, scheduler.AddJob("stale", "@hourly", tools.Func(func() {})))
+	watchdog := c.Watchdog(scheduler)
+
+	for _, lastSuccess := range []time.Time{now.Add(-3 * time.Hour), now.Add(-3 * time.Hour), now, now.Add(-3 * time.Hour)} {
+		mock.ExpectQuery("FROM job_runs").WillReturnRows(pgxmock.NewRows([]string{"job", "last_success"}).AddRow("stale", lastSuccess))
+		require.NoError(t, watchdog(context.Background()))
+	}
+
+	// Overdue, still overdue, recovered, overdue again
+	assert.Equal(t, int32(2), alerts.Load())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
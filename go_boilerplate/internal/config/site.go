@@ -45,6 +45,78 @@ var pageMeta = map[string]PageMeta{
 		Description: "GO Server boilerplate for Rust Gospin CLI app",
 		Indexable:   false,
 	},
+	//===
+	"/login": {
+		Title:       "Sign in",
+		Description: "Sign in to your account",
+		Indexable:   false,
+	},
+	"/login/2fa": {
+		Title:       "Two-factor authentication",
+		Description: "Confirm your sign in with an authentication code",
+		Indexable:   false,
+	},
+	"/login/magic": {
+		Title:       "Sign in with email",
+		Description: "Get a sign in link by email",
+		Indexable:   false,
+	},
+	"/signup": {
+		Title:       "Sign up",
+		Description: "Create a new account",
+		Indexable:   false,
+	},
+	"/account": {
+		Title:       "Account",
+		Description: "Manage your account",
+		Indexable:   false,
+	},
+	"/account/2fa": {
+		Title:       "Two-factor authentication",
+		Description: "Manage two-factor authentication for your account",
+		Indexable:   false,
+	},
+	"/account/passkeys": {
+		Title:       "Passkeys",
+		Description: "Manage the passkeys you sign in with",
+		Indexable:   false,
+	},
+	"/admin": {
+		Title:       "Admin",
+		Description: "Manage users",
+		Indexable:   false,
+	},
+	"/admin/webhooks": {
+		Title:       "Webhooks",
+		Description: "Inspect and replay inbound webhooks",
+		Indexable:   false,
+	},
+	"/admin/webhook-subscriptions": {
+		Title:       "Webhook subscriptions",
+		Description: "Manage outbound webhook subscriptions and deliveries",
+		Indexable:   false,
+	},
+	"/admin/alerts": {
+		Title:       "Alerts",
+		Description: "Firing alerts and silences",
+		Indexable:   false,
+	},
+	"/admin/errors": {
+		Title:       "Errors",
+		Description: "Reported errors and their stacks",
+		Indexable:   false,
+	},
+	"/admin/audit": {
+		Title:       "Audit log",
+		Description: "Who changed what, and when",
+		Indexable:   false,
+	},
+	"/admin/jobs": {
+		Title:       "Scheduled jobs",
+		Description: "Run, pause and reschedule jobs, and read their history",
+		Indexable:   false,
+	},
+	===//
 }
 
 type Organization struct {
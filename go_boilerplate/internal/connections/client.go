@@ -2,8 +2,10 @@ package connections
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
+	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/gommon/log"
 )
@@ -19,17 +21,41 @@ type Client struct {
 
 	manager *ConnectionManager
 
-	room string
+	// rooms the client is in, only touched by Run
+	rooms map[string]bool
+
+	// user is the ID of the signed in user, empty for visitors
+	user string
+
+	overflow OverflowPolicy
+
+	// disconnected makes read and write ask Run to remove the client once between them
+	disconnected sync.Once
 
 	sauce string
 
 	agent string
 }
 
+// ID names the connection for SendToClient, the client learns it from EventRooms
+func (client *Client) ID() string {
+	return client.id
+}
+
+// User is the ID of the signed in user, empty for visitors
+func (client *Client) User() string {
+	return client.user
+}
+
+// disconnect asks Run to remove the client, read and write both call it but only the first call sends
+func (client *Client) disconnect() {
+	client.disconnected.Do(func() {
+		send(client.manager, client.manager.disconnect, client)
+	})
+}
+
 func (client *Client) read() {
-	defer func() {
-		client.manager.disconnect <- client
-	}()
+	defer client.disconnect()
 
 	client.socket.SetReadLimit(messageBufferSize)
 
@@ -69,9 +95,11 @@ func (client *Client) read() {
 func (client *Client) write() {
 	ticker := time.NewTicker(pingInterval)
 
+	// Closing the socket ends read too
 	defer func() {
 		ticker.Stop()
-		client.manager.disconnect <- client
+		client.socket.Close()
+		client.disconnect()
 	}()
 
 	for {
@@ -80,7 +108,7 @@ func (client *Client) write() {
 			// Ok will be false Incase the egress channel is closed
 			if !ok {
 				// Manager has closed this connection channel, so communicate that to frontend
-				if err := client.socket.WriteMessage(websocket.CloseMessage, nil); err != nil {
+				if err := client.socket.WriteControl(websocket.CloseMessage, nil, time.Now().Add(writeWait)); err != nil {
 					// Log that the connection is closed and the reason
 					log.Infof("connection closed: %v", err)
 				}
@@ -91,17 +119,27 @@ func (client *Client) write() {
 			data, err := json.Marshal(message)
 			if err != nil {
 				log.Error(err)
+				monitoring.RecordWebsocketDropped("marshal", 1)
 				return // closes the connection, should we really
 			}
+			// A client that stops reading fails the write instead of holding the goroutine
+			if err := client.socket.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				log.Error(err)
+				return
+			}
 			// Write a Regular text message to the connection
 			if err := client.socket.WriteMessage(websocket.TextMessage, data); err != nil {
 				log.Error(err)
+				monitoring.RecordWebsocketDropped("write", 1)
+				// The connection is unusable after a failed write
+				return
 			}
+			monitoring.RecordWebsocketEvent("out", message.Type)
 			log.Debug("sent message")
 		case <-ticker.C:
 			log.Debug("Ping")
 			// Send the Ping
-			if err := client.socket.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+			if err := client.socket.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait)); err != nil {
 				log.Errorf("writemsg: %v", err)
 				return // return to break this goroutine triggeing cleanup
 			}
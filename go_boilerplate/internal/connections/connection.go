@@ -2,21 +2,73 @@ package connections
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	uuid "github.com/satori/go.uuid"
 )
 
 type ConnectionManager struct {
+	// clients and the indexes of them by room, id and user are only touched by Run
 	clients    map[*Client]bool
+	rooms      map[string]map[*Client]bool
+	ids        map[string]*Client
+	users      map[string]map[*Client]bool
 	connect    chan *Client
 	disconnect chan *Client
 	handlers   map[string]EventHandler
+	// memberships and lists come from client goroutines and deliveries from anywhere, all applied by Run
+	memberships chan membership
+	lists       chan *Client
+	deliveries  chan delivery
+	// stats hands a snapshot of the rooms to the metrics collector, answered by Run
+	stats chan chan map[string]roomStats
 	// otps is a map of allowed OTP to accept connections from
-	otps RetentionMap
+	otps *RetentionMap
+
+	// done ends Run, the requests sent to it afterwards are dropped
+	done <-chan struct{}
+
+	mu          sync.RWMutex
+	authorizers map[string]RoomAuthorizer
+	observers   []func(change MembershipChange)
+
+	// Overflow is given to the clients connecting afterwards, set it before serving
+	Overflow OverflowPolicy
+}
+
+// OverflowPolicy says what happens to an event for a client whose egress is full
+type OverflowPolicy int
+
+const (
+	// DropNewest loses the event, the client keeps what it had queued
+	DropNewest OverflowPolicy = iota
+	// DropOldest makes room by losing the oldest queued event, for feeds where the latest state matters
+	DropOldest
+	// DisconnectSlow drops the client, which can connect again and load the page afresh
+	DisconnectSlow
+)
+
+type membership struct {
+	client *Client
+	room   string
+	join   bool
+}
+
+// delivery is an event for every client, the members of room, the client with the id client or the
+// clients of user
+type delivery struct {
+	all    bool
+	room   string
+	client string
+	user   string
+	event  Event
 }
 
 func (cm *ConnectionManager) GenerateNewOtp() string {
@@ -25,11 +77,20 @@ func (cm *ConnectionManager) GenerateNewOtp() string {
 
 func NewManager(ctx context.Context) *ConnectionManager {
 	cm := &ConnectionManager{
-		connect:    make(chan *Client),
-		disconnect: make(chan *Client),
-		clients:    make(map[*Client]bool),
-		handlers:   make(map[string]EventHandler),
-		otps:       NewRetentionMap(ctx, 5*time.Second),
+		connect:     make(chan *Client),
+		disconnect:  make(chan *Client),
+		clients:     make(map[*Client]bool),
+		rooms:       make(map[string]map[*Client]bool),
+		ids:         make(map[string]*Client),
+		users:       make(map[string]map[*Client]bool),
+		handlers:    make(map[string]EventHandler),
+		memberships: make(chan membership),
+		lists:       make(chan *Client),
+		deliveries:  make(chan delivery, messageBufferSize),
+		stats:       make(chan chan map[string]roomStats),
+		otps:        NewRetentionMap(ctx, 5*time.Second),
+		authorizers: make(map[string]RoomAuthorizer),
+		done:        ctx.Done(),
 	}
 
 	cm.setupEventHandlers()
@@ -40,49 +101,172 @@ func NewManager(ctx context.Context) *ConnectionManager {
 // setupEventHandlers configures and adds all handlers
 func (m *ConnectionManager) setupEventHandlers() {
 	// m.handlers[EventVisit] = SendVisitHandler
+	m.handlers[EventSendOtp] = SendOtpHandler
+	m.handlers[EventJoin] = JoinHandler
+	m.handlers[EventLeave] = LeaveHandler
+	m.handlers[EventList] = ListHandler
 }
 
 // routeEvent is used to make sure the correct event goes into the correct handler
 func (m *ConnectionManager) routeEvent(event Event, c *Client) error {
 	// Check if Handler is present in Map
 	if handler, ok := m.handlers[event.Type]; ok {
+		monitoring.RecordWebsocketEvent("in", event.Type)
 		// Execute the handler and return any err
 		if err := handler(event, c); err != nil {
 			return err
 		}
 		return nil
 	} else {
+		// Types are client controlled, so they are not used as labels unless handled
+		monitoring.RecordWebsocketEvent("in", "unsupported")
 		return errors.New("this event type is not supported")
 	}
 }
 
+// BroadcastEvent queues event for every connected client, like BroadcastToRoom it never waits
 func (cm *ConnectionManager) BroadcastEvent(event Event) {
-	for client := range cm.clients {
-		client.egress <- event
+	cm.queue(delivery{all: true, event: event})
+}
+
+// BroadcastToRoom queues event for every client in room, safe to call from any goroutine.
+// Clients too slow to keep up are handled by their OverflowPolicy rather than holding up the others.
+func (cm *ConnectionManager) BroadcastToRoom(room string, event Event) {
+	cm.queue(delivery{room: room, event: event})
+}
+
+// SendToClient queues event for the client with id, if it is still connected. Like
+// BroadcastToRoom it never waits, so HTTP handlers can call it.
+func (cm *ConnectionManager) SendToClient(id string, event Event) {
+	if id != "" {
+		cm.queue(delivery{client: id, event: event})
+	}
+}
+
+// SendToUser queues event for every connection the signed in user has open, one per tab
+func (cm *ConnectionManager) SendToUser(user string, event Event) {
+	if user != "" {
+		cm.queue(delivery{user: user, event: event})
+	}
+}
+
+func (cm *ConnectionManager) queue(d delivery) {
+	select {
+	case cm.deliveries <- d:
+	default:
+		monitoring.RecordWebsocketDropped("broadcast", 1)
+	}
+}
+
+// send hands a request to Run, giving up once the manager is stopped
+func send[T any](cm *ConnectionManager, ch chan<- T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-cm.done:
+		return false
 	}
 }
 
+// deliver queues event for client without waiting, a full egress is handled by the client's policy.
+// Like every method touching clients, it must only be called from Run.
+func (cm *ConnectionManager) deliver(client *Client, event Event) {
+	// An evicted client may still be listed in a room being walked
+	if !cm.clients[client] {
+		return
+	}
+	select {
+	case client.egress <- event:
+		return
+	default:
+	}
+
+	switch client.overflow {
+	case DropOldest:
+		// Run is the only sender, so the freed slot stays free
+		select {
+		case <-client.egress:
+		default:
+		}
+		select {
+		case client.egress <- event:
+		default:
+		}
+		monitoring.RecordWebsocketDropped("overflow", 1)
+	case DisconnectSlow:
+		log.Warnf("Disconnecting client %s, it fell %d messages behind", client.id, cap(client.egress))
+		cm.remove(client, "evicted")
+	default:
+		monitoring.RecordWebsocketDropped("overflow", 1)
+	}
+}
+
+// remove closes the egress of client, which makes its write goroutine close the socket, and drops
+// it from the indexes. Removing a client twice does nothing.
+func (cm *ConnectionManager) remove(client *Client, reason string) {
+	if !cm.clients[client] {
+		return
+	}
+	delete(cm.clients, client)
+	if queued := len(client.egress); queued > 0 {
+		monitoring.RecordWebsocketDropped(reason, queued)
+	}
+	close(client.egress)
+	cm.unindex(client)
+	monitoring.RecordWebsocketDisconnect()
+}
+
+// Run owns the clients and their indexes, every change and delivery goes through it. It returns
+// once the context given to NewManager ends, disconnecting every client.
 func (cm *ConnectionManager) Run() {
 	for {
 		select {
+		case <-cm.done:
+			for client := range cm.clients {
+				cm.remove(client, "disconnect")
+			}
+			return
 		case client := <-cm.connect:
-			cm.clients[client] = true
+			cm.index(client)
 		case client := <-cm.disconnect:
-			if _, ok := cm.clients[client]; ok {
-
-				// for cl := range client.manager.clients {
-				// 	// Only send to clients inside the same chatroom
-				// 	if cl.room == "admin" {
-				// 		cl.egress <- Event{Type: EventUpdateVisitsAdmin, Payload: []byte("{}")}
-				// 	}
-
-				// }
-
-				close(client.egress)
-				// analizer.archiveVisit(client.id)
-				client.socket.Close()
-				delete(cm.clients, client)
+			cm.remove(client, "disconnect")
+		case change := <-cm.memberships:
+			if cm.clients[change.client] {
+				if change.join {
+					cm.join(change.client, change.room)
+				} else {
+					cm.leave(change.client, change.room, true)
+				}
+			}
+		case client := <-cm.lists:
+			if cm.clients[client] {
+				payload, err := json.Marshal(cm.roomList(client))
+				if err != nil {
+					log.Error(err)
+					break
+				}
+				cm.deliver(client, Event{Type: EventRooms, Payload: payload})
 			}
+		case d := <-cm.deliveries:
+			if d.all {
+				for client := range cm.clients {
+					cm.deliver(client, d.event)
+				}
+			} else if d.client != "" {
+				if client, ok := cm.ids[d.client]; ok {
+					cm.deliver(client, d.event)
+				}
+			} else if d.user != "" {
+				for client := range cm.users[d.user] {
+					cm.deliver(client, d.event)
+				}
+			} else {
+				for client := range cm.rooms[d.room] {
+					cm.deliver(client, d.event)
+				}
+			}
+		case reply := <-cm.stats:
+			reply <- cm.roomStats()
 		}
 	}
 }
@@ -90,24 +274,33 @@ func (cm *ConnectionManager) Run() {
 func (cm *ConnectionManager) ServeWS(c echo.Context) error {
 	socket, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
-		log.Fatal("Serve HTTP Sockets Error: ", err)
-		return err
+		// The upgrader already answered the request
+		log.Errorf("Failed to upgrade to a websocket: %v", err)
+		return nil
 	}
 
 	log.Info("Connection Received")
 
+	// The user is known when a session was loaded for the upgrade request
+	user, _ := c.Get(helpers.UserKey).(string)
+
 	client := &Client{
-		id:      uuid.NewV4().String(),
-		ip:      c.Request().Header.Get("X-Forwarded-For"),
-		socket:  socket,
-		egress:  make(chan Event, messageBufferSize),
-		manager: cm,
-		room:    "base",
-		sauce:   c.Request().Header.Get("Referer"),
-		agent:   c.Request().Header.Get("User-Agent"),
+		id:       uuid.NewV4().String(),
+		ip:       c.RealIP(),
+		socket:   socket,
+		egress:   make(chan Event, messageBufferSize),
+		manager:  cm,
+		rooms:    map[string]bool{RoomBase: true},
+		user:     user,
+		overflow: cm.Overflow,
+		sauce:    c.Request().Header.Get("Referer"),
+		agent:    c.Request().Header.Get("User-Agent"),
 	}
 
-	cm.connect <- client
+	if !send(cm, cm.connect, client) {
+		socket.Close()
+		return nil
+	}
 
 	go client.read()
 
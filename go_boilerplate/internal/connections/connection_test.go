@@ -0,0 +1,392 @@
+// connection_test.go
+package connections
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendOtpHandler_JoinsAdmin(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	go cm.Run()
+
+	client := &Client{rooms: map[string]bool{"base": true}, egress: make(chan Event, 4), manager: cm}
+	cm.connect <- client
+
+	bad, _ := json.Marshal(SendOtp{OTP: "guess"})
+	assert.Error(t, cm.routeEvent(Event{Type: EventSendOtp, Payload: bad}, client))
+
+	good, _ := json.Marshal(SendOtp{OTP: cm.GenerateNewOtp()})
+	require.NoError(t, cm.routeEvent(Event{Type: EventSendOtp, Payload: good}, client))
+	assert.Error(t, cm.routeEvent(Event{Type: EventSendOtp, Payload: good}, client), "passwords are used once")
+	assert.Equal(t, EventJoined, next(t, client).Type)
+
+	cm.BroadcastToRoom("admin", Event{Type: EventHTML})
+	select {
+	case event := <-client.egress:
+		assert.Equal(t, EventHTML, event.Type)
+	case <-time.After(time.Second):
+		t.Fatal("joined client got nothing")
+	}
+}
+
+func TestBroadcastToRoom(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	go cm.Run()
+
+	admin := &Client{rooms: map[string]bool{"admin": true}, egress: make(chan Event, 4)}
+	visitor := &Client{rooms: map[string]bool{"base": true}, egress: make(chan Event, 4)}
+	full := &Client{rooms: map[string]bool{"admin": true}, egress: make(chan Event, 1)}
+	full.egress <- Event{Type: EventNewCategory}
+	for _, client := range []*Client{admin, visitor, full} {
+		cm.connect <- client
+	}
+
+	cm.BroadcastToRoom("admin", Event{Type: EventHTML})
+	cm.BroadcastToRoom("admin", Event{Type: EventHTML})
+
+	assert.Eventually(t, func() bool { return len(admin.egress) == 2 }, time.Second, time.Millisecond)
+	assert.Len(t, visitor.egress, 0)
+	assert.Len(t, full.egress, 1, "a full client misses the events instead of blocking the room")
+}
+
+// next waits for the next event queued for client
+func next(t *testing.T, client *Client) Event {
+	t.Helper()
+	select {
+	case event := <-client.egress:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("client got nothing")
+		return Event{}
+	}
+}
+
+func roomEventFor(t *testing.T, eventType string, room string) Event {
+	t.Helper()
+	payload, err := json.Marshal(RoomRequest{Room: room})
+	require.NoError(t, err)
+	return Event{Type: eventType, Payload: payload}
+}
+
+func TestJoinLeave(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	cm.Authorize("chat:", Anyone)
+	cm.Authorize("chat:staff", SignedIn)
+	var changes []MembershipChange
+	cm.OnMembership(func(change MembershipChange) { changes = append(changes, change) })
+	go cm.Run()
+
+	alice := &Client{id: "a", user: "alice", rooms: map[string]bool{RoomBase: true}, egress: make(chan Event, 8), manager: cm}
+	visitor := &Client{id: "v", rooms: map[string]bool{RoomBase: true}, egress: make(chan Event, 8), manager: cm}
+	cm.connect <- alice
+	cm.connect <- visitor
+
+	tests := []struct {
+		name   string
+		client *Client
+		room   string
+		err    bool
+	}{
+		{name: "prefix rule", client: alice, room: "chat:1"},
+		{name: "exact rule wins over prefix", client: visitor, room: "chat:staff", err: true},
+		{name: "rooms without a rule are closed", client: visitor, room: "lobby", err: true},
+		{name: "admin needs an otp", client: alice, room: RoomAdmin, err: true},
+		{name: "empty room", client: alice, room: "", err: true},
+	}
+	for _, tt := range tests {
+		err := cm.routeEvent(roomEventFor(t, EventJoin, tt.room), tt.client)
+		if tt.err {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+
+	joined := next(t, alice)
+	assert.Equal(t, EventJoined, joined.Type)
+	assert.JSONEq(t, `{"room":"chat:1","client":"a","user":"alice"}`, string(joined.Payload))
+
+	require.NoError(t, cm.routeEvent(roomEventFor(t, EventJoin, "chat:1"), visitor))
+	assert.JSONEq(t, `{"room":"chat:1","client":"v"}`, string(next(t, alice).Payload))
+	assert.Equal(t, EventJoined, next(t, visitor).Type)
+
+	require.NoError(t, cm.routeEvent(Event{Type: EventList}, visitor))
+	list := next(t, visitor)
+	assert.Equal(t, EventRooms, list.Type)
+	assert.JSONEq(t, `{"client":"v","rooms":[{"room":"base","members":2},{"room":"chat:1","members":2}]}`, string(list.Payload))
+
+	require.NoError(t, cm.routeEvent(roomEventFor(t, EventLeave, "chat:1"), visitor))
+	assert.Equal(t, EventLeft, next(t, alice).Type)
+	assert.Equal(t, EventLeft, next(t, visitor).Type, "the leaving client is told too")
+
+	cm.BroadcastToRoom("chat:1", Event{Type: EventHTML})
+	assert.Equal(t, EventHTML, next(t, alice).Type)
+	assert.Len(t, visitor.egress, 0)
+
+	reply := make(chan map[string]roomStats, 1)
+	cm.stats <- reply
+	<-reply
+	assert.Equal(t, []MembershipChange{
+		{Room: "chat:1", Client: "a", User: "alice", Joined: true},
+		{Room: "chat:1", Client: "v", Joined: true},
+		{Room: "chat:1", Client: "v"},
+	}, changes)
+}
+
+func TestSendToClientAndUser(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	go cm.Run()
+
+	tab1 := &Client{id: "1", user: "alice", egress: make(chan Event, 4)}
+	tab2 := &Client{id: "2", user: "alice", egress: make(chan Event, 4)}
+	other := &Client{id: "3", user: "bob", egress: make(chan Event, 4)}
+	for _, client := range []*Client{tab1, tab2, other} {
+		cm.connect <- client
+	}
+
+	cm.SendToClient("2", Event{Type: EventHTML})
+	cm.SendToClient("missing", Event{Type: EventHTML})
+	cm.SendToUser("alice", Event{Type: EventNewCategory})
+	cm.SendToUser("", Event{Type: EventNewCategory})
+
+	assert.Eventually(t, func() bool { return len(tab1.egress) == 1 && len(tab2.egress) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, EventNewCategory, next(t, tab1).Type)
+	assert.Equal(t, EventHTML, next(t, tab2).Type)
+	assert.Len(t, other.egress, 0)
+}
+
+// snapshot asks Run for the rooms, which also waits for the requests sent to it before
+func snapshot(cm *ConnectionManager) map[string]roomStats {
+	reply := make(chan map[string]roomStats, 1)
+	cm.stats <- reply
+	return <-reply
+}
+
+func TestDisconnect_IsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	go cm.Run()
+
+	client := &Client{id: "1", rooms: map[string]bool{RoomBase: true}, egress: make(chan Event, 4), manager: cm}
+	cm.connect <- client
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.disconnect()
+		}()
+	}
+	wg.Wait()
+	// Run may also be asked directly, after the client is gone
+	cm.disconnect <- client
+	cm.BroadcastEvent(Event{Type: EventHTML})
+	cm.SendToClient("1", Event{Type: EventHTML})
+
+	assert.Empty(t, snapshot(cm))
+	_, open := <-client.egress
+	assert.False(t, open, "the egress is closed, once")
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		queued  []string
+		evicted bool
+	}{
+		{name: "drop newest", policy: DropNewest, queued: []string{"a", "b"}},
+		{name: "drop oldest", policy: DropOldest, queued: []string{"b", "c"}},
+		{name: "disconnect slow", policy: DisconnectSlow, queued: []string{"a", "b"}, evicted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cm := NewManager(ctx)
+			go cm.Run()
+
+			slow := &Client{id: "slow", rooms: map[string]bool{"feed": true}, egress: make(chan Event, 2), overflow: tt.policy, manager: cm}
+			slow.egress <- Event{Type: "a"}
+			slow.egress <- Event{Type: "b"}
+			fast := &Client{id: "fast", rooms: map[string]bool{"feed": true}, egress: make(chan Event, 4), manager: cm}
+			cm.connect <- slow
+			cm.connect <- fast
+
+			cm.BroadcastToRoom("feed", Event{Type: "c"})
+			received := []string{next(t, fast).Type}
+			if tt.evicted {
+				// The room hears of the eviction, before or after the event depending on the order it is walked in
+				received = append(received, next(t, fast).Type)
+			}
+			assert.Contains(t, received, "c", "a slow client does not hold up the room")
+
+			var queued []string
+			for len(queued) < len(tt.queued) {
+				queued = append(queued, next(t, slow).Type)
+			}
+			assert.Equal(t, tt.queued, queued)
+
+			stats := snapshot(cm)
+			if tt.evicted {
+				_, open := <-slow.egress
+				assert.False(t, open)
+				assert.Equal(t, 1, stats["feed"].clients)
+				assert.Contains(t, received, EventLeft)
+			} else {
+				assert.Equal(t, 2, stats["feed"].clients)
+				assert.Len(t, slow.egress, 0)
+			}
+		})
+	}
+}
+
+func TestRun_StopsWithContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cm := NewManager(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		cm.Run()
+		close(stopped)
+	}()
+
+	client := &Client{egress: make(chan Event, 4), manager: cm}
+	cm.connect <- client
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run kept going")
+	}
+	_, open := <-client.egress
+	assert.False(t, open, "clients are disconnected")
+
+	// Requests after the end are dropped instead of blocking the client goroutines
+	client.disconnect()
+	assert.NoError(t, ListHandler(Event{Type: EventList}, client))
+}
+
+// TestConcurrentUse runs real connections joining, leaving and disconnecting while events are
+// sent from other goroutines, meant for go test -race
+func TestConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	cm.Authorize("room:", Anyone)
+	cm.Overflow = DropOldest
+	go cm.Run()
+
+	e := echo.New()
+	e.GET("/ws", cm.ServeWS)
+	server := httptest.NewServer(e)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	const connections = 20
+	sending, stopSending := context.WithCancel(ctx)
+	var senders sync.WaitGroup
+	for i := range 4 {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for sending.Err() == nil {
+				cm.BroadcastToRoom(fmt.Sprintf("room:%d", i), Event{Type: EventHTML})
+				cm.BroadcastEvent(Event{Type: EventNewCategory})
+				cm.SendToClient("missing", Event{Type: EventHTML})
+				snapshot(cm)
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	var clients sync.WaitGroup
+	for i := range connections {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			reading := make(chan struct{})
+			go func() {
+				defer close(reading)
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			for j := range 20 {
+				request := RoomRequest{Room: fmt.Sprintf("room:%d", (i+j)%4)}
+				eventType := EventJoin
+				if j%3 == 2 {
+					eventType = EventLeave
+				}
+				payload, _ := json.Marshal(request)
+				if err := conn.WriteJSON(Event{Type: eventType, Payload: payload}); err != nil {
+					return
+				}
+				if err := conn.WriteJSON(Event{Type: EventList}); err != nil {
+					return
+				}
+			}
+
+			// Half the clients leave politely, the others just drop the connection
+			if i%2 == 0 {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			}
+			conn.Close()
+			<-reading
+		}()
+	}
+	clients.Wait()
+
+	assert.Eventually(t, func() bool { return len(snapshot(cm)) == 0 }, 5*time.Second, 10*time.Millisecond, "every client is removed from every room")
+	stopSending()
+	senders.Wait()
+}
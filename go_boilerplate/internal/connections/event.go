@@ -13,25 +13,81 @@ type Event struct {
 type HtmlData struct {
 	Id   string `json:"id"`
 	Html string `json:"html"`
+	// Swap is "outerHTML" to replace the element with Id, empty to insert at its top
+	Swap string `json:"swap,omitempty"`
 }
 
 type EventHandler func(event Event, c *Client) error
 
 const (
 	EventNewCategory = "newcategory"
+	EventSendOtp     = "sendotp"
+	// EventHTML carries HtmlData, the client inserts Html at the top of the element with Id, or replaces it
+	EventHTML = "html"
+	// EventJoin and EventLeave carry a RoomRequest, EventList is answered with EventRooms
+	EventJoin  = "join"
+	EventLeave = "leave"
+	EventList  = "list"
+	EventRooms = "rooms"
+	// EventJoined and EventLeft carry a MembershipChange, sent to the members of the room
+	EventJoined = "joined"
+	EventLeft   = "left"
 )
 
 func SendNewCategoryHandler(event Event, client *Client) error {
-	var outgoingEvent Event
-	outgoingEvent.Payload = event.Payload
-	outgoingEvent.Type = EventNewCategory
-
-	for client := range client.manager.clients {
-		// Only send to clients inside the same chatroom
-		if client.room != "admin" {
-			client.egress <- outgoingEvent
-		}
+	client.manager.BroadcastToRoom(RoomBase, Event{Type: EventNewCategory, Payload: event.Payload})
+	return nil
+}
+
+type RoomRequest struct {
+	Room string `json:"room"`
+}
+
+// RoomList is the payload of EventRooms
+type RoomList struct {
+	Client string     `json:"client"`
+	Rooms  []RoomInfo `json:"rooms"`
+}
+
+type RoomInfo struct {
+	Room    string `json:"room"`
+	Members int    `json:"members"`
+}
+
+func decodeRoom(event Event) (string, error) {
+	var payload RoomRequest
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return "", fmt.Errorf("bad payload in request: %v", err)
 	}
+	return payload.Room, validRoom(payload.Room)
+}
+
+// JoinHandler adds the client to a room its authorizer lets it in
+func JoinHandler(event Event, client *Client) error {
+	room, err := decodeRoom(event)
+	if err != nil {
+		return err
+	}
+	if !client.manager.authorized(client, room) {
+		return fmt.Errorf("client %s may not join room %s", client.id, room)
+	}
+
+	send(client.manager, client.manager.memberships, membership{client: client, room: room, join: true})
+	return nil
+}
+
+func LeaveHandler(event Event, client *Client) error {
+	room, err := decodeRoom(event)
+	if err != nil {
+		return err
+	}
+
+	send(client.manager, client.manager.memberships, membership{client: client, room: room})
+	return nil
+}
+
+func ListHandler(event Event, client *Client) error {
+	send(client.manager, client.manager.lists, client)
 	return nil
 }
 
@@ -50,6 +106,6 @@ func SendOtpHandler(event Event, client *Client) error {
 		return fmt.Errorf("authauthorized bad otp in request")
 	}
 
-	client.room = "admin"
+	send(client.manager, client.manager.memberships, membership{client: client, room: RoomAdmin, join: true})
 	return nil
 }
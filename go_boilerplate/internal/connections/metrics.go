@@ -0,0 +1,70 @@
+package connections
+
+import (
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// roomStats counts the clients of a room and the messages waiting in their egress
+type roomStats struct {
+	clients int
+	queued  int
+}
+
+// roomStats must only be called from Run, which owns the room index
+func (cm *ConnectionManager) roomStats() map[string]roomStats {
+	stats := make(map[string]roomStats, len(cm.rooms))
+	for name, members := range cm.rooms {
+		room := roomStats{clients: len(members)}
+		for client := range members {
+			room.queued += len(client.egress)
+		}
+		stats[name] = room
+	}
+	return stats
+}
+
+// Collector exports the connected clients and egress queue depth per room, a client in several
+// rooms counts in each.
+// Event and drop counters are recorded through the monitoring package as they happen.
+type Collector struct {
+	manager *ConnectionManager
+	timeout time.Duration
+
+	clients *prometheus.Desc
+	queued  *prometheus.Desc
+}
+
+// NewCollector describes cm, register it with prometheus.MustRegister once Run is started
+func NewCollector(cm *ConnectionManager) *Collector {
+	return &Collector{
+		manager: cm,
+		timeout: time.Second,
+		clients: prometheus.NewDesc("websocket_connected_clients", "Websocket clients currently connected", []string{"room"}, nil),
+		queued:  prometheus.NewDesc("websocket_egress_queue_depth", "Outgoing messages waiting to be written to clients", []string{"room"}, nil),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.clients
+	ch <- c.queued
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	reply := make(chan map[string]roomStats, 1)
+	select {
+	case c.manager.stats <- reply:
+	case <-c.manager.done:
+		return
+	case <-time.After(c.timeout):
+		log.Warn("Connection manager did not answer the metrics scrape, is Run started?")
+		return
+	}
+
+	for room, stats := range <-reply {
+		ch <- prometheus.MustNewConstMetric(c.clients, prometheus.GaugeValue, float64(stats.clients), room)
+		ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stats.queued), room)
+	}
+}
@@ -0,0 +1,55 @@
+// metrics_test.go
+package connections
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := NewManager(ctx)
+	go cm.Run()
+
+	waiting := &Client{rooms: map[string]bool{"admin": true}, egress: make(chan Event, 4)}
+	waiting.egress <- Event{Type: EventNewCategory}
+	waiting.egress <- Event{Type: EventNewCategory}
+	cm.connect <- waiting
+	cm.connect <- &Client{rooms: map[string]bool{"base": true}, egress: make(chan Event, 4)}
+	cm.connect <- &Client{rooms: map[string]bool{"base": true}, egress: make(chan Event, 4)}
+
+	expected := `
+# HELP websocket_connected_clients Websocket clients currently connected
+# TYPE websocket_connected_clients gauge
+websocket_connected_clients{room="admin"} 1
+websocket_connected_clients{room="base"} 2
+# HELP websocket_egress_queue_depth Outgoing messages waiting to be written to clients
+# TYPE websocket_egress_queue_depth gauge
+websocket_egress_queue_depth{room="admin"} 2
+websocket_egress_queue_depth{room="base"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(NewCollector(cm), strings.NewReader(expected)))
+}
+
+func TestCollector_SkipsScrapeWithoutRun(t *testing.T) {
+	t.Parallel()
+
+	collector := NewCollector(NewManager(context.Background()))
+	collector.timeout = 10 * time.Millisecond
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
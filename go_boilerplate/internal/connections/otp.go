@@ -2,6 +2,7 @@ package connections
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -16,11 +17,15 @@ type Verifier interface {
 	VerifyOTP(otp string) bool
 }
 
-type RetentionMap map[string]OTP
+// RetentionMap holds the OTPs handed out, it is used from request, client and retention goroutines alike
+type RetentionMap struct {
+	mu   sync.Mutex
+	otps map[string]OTP
+}
 
 // NewRetentionMap will create a new retentionmap and start the retention given the set period
-func NewRetentionMap(ctx context.Context, retentionPeriod time.Duration) RetentionMap {
-	rm := make(RetentionMap)
+func NewRetentionMap(ctx context.Context, retentionPeriod time.Duration) *RetentionMap {
+	rm := &RetentionMap{otps: make(map[string]OTP)}
 
 	go rm.Retention(ctx, retentionPeriod)
 
@@ -28,42 +33,50 @@ func NewRetentionMap(ctx context.Context, retentionPeriod time.Duration) Retenti
 }
 
 // NewOTP creates and adds a new otp to the map
-func (rm RetentionMap) NewOTP() OTP {
+func (rm *RetentionMap) NewOTP() OTP {
 	o := OTP{
 		Key:     uuid.NewV4().String(),
 		Created: time.Now(),
 	}
 
-	rm[o.Key] = o
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.otps[o.Key] = o
 	return o
 }
 
 // VerifyOTP will make sure a OTP exists
 // and return true if so
 // It will also delete the key so it cant be reused
-func (rm RetentionMap) VerifyOTP(otp string) bool {
+func (rm *RetentionMap) VerifyOTP(otp string) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
 	// Verify OTP is existing
-	if _, ok := rm[otp]; !ok {
+	if _, ok := rm.otps[otp]; !ok {
 		// otp does not exist
 		return false
 	}
-	delete(rm, otp)
+	delete(rm.otps, otp)
 	return true
 }
 
 // Retention will make sure old OTPs are removed
 // Is Blocking, so run as a Goroutine
-func (rm RetentionMap) Retention(ctx context.Context, retentionPeriod time.Duration) {
+func (rm *RetentionMap) Retention(ctx context.Context, retentionPeriod time.Duration) {
 	ticker := time.NewTicker(400 * time.Millisecond)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			for _, otp := range rm {
+			rm.mu.Lock()
+			for _, otp := range rm.otps {
 				// Add Retention to Created and check if it is expired
 				if otp.Created.Add(retentionPeriod).Before(time.Now()) {
-					delete(rm, otp.Key)
+					delete(rm.otps, otp.Key)
 				}
 			}
+			rm.mu.Unlock()
 		case <-ctx.Done():
 			return
 
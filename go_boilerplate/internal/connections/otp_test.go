@@ -0,0 +1,39 @@
+// otp_test.go
+package connections
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionMap_ConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rm := NewRetentionMap(ctx, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				otp := rm.NewOTP()
+				rm.VerifyOTP(otp.Key)
+				rm.NewOTP()
+			}
+		}()
+	}
+	wg.Wait()
+
+	otp := rm.NewOTP()
+	assert.True(t, rm.VerifyOTP(otp.Key))
+	assert.False(t, rm.VerifyOTP(otp.Key), "passwords are used once")
+	expired := rm.NewOTP()
+	assert.Eventually(t, func() bool { return !rm.VerifyOTP(expired.Key) }, 2*time.Second, 50*time.Millisecond)
+}
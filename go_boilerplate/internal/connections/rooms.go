@@ -0,0 +1,190 @@
+package connections
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// RoomBase is the lobby every client starts in, its comings and goings are not announced
+	RoomBase = "base"
+	// RoomAdmin is only joined with an OTP handed out to an admin page
+	RoomAdmin = "admin"
+
+	maxRoomLength = 100
+	// maxRooms bounds the indexes a single client can grow
+	maxRooms = 32
+)
+
+// RoomAuthorizer decides whether client may join room, it is called from the client's goroutine
+type RoomAuthorizer func(client *Client, room string) bool
+
+// Anyone lets every client join
+func Anyone(client *Client, room string) bool {
+	return true
+}
+
+// SignedIn lets the clients of a signed in user join
+func SignedIn(client *Client, room string) bool {
+	return client.user != ""
+}
+
+// MembershipChange is the payload of EventJoined and EventLeft, and what OnMembership observers get
+type MembershipChange struct {
+	Room   string `json:"room"`
+	Client string `json:"client"`
+	User   string `json:"user,omitempty"`
+	Joined bool   `json:"-"`
+}
+
+// Authorize sets who may join the rooms matching pattern by sending EventJoin. A pattern ending in
+// ":" covers the rooms starting with it, like "chat:" for "chat:42", an exact room name wins over it.
+// Rooms without a rule are closed to EventJoin.
+func (cm *ConnectionManager) Authorize(pattern string, allow RoomAuthorizer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.authorizers[pattern] = allow
+}
+
+// OnMembership calls observer from Run whenever a client joins or leaves a room other than RoomBase.
+// It must not block, the manager waits for it.
+func (cm *ConnectionManager) OnMembership(observer func(change MembershipChange)) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.observers = append(cm.observers, observer)
+}
+
+func (cm *ConnectionManager) authorized(client *Client, room string) bool {
+	cm.mu.RLock()
+	allow, ok := cm.authorizers[room]
+	if i := strings.Index(room, ":"); !ok && i >= 0 {
+		allow, ok = cm.authorizers[room[:i+1]]
+	}
+	cm.mu.RUnlock()
+	return ok && allow(client, room)
+}
+
+func validRoom(room string) error {
+	if room == "" || len(room) > maxRoomLength {
+		return fmt.Errorf("room names are 1 to %d bytes long", maxRoomLength)
+	}
+	return nil
+}
+
+// index adds a connecting client to the indexes, with the rooms it starts in.
+// It and the other index methods must only be called from Run.
+func (cm *ConnectionManager) index(client *Client) {
+	cm.clients[client] = true
+	if client.id != "" {
+		cm.ids[client.id] = client
+	}
+	if client.user != "" {
+		if cm.users[client.user] == nil {
+			cm.users[client.user] = make(map[*Client]bool)
+		}
+		cm.users[client.user][client] = true
+	}
+	if client.rooms == nil {
+		client.rooms = make(map[string]bool)
+	}
+	for room := range client.rooms {
+		cm.members(room)[client] = true
+	}
+}
+
+// unindex removes a client remove took out of clients from the other indexes, telling the rooms it was in
+func (cm *ConnectionManager) unindex(client *Client) {
+	for room := range client.rooms {
+		cm.leave(client, room, false)
+	}
+	if cm.ids[client.id] == client {
+		delete(cm.ids, client.id)
+	}
+	if clients, ok := cm.users[client.user]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(cm.users, client.user)
+		}
+	}
+}
+
+func (cm *ConnectionManager) members(room string) map[*Client]bool {
+	members, ok := cm.rooms[room]
+	if !ok {
+		members = make(map[*Client]bool)
+		cm.rooms[room] = members
+	}
+	return members
+}
+
+// join adds client to room and tells the members, client included
+func (cm *ConnectionManager) join(client *Client, room string) {
+	if client.rooms[room] {
+		return
+	}
+	if len(client.rooms) >= maxRooms {
+		log.Warnf("Client %s is in %d rooms already, not joining %s", client.id, maxRooms, room)
+		return
+	}
+	client.rooms[room] = true
+	cm.members(room)[client] = true
+	cm.announce(client, room, true)
+}
+
+// leave removes client from room and tells the members, and client unless it is disconnecting
+func (cm *ConnectionManager) leave(client *Client, room string, tellClient bool) {
+	if !client.rooms[room] {
+		return
+	}
+	if tellClient {
+		cm.announce(client, room, false)
+	}
+	delete(client.rooms, room)
+	delete(cm.rooms[room], client)
+	if len(cm.rooms[room]) == 0 {
+		delete(cm.rooms, room)
+	}
+	if !tellClient {
+		cm.announce(client, room, false)
+	}
+}
+
+func (cm *ConnectionManager) announce(client *Client, room string, joined bool) {
+	if room == RoomBase {
+		return
+	}
+	change := MembershipChange{Room: room, Client: client.id, User: client.user, Joined: joined}
+	eventType := EventLeft
+	if joined {
+		eventType = EventJoined
+	}
+	payload, err := json.Marshal(change)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for member := range cm.rooms[room] {
+		cm.deliver(member, Event{Type: eventType, Payload: payload})
+	}
+
+	cm.mu.RLock()
+	observers := cm.observers
+	cm.mu.RUnlock()
+	for _, observer := range observers {
+		observer(change)
+	}
+}
+
+// roomList is the answer to EventList, the rooms of client and how many clients are in each
+func (cm *ConnectionManager) roomList(client *Client) RoomList {
+	list := RoomList{Client: client.id, Rooms: make([]RoomInfo, 0, len(client.rooms))}
+	for room := range client.rooms {
+		list.Rooms = append(list.Rooms, RoomInfo{Room: room, Members: len(cm.rooms[room])})
+	}
+	slices.SortFunc(list.Rooms, func(a, b RoomInfo) int { return strings.Compare(a.Room, b.Room) })
+	return list
+}
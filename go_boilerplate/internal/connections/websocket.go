@@ -19,6 +19,8 @@ var (
 	// Because that can make decimals, so instead *9 / 10 to get 90%
 	// The reason why it has to be less than PingRequency is becuase otherwise it will send a new Ping before getting response
 	pingInterval = (pongWait * 9) / 10
+	// writeWait is how long a message may take to reach a client before it is dropped as too slow
+	writeWait = 10 * time.Second
 )
 
 func checkOrigin(r *http.Request) bool {
@@ -0,0 +1,801 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/audit"
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/views/account"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// formError shows a message below the submitted form through the htmx:responseError handler
+func formError(c echo.Context, code int, message string, userMessage string) error {
+	return apperrors.SendReturnedHTMLErrorMessage(c, apperrors.ErrorMessage{
+		Error:       apperrors.GenericError{Code: code, Message: message, UserMessage: userMessage},
+		Box:         enums.Boxes.BELOW,
+		Persistance: "5000"}, nil)
+}
+
+func Signup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.Signup(data))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostSignup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		username := strings.TrimSpace(c.FormValue("username"))
+		email := strings.ToLower(strings.TrimSpace(c.FormValue("email")))
+		password := c.FormValue("password")
+
+		if len(username) < 3 || len(username) > 15 {
+			return formError(c, http.StatusBadRequest, "Invalid username length", "Usernames are 3 to 15 characters long")
+		}
+		if _, err := mail.ParseAddress(email); err != nil || len(email) > 30 {
+			return formError(c, http.StatusBadRequest, "Invalid email address", "Please enter a valid email address")
+		}
+
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			if errors.Is(err, auth.ErrPasswordTooShort) || errors.Is(err, auth.ErrPasswordTooLong) {
+				return formError(c, http.StatusBadRequest, err.Error(), "Passwords are 12 to 72 characters long")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not create your account")
+		}
+
+		user, err := repo.CreateUserWithPassword(c.Request().Context(), repository.CreateUserWithPasswordParams{
+			ID:           uuid.New(),
+			Username:     username,
+			Email:        email,
+			PasswordHash: hash,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return formError(c, http.StatusConflict, err.Error(), "Username or email is already taken")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not create your account")
+		}
+
+		if err := auth.StartSession(c, repo, user.ID, false); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Account created, but signing in failed")
+		}
+
+		// A failed verification mail must not fail the signup, it can be resent from the account page
+		if err := sendVerification(c.Request().Context(), user.ID, user.Email); err != nil {
+			log.Errorf("Failed to send verification email to %s: %v", user.Email, err)
+		}
+
+		return helpers.Redirect(c, "/account")
+	}
+}
+
+func Login() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if session, ok := auth.CurrentSession(c); ok && session.Authenticated() {
+			return helpers.Redirect(c, "/")
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.Login(data))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		login := strings.TrimSpace(c.FormValue("login"))
+		password := c.FormValue("password")
+
+		user, err := repo.GetUserCredentials(ctx, login)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+		}
+
+		// Unknown users are checked against an empty hash so both failures take as long
+		if !auth.CheckPassword(user.PasswordHash, password) {
+			return formError(c, http.StatusUnauthorized, "Failed login for "+login, "Invalid username or password")
+		}
+
+		return signIn(c, repo, user.ID)
+	}
+}
+
+// signIn finishes a first factor login, sending users with 2FA on an untrusted device to the challenge
+func signIn(c echo.Context, repo *repository.Queries, userID uuid.UUID) error {
+	totp, err := repo.GetUserTOTP(c.Request().Context(), userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+	}
+
+	if totp.Confirmed && !auth.IsDeviceTrusted(c, repo, userID) {
+		if err := auth.StartPendingSession(c, repo, userID); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+		}
+		return helpers.Redirect(c, "/login/2fa")
+	}
+
+	if err := auth.StartSession(c, repo, userID, totp.Confirmed); err != nil {
+		return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+	}
+
+	return helpers.Redirect(c, "/")
+}
+
+func Logout() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		if err := auth.EndSession(c, repo); err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not sign you out"}, nil)
+		}
+
+		return helpers.Redirect(c, "/")
+	}
+}
+
+func MagicLink() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.MagicLink(data))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostMagicLink() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		email := strings.ToLower(strings.TrimSpace(c.FormValue("email")))
+		if _, err := mail.ParseAddress(email); err != nil {
+			return formError(c, http.StatusBadRequest, "Invalid email address", "Please enter a valid email address")
+		}
+
+		// Unknown and rate limited addresses get the same answer, so this form cannot tell who has an account
+		sent := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailSent("If an account uses this address, a sign in link is on its way"))
+
+		user, err := repo.GetUserByEmail(ctx, email)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return formError(c, http.StatusInternalServerError, err.Error(), "Could not send the sign in link")
+			}
+			return c.Blob(http.StatusOK, "text/html; charset=utf-8", sent)
+		}
+
+		err = inTransaction(ctx, func(repo *repository.Queries) error {
+			token, err := auth.IssueEmailToken(ctx, repo, user.ID, user.Email, auth.PurposeLogin)
+			if err != nil {
+				return err
+			}
+			return auth.SendMagicLink(ctx, repo, user.Email, token)
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrRateLimited) {
+				log.Warnf("Magic link rate limit reached for %s", user.Email)
+				return c.Blob(http.StatusOK, "text/html; charset=utf-8", sent)
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not send the sign in link")
+		}
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", sent)
+	}
+}
+
+func MagicLinkConfirm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.MagicLinkConfirm(data, c.Param("token")))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostMagicLinkConfirm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		row, err := auth.ConsumeEmailToken(ctx, repo, c.Param("token"), auth.PurposeLogin)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				return formError(c, http.StatusUnauthorized, err.Error(), "This sign in link is invalid or has expired")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+		}
+
+		// Following the link proves control of the address
+		if _, err := repo.VerifyUserEmail(ctx, repository.VerifyUserEmailParams{ID: row.UserID, Email: row.Email}); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+		}
+
+		return signIn(c, repo, row.UserID)
+	}
+}
+
+func TwoFactorChallenge() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, ok := auth.CurrentSession(c)
+		if !ok {
+			return helpers.Redirect(c, "/login")
+		}
+		if session.Authenticated() {
+			return helpers.Redirect(c, "/")
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.TwoFactorChallenge(data))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostTwoFactorChallenge() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		session, ok := auth.CurrentSession(c)
+		if !ok || session.Authenticated() {
+			return helpers.Redirect(c, "/login")
+		}
+
+		recovery, err := auth.VerifySecondFactor(ctx, repo, session.UserID, c.FormValue("code"))
+		if err != nil {
+			if !errors.Is(err, auth.ErrInvalidCode) {
+				return formError(c, http.StatusInternalServerError, err.Error(), "Could not verify your code")
+			}
+
+			attempts, err := repo.IncrementSessionMFAAttempts(ctx, session.ID)
+			if err != nil {
+				return formError(c, http.StatusInternalServerError, err.Error(), "Could not verify your code")
+			}
+			if attempts >= auth.MaxMFAAttempts {
+				if err := auth.EndSession(c, repo); err != nil {
+					return formError(c, http.StatusInternalServerError, err.Error(), "Could not verify your code")
+				}
+				return formError(c, http.StatusTooManyRequests, "Too many 2FA attempts for "+session.Username, "Too many attempts, please sign in again")
+			}
+
+			return formError(c, http.StatusUnauthorized, "Invalid 2FA code for "+session.Username, "Invalid authentication code")
+		}
+
+		if err := auth.StartSession(c, repo, session.UserID, true); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not sign you in")
+		}
+
+		if c.FormValue("remember") == "on" {
+			if err := auth.TrustDevice(c, repo, session.UserID); err != nil {
+				return formError(c, http.StatusInternalServerError, err.Error(), "Signed in, but this device could not be remembered")
+			}
+		}
+
+		// Send users who burned a recovery code to the page showing how many are left
+		if recovery {
+			return helpers.Redirect(c, "/account/2fa")
+		}
+
+		return helpers.Redirect(c, "/")
+	}
+}
+
+func AccountSettings() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.AccountSettings(data, session.Email, session.EmailVerified, session.TwoFactor))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func ResendVerification() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		if session.EmailVerified {
+			return formError(c, http.StatusConflict, "Email of "+session.Username+" is already verified", "Your email address is already verified")
+		}
+
+		if err := sendVerification(c.Request().Context(), session.UserID, session.Email); err != nil {
+			if errors.Is(err, auth.ErrRateLimited) {
+				return formError(c, http.StatusTooManyRequests, err.Error(), "Too many emails sent, please try again later")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not send the verification email")
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailSent("Verification email sent to " + session.Email))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// VerifyEmail asks for a click before the token is burned, like MagicLinkConfirm
+func VerifyEmail() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailLinkConfirm(data, "Verify email", "Continue to verify the address this link was sent to", "/account/verify/"+c.Param("token"), "Verify"))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostVerifyEmail() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		row, err := auth.ConsumeEmailToken(ctx, repo, c.Param("token"), auth.PurposeVerifyEmail)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Verification failed", "This verification link is invalid or has expired.", false))
+				return c.Blob(http.StatusBadRequest, "text/html; charset=utf-8", html)
+			}
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error verifying email"}, nil)
+		}
+
+		// Zero rows means the address changed after the link was sent, so it proves nothing anymore
+		rows, err := repo.VerifyUserEmail(ctx, repository.VerifyUserEmailParams{ID: row.UserID, Email: row.Email})
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error verifying email"}, nil)
+		}
+		if rows == 0 {
+			html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Verification failed", "This verification link is for an address the account no longer uses.", false))
+			return c.Blob(http.StatusBadRequest, "text/html; charset=utf-8", html)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Email verified", row.Email+" is verified", true))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func ChangeEmail() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
+
+		email := strings.ToLower(strings.TrimSpace(c.FormValue("email")))
+		if _, err := mail.ParseAddress(email); err != nil || len(email) > 30 {
+			return formError(c, http.StatusBadRequest, "Invalid email address", "Please enter a valid email address")
+		}
+
+		if err := requestEmailChange(c.Request().Context(), repo, audit.FromRequest(c), session.UserID, session.Email, email); err != nil {
+			return emailChangeError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailSent("Confirm the change with the link sent to " + email))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// ConfirmEmailChange asks for a click before the token is burned, like MagicLinkConfirm
+func ConfirmEmailChange() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailLinkConfirm(data, "Change email", "Continue to switch your account to the address this link was sent to", "/account/email/confirm/"+c.Param("token"), "Change email"))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func PostConfirmEmailChange() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		row, err := auth.ConsumeEmailToken(ctx, repo, c.Param("token"), auth.PurposeChangeEmail)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Email change failed", "This confirmation link is invalid or has expired.", false))
+				return c.Blob(http.StatusBadRequest, "text/html; charset=utf-8", html)
+			}
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error changing email"}, nil)
+		}
+
+		var user repository.ChangeUserEmailRow
+		err = inTransaction(ctx, func(repo *repository.Queries) error {
+			before, err := repo.GetUserByID(ctx, row.UserID)
+			if err != nil {
+				return err
+			}
+			user, err = repo.ChangeUserEmail(ctx, repository.ChangeUserEmailParams{ID: row.UserID, Email: row.Email})
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, repo, audit.FromRequest(c), audit.Change{Action: audit.ActionUserEmailChanged, EntityType: audit.EntityUser, EntityID: user.ID.String(), Before: before, After: user})
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Email change failed", row.Email+" is already used by another account.", false))
+				return c.Blob(http.StatusConflict, "text/html; charset=utf-8", html)
+			}
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error changing email"}, nil)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.EmailResult(data, "Email changed", "Your account now uses "+user.Email, true))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// sendVerification issues a verification token and queues its mail in one transaction
+func sendVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	return inTransaction(ctx, func(repo *repository.Queries) error {
+		token, err := auth.IssueEmailToken(ctx, repo, userID, email, auth.PurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		return auth.SendEmailVerification(ctx, repo, email, token)
+	})
+}
+
+var errEmailTaken = errors.New("email already in use")
+
+// requestEmailChange mails a confirmation link to the new address, the change only applies once it is followed
+func requestEmailChange(ctx context.Context, repo *repository.Queries, ac audit.Context, userID uuid.UUID, current string, email string) error {
+	if email == current {
+		return errEmailTaken
+	}
+
+	if _, err := repo.GetUserByEmail(ctx, email); err == nil {
+		return errEmailTaken
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	return inTransaction(ctx, func(repo *repository.Queries) error {
+		token, err := auth.IssueEmailToken(ctx, repo, userID, email, auth.PurposeChangeEmail)
+		if err != nil {
+			return err
+		}
+		err = audit.Record(ctx, repo, ac, audit.Change{
+			Action:     audit.ActionUserEmailRequested,
+			EntityType: audit.EntityUser,
+			EntityID:   userID.String(),
+			Before:     map[string]string{"email": current},
+			After:      map[string]string{"email": current, "pending_email": email},
+		})
+		if err != nil {
+			return err
+		}
+		return auth.SendEmailChange(ctx, repo, email, token)
+	})
+}
+
+func emailChangeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errEmailTaken):
+		return formError(c, http.StatusConflict, err.Error(), "This email address is already in use")
+	case errors.Is(err, auth.ErrRateLimited):
+		return formError(c, http.StatusTooManyRequests, err.Error(), "Too many emails sent, please try again later")
+	default:
+		return formError(c, http.StatusInternalServerError, err.Error(), "Could not send the confirmation email")
+	}
+}
+
+func TwoFactorSettings() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		ctx := c.Request().Context()
+		session, _ := auth.CurrentSession(c)
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		if session.TwoFactor {
+			remaining, err := repo.CountUnusedRecoveryCodes(ctx, session.UserID)
+			if err != nil {
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error loading two-factor settings"}, nil)
+			}
+
+			html := helpers.MustRenderHTMLContext(c.Request().Context(), account.TwoFactorSettings(data, remaining, session.IsAdmin))
+
+			return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+		}
+
+		enrollment, err := auth.BeginEnrollment(ctx, repo, session.UserID, session.Email)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error starting two-factor enrollment"}, nil)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.TwoFactorSetup(data, enrollment.Secret, enrollment.QRCode))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func ConfirmTwoFactor() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		session, _ := auth.CurrentSession(c)
+
+		var codes []string
+		err := inTransaction(ctx, func(repo *repository.Queries) (err error) {
+			codes, err = auth.ConfirmEnrollment(ctx, repo, session.UserID, c.FormValue("code"))
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCode) {
+				return formError(c, http.StatusUnauthorized, "Invalid 2FA enrollment code for "+session.Username, "Invalid authentication code")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not enable two-factor authentication")
+		}
+
+		// The user just proved possession of the device, so the session is upgraded in place
+		if err := auth.StartSession(c, repository.New(database.DB()), session.UserID, true); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not enable two-factor authentication")
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.RecoveryCodes(codes))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func RegenerateRecoveryCodes() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		session, _ := auth.CurrentSession(c)
+
+		var codes []string
+		err := inTransaction(ctx, func(repo *repository.Queries) (err error) {
+			if _, err = auth.VerifySecondFactor(ctx, repo, session.UserID, c.FormValue("code")); err != nil {
+				return err
+			}
+			codes, err = auth.RegenerateRecoveryCodes(ctx, repo, session.UserID)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCode) {
+				return formError(c, http.StatusUnauthorized, "Invalid 2FA code for "+session.Username, "Invalid authentication code")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not generate recovery codes")
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.RecoveryCodes(codes))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func DisableTwoFactor() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		session, _ := auth.CurrentSession(c)
+
+		if session.IsAdmin {
+			return formError(c, http.StatusForbidden, "Admin "+session.Username+" attempted to disable 2FA", "Admins must keep two-factor authentication enabled")
+		}
+
+		err := inTransaction(ctx, func(repo *repository.Queries) error {
+			if _, err := auth.VerifySecondFactor(ctx, repo, session.UserID, c.FormValue("code")); err != nil {
+				return err
+			}
+			return auth.DisableTwoFactor(ctx, repo, session.UserID)
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCode) {
+				return formError(c, http.StatusUnauthorized, "Invalid 2FA code for "+session.Username, "Invalid authentication code")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not disable two-factor authentication")
+		}
+
+		return helpers.Redirect(c, "/account/2fa")
+	}
+}
+
+func Passkeys() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
+
+		passkeys, err := repo.ListUserPasskeys(c.Request().Context(), session.UserID)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error loading passkeys"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), account.Passkeys(data, passkeys))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func BeginPasskeyRegistration() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
+
+		wa, err := auth.RelyingParty()
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Passkeys are not available"}, nil)
+		}
+
+		creation, err := auth.BeginPasskeyRegistration(c, wa, repo, session)
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not start passkey registration"}, nil)
+		}
+
+		return c.JSON(http.StatusOK, creation)
+	}
+}
+
+func FinishPasskeyRegistration() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
+
+		wa, err := auth.RelyingParty()
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Passkeys are not available"}, nil)
+		}
+
+		if err := auth.FinishPasskeyRegistration(c, wa, repo, session, c.QueryParam("name")); err != nil {
+			if errors.Is(err, auth.ErrNoCeremony) {
+				return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Passkey registration expired, please try again"}, nil)
+			}
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Could not register the passkey"}, nil)
+		}
+
+		return c.JSON(http.StatusOK, map[string]string{"redirect": "/account/passkeys"})
+	}
+}
+
+func DeletePasskey() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Passkey not found")
+		}
+
+		// Scoping the delete to the owner keeps users from removing each other's passkeys
+		rows, err := repo.DeletePasskey(c.Request().Context(), repository.DeletePasskeyParams{ID: id, UserID: session.UserID})
+		if err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not remove the passkey")
+		}
+		if rows == 0 {
+			return formError(c, http.StatusNotFound, "Passkey "+id.String()+" not found for "+session.Username, "Passkey not found")
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func BeginPasskeyLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		wa, err := auth.RelyingParty()
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Passkeys are not available"}, nil)
+		}
+
+		assertion, err := auth.BeginPasskeyLogin(c, wa, repo)
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not start passkey sign in"}, nil)
+		}
+
+		return c.JSON(http.StatusOK, assertion)
+	}
+}
+
+func FinishPasskeyLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		wa, err := auth.RelyingParty()
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Passkeys are not available"}, nil)
+		}
+
+		userID, err := auth.FinishPasskeyLogin(c, wa, repo)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrNoCeremony):
+				return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Passkey sign in expired, please try again"}, nil)
+			case errors.Is(err, auth.ErrPasskeyReplayed):
+				log.Warnf("Rejected passkey login: %v", err)
+				return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusUnauthorized, Message: err.Error(), UserMessage: "This passkey can not be used, please sign in another way"}, nil)
+			default:
+				return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusUnauthorized, Message: err.Error(), UserMessage: "Passkey sign in failed"}, nil)
+			}
+		}
+
+		// Passkeys require user verification, so they satisfy two-factor on their own
+		if err := auth.StartSession(c, repo, userID, true); err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not sign you in"}, nil)
+		}
+
+		return c.JSON(http.StatusOK, map[string]string{"redirect": "/"})
+	}
+}
+
+// inTransaction runs fn against a transaction scoped repository, committing only when fn succeeds
+func inTransaction(ctx context.Context, fn func(repo *repository.Queries) error) (err error) {
+	tx, err := database.DB().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.HandleTransaction(ctx, tx, &err)
+
+	return fn(repository.New(database.DB()).WithTx(tx))
+}
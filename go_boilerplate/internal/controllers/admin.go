@@ -0,0 +1,804 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/alerting"
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/audit"
+	"github.com/__username__/go_boilerplate/internal/auth"
+	//--
+	"github.com/__username__/go_boilerplate/internal/connections"
+	--//
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/tools"
+	"github.com/__username__/go_boilerplate/internal/webhooks"
+	"github.com/__username__/go_boilerplate/views/admin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+func AdminDashboard() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		users, err := repo.ListUsersWithTwoFactor(c.Request().Context())
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching users"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Dashboard(data, users))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// ResetUserTwoFactor removes a user's second factor and signs them out everywhere, for users who lost both device and recovery codes
+func ResetUserTwoFactor() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		session, _ := auth.CurrentSession(c)
+
+		uid, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Error parsing UUID"}, nil)
+		}
+
+		var user repository.GetUserWithTwoFactorRow
+		err = inTransaction(ctx, func(repo *repository.Queries) (err error) {
+			before, err := repo.GetUserWithTwoFactor(ctx, uid)
+			if err != nil {
+				return err
+			}
+			if err = auth.ResetTwoFactor(ctx, repo, uid); err != nil {
+				return err
+			}
+			if user, err = repo.GetUserWithTwoFactor(ctx, uid); err != nil {
+				return err
+			}
+			return audit.Record(ctx, repo, audit.FromRequest(c), audit.Change{Action: audit.ActionUserTwoFactorReset, EntityType: audit.EntityUser, EntityID: uid.String(), Before: before, After: user})
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: "User Not found for 2FA reset", UserMessage: "User not found"}, nil)
+			}
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error resetting two-factor authentication"}, nil)
+		}
+
+		log.Warnf("Admin %s reset two-factor authentication for %s", session.Username, user.Username)
+
+		csrf, err := helpers.CSRFToken(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.UserRow(repository.ListUsersWithTwoFactorRow(user), csrf))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func AdminWebhooks() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		repo := repository.New(database.DB())
+
+		filter := repository.ListWebhookEventsParams{Limit: 100}
+		if status := c.QueryParam("status"); status != "" {
+			filter.Status = &status
+		}
+		if provider := c.QueryParam("provider"); provider != "" {
+			filter.Provider = &provider
+		}
+
+		events, err := repo.ListWebhookEvents(c.Request().Context(), filter)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching webhook events"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Webhooks(data, events, c.QueryParam("status"), c.QueryParam("provider")))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func AdminWebhookEvent() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		event, err := loadWebhookEvent(c)
+		if err != nil {
+			return webhookEventError(c, err)
+		}
+
+		var headers http.Header
+		if err := json.Unmarshal(event.Headers, &headers); err != nil {
+			log.Warnf("Unreadable headers on webhook event %s: %v", event.ID, err)
+		}
+
+		// JSON payloads are indented for reading, anything else is shown as received
+		payload := string(event.Payload)
+		var indented bytes.Buffer
+		if json.Indent(&indented, event.Payload, "", "  ") == nil {
+			payload = indented.String()
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.WebhookEvent(data, event, headers, payload))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// AdminWebhookPayload downloads the payload byte for byte, as an attachment so a hostile body is never rendered
+func AdminWebhookPayload() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		event, err := loadWebhookEvent(c)
+		if err != nil {
+			return webhookEventError(c, err)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+event.Provider+"-"+event.ID.String()+`.bin"`)
+
+		return c.Blob(http.StatusOK, echo.MIMEOctetStream, event.Payload)
+	}
+}
+
+func ReplayWebhookEvent() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Webhook event not found")
+		}
+
+		if err := webhooks.Replay(c.Request().Context(), repository.New(database.DB()), id); err != nil {
+			if errors.Is(err, webhooks.ErrNotReplayable) {
+				return formError(c, http.StatusConflict, err.Error(), "The event is being processed right now, try again shortly")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not replay the event")
+		}
+
+		log.Infof("Admin %s replayed webhook event %s", session.Username, id)
+
+		return helpers.Redirect(c, "/admin/webhooks/"+id.String())
+	}
+}
+
+// loadWebhookEvent reads the event named by the id parameter, an id that is no UUID is simply not found
+func loadWebhookEvent(c echo.Context) (repository.WebhookEvent, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return repository.WebhookEvent{}, pgx.ErrNoRows
+	}
+	return repository.New(database.DB()).GetWebhookEvent(c.Request().Context(), id)
+}
+
+func webhookEventError(c echo.Context, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: "Webhook event " + c.Param("id") + " not found", UserMessage: "Webhook event not found"}, nil)
+	}
+	return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching webhook event"}, nil)
+}
+
+func AdminWebhookSubscriptions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		subscriptions, err := repository.New(database.DB()).ListWebhookSubscriptions(c.Request().Context())
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching webhook subscriptions"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.WebhookSubscriptions(data, subscriptions, webhooks.Events))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// CreateWebhookSubscription answers with the signing secret, the only time it is shown
+func CreateWebhookSubscription() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		target, err := url.Parse(c.FormValue("url"))
+		if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+			return formError(c, http.StatusBadRequest, "Invalid webhook URL "+c.FormValue("url"), "Enter an absolute http or https URL")
+		}
+
+		form, err := c.FormParams()
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Could not read the form")
+		}
+		events := form["events"]
+		for _, event := range events {
+			if !slices.Contains(webhooks.Events, event) {
+				return formError(c, http.StatusBadRequest, "Unknown webhook event "+event, "Unknown event "+event)
+			}
+		}
+
+		subscription, secret, err := webhooks.Subscribe(c.Request().Context(), repository.New(database.DB()), target.String(), events)
+		if err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not create the subscription")
+		}
+
+		log.Infof("Admin %s subscribed %s to webhooks", session.Username, subscription.Url)
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.WebhookSecret(subscription.Url, secret))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func AdminWebhookSubscription() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		repo := repository.New(database.DB())
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return webhookSubscriptionError(c, pgx.ErrNoRows)
+		}
+		subscription, err := repo.GetWebhookSubscription(ctx, id)
+		if err != nil {
+			return webhookSubscriptionError(c, err)
+		}
+		deliveries, err := repo.ListWebhookDeliveries(ctx, repository.ListWebhookDeliveriesParams{SubscriptionID: id, Limit: 100})
+		if err != nil {
+			return webhookSubscriptionError(c, err)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.WebhookSubscription(data, repository.ListWebhookSubscriptionsRow(subscription), deliveries))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// EnableWebhookSubscription turns a subscription disabled for failures back on, its pending deliveries resume
+func EnableWebhookSubscription() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Subscription not found")
+		}
+
+		enabled, err := repository.New(database.DB()).EnableWebhookSubscription(c.Request().Context(), id)
+		if err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not enable the subscription")
+		}
+		if enabled == 0 {
+			return formError(c, http.StatusNotFound, "Webhook subscription "+id.String()+" not found", "Subscription not found")
+		}
+
+		log.Infof("Admin %s enabled webhook subscription %s", session.Username, id)
+
+		return helpers.Redirect(c, "/admin/webhook-subscriptions/"+id.String())
+	}
+}
+
+func DeleteWebhookSubscription() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Subscription not found")
+		}
+
+		if _, err := repository.New(database.DB()).DeleteWebhookSubscription(c.Request().Context(), id); err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not delete the subscription")
+		}
+
+		log.Infof("Admin %s deleted webhook subscription %s", session.Username, id)
+
+		return helpers.Redirect(c, "/admin/webhook-subscriptions")
+	}
+}
+
+func RedeliverWebhook() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return formError(c, http.StatusBadRequest, err.Error(), "Delivery not found")
+		}
+
+		subscription, err := webhooks.Redeliver(c.Request().Context(), repository.New(database.DB()), id)
+		if err != nil {
+			if errors.Is(err, webhooks.ErrNotRedeliverable) {
+				return formError(c, http.StatusConflict, err.Error(), "The delivery is being sent right now, try again shortly")
+			}
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not redeliver the webhook")
+		}
+
+		log.Infof("Admin %s queued webhook delivery %s again", session.Username, id)
+
+		return helpers.Redirect(c, "/admin/webhook-subscriptions/"+subscription.String())
+	}
+}
+
+func webhookSubscriptionError(c echo.Context, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: "Webhook subscription " + c.Param("id") + " not found", UserMessage: "Subscription not found"}, nil)
+	}
+	return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching webhook subscription"}, nil)
+}
+
+func AdminAlerts(engine *alerting.Engine) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Alerts(data, engine.Active(), engine.Silences(), engine.Rules()))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// SilenceAlert mutes a rule, or only the alert with the posted labels, for the posted duration
+func SilenceAlert(engine *alerting.Engine) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		rule := c.FormValue("rule")
+		if !slices.Contains(engine.Rules(), rule) {
+			return formError(c, http.StatusBadRequest, "Unknown alert rule "+rule, "Unknown rule "+rule)
+		}
+		duration, err := time.ParseDuration(c.FormValue("duration"))
+		if err != nil || duration <= 0 {
+			return formError(c, http.StatusBadRequest, "Invalid silence duration "+c.FormValue("duration"), "Pick how long to silence the alert for")
+		}
+
+		silence := engine.Silence(rule, c.FormValue("labels"), duration, c.FormValue("comment"))
+
+		log.Infof("Admin %s silenced alert %s {%s} until %s", session.Username, silence.Rule, silence.Labels, silence.Until.Format(time.RFC3339))
+
+		return helpers.Redirect(c, "/admin/alerts")
+	}
+}
+
+func ExpireSilence(engine *alerting.Engine) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		if !engine.Expire(c.Param("id")) {
+			return formError(c, http.StatusNotFound, "Silence "+c.Param("id")+" not found", "Silence not found")
+		}
+
+		log.Infof("Admin %s expired alert silence %s", session.Username, c.Param("id"))
+
+		return helpers.Redirect(c, "/admin/alerts")
+	}
+}
+
+// AdminErrors lists what the default reporter wrote, otp hands out live tail passwords and may be nil
+func AdminErrors(otp func() string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query := admin.ErrorsQuery{
+			Level:  c.QueryParam("level"),
+			Since:  c.QueryParam("since"),
+			Until:  c.QueryParam("until"),
+			Route:  c.QueryParam("route"),
+			Text:   c.QueryParam("q"),
+			Groups: c.QueryParam("view") == "groups",
+		}
+
+		filter := helpers.ReportFilter{Level: helpers.SeverityType(query.Level), Route: query.Route, Text: query.Text, Limit: 500}
+		if query.Groups {
+			// Counts need every occurrence, not the latest page of them
+			filter.Limit = 20000
+		}
+		var err error
+		if filter.Since, err = parseFilterTime(query.Since); err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Invalid time " + query.Since}, nil)
+		}
+		if filter.Until, err = parseFilterTime(query.Until); err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Invalid time " + query.Until}, nil)
+		}
+
+		entries, err := helpers.DefaultReporter().Entries(filter)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error reading the error log"}, nil)
+		}
+		var groups []helpers.EntryGroup
+		if query.Groups {
+			groups = helpers.GroupEntries(entries)
+		}
+
+		password := ""
+		if otp != nil && !query.Groups {
+			password = otp()
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Errors(data, query, entries, groups, password))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// parseFilterTime reads a datetime-local input, in the server's time zone, empty is the zero time
+func parseFilterTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+}
+
+func AdminErrorEntry() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		entries, err := helpers.DefaultReporter().Entries(helpers.ReportFilter{ID: c.Param("id"), Limit: 1})
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error reading the error log"}, nil)
+		}
+		if len(entries) == 0 {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: "Report entry " + c.Param("id") + " not found", UserMessage: "Error not found, it may have been removed by retention"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.ErrorEntry(data, entries[0]))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// auditFilter reads the audit log filter form, shared by the page and the CSV export
+func auditFilter(c echo.Context) (admin.AuditQuery, repository.ListAuditEntriesParams, error) {
+	query := admin.AuditQuery{
+		Actor:      c.QueryParam("actor"),
+		Action:     c.QueryParam("action"),
+		EntityType: c.QueryParam("entity_type"),
+		EntityID:   c.QueryParam("entity_id"),
+		Since:      c.QueryParam("since"),
+		Until:      c.QueryParam("until"),
+	}
+
+	filter := repository.ListAuditEntriesParams{
+		Actor:      optional(query.Actor),
+		Action:     optional(query.Action),
+		EntityType: optional(query.EntityType),
+		EntityID:   optional(query.EntityID),
+	}
+	since, err := parseFilterTime(query.Since)
+	if err != nil {
+		return query, filter, err
+	}
+	until, err := parseFilterTime(query.Until)
+	if err != nil {
+		return query, filter, err
+	}
+	// Entries are stored in UTC
+	filter.Since = pgtype.Timestamp{Time: since.UTC(), Valid: !since.IsZero()}
+	filter.Until = pgtype.Timestamp{Time: until.UTC(), Valid: !until.IsZero()}
+	if before, err := strconv.ParseInt(c.QueryParam("before"), 10, 64); err == nil {
+		filter.BeforeSeq = &before
+	}
+	return query, filter, nil
+}
+
+// optional turns an empty filter field into NULL, which sqlc.narg filters read as match all
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func AdminAudit() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query, filter, err := auditFilter(c)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Invalid time in the filter"}, nil)
+		}
+		filter.Limit = 100
+
+		entries, err := repository.New(database.DB()).ListAuditEntries(c.Request().Context(), filter)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching the audit log"}, nil)
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Audit(data, query, entries, len(entries) == int(filter.Limit)))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// AdminAuditCSV streams every entry matching the filter, newest first
+func AdminAuditCSV() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		_, filter, err := auditFilter(c)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Invalid time in the filter"}, nil)
+		}
+		filter.Limit = 1000
+		repo := repository.New(database.DB())
+
+		// The first page is read before answering, so a failing query still gets an error page
+		entries, err := repo.ListAuditEntries(ctx, filter)
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching the audit log"}, nil)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-log-`+time.Now().UTC().Format("20060102T150405")+`.csv"`)
+		c.Response().WriteHeader(http.StatusOK)
+
+		w := csv.NewWriter(c.Response())
+		_ = w.Write([]string{"seq", "id", "occurred", "actor_id", "actor", "action", "entity_type", "entity_id", "diff", "ip", "user_agent", "request_id", "prev_hash", "hash"})
+		for len(entries) > 0 {
+			for _, entry := range entries {
+				_ = w.Write([]string{
+					strconv.FormatInt(entry.Seq, 10), entry.ID.String(), entry.Occurred.Format(time.RFC3339Nano),
+					entry.ActorID, entry.Actor, entry.Action, entry.EntityType, entry.EntityID, string(entry.Diff),
+					entry.Ip, entry.UserAgent, entry.RequestID, hex.EncodeToString(entry.PrevHash), hex.EncodeToString(entry.Hash),
+				})
+			}
+			if len(entries) < int(filter.Limit) {
+				break
+			}
+			filter.BeforeSeq = &entries[len(entries)-1].Seq
+			if entries, err = repo.ListAuditEntries(ctx, filter); err != nil {
+				// Too late for an error page, the truncated file is the best left to give
+				log.Errorf("Audit log export failed after seq %d: %v", *filter.BeforeSeq, err)
+				break
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+}
+
+// VerifyAudit checks the hash chain of the whole audit log
+func VerifyAudit() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+
+		result, err := audit.Verify(c.Request().Context(), repository.New(database.DB()))
+		if err != nil {
+			return formError(c, http.StatusInternalServerError, err.Error(), "Could not verify the audit log")
+		}
+		if result.Broken != nil {
+			log.Errorf("Admin %s found the audit log chain broken at seq %d", session.Username, result.Broken.Seq)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.AuditVerification(result.Checked, result.Broken))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// AdminJobs lists the jobs of scheduler, otp hands out live update passwords and may be nil
+func AdminJobs(scheduler *tools.Scheduler, otp func() string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		password := ""
+		if otp != nil {
+			password = otp()
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Jobs(data, scheduler.List(), password))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// AdminJobsJSON lists the jobs of scheduler for scripts, durations are in nanoseconds
+func AdminJobsJSON(scheduler *tools.Scheduler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, scheduler.List())
+	}
+}
+
+// AdminJob shows a job with its latest runs, from every instance
+func AdminJob(scheduler *tools.Scheduler, otp func() string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		info, err := scheduler.Info(c.Param("id"))
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: err.Error(), UserMessage: "Job not found"}, nil)
+		}
+
+		runs, err := repository.New(database.DB()).ListJobRuns(c.Request().Context(), repository.ListJobRunsParams{Job: info.ID, Limit: 50})
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching the job runs"}, nil)
+		}
+
+		password := ""
+		if otp != nil {
+			password = otp()
+		}
+
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.Job(data, info, runs, password))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// RunJob starts a run of a job right away, on this instance
+func RunJob(scheduler *tools.Scheduler) echo.HandlerFunc {
+	return jobAction(scheduler, "ran", scheduler.RunNow)
+}
+
+func PauseJob(scheduler *tools.Scheduler) echo.HandlerFunc {
+	return jobAction(scheduler, "paused", scheduler.Pause)
+}
+
+func ResumeJob(scheduler *tools.Scheduler) echo.HandlerFunc {
+	return jobAction(scheduler, "resumed", scheduler.Resume)
+}
+
+// jobAction applies action to the job in the path, then answers with the job's row for htmx
+// and its JSON otherwise
+func jobAction(scheduler *tools.Scheduler, verb string, action func(id string) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+		id := c.Param("id")
+
+		if err := action(id); err != nil {
+			return jobError(c, err)
+		}
+
+		log.Infof("Admin %s %s job %s", session.Username, verb, id)
+
+		return jobResponse(c, scheduler, id)
+	}
+}
+
+// RescheduleJob changes the schedule of a job to the spec in the form
+func RescheduleJob(scheduler *tools.Scheduler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, _ := auth.CurrentSession(c)
+		id := c.Param("id")
+
+		spec := strings.TrimSpace(c.FormValue("spec"))
+		if spec == "" {
+			return formError(c, http.StatusBadRequest, "Empty schedule for job "+id, "Enter a schedule")
+		}
+		if err := scheduler.Reschedule(id, spec); err != nil {
+			return jobError(c, err)
+		}
+		info, err := scheduler.Info(id)
+		if err != nil {
+			return jobError(c, err)
+		}
+		// A spec can parse and still never match, like the 30th of February
+		if info.Next.IsZero() && !info.Paused {
+			log.Warnf("Admin %s rescheduled job %s to %s, which never runs", session.Username, id, spec)
+		} else {
+			log.Infof("Admin %s rescheduled job %s to %s", session.Username, id, spec)
+		}
+
+		if helpers.IsHTMX(c) {
+			return helpers.Redirect(c, "/admin/jobs/"+id)
+		}
+		return c.JSON(http.StatusOK, info)
+	}
+}
+
+func jobResponse(c echo.Context, scheduler *tools.Scheduler, id string) error {
+	info, err := scheduler.Info(id)
+	if err != nil {
+		return jobError(c, err)
+	}
+	if !helpers.IsHTMX(c) {
+		return c.JSON(http.StatusOK, info)
+	}
+
+	html := helpers.MustRenderHTMLContext(c.Request().Context(), admin.JobRow(info))
+
+	return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+}
+
+func jobError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, tools.ErrJobNotFound):
+		return formError(c, http.StatusNotFound, err.Error(), "Job not found")
+	case errors.Is(err, tools.ErrSchedulerStopped):
+		return formError(c, http.StatusServiceUnavailable, err.Error(), "The scheduler is shutting down")
+	}
+	// Everything else the scheduler returns is a spec it could not parse
+	var jobErr *tools.JobError
+	if errors.As(err, &jobErr) {
+		return formError(c, http.StatusBadRequest, err.Error(), "Invalid schedule: "+jobErr.Err.Error())
+	}
+	return formError(c, http.StatusInternalServerError, err.Error(), "Could not change the job")
+}
+
+//--
+
+// JobsLive is a scheduler observer pushing the new row of a job to the admins watching the jobs
+func JobsLive(manager *connections.ConnectionManager) func(tools.JobInfo) {
+	return func(info tools.JobInfo) {
+		html, err := helpers.RenderHTML(admin.JobRow(info))
+		if err != nil {
+			log.Errorf("Failed to render job %s: %v", info.ID, err)
+			return
+		}
+		payload, err := json.Marshal(connections.HtmlData{Id: "job-" + info.ID, Html: string(html), Swap: "outerHTML"})
+		if err != nil {
+			log.Errorf("Failed to encode job %s: %v", info.ID, err)
+			return
+		}
+		manager.BroadcastToRoom(connections.RoomAdmin, connections.Event{Type: connections.EventHTML, Payload: payload})
+	}
+}
+
+// ErrorLogTail is a report sink pushing every entry to the admins watching the error log
+func ErrorLogTail(manager *connections.ConnectionManager) helpers.Sink {
+	return helpers.SinkFunc(func(entry helpers.Entry) error {
+		html, err := helpers.RenderHTML(admin.ErrorRow(entry))
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(connections.HtmlData{Id: "error-entries", Html: string(html)})
+		if err != nil {
+			return err
+		}
+		manager.BroadcastToRoom(connections.RoomAdmin, connections.Event{Type: connections.EventHTML, Payload: payload})
+		return nil
+	})
+}
+
+--//
@@ -5,19 +5,23 @@ import (
 	//===
 	"bytes"
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 
+	"github.com/__username__/go_boilerplate/internal/audit"
+	"github.com/__username__/go_boilerplate/internal/auth"
 	"github.com/__username__/go_boilerplate/internal/database"
 	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/__username__/go_boilerplate/internal/webhooks"
 	"github.com/__username__/go_boilerplate/views/components"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/gommon/log"
 
 	===//
 
 	"github.com/__username__/go_boilerplate/internal/apperrors"
-	"github.com/__username__/go_boilerplate/internal/config"
 
 	"github.com/__username__/go_boilerplate/internal/enums"
 	"github.com/__username__/go_boilerplate/internal/helpers"
@@ -29,12 +33,12 @@ import (
 
 func Examples() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		data := config.GetDefaultSite(c.Request())
-
-		data.CSRF = c.Get("csrf").(string)
-		data.Nonce = c.Get("nonce").(string)
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
 
-		html := helpers.MustRenderHTML(views.Examples(data))
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), views.Examples(data))
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
 	}
@@ -43,7 +47,7 @@ func Examples() echo.HandlerFunc {
 //===
 func FetchAllUsers() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		repo := repository.New(database.Pool())
+		repo := repository.New(database.DB())
 
 		users, err := repo.GetAllUsers(context.Background())
 
@@ -51,9 +55,12 @@ func FetchAllUsers() echo.HandlerFunc {
 			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching users"}, nil)
 		}
 
-		csrf := c.Get("csrf").(string)
+		csrf, err := helpers.CSRFToken(c)
+		if err != nil {
+			return pageError(c, err)
+		}
 
-		html := helpers.MustRenderHTML(components.UsersList(users, csrf))
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), components.UsersList(users, csrf))
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
 
@@ -62,15 +69,27 @@ func FetchAllUsers() echo.HandlerFunc {
 
 func AddNewUser() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		repo := repository.New(database.Pool())
+		ctx := c.Request().Context()
+		repo := repository.New(database.DB())
 
 		username := c.FormValue("username")
 		email := c.FormValue("email")
 
-		user, err := repo.CreateUser(context.Background(), repository.CreateUserParams{
-			ID:       uuid.New(),
-			Username: username,
-			Email:    email,
+		// Subscribers only hear about the user once it is committed
+		var user repository.CreateUserRow
+		err := inTransaction(ctx, func(repo *repository.Queries) (err error) {
+			user, err = repo.CreateUser(ctx, repository.CreateUserParams{
+				ID:       uuid.New(),
+				Username: username,
+				Email:    email,
+			})
+			if err != nil {
+				return err
+			}
+			if err = audit.Record(ctx, repo, audit.FromRequest(c), audit.Change{Action: audit.ActionUserCreated, EntityType: audit.EntityUser, EntityID: user.ID.String(), After: user}); err != nil {
+				return err
+			}
+			return webhooks.Dispatch(ctx, repo, webhooks.EventUserCreated, user)
 		})
 
 		if err != nil {
@@ -82,11 +101,14 @@ func AddNewUser() echo.HandlerFunc {
 			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error counting users"}, nil)
 		}
 
-		csrf := c.Get("csrf").(string)
+		csrf, err := helpers.CSRFToken(c)
+		if err != nil {
+			return pageError(c, err)
+		}
 
-		html := helpers.MustRenderHTML(components.UserItem(user.ID, user.Username, user.Email, csrf))
-		html = append(html, helpers.MustRenderHTML(components.UserCountPartial(strconv.FormatInt(int64(int(userCount)), 10), true))...)
-		html = append(html, helpers.MustRenderHTML(components.EmptyUserMessage(userCount == 0, true))...)
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), components.UserItem(user.ID, user.Username, user.Email, csrf))
+		html = append(html, helpers.MustRenderHTMLContext(c.Request().Context(), components.UserCountPartial(strconv.FormatInt(int64(int(userCount)), 10), true))...)
+		html = append(html, helpers.MustRenderHTMLContext(c.Request().Context(), components.EmptyUserMessage(userCount == 0, true))...)
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
 	}
@@ -94,7 +116,8 @@ func AddNewUser() echo.HandlerFunc {
 
 func ToggeleUserEmail() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		repo := repository.New(database.Pool())
+		repo := repository.New(database.DB())
+		session, _ := auth.CurrentSession(c)
 
 		id := c.Param("id")
 		log.Debugf("ID: %s", id)
@@ -104,6 +127,11 @@ func ToggeleUserEmail() echo.HandlerFunc {
 			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error fetching user"}, nil)
 		}
 
+		// Only the owner can change an address, and only through a link mailed to the new one
+		if uid != session.UserID {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: session.Username + " attempted to change the email of " + id, UserMessage: "You can only change your own email"}, nil)
+		}
+
 		user, err := repo.GetUserByID(context.Background(), uid)
 
 		if err != nil {
@@ -118,16 +146,11 @@ func ToggeleUserEmail() echo.HandlerFunc {
 
 		log.Debugf("New email: %s", newEmail)
 
-		updatedUser, err := repo.UpdateUserEmail(context.Background(), repository.UpdateUserEmailParams{
-			ID:    user.ID,
-			Email: newEmail,
-		})
-
-		if err != nil {
-			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error updating user"}, nil)
+		if err := requestEmailChange(context.Background(), repo, audit.FromRequest(c), user.ID, user.Email, newEmail); err != nil {
+			return emailChangeError(c, err)
 		}
 
-		html := helpers.MustRenderHTML(components.EmailPartial(updatedUser.ID.String(), updatedUser.Email))
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), components.EmailChangePendingPartial(user.ID.String(), user.Email, newEmail))
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
 
@@ -136,7 +159,7 @@ func ToggeleUserEmail() echo.HandlerFunc {
 
 func DeleteUser() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		repo := repository.New(database.Pool())
+		repo := repository.New(database.DB())
 
 		id := c.Param("id")
 		uid, err := uuid.Parse(id)
@@ -147,7 +170,25 @@ func DeleteUser() echo.HandlerFunc {
 			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error parsing UUID"}, nil)
 		}
 
-		rows, err := repo.DeleteUser(context.Background(), uid)
+		ctx := c.Request().Context()
+		var rows int64
+		err = inTransaction(ctx, func(repo *repository.Queries) (err error) {
+			user, err := repo.GetUserByID(ctx, uid)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			rows, err = repo.DeleteUser(ctx, uid)
+			if err != nil || rows == 0 {
+				return err
+			}
+			if err = audit.Record(ctx, repo, audit.FromRequest(c), audit.Change{Action: audit.ActionUserDeleted, EntityType: audit.EntityUser, EntityID: uid.String(), Before: user}); err != nil {
+				return err
+			}
+			return webhooks.Dispatch(ctx, repo, webhooks.EventUserDeleted, map[string]uuid.UUID{"id": uid})
+		})
 
 		if err != nil {
 			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error deleting user"}, nil)
@@ -169,10 +210,10 @@ func DeleteUser() echo.HandlerFunc {
 		buf.WriteString("") // Empty response removes the element
 
 		// 2. OOB: Update user count
-		buf.Write(helpers.MustRenderHTML(components.UserCountPartial(strconv.Itoa(int(userCount)), true)))
+		buf.Write(helpers.MustRenderHTMLContext(c.Request().Context(), components.UserCountPartial(strconv.Itoa(int(userCount)), true)))
 
 		// 3. OOB: Show empty message if no users left
-		buf.Write(helpers.MustRenderHTML(components.EmptyUserMessage(userCount == 0, true)))
+		buf.Write(helpers.MustRenderHTMLContext(c.Request().Context(), components.EmptyUserMessage(userCount == 0, true)))
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
 	}
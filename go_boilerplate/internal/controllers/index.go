@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 
+	"github.com/__username__/go_boilerplate/internal/apperrors"
 	"github.com/__username__/go_boilerplate/internal/config"
 	"github.com/__username__/go_boilerplate/internal/helpers"
 	"github.com/__username__/go_boilerplate/views"
@@ -11,13 +12,31 @@ import (
 
 func Index() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		data := config.GetDefaultSite(c.Request())
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
 
-		data.CSRF = c.Get("csrf").(string)
-		data.Nonce = c.Get("nonce").(string)
-
-		html := helpers.MustRenderHTML(views.Index(data))
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), views.Index(data))
 
 		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
 	}
 }
+
+// pageSite builds the site data for a full page, with the CSRF token and CSP nonce of the request
+func pageSite(c echo.Context) (config.Site, error) {
+	data := config.GetDefaultSite(c.Request())
+
+	var err error
+	if data.CSRF, err = helpers.CSRFToken(c); err != nil {
+		return data, err
+	}
+	data.Nonce, err = helpers.Nonce(c)
+
+	return data, err
+}
+
+// pageError reports a page that could not be rendered because the middleware chain is misconfigured
+func pageError(c echo.Context, err error) error {
+	return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error loading page"}, nil)
+}
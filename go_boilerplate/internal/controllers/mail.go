@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/__username__/go_boilerplate/internal/mail"
+	"github.com/__username__/go_boilerplate/views"
+	"github.com/labstack/echo/v4"
+)
+
+// MailPreviews lists the mail written by the file backend, only routed in development
+func MailPreviews() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		names, err := mail.NewFileSender(boot.Environment.MailDir).List()
+		if err != nil {
+			return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error listing mail"}, nil)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), views.MailPreviews(data, names))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func MailPreview() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, err := pageSite(c)
+		if err != nil {
+			return pageError(c, err)
+		}
+
+		msg, err := mail.NewFileSender(boot.Environment.MailDir).Open(c.Param("name"))
+		if err != nil {
+			return mailPreviewError(c, err)
+		}
+
+		html := helpers.MustRenderHTMLContext(c.Request().Context(), views.MailPreview(data, c.Param("name"), msg))
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+// MailPreviewHTML serves the HTML body as the recipient would see it, with embedded images as data: URIs.
+// It is routed outside the site CSP, its own policy forbids scripts and anything remote but images.
+func MailPreviewHTML() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		msg, err := mail.NewFileSender(boot.Environment.MailDir).Open(c.Param("name"))
+		if err != nil {
+			return mailPreviewError(c, err)
+		}
+
+		c.Response().Header().Set("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+		c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+
+		return c.Blob(http.StatusOK, "text/html; charset=utf-8", []byte(msg.PreviewHTML()))
+	}
+}
+
+func mailPreviewError(c echo.Context, err error) error {
+	if errors.Is(err, mail.ErrNotFound) {
+		return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: err.Error(), UserMessage: "Mail not found"}, nil)
+	}
+	return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Error reading mail"}, nil)
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var pool *pgxpool.Pool
+var (
+	pool *pgxpool.Pool
+	db   *Instrumented
+)
 
 func Setup(dsn string) {
 	var err error
@@ -20,6 +23,8 @@ func Setup(dsn string) {
 		log.Fatalf("Unable to connect to database: %v", err)
 	}
 
+	db = Instrument(pool)
+
 	err = pool.Ping(context.Background())
 	if err != nil {
 		log.Fatalf("Unable to ping database: %v", err)
@@ -72,6 +77,12 @@ func Pool() *pgxpool.Pool {
 	return pool
 }
 
+// DB returns the pool instrumented with query metrics, spans and the slow query log,
+// use it for repository.New and to begin transactions
+func DB() *Instrumented {
+	return db
+}
+
 // Close closes the database connection pool.
 func Close() {
 	if pool != nil {
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/monitoring"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/gommon/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlowQueryThreshold is how long a query may take before it is logged, zero disables the log
+var SlowQueryThreshold = 200 * time.Millisecond
+
+var tracer = otel.Tracer("github.com/__username__/go_boilerplate/internal/database")
+
+// Conn is what Instrumented wraps, satisfied by *pgxpool.Pool and pgx.Tx
+type Conn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Instrumented implements repository.DBTX, measuring every query under its sqlc name.
+// Transactions from Begin are instrumented too, so repository.Queries.WithTx keeps the metrics.
+type Instrumented struct {
+	conn Conn
+}
+
+func Instrument(conn Conn) *Instrumented {
+	return &Instrumented{conn: conn}
+}
+
+func (db *Instrumented) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return instrumentedExec(ctx, db.conn, sql, args)
+}
+
+func (db *Instrumented) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return instrumentedQuery(ctx, db.conn, sql, args)
+}
+
+func (db *Instrumented) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return instrumentedQueryRow(ctx, db.conn, sql, args)
+}
+
+func (db *Instrumented) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx}, nil
+}
+
+// instrumentedTx overrides the query methods of a transaction, commit and rollback pass through
+type instrumentedTx struct {
+	pgx.Tx
+}
+
+func (tx *instrumentedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return instrumentedExec(ctx, tx.Tx, sql, args)
+}
+
+func (tx *instrumentedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return instrumentedQuery(ctx, tx.Tx, sql, args)
+}
+
+func (tx *instrumentedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return instrumentedQueryRow(ctx, tx.Tx, sql, args)
+}
+
+// Begin starts a savepoint, which stays instrumented like its parent
+func (tx *instrumentedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	nested, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: nested}, nil
+}
+
+func instrumentedExec(ctx context.Context, conn Conn, sql string, args []any) (pgconn.CommandTag, error) {
+	ctx, done := observe(ctx, sql, args)
+	tag, err := conn.Exec(ctx, sql, args...)
+	done(err)
+	return tag, err
+}
+
+func instrumentedQuery(ctx context.Context, conn Conn, sql string, args []any) (pgx.Rows, error) {
+	ctx, done := observe(ctx, sql, args)
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	// Rows stream in while they are read, so the query ends when they are closed
+	return &instrumentedRows{Rows: rows, done: done}, nil
+}
+
+func instrumentedQueryRow(ctx context.Context, conn Conn, sql string, args []any) pgx.Row {
+	ctx, done := observe(ctx, sql, args)
+	return &instrumentedRow{row: conn.QueryRow(ctx, sql, args...), done: done}
+}
+
+type instrumentedRows struct {
+	pgx.Rows
+	once sync.Once
+	done func(error)
+}
+
+func (r *instrumentedRows) Close() {
+	r.Rows.Close()
+	r.once.Do(func() { r.done(r.Rows.Err()) })
+}
+
+type instrumentedRow struct {
+	row  pgx.Row
+	done func(error)
+}
+
+func (r *instrumentedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	r.done(err)
+	return err
+}
+
+// observe starts measuring a query, the returned func records its outcome
+func observe(ctx context.Context, sql string, args []any) (context.Context, func(error)) {
+	name := QueryName(sql)
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", name),
+	))
+
+	return ctx, func(err error) {
+		elapsed := time.Since(start)
+		monitoring.RecordDBQueryLatency(name, start)
+		monitoring.AddTiming(ctx, "db", elapsed)
+
+		// No rows is an answer, not a failure
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			monitoring.RecordDBQueryError(name)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		if SlowQueryThreshold > 0 && elapsed >= SlowQueryThreshold {
+			log.Warnf("Slow query %s took %s with args %s", name, elapsed.Round(time.Millisecond), redact(args))
+		}
+	}
+}
+
+// QueryName returns X from the "-- name: X :kind" comment sqlc puts in front of every query
+func QueryName(sql string) string {
+	header, ok := strings.CutPrefix(sql, "-- name: ")
+	if !ok {
+		return "unnamed"
+	}
+	header, _, _ = strings.Cut(header, "\n")
+	if name, _, _ := strings.Cut(header, " "); name != "" {
+		return name
+	}
+	return "unnamed"
+}
+
+// redact describes args by type only, values can be passwords, tokens or personal data
+func redact(args []any) string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = fmt.Sprintf("$%d=%T", i+1, arg)
+	}
+	return "[" + strings.Join(types, " ") + "]"
+}
@@ -0,0 +1,133 @@
+// instrument_test.go
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryMetrics reads the latency sample count and error count recorded for a query
+func queryMetrics(t *testing.T, query string) (runs uint64, failures float64) {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "query_type" || label.GetValue() != query {
+					continue
+				}
+				switch family.GetName() {
+				case "db_query_duration_seconds":
+					runs = metric.GetHistogram().GetSampleCount()
+				case "db_query_errors_total":
+					failures = metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return runs, failures
+}
+
+func TestQueryName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{sql: "-- name: GetRateLimit :one\nSELECT tat FROM rate_limits", want: "GetRateLimit"},
+		{sql: "-- name: DeleteExpiredRateLimits :execrows\n", want: "DeleteExpiredRateLimits"},
+		{sql: "-- name: Bare", want: "Bare"},
+		{sql: "SELECT 1", want: "unnamed"},
+		{sql: "-- name: \nSELECT 1", want: "unnamed"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, QueryName(tt.sql), tt.sql)
+	}
+}
+
+func TestInstrumented_RecordsQueries(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+	repo := repository.New(Instrument(mock))
+
+	mock.ExpectExec("DELETE FROM rate_limits").WithArgs(pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectQuery("SELECT tat FROM rate_limits").WithArgs("missing").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("SELECT id, url, events").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "url", "events", "active", "failures", "disabled_reason", "created"}).
+			AddRow(uuid.New(), "https://example.com", []string{}, true, int32(0), "", time.Now()).
+			RowError(0, errors.New("connection reset")))
+
+	removed, err := repo.DeleteExpiredRateLimits(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+
+	_, err = repo.GetRateLimit(context.Background(), "missing")
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = repo.ListWebhookSubscriptions(context.Background())
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	runs, failures := queryMetrics(t, "DeleteExpiredRateLimits")
+	assert.Equal(t, uint64(1), runs)
+	assert.Zero(t, failures)
+
+	// No rows is an answer, not a failure
+	runs, failures = queryMetrics(t, "GetRateLimit")
+	assert.Equal(t, uint64(1), runs)
+	assert.Zero(t, failures)
+
+	runs, failures = queryMetrics(t, "ListWebhookSubscriptions")
+	assert.Equal(t, uint64(1), runs)
+	assert.Equal(t, float64(1), failures)
+}
+
+func TestInstrumented_WithTx(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+	db := Instrument(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM webhook_subscriptions").WithArgs(pgxmock.AnyArg()).
+		WillReturnError(errors.New("deadlock detected"))
+	mock.ExpectRollback()
+
+	tx, err := db.Begin(context.Background())
+	require.NoError(t, err)
+	_, err = repository.New(db).WithTx(tx).DeleteWebhookSubscription(context.Background(), uuid.New())
+	require.Error(t, err)
+	require.NoError(t, tx.Rollback(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	runs, failures := queryMetrics(t, "DeleteWebhookSubscription")
+	assert.Equal(t, uint64(1), runs)
+	assert.Equal(t, float64(1), failures)
+}
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	got := redact([]any{"hunter2", int32(4), nil})
+	assert.Equal(t, "[$1=string $2=int32 $3=<nil>]", got)
+	assert.NotContains(t, got, "hunter2")
+}
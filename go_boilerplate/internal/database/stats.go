@@ -0,0 +1,67 @@
+package database
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports pgxpool statistics, read fresh from the pool on every scrape
+type PoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquired      *prometheus.Desc
+	idle          *prometheus.Desc
+	constructing  *prometheus.Desc
+	total         *prometheus.Desc
+	max           *prometheus.Desc
+	acquires      *prometheus.Desc
+	acquireTime   *prometheus.Desc
+	emptyAcquires *prometheus.Desc
+	emptyWait     *prometheus.Desc
+	canceled      *prometheus.Desc
+}
+
+// NewPoolCollector describes pool, register it with prometheus.MustRegister after Setup
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{
+		stat:          pool.Stat,
+		acquired:      prometheus.NewDesc("db_pool_acquired_connections", "Connections currently in use", nil, nil),
+		idle:          prometheus.NewDesc("db_pool_idle_connections", "Connections currently idle", nil, nil),
+		constructing:  prometheus.NewDesc("db_pool_constructing_connections", "Connections currently being established", nil, nil),
+		total:         prometheus.NewDesc("db_pool_total_connections", "Connections open or being established", nil, nil),
+		max:           prometheus.NewDesc("db_pool_max_connections", "Maximum size of the pool", nil, nil),
+		acquires:      prometheus.NewDesc("db_pool_acquires_total", "Successful connection acquires", nil, nil),
+		acquireTime:   prometheus.NewDesc("db_pool_acquire_seconds_total", "Total time spent acquiring connections", nil, nil),
+		emptyAcquires: prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that had to wait because the pool was empty", nil, nil),
+		emptyWait:     prometheus.NewDesc("db_pool_empty_acquire_wait_seconds_total", "Total time acquires waited for a connection to free up", nil, nil),
+		canceled:      prometheus.NewDesc("db_pool_canceled_acquires_total", "Acquires canceled by their context while waiting", nil, nil),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.constructing
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquires
+	ch <- c.acquireTime
+	ch <- c.emptyAcquires
+	ch <- c.emptyWait
+	ch <- c.canceled
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireTime, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyWait, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
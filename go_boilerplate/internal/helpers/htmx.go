@@ -0,0 +1,23 @@
+package helpers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IsHTMX reports whether the request was issued by htmx
+func IsHTMX(c echo.Context) bool {
+	return c.Request().Header.Get("HX-Request") == "true"
+}
+
+// Redirect sends the client to url, using HX-Redirect for htmx requests
+// so the browser performs a full navigation instead of swapping the page in
+func Redirect(c echo.Context, url string) error {
+	if IsHTMX(c) {
+		c.Response().Header().Set("HX-Redirect", url)
+		return c.NoContent(http.StatusOK)
+	}
+
+	return c.Redirect(http.StatusSeeOther, url)
+}
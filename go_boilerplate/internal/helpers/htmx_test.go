@@ -0,0 +1,69 @@
+// htmx_test.go
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHTMX(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"htmx request", "true", true},
+		{"plain request", "", false},
+		{"unexpected value", "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("HX-Request", tt.header)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			assert.Equal(t, tt.want, IsHTMX(c))
+		})
+	}
+}
+
+func TestRedirect_PlainRequest(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	require.NoError(t, Redirect(c, "/account"))
+
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/account", rec.Header().Get("Location"))
+	assert.Empty(t, rec.Header().Get("HX-Redirect"))
+}
+
+func TestRedirect_HTMXRequest(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	require.NoError(t, Redirect(c, "/account"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/account", rec.Header().Get("HX-Redirect"))
+	assert.Empty(t, rec.Header().Get("Location"))
+}
@@ -0,0 +1,44 @@
+// network_test.go
+package helpers
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	t.Parallel()
+
+	prefixes, err := ParseCIDRs(" 10.1.2.3/8, 127.0.0.1 ,,::1,::ffff:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("::1/128"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	}, prefixes)
+
+	empty, err := ParseCIDRs("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, value := range []string{"localhost", "10.0.0.0/33", "10.0.0.1,nope"} {
+		_, err := ParseCIDRs(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestContainsAddr(t *testing.T) {
+	t.Parallel()
+
+	prefixes := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	assert.True(t, ContainsAddr(prefixes, netip.MustParseAddr("10.20.30.40")))
+	assert.True(t, ContainsAddr(prefixes, netip.MustParseAddr("::ffff:10.20.30.40")))
+	assert.True(t, ContainsAddr(prefixes, netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, ContainsAddr(prefixes, netip.MustParseAddr("11.0.0.1")))
+	assert.False(t, ContainsAddr(nil, netip.MustParseAddr("10.0.0.1")))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 
+	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/a-h/templ"
 )
 
@@ -20,9 +21,16 @@ func RenderHTML(page templ.Component) ([]byte, error) {
 }
 
 func MustRenderHTML(page templ.Component) []byte {
+	return MustRenderHTMLContext(context.Background(), page)
+}
+
+// MustRenderHTMLContext renders with the request context, which adds the time spent to its Server-Timing
+func MustRenderHTMLContext(ctx context.Context, page templ.Component) []byte {
+	defer monitoring.Time(ctx, "render")()
+
 	buf := bytes.NewBuffer(nil)
 
-	err := page.Render(context.Background(), buf)
+	err := page.Render(ctx, buf)
 
 	if err != nil {
 		panic(err)
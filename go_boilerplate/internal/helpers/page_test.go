@@ -7,7 +7,9 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -92,6 +94,23 @@ func TestMustRenderHTML_Success(t *testing.T) {
 }
 
 // ——————— CONCURRENT SAFETY (1000 GOROUTINES) ———————
+func TestMustRenderHTMLContext_RecordsRenderTiming(t *testing.T) {
+	t.Parallel()
+
+	ctx, timings := monitoring.WithTimings(context.Background())
+	comp := fakeComponent{
+		renderFunc: func(got context.Context, w Writer) error {
+			assert.Same(t, timings, monitoring.TimingsFrom(got))
+			_, err := w.Write([]byte("<h1>timed</h1>"))
+			return err
+		},
+	}
+
+	html := MustRenderHTMLContext(ctx, comp)
+	assert.Equal(t, []byte("<h1>timed</h1>"), html)
+	assert.Contains(t, timings.Header(time.Second), "render;dur=")
+}
+
 func TestRenderHTML_ConcurrentSafety(t *testing.T) {
 	t.Parallel()
 
@@ -1,12 +1,19 @@
 package helpers
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 )
 
@@ -31,48 +38,141 @@ var SeverityLevels = &Severity{
 	DEBUG: "DEBUG",
 }
 
-type Reporter struct {
-	lock     sync.Mutex
-	filePath string
-	file     *os.File
-	closed   bool
+// AtLeast reports whether s is as severe as min or more, unknown levels rank lowest
+func (s SeverityType) AtLeast(min SeverityType) bool {
+	return s.rank() >= min.rank()
 }
 
-func NewReporter(filePath string) (*Reporter, error) {
-	// Create directory structure if it doesn't exist
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, err
+func (s SeverityType) rank() int {
+	switch s {
+	case SeverityLevels.PANIC:
+		return 4
+	case SeverityLevels.ERROR:
+		return 3
+	case SeverityLevels.WARN:
+		return 2
+	case SeverityLevels.INFO:
+		return 1
+	default:
+		return 0
 	}
+}
 
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+var ErrReporterClosed = errors.New("reporter is closed")
+
+// Entry is one report, written as a line of JSON
+type Entry struct {
+	ID        string       `json:"id"`
+	Time      time.Time    `json:"time"`
+	Level     SeverityType `json:"level"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Method    string       `json:"method,omitempty"`
+	Route     string       `json:"route,omitempty"`
+	Path      string       `json:"path,omitempty"`
+	Status    int          `json:"status,omitempty"`
+	Stack     string       `json:"stack,omitempty"`
+}
+
+// RequestEntry returns an entry describing the request of c, with the request ID set by the RequestID middleware
+func RequestEntry(c echo.Context, level SeverityType, message string, status int) Entry {
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
 	}
+	return Entry{
+		Level:     level,
+		Message:   message,
+		RequestID: requestID,
+		Method:    c.Request().Method,
+		Route:     c.Path(),
+		Path:      c.Request().URL.Path,
+		Status:    status,
+	}
+}
+
+// Sink receives every entry of a Reporter, implementations must be safe for concurrent use
+type Sink interface {
+	Write(entry Entry) error
+}
 
-	return &Reporter{
-		filePath: filePath,
-		file:     file,
-	}, nil
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(entry Entry) error
+
+func (f SinkFunc) Write(entry Entry) error {
+	return f(entry)
+}
+
+// Reporter fans entries out to its sinks, an error from one does not keep the others from writing
+type Reporter struct {
+	lock   sync.RWMutex
+	sinks  []Sink
+	closed bool
 }
 
-// Report writes a log entry to the file
+func NewReporter(sinks ...Sink) *Reporter {
+	return &Reporter{sinks: sinks}
+}
+
+var defaultReporter = NewReporter()
+
+// SetDefaultReporter replaces the reporter used when nil is passed to apperrors
+func SetDefaultReporter(r *Reporter) {
+	defaultReporter = r
+}
+
+// DefaultReporter returns the reporter set with SetDefaultReporter, one without sinks until then
+func DefaultReporter() *Reporter {
+	return defaultReporter
+}
+
+// Report writes a log entry without request details
 func (r *Reporter) Report(level SeverityType, message string) error {
-	// PRE-COMPUTE BEFORE LOCK
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	entry := fmt.Sprintf("%s [%s] %s\n", timestamp, level, message)
+	return r.Write(Entry{Level: level, Message: message})
+}
+
+// Write stamps entry with an ID and the current time unless it has them and hands it to every sink
+func (r *Reporter) Write(entry Entry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.closed {
+		return ErrReporterClosed
+	}
+	var errs []error
+	for _, sink := range r.sinks {
+		errs = append(errs, sink.Write(entry))
+	}
+	return errors.Join(errs...)
+}
 
+// AddSink adds a sink after construction, for parts of the app that start after the reporter
+func (r *Reporter) AddSink(sink Sink) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	r.sinks = append(r.sinks, sink)
+}
 
-	if r.closed {
-		return fmt.Errorf("reporter is closed")
+// Cleanup applies the retention of the sinks that keep old segments, meant to be scheduled with tools.Func
+func (r *Reporter) Cleanup() {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, sink := range r.sinks {
+		if cleaner, ok := sink.(interface{ Cleanup() }); ok {
+			cleaner.Cleanup()
+		}
 	}
-	_, err := r.file.WriteString(entry)
-	return err
 }
 
-// Close the report file
+// Close closes the sinks that hold resources, later reports fail with ErrReporterClosed
 func (r *Reporter) Close() error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -81,31 +181,237 @@ func (r *Reporter) Close() error {
 		return nil
 	}
 	r.closed = true
-	return r.file.Close()
+
+	var errs []error
+	for _, sink := range r.sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriterSink writes entries as JSON lines to W, like os.Stdout for a log collector
+type WriterSink struct {
+	lock sync.Mutex
+	W    io.Writer
 }
 
-func (r *Reporter) Cleanup(frequency time.Duration) {
-	// Remove old report files in the report directory
-	files, err := os.ReadDir(r.filePath)
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{W: w}
+}
+
+func (s *WriterSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
 	if err != nil {
-		log.Errorf("Failed to read report directory: %v", err)
-		return
+		return err
 	}
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.W.Write(append(line, '\n'))
+	return err
+}
+
+// RotateOptions tune a FileSink, zero values disable the matching rotation or retention
+type RotateOptions struct {
+	// MaxSize rotates the file once a write would grow it past this many bytes
+	MaxSize int64
+	// Every rotates the file when the clock crosses a multiple of it, 24h starts a file per UTC day
+	Every time.Duration
+	// Retention is how long Cleanup keeps rotated segments
+	Retention time.Duration
+}
+
+// FileSink appends JSON lines to a file and rotates it into gzipped segments next to it,
+// errors.log becomes errors-20060102T150405.000.log.gz
+type FileSink struct {
+	lock    sync.Mutex
+	path    string
+	options RotateOptions
+	file    *os.File
+	size    int64
+	opened  time.Time
+	closed  bool
+	// compressing tracks the segments being gzipped, Close waits for them
+	compressing sync.WaitGroup
+
+	Now func() time.Time
+}
+
+func NewFileSink(path string, options RotateOptions) (*FileSink, error) {
+	s := &FileSink{path: path, options: options, Now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open appends to the current file, an existing one counts from its last write so a restart does not postpone rotation
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.opened = s.Now()
+	if s.size > 0 {
+		s.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (s *FileSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrReporterClosed
+	}
+	if s.due(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			return err
 		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) due(incoming int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.options.MaxSize > 0 && s.size+incoming > s.options.MaxSize {
+		return true
+	}
+	return s.options.Every > 0 && !s.Now().Truncate(s.options.Every).Equal(s.opened.Truncate(s.options.Every))
+}
+
+// rotate moves the current file aside and gzips it in the background, writes go on to a fresh file meanwhile
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(s.path)
+	segment := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(s.path, ext), s.Now().UTC().Format("20060102T150405.000"), ext)
+	if err := os.Rename(s.path, segment); err != nil {
+		return err
+	}
+
+	s.compressing.Add(1)
+	go func() {
+		defer s.compressing.Done()
+		if err := compress(segment); err != nil {
+			log.Errorf("Failed to compress report segment %s: %v", segment, err)
+		}
+	}()
+
+	return s.open()
+}
+
+func compress(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
 
-		fileInfo, err := file.Info()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
 		if err != nil {
-			log.Errorf("Failed to get file info: %v", err)
+			_ = out.Close()
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Segments returns the rotated segments of the sink, oldest first since their names sort by time
+func (s *FileSink) Segments() ([]string, error) {
+	ext := filepath.Ext(s.path)
+	prefix := filepath.Base(strings.TrimSuffix(s.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, err
+	}
+	var segments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz") {
+			segments = append(segments, filepath.Join(filepath.Dir(s.path), name))
+		}
+	}
+	return segments, nil
+}
+
+// Cleanup removes the segments last written before the retention window, the current file is never removed
+func (s *FileSink) Cleanup() {
+	if s.options.Retention <= 0 {
+		return
+	}
+
+	segments, err := s.Segments()
+	if err != nil {
+		log.Errorf("Failed to list report segments: %v", err)
+		return
+	}
+	cutoff := s.Now().Add(-s.options.Retention)
+	for _, segment := range segments {
+		info, err := os.Stat(segment)
+		if err != nil || !info.ModTime().Before(cutoff) {
 			continue
 		}
-		if time.Since(fileInfo.ModTime()) > frequency {
-			err := os.Remove(filepath.Join(r.filePath, file.Name()))
-			if err != nil {
-				log.Errorf("Failed to remove old report file: %v", err)
-			}
+		if err := os.Remove(segment); err != nil {
+			log.Errorf("Failed to remove old report segment: %v", err)
 		}
 	}
 }
+
+// Close closes the file once the segments still being compressed are done
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.compressing.Wait()
+	return s.file.Close()
+}
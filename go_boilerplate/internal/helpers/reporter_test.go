@@ -3,7 +3,12 @@ package helpers
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,40 +31,49 @@ func createFileWithModTime(tb testing.TB, path string, modTime time.Time) {
 	require.NoError(tb, os.Chtimes(path, modTime, modTime))
 }
 
-func parseLogLine(tb testing.TB, line string) (time.Time, SeverityType, string) {
+func readEntries(tb testing.TB, r io.Reader) []Entry {
 	tb.Helper()
-	line = strings.TrimSpace(line)
-	if line == "" {
-		tb.Fatal("empty log line")
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(tb, json.Unmarshal(scanner.Bytes(), &entry), scanner.Text())
+		entries = append(entries, entry)
 	}
+	require.NoError(tb, scanner.Err())
+	return entries
+}
 
-	parts := strings.SplitN(line, " [", 2)
-	require.Len(tb, parts, 2)
+func newTestFileSink(tb testing.TB, options RotateOptions) (*FileSink, string) {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "logs", "app.log")
+	s, err := NewFileSink(path, options)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = s.Close() })
+	return s, path
+}
+
+type failingSink struct{}
 
-	ts, err := time.ParseInLocation("2006-01-02 15:04:05", parts[0], time.Local)
-	require.NoError(tb, err, "failed to parse timestamp")
+func (failingSink) Write(Entry) error { return errors.New("sink down") }
 
-	levelAndMsg := strings.SplitN(parts[1], "] ", 2)
-	require.Len(tb, levelAndMsg, 2)
+func TestSeverityType_AtLeast(t *testing.T) {
+	t.Parallel()
 
-	level := SeverityType(strings.TrimSuffix(levelAndMsg[0], "]"))
-	return ts, level, levelAndMsg[1]
+	assert.True(t, SeverityLevels.PANIC.AtLeast(SeverityLevels.ERROR))
+	assert.True(t, SeverityLevels.ERROR.AtLeast(SeverityLevels.ERROR))
+	assert.False(t, SeverityLevels.WARN.AtLeast(SeverityLevels.ERROR))
+	assert.False(t, SeverityType("LOUD").AtLeast(SeverityLevels.INFO), "unknown levels rank lowest")
 }
 
-func TestNewReporter_CreatesFileAndDir(t *testing.T) {
+func TestNewFileSink_CreatesFileAndDir(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	path := filepath.Join(dir, "logs", "app.log")
-
-	r, err := NewReporter(path)
-	require.NoError(t, err)
-	defer func() { _ = r.Close() }()
+	_, path := newTestFileSink(t, RotateOptions{})
 	assert.FileExists(t, path)
 }
 
-func TestReporter_Report_WritesCorrectFormat(t *testing.T) {
+func TestReporter_Report_WritesJSONLines(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
 
 	testCases := []struct {
 		level SeverityType
@@ -73,47 +87,55 @@ func TestReporter_Report_WritesCorrectFormat(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		tc := tc
 		t.Run(string(tc.level), func(t *testing.T) {
 			t.Parallel()
-			path := filepath.Join(dir, fmt.Sprintf("log_%s.log", tc.level))
-			r, err := NewReporter(path)
-			require.NoError(t, err)
-			defer func() { _ = r.Close() }()
+			sink, path := newTestFileSink(t, RotateOptions{})
+			r := NewReporter(sink)
 
 			require.NoError(t, r.Report(tc.level, tc.msg))
 
-			content, _ := os.ReadFile(path)
-			line := strings.TrimSpace(string(content))
-			ts, level, msg := parseLogLine(t, line)
-
-			assert.Equal(t, tc.level, level)
-			assert.Equal(t, tc.msg, msg)
+			file, err := os.Open(path)
+			require.NoError(t, err)
+			defer func() { _ = file.Close() }()
+			entries := readEntries(t, file)
+			require.Len(t, entries, 1)
 
-			// FINAL FIX: Compare in the same location
-			nowInLogLocation := time.Now().In(ts.Location())
-			assert.WithinDuration(t, nowInLogLocation, ts, 3*time.Second, "timestamp should be recent")
+			assert.Equal(t, tc.level, entries[0].Level)
+			assert.Equal(t, tc.msg, entries[0].Message)
+			assert.WithinDuration(t, time.Now(), entries[0].Time, 3*time.Second, "timestamp should be recent")
 		})
 	}
 }
 
+func TestReporter_Write_FansOutToEverySink(t *testing.T) {
+	t.Parallel()
+
+	var first, second bytes.Buffer
+	r := NewReporter(NewWriterSink(&first), failingSink{}, NewWriterSink(&second))
+
+	err := r.Write(Entry{Level: SeverityLevels.ERROR, Message: "boom", Stack: "goroutine 1", RequestID: "abc"})
+	assert.ErrorContains(t, err, "sink down")
+
+	for _, buf := range []*bytes.Buffer{&first, &second} {
+		entries := readEntries(t, buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "goroutine 1", entries[0].Stack)
+		assert.Equal(t, "abc", entries[0].RequestID)
+	}
+}
+
 func TestReporter_Report_ConcurrentSafety(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	path := filepath.Join(dir, "race.log")
-	r, err := NewReporter(path)
-	require.NoError(t, err)
-	defer func() { _ = r.Close() }()
+	sink, path := newTestFileSink(t, RotateOptions{})
+	r := NewReporter(sink)
 
 	var wg sync.WaitGroup
-	for i := 0; i < 100; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for j := 0; j < 100; j++ {
-				_ = r.Report(SeverityLevels.INFO, fmt.Sprintf("g%d-%d", id, j))
+	for i := range 100 {
+		wg.Go(func() {
+			for j := range 100 {
+				_ = r.Report(SeverityLevels.INFO, fmt.Sprintf("g%d-%d", i, j))
 			}
-		}(i)
+		})
 	}
 
 	done := make(chan struct{})
@@ -124,63 +146,136 @@ func TestReporter_Report_ConcurrentSafety(t *testing.T) {
 		t.Fatal("timeout")
 	}
 
-	file, _ := os.Open(path)
-	scanner := bufio.NewScanner(file)
-	count := 0
-	for scanner.Scan() {
-		count++
-	}
-	assert.Equal(t, 100*100, count)
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	assert.Len(t, readEntries(t, file), 100*100)
 }
 
 func TestReporter_Close_PreventsFurtherWrites(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	r, _ := NewReporter(filepath.Join(dir, "close.log"))
+	sink, _ := newTestFileSink(t, RotateOptions{})
+	r := NewReporter(sink)
+	require.NoError(t, r.Close())
 	require.NoError(t, r.Close())
 
 	err := r.Report(SeverityLevels.ERROR, "after close")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrReporterClosed)
 	assert.Contains(t, err.Error(), "reporter is closed")
+	assert.ErrorIs(t, sink.Write(Entry{}), ErrReporterClosed)
+}
+
+func TestDefaultReporter_DiscardsWithoutSinks(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, DefaultReporter().Report(SeverityLevels.ERROR, "nowhere"))
+}
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	t.Parallel()
+	sink, path := newTestFileSink(t, RotateOptions{MaxSize: 300})
+	r := NewReporter(sink)
+
+	for i := range 10 {
+		require.NoError(t, r.Report(SeverityLevels.INFO, fmt.Sprintf("entry %d %s", i, strings.Repeat("x", 50))))
+		// Segment names carry milliseconds, keep rotations apart
+		time.Sleep(2 * time.Millisecond)
+	}
+	require.NoError(t, r.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(300))
+
+	segments, err := sink.Segments()
+	require.NoError(t, err)
+	require.NotEmpty(t, segments)
+
+	// Every entry survives rotation, oldest in the first segment
+	var all []Entry
+	for _, segment := range segments {
+		require.True(t, strings.HasSuffix(segment, ".log.gz"), segment)
+		file, err := os.Open(segment)
+		require.NoError(t, err)
+		gz, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		all = append(all, readEntries(t, gz)...)
+		_ = file.Close()
+	}
+	current, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = current.Close() }()
+	all = append(all, readEntries(t, current)...)
+
+	require.Len(t, all, 10)
+	assert.True(t, strings.HasPrefix(all[0].Message, "entry 0"))
+	assert.True(t, strings.HasPrefix(all[9].Message, "entry 9"))
+}
+
+func TestFileSink_RotatesByTime(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 1, 1, 23, 59, 0, 0, time.UTC)
+	sink, path := newTestFileSink(t, RotateOptions{Every: 24 * time.Hour})
+	sink.Now = func() time.Time { return now }
+	sink.opened = now
+
+	require.NoError(t, sink.Write(Entry{Message: "before midnight"}))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, sink.Write(Entry{Message: "same day"}))
+	segments, err := sink.Segments()
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, sink.Write(Entry{Message: "next day"}))
+	require.NoError(t, sink.Close())
+
+	segments, err = sink.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, "app-20260102T000030.000.log.gz", filepath.Base(segments[0]))
+
+	current, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = current.Close() }()
+	entries := readEntries(t, current)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "next day", entries[0].Message)
 }
 
-func TestReporter_Cleanup_RemovesOldFiles(t *testing.T) {
+func TestFileSink_Cleanup_RemovesOldSegments(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	r, _ := NewReporter(filepath.Join(dir, "app.log"))
-	defer func() { _ = r.Close() }()
+	sink, path := newTestFileSink(t, RotateOptions{Retention: 24 * time.Hour})
+	dir := filepath.Dir(path)
+	r := NewReporter(sink)
 
 	now := time.Now()
-	for _, name := range []string{"old1.log", "old2.log"} {
+	for _, name := range []string{"app-20250101T000000.000.log.gz", "app-20250102T000000.000.log"} {
 		createFileWithModTime(t, filepath.Join(dir, name), now.Add(-48*time.Hour))
 	}
-	for _, name := range []string{"keep1.log", "keep2.log"} {
+	for _, name := range []string{"app-20250103T000000.000.log.gz", "other.log"} {
 		createFileWithModTime(t, filepath.Join(dir, name), now.Add(-6*time.Hour))
 	}
+	createFileWithModTime(t, filepath.Join(dir, "unrelated.log"), now.Add(-48*time.Hour))
+	require.NoError(t, os.Chtimes(path, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
 
-	orig := r.filePath
-	r.filePath = dir
-	defer func() { r.filePath = orig }()
-	r.Cleanup(24 * time.Hour)
+	r.Cleanup()
 
-	assert.NoFileExists(t, filepath.Join(dir, "old1.log"))
-	assert.FileExists(t, filepath.Join(dir, "keep1.log"))
+	assert.NoFileExists(t, filepath.Join(dir, "app-20250101T000000.000.log.gz"))
+	assert.NoFileExists(t, filepath.Join(dir, "app-20250102T000000.000.log"))
+	assert.FileExists(t, filepath.Join(dir, "app-20250103T000000.000.log.gz"))
+	assert.FileExists(t, filepath.Join(dir, "unrelated.log"), "not a segment of this sink")
+	assert.FileExists(t, path, "the current file is never removed")
 }
 
-func TestReporter_Cleanup_IgnoresDirectories(t *testing.T) {
+func TestFileSink_Cleanup_IgnoresDirectories(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	r, _ := NewReporter(filepath.Join(dir, "log.log"))
-	defer func() { _ = r.Close() }()
+	sink, path := newTestFileSink(t, RotateOptions{Retention: time.Hour})
 
-	sub := filepath.Join(dir, "archive")
-	_ = os.Mkdir(sub, 0o755)
-	createFileWithModTime(t, filepath.Join(sub, "old.log"), time.Now().Add(-1000*time.Hour))
+	sub := filepath.Join(filepath.Dir(path), "app-archive.log")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	require.NoError(t, os.Chtimes(sub, time.Now().Add(-1000*time.Hour), time.Now().Add(-1000*time.Hour)))
 
-	orig := r.filePath
-	r.filePath = dir
-	r.Cleanup(1 * time.Hour)
-	r.filePath = orig
+	sink.Cleanup()
 
 	assert.DirExists(t, sub)
 }
@@ -188,9 +283,8 @@ func TestReporter_Cleanup_IgnoresDirectories(t *testing.T) {
 // ——————————————————— BENCHMARKS ———————————————————
 
 func BenchmarkReporter_Report(b *testing.B) {
-	dir := b.TempDir()
-	r, _ := NewReporter(filepath.Join(dir, "bench.log"))
-	defer func() { _ = r.Close() }()
+	sink, _ := newTestFileSink(b, RotateOptions{})
+	r := NewReporter(sink)
 
 	for b.Loop() {
 		_ = r.Report(SeverityLevels.INFO, "bench")
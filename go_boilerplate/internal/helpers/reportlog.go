@@ -0,0 +1,191 @@
+package helpers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrNotQueryable = errors.New("no report sink can be queried")
+
+// ReportFilter selects report entries, zero fields match everything
+type ReportFilter struct {
+	ID string
+	// Level is the least severe level to include
+	Level SeverityType
+	Since time.Time
+	Until time.Time
+	// Route matches the route pattern or the path of the request
+	Route string
+	// Text is searched case insensitively in the message and stack
+	Text string
+	// Limit caps the number of entries returned, newest first
+	Limit int
+}
+
+func (f ReportFilter) Match(entry Entry) bool {
+	switch {
+	case f.ID != "" && entry.ID != f.ID:
+		return false
+	case f.Level != "" && !entry.Level.AtLeast(f.Level):
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Time.Before(f.Until):
+		return false
+	case f.Route != "" && !strings.Contains(entry.Route, f.Route) && !strings.Contains(entry.Path, f.Route):
+		return false
+	case f.Text != "":
+		text := strings.ToLower(f.Text)
+		return strings.Contains(strings.ToLower(entry.Message), text) || strings.Contains(strings.ToLower(entry.Stack), text)
+	}
+	return true
+}
+
+// Querier is a sink that can read back what it wrote
+type Querier interface {
+	Entries(filter ReportFilter) ([]Entry, error)
+}
+
+// Entries reads matching entries back from the first sink that keeps them
+func (r *Reporter) Entries(filter ReportFilter) ([]Entry, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, sink := range r.sinks {
+		if querier, ok := sink.(Querier); ok {
+			return querier.Entries(filter)
+		}
+	}
+	return nil, ErrNotQueryable
+}
+
+// Entries reads the current file then the segments, newest first, and stops once filter.Limit entries
+// matched. Segments rotated before filter.Since are not opened at all.
+func (s *FileSink) Entries(filter ReportFilter) ([]Entry, error) {
+	segments, err := s.Segments()
+	if err != nil {
+		return nil, err
+	}
+	files := []string{s.path}
+	for _, segment := range slices.Backward(segments) {
+		// A segment being compressed has both files until the gzip is complete
+		if strings.HasSuffix(segment, ".gz") && slices.Contains(segments, strings.TrimSuffix(segment, ".gz")) {
+			continue
+		}
+		files = append(files, segment)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if rotated, ok := s.rotatedAt(file); ok && !filter.Since.IsZero() && rotated.Before(filter.Since) {
+			break
+		}
+
+		matched, err := readReport(file, filter)
+		if errors.Is(err, os.ErrNotExist) {
+			// Compressed or removed since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range slices.Backward(matched) {
+			entries = append(entries, entry)
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				return entries, nil
+			}
+		}
+	}
+	return entries, nil
+}
+
+// rotatedAt parses the rotation time out of a segment name
+func (s *FileSink) rotatedAt(segment string) (time.Time, bool) {
+	ext := filepath.Ext(s.path)
+	stamp := strings.TrimPrefix(filepath.Base(segment), filepath.Base(strings.TrimSuffix(s.path, ext))+"-")
+	stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+	rotated, err := time.Parse("20060102T150405.000", stamp)
+	return rotated, err == nil
+}
+
+// readReport returns the entries of one file matching filter, in the order they were written.
+// Lines that are not entries, like a torn last write, are skipped.
+func readReport(path string, filter ReportFilter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(reader)
+	// Stacks make long lines
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// EntryGroup is every occurrence of the same error
+type EntryGroup struct {
+	Level     SeverityType
+	Route     string
+	Message   string
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Latest is the most recent occurrence, for its details
+	Latest Entry
+}
+
+// GroupEntries groups entries with the same level, route and message, most recently seen first
+func GroupEntries(entries []Entry) []EntryGroup {
+	index := make(map[string]int)
+	var groups []EntryGroup
+	for _, entry := range entries {
+		key := string(entry.Level) + "\x00" + entry.Route + "\x00" + entry.Message
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(groups)
+			groups = append(groups, EntryGroup{Level: entry.Level, Route: entry.Route, Message: entry.Message, FirstSeen: entry.Time, LastSeen: entry.Time, Latest: entry})
+			i = len(groups) - 1
+		}
+		group := &groups[i]
+		group.Count++
+		if entry.Time.Before(group.FirstSeen) {
+			group.FirstSeen = entry.Time
+		}
+		if entry.Time.After(group.LastSeen) {
+			group.LastSeen, group.Latest = entry.Time, entry
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b EntryGroup) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return groups
+}
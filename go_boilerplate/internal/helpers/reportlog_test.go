@@ -0,0 +1,136 @@
+// reportlog_test.go
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportFilter_Match(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := Entry{ID: "a", Time: at, Level: SeverityLevels.ERROR, Message: "Connection refused", Route: "/users/:id", Path: "/users/42", Stack: "main.handler()"}
+
+	testCases := []struct {
+		name   string
+		filter ReportFilter
+		match  bool
+	}{
+		{"empty", ReportFilter{}, true},
+		{"id", ReportFilter{ID: "a"}, true},
+		{"other id", ReportFilter{ID: "b"}, false},
+		{"less severe level", ReportFilter{Level: SeverityLevels.WARN}, true},
+		{"more severe level", ReportFilter{Level: SeverityLevels.PANIC}, false},
+		{"since inclusive", ReportFilter{Since: at}, true},
+		{"until exclusive", ReportFilter{Until: at}, false},
+		{"route", ReportFilter{Route: "/users/:id"}, true},
+		{"path", ReportFilter{Route: "/users/42"}, true},
+		{"other route", ReportFilter{Route: "/admin"}, false},
+		{"text in message", ReportFilter{Text: "connection REFUSED"}, true},
+		{"text in stack", ReportFilter{Text: "main.handler"}, true},
+		{"missing text", ReportFilter{Text: "timeout"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.match, tc.filter.Match(entry))
+		})
+	}
+}
+
+func TestReporter_Entries_NotQueryable(t *testing.T) {
+	t.Parallel()
+	_, err := NewReporter(NewWriterSink(os.Stdout)).Entries(ReportFilter{})
+	assert.ErrorIs(t, err, ErrNotQueryable)
+}
+
+func TestFileSink_Entries_NewestFirstAcrossSegments(t *testing.T) {
+	t.Parallel()
+	sink, _ := newTestFileSink(t, RotateOptions{MaxSize: 300})
+	r := NewReporter(sink)
+
+	for i := range 10 {
+		level := SeverityLevels.INFO
+		if i%2 == 0 {
+			level = SeverityLevels.ERROR
+		}
+		require.NoError(t, r.Write(Entry{Level: level, Message: fmt.Sprintf("entry %d", i)}))
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Waits for the segments to be compressed
+	require.NoError(t, sink.Close())
+	segments, err := sink.Segments()
+	require.NoError(t, err)
+	require.NotEmpty(t, segments)
+
+	entries, err := r.Entries(ReportFilter{Level: SeverityLevels.ERROR})
+	require.NoError(t, err)
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"entry 8", "entry 6", "entry 4", "entry 2", "entry 0"}, messages)
+
+	entries, err = r.Entries(ReportFilter{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "entry 9", entries[0].Message)
+	assert.Equal(t, "entry 7", entries[2].Message)
+}
+
+func TestFileSink_Entries_SkipsSegmentsBeforeSince(t *testing.T) {
+	t.Parallel()
+	sink, path := newTestFileSink(t, RotateOptions{})
+	dir := filepath.Dir(path)
+
+	// Unreadable on purpose, opening it would fail the query
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-20250101T000000.000.log.gz"), []byte("not gzip"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-20260101T000000.000.log"), []byte(`{"id":"old","time":"2025-12-31T23:00:00Z","level":"ERROR","message":"old"}`+"\ntorn line\n"), 0o644))
+	require.NoError(t, sink.Write(Entry{ID: "new", Time: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Level: SeverityLevels.ERROR, Message: "new"}))
+
+	entries, err := sink.Entries(ReportFilter{Since: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "new", entries[0].ID)
+	assert.Equal(t, "old", entries[1].ID)
+
+	_, err = sink.Entries(ReportFilter{})
+	assert.Error(t, err, "without Since every segment is read")
+}
+
+func TestGroupEntries(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "4", Time: at.Add(4 * time.Minute), Level: SeverityLevels.ERROR, Route: "/a", Message: "boom"},
+		{ID: "3", Time: at.Add(3 * time.Minute), Level: SeverityLevels.WARN, Route: "/a", Message: "boom"},
+		{ID: "2", Time: at.Add(2 * time.Minute), Level: SeverityLevels.ERROR, Route: "/b", Message: "bust"},
+		{ID: "1", Time: at.Add(1 * time.Minute), Level: SeverityLevels.ERROR, Route: "/a", Message: "boom"},
+		{ID: "5", Time: at.Add(5 * time.Minute), Level: SeverityLevels.ERROR, Route: "/b", Message: "bust"},
+	}
+
+	groups := GroupEntries(entries)
+	require.Len(t, groups, 3)
+
+	assert.Equal(t, "/b", groups[0].Route)
+	assert.Equal(t, 2, groups[0].Count)
+	assert.Equal(t, at.Add(2*time.Minute), groups[0].FirstSeen)
+	assert.Equal(t, "5", groups[0].Latest.ID)
+
+	assert.Equal(t, "/a", groups[1].Route)
+	assert.Equal(t, SeverityLevels.ERROR, groups[1].Level)
+	assert.Equal(t, 2, groups[1].Count)
+	assert.Equal(t, at.Add(time.Minute), groups[1].FirstSeen)
+	assert.Equal(t, at.Add(4*time.Minute), groups[1].LastSeen)
+
+	assert.Equal(t, SeverityLevels.WARN, groups[2].Level, "levels are grouped apart")
+}
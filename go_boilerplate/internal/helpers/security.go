@@ -3,9 +3,43 @@ package helpers
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Context keys under which the security middlewares store per request values
+const (
+	CSRFKey  = "csrf"
+	NonceKey = "nonce"
+	// UserKey holds the signed in user's ID as a string, for code that can not depend on auth
+	UserKey = "user_id"
 )
 
+var (
+	ErrNoCSRFToken = errors.New("no CSRF token in context, is the CSRF middleware in the chain?")
+	ErrNoNonce     = errors.New("no CSP nonce in context, is the SecurityHeaders middleware in the chain?")
+)
+
+// CSRFToken returns the token issued for the request by the CSRF middleware
+func CSRFToken(c echo.Context) (string, error) {
+	token, ok := c.Get(CSRFKey).(string)
+	if !ok || token == "" {
+		return "", ErrNoCSRFToken
+	}
+	return token, nil
+}
+
+// Nonce returns the CSP nonce generated for the request by the SecurityHeaders middleware
+func Nonce(c echo.Context) (string, error) {
+	nonce, ok := c.Get(NonceKey).(string)
+	if !ok || nonce == "" {
+		return "", ErrNoNonce
+	}
+	return nonce, nil
+}
+
 func GenerateNonce() (string, error) {
 	bytes := make([]byte, 16) // 16 bytes nonce
 	if _, err := rand.Read(bytes); err != nil {
@@ -4,10 +4,13 @@ package helpers
 import (
 	"encoding/base64"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 
 	"sync"
 	"testing"
 
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -98,6 +101,47 @@ func (errorReader) Read(p []byte) (int, error) {
 	return 0, errors.New("mock read error")
 }
 
+func TestContextAccessors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		values  map[string]any
+		wantErr error
+	}{
+		{"present", map[string]any{CSRFKey: "token", NonceKey: "nonce"}, nil},
+		{"missing", map[string]any{}, ErrNoCSRFToken},
+		{"wrong type", map[string]any{CSRFKey: 42, NonceKey: 42}, ErrNoCSRFToken},
+		{"empty", map[string]any{CSRFKey: "", NonceKey: ""}, ErrNoCSRFToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			for key, value := range tt.values {
+				c.Set(key, value)
+			}
+
+			token, err := CSRFToken(c)
+			nonce, nonceErr := Nonce(c)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, ErrNoCSRFToken)
+				assert.ErrorIs(t, nonceErr, ErrNoNonce)
+				assert.Empty(t, token)
+				assert.Empty(t, nonce)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, nonceErr)
+			assert.Equal(t, "token", token)
+			assert.Equal(t, "nonce", nonce)
+		})
+	}
+}
+
 // ——————————————————— BENCHMARKS ———————————————————
 
 func BenchmarkGenerateNonce(b *testing.B) {
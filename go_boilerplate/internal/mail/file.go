@@ -0,0 +1,175 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+var ErrNotFound = errors.New("mail: no such file")
+
+// FileSender writes every message as an .eml file, handy in development to open mails in any client
+type FileSender struct {
+	Dir string
+}
+
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{Dir: dir}
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.NewString()[:8])
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		return err
+	}
+
+	log.Infof("Mail %q to %v written to %s", msg.Subject, msg.To, path)
+	return nil
+}
+
+// List returns the names of the written files, newest first
+func (s *FileSender) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".eml") {
+			names = append(names, entry.Name())
+		}
+	}
+	// Names start with their timestamp
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names, nil
+}
+
+// Open parses the file called name, anything that is not a plain .eml name in Dir is ErrNotFound
+func (s *FileSender) Open(name string) (Message, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".eml") {
+		return Message{}, ErrNotFound
+	}
+	raw, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Message{}, ErrNotFound
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	return Parse(raw)
+}
+
+// Parse reads back a message rendered by Bytes
+func Parse(raw []byte) (Message, error) {
+	parsed, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{From: parsed.Header.Get("From"), Subject: subject}
+	for to := range strings.SplitSeq(parsed.Header.Get("To"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			msg.To = append(msg.To, to)
+		}
+	}
+
+	err = msg.readPart(textproto.MIMEHeader(parsed.Header), parsed.Body)
+	return msg, err
+}
+
+// readPart fills msg from a part, recursing into multiparts. Parts are read raw
+// so every transfer encoding is decoded here, whatever the nesting.
+func (m *Message) readPart(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.readPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mediaType == "text/plain" && m.Text == "":
+		m.Text = string(data)
+	case mediaType == "text/html" && m.HTML == "":
+		m.HTML = string(data)
+	case header.Get("Content-ID") != "":
+		m.Inline = append(m.Inline, Inline{
+			ContentID:   strings.Trim(header.Get("Content-ID"), "<>"),
+			ContentType: mediaType,
+			Data:        data,
+		})
+	}
+	return nil
+}
+
+// PreviewHTML returns the HTML body with its cid: references swapped for data: URIs,
+// so a browser can show it without the rest of the message
+func (m Message) PreviewHTML() string {
+	html := m.HTML
+	for _, inline := range m.Inline {
+		uri := "data:" + inline.ContentType + ";base64," + base64.StdEncoding.EncodeToString(inline.Data)
+		html = strings.ReplaceAll(html, "cid:"+inline.ContentID, uri)
+	}
+	return html
+}
@@ -0,0 +1,250 @@
+package mail
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// cssRule is a rule of a <style> block simple enough to inline
+type cssRule struct {
+	tag     string
+	id      string
+	classes []string
+	decls   string
+	order   int
+}
+
+func (r cssRule) specificity() int {
+	specificity := 10 * len(r.classes)
+	if r.id != "" {
+		specificity += 100
+	}
+	if r.tag != "" {
+		specificity++
+	}
+	return specificity
+}
+
+func (r cssRule) matches(n *html.Node) bool {
+	if r.tag != "" && r.tag != n.Data {
+		return false
+	}
+	if r.id != "" && r.id != attr(n, "id") {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range r.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// simpleSelector is a tag, classes and an id, anything with combinators or pseudo classes is left to the client
+	simpleSelector = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9]*)?((?:[.#][a-zA-Z_-][a-zA-Z0-9_-]*)*)$`)
+	selectorPart   = regexp.MustCompile(`[.#][a-zA-Z_-][a-zA-Z0-9_-]*`)
+)
+
+// parseCSS splits a stylesheet into rules to inline and the rest, like @media blocks,
+// which stays in a <style> element for the clients that honour it
+func parseCSS(css string) ([]cssRule, string) {
+	css = cssComment.ReplaceAllString(css, "")
+
+	var rules []cssRule
+	var kept strings.Builder
+	for {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[:open])
+
+		// Find the matching brace, at-rules nest whole rule sets
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				if depth--; depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		block := css[open+1 : end]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			kept.WriteString(prelude + " {" + block + "}\n")
+			continue
+		}
+
+		decls := strings.TrimSpace(block)
+		for selector := range strings.SplitSeq(prelude, ",") {
+			selector = strings.TrimSpace(selector)
+			match := simpleSelector.FindStringSubmatch(selector)
+			if match == nil || selector == "" {
+				kept.WriteString(selector + " {" + block + "}\n")
+				continue
+			}
+			rule := cssRule{tag: strings.ToLower(match[1]), decls: decls, order: len(rules)}
+			for _, part := range selectorPart.FindAllString(match[2], -1) {
+				if part[0] == '#' {
+					rule.id = part[1:]
+				} else {
+					rule.classes = append(rule.classes, part[1:])
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, kept.String()
+}
+
+// inlineStyles moves the rules of every <style> element into style attributes.
+// Rules apply by specificity then source order and the element's own style wins, like in a browser.
+func inlineStyles(doc *html.Node) {
+	var styles []*html.Node
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom == atom.Style {
+			styles = append(styles, n)
+		}
+	})
+
+	var rules []cssRule
+	for _, style := range styles {
+		var css strings.Builder
+		for child := style.FirstChild; child != nil; child = child.NextSibling {
+			css.WriteString(child.Data)
+		}
+		parsed, kept := parseCSS(css.String())
+		for _, rule := range parsed {
+			rule.order += len(rules)
+			rules = append(rules, rule)
+		}
+
+		if strings.TrimSpace(kept) == "" {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		for style.FirstChild != nil {
+			style.RemoveChild(style.FirstChild)
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: kept})
+	}
+	slices.SortStableFunc(rules, func(a, b cssRule) int {
+		if a.specificity() != b.specificity() {
+			return a.specificity() - b.specificity()
+		}
+		return a.order - b.order
+	})
+
+	walk(doc, func(n *html.Node) {
+		var decls []string
+		for _, rule := range rules {
+			if rule.matches(n) {
+				decls = append(decls, strings.TrimSuffix(rule.decls, ";"))
+			}
+		}
+		if len(decls) == 0 {
+			return
+		}
+		if own := strings.TrimSpace(attr(n, "style")); own != "" {
+			decls = append(decls, strings.TrimSuffix(own, ";"))
+		}
+		setAttr(n, "style", strings.Join(decls, "; "))
+	})
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// textOf renders the plaintext alternative: paragraphs become blank lines,
+// links keep their target next to their text and images their alt text
+func textOf(doc *html.Node) string {
+	var b strings.Builder
+	var render func(n *html.Node)
+	newline := func(count int) {
+		text := b.String()
+		if text == "" {
+			return
+		}
+		for trailing := len(text) - len(strings.TrimRight(text, "\n")); trailing < count; trailing++ {
+			b.WriteString("\n")
+		}
+	}
+	render = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			text := strings.Join(strings.Fields(n.Data), " ")
+			if text == "" {
+				return
+			}
+			current := b.String()
+			if current != "" && !strings.HasSuffix(current, "\n") && !strings.HasSuffix(current, " ") &&
+				(n.Data[0] == ' ' || n.Data[0] == '\n' || n.Data[0] == '\t') {
+				b.WriteString(" ")
+			}
+			b.WriteString(text)
+			if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\n' || last == '\t' {
+				b.WriteString(" ")
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Head, atom.Style, atom.Script, atom.Title:
+				return
+			case atom.Br:
+				b.WriteString("\n")
+				return
+			case atom.Hr:
+				newline(2)
+				b.WriteString("---")
+				newline(2)
+				return
+			case atom.Img:
+				b.WriteString(attr(n, "alt"))
+				return
+			case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Blockquote, atom.Ul, atom.Ol:
+				newline(2)
+				defer newline(2)
+			case atom.Div, atom.Tr, atom.Section, atom.Header, atom.Footer:
+				newline(1)
+				defer newline(1)
+			case atom.Li:
+				newline(1)
+				b.WriteString("- ")
+				defer newline(1)
+			case atom.A:
+				start := b.Len()
+				for child := n.FirstChild; child != nil; child = child.NextSibling {
+					render(child)
+				}
+				label := strings.TrimSpace(b.String()[start:])
+				if href := attr(n, "href"); href != "" && href != label && !strings.HasPrefix(href, "mailto:") {
+					b.WriteString(" (" + href + ")")
+				}
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			render(child)
+		}
+	}
+	render(doc)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
@@ -0,0 +1,215 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/google/uuid"
+)
+
+var ErrNoRecipient = errors.New("mail: message has no recipient")
+
+// Message is a single email, Text is required and HTML is sent as an alternative when set
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Inline files are embedded next to the HTML, which refers to them as cid:ContentID
+	Inline []Inline
+}
+
+// Inline is a file embedded in a message, like the logo of the email layout
+type Inline struct {
+	ContentID   string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers messages, implementations must be safe for concurrent use
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	sender      Sender = NewMemorySender()
+	defaultFrom        = "no-reply@localhost"
+)
+
+// Setup replaces the sender used by Send and the address used when a message has no From
+func Setup(s Sender, from string) {
+	sender = s
+	if from != "" {
+		defaultFrom = from
+	}
+}
+
+// Configure sets up the sender selected by MAIL_BACKEND
+func Configure(cfg *boot.Config) error {
+	switch cfg.MailBackend {
+	case "smtp":
+		Setup(NewSMTPSender(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword), cfg.MailFrom)
+	case "file":
+		Setup(NewFileSender(cfg.MailDir), cfg.MailFrom)
+	case "memory":
+		Setup(NewMemorySender(), cfg.MailFrom)
+	default:
+		return fmt.Errorf("invalid MAIL_BACKEND: %s", cfg.MailBackend)
+	}
+	return nil
+}
+
+// Default returns the sender configured with Setup
+func Default() Sender {
+	return sender
+}
+
+// Send delivers msg through the configured sender
+func Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = defaultFrom
+	}
+	return sender.Send(ctx, msg)
+}
+
+// Bytes renders msg as an RFC 5322 message ready for SMTP or an .eml file
+func (m Message) Bytes() ([]byte, error) {
+	if len(m.To) == 0 {
+		return nil, ErrNoRecipient
+	}
+
+	var buf bytes.Buffer
+	header := func(key string, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domain(m.From)))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "8bit")
+		buf.WriteString("\r\n")
+		buf.WriteString(m.Text)
+		return buf.Bytes(), nil
+	}
+
+	alternative, boundary, err := m.alternative()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Inline) == 0 {
+		header("Content-Type", "multipart/alternative; boundary="+boundary)
+		buf.WriteString("\r\n")
+		buf.Write(alternative)
+		return buf.Bytes(), nil
+	}
+
+	// Embedded files sit next to the alternatives in a multipart/related, which ties the cid: references together
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + boundary}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(alternative); err != nil {
+		return nil, err
+	}
+	for _, inline := range m.Inline {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {inline.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + inline.ContentID + ">"},
+			"Content-Disposition":       {"inline"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(w, inline.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	header("Content-Type", `multipart/related; type="multipart/alternative"; boundary=`+writer.Boundary())
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// alternative renders the text and HTML parts. HTML is quoted-printable,
+// inlined styles easily break the 998 character line limit of SMTP.
+func (m Message) alternative() ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	w, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write([]byte(m.Text)); err != nil {
+		return nil, "", err
+	}
+
+	w, err = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(m.HTML)); err != nil {
+		return nil, "", err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.Boundary(), nil
+}
+
+// writeBase64 wraps the encoding at 76 characters like RFC 2045 asks
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func domain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "localhost"
+	}
+	return strings.TrimSuffix(address[at+1:], ">")
+}
@@ -0,0 +1,249 @@
+// mail_test.go
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/assert"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+func TestMessage_Bytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		msg      Message
+		contains []string
+		wantErr  error
+	}{
+		{
+			name:     "plain text",
+			msg:      Message{From: "App <no-reply@example.com>", To: []string{"a@x.com"}, Subject: "Hello", Text: "Body"},
+			contains: []string{"From: App <no-reply@example.com>\r\n", "To: a@x.com\r\n", "Subject: Hello\r\n", "Content-Type: text/plain; charset=utf-8", "@example.com>", "\r\n\r\nBody"},
+		},
+		{
+			name:     "html alternative",
+			msg:      Message{From: "no-reply@example.com", To: []string{"a@x.com", "b@x.com"}, Subject: "Hi", Text: "Plain", HTML: "<p>Rich</p>"},
+			contains: []string{"To: a@x.com, b@x.com\r\n", "multipart/alternative; boundary=", "text/plain; charset=utf-8", "text/html; charset=utf-8", "Plain", "<p>Rich</p>"},
+		},
+		{
+			name:     "encoded subject",
+			msg:      Message{From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Café", Text: "x"},
+			contains: []string{"Subject: =?utf-8?q?Caf=C3=A9?="},
+		},
+		{
+			name:    "no recipient",
+			msg:     Message{From: "no-reply@example.com", Subject: "x", Text: "x"},
+			wantErr: ErrNoRecipient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := tt.msg.Bytes()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, want := range tt.contains {
+				assert.Contains(t, string(raw), want)
+			}
+		})
+	}
+}
+
+func TestMemorySender(t *testing.T) {
+	t.Parallel()
+	s := NewMemorySender()
+	ctx := context.Background()
+
+	require.NoError(t, s.Send(ctx, Message{To: []string{"a@x.com"}, Subject: "first"}))
+	require.NoError(t, s.Send(ctx, Message{To: []string{"b@x.com"}, Subject: "other"}))
+	require.NoError(t, s.Send(ctx, Message{To: []string{"a@x.com"}, Subject: "second"}))
+	assert.ErrorIs(t, s.Send(ctx, Message{Subject: "nobody"}), ErrNoRecipient)
+
+	assert.Len(t, s.Messages(), 3)
+
+	last, ok := s.Last("a@x.com")
+	require.True(t, ok)
+	assert.Equal(t, "second", last.Subject)
+
+	_, ok = s.Last("c@x.com")
+	assert.False(t, ok)
+
+	s.Reset()
+	assert.Empty(t, s.Messages())
+}
+
+func TestMemorySender_Concurrent(t *testing.T) {
+	t.Parallel()
+	s := NewMemorySender()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			_ = s.Send(context.Background(), Message{To: []string{"a@x.com"}})
+		})
+	}
+	wg.Wait()
+
+	assert.Len(t, s.Messages(), 50)
+}
+
+func TestFileSender(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "mail")
+	s := NewFileSender(dir)
+
+	require.NoError(t, s.Send(context.Background(), Message{From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Saved", Text: "On disk"}))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+
+	raw, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Subject: Saved")
+	assert.Contains(t, string(raw), "On disk")
+}
+
+func TestMessage_BytesInline(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{
+		From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Logo", Text: "Plain",
+		HTML:   `<img src="cid:logo@example.com">`,
+		Inline: []Inline{{ContentID: "logo@example.com", ContentType: "image/png", Data: []byte("png")}},
+	}
+	raw, err := msg.Bytes()
+	require.NoError(t, err)
+
+	for _, want := range []string{`multipart/related; type="multipart/alternative"`, "Content-ID: <logo@example.com>", "Content-Disposition: inline", "cG5n"} {
+		assert.Contains(t, string(raw), want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat(`<td style="padding: 0; color: #3f3f46">Café</td>`, 40)
+	want := Message{
+		From: "no-reply@example.com", To: []string{"a@x.com", "b@x.com"}, Subject: "Café menu", Text: "Plain\n",
+		HTML:   long,
+		Inline: []Inline{{ContentID: "logo@example.com", ContentType: "image/png", Data: []byte(strings.Repeat("binary\x00", 30))}},
+	}
+	raw, err := want.Bytes()
+	require.NoError(t, err)
+
+	got, err := Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	body := templ.Raw(`<html><head><title>Hi</title><style>
+		p { color: red; margin: 0 }
+		.lead { color: blue }
+		a:hover { color: green }
+		@media (max-width: 600px) { p { margin: 4px } }
+	</style></head><body>
+		<h1>Welcome</h1>
+		<p class="lead" style="font-weight: bold">Hello <b>there</b></p>
+		<p>Open <a href="/account">your account</a> or write to <a href="mailto:a@x.com">a@x.com</a>.</p>
+		<ul><li>One</li><li>Two</li></ul>
+	</body></html>`)
+
+	msg, err := Render(context.Background(), "Welcome", body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Welcome", msg.Subject)
+	assert.Contains(t, msg.HTML, `style="color: red; margin: 0; color: blue; font-weight: bold"`)
+	assert.Contains(t, msg.HTML, "a:hover")
+	assert.Contains(t, msg.HTML, "@media")
+	assert.Contains(t, msg.HTML, `href="`+boot.Environment.URL+`/account"`)
+	assert.Equal(t, "Welcome\n\nHello there\n\nOpen your account ("+boot.Environment.URL+"/account) or write to a@x.com.\n\n- One\n- Two\n", msg.Text)
+}
+
+func TestRender_RemovesInlinedStylesheet(t *testing.T) {
+	t.Parallel()
+
+	msg, err := Render(context.Background(), "x", templ.Raw(`<style>p { color: red }</style><p>x</p>`))
+	require.NoError(t, err)
+	assert.NotContains(t, msg.HTML, "<style>")
+	assert.Contains(t, msg.HTML, `<p style="color: red">`)
+}
+
+// Not parallel, it points the package at its own assets
+func TestRender_EmbedsImages(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG"), 0o600))
+	previous := AssetsDir
+	AssetsDir = dir
+	t.Cleanup(func() { AssetsDir = previous })
+
+	msg, err := Render(context.Background(), "x", templ.Raw(`<img src="/assets/logo.png" alt="Logo"><img src="/assets/logo.png"><img src="/assets/missing.png">`))
+	require.NoError(t, err)
+
+	require.Len(t, msg.Inline, 1)
+	assert.Equal(t, "image/png", msg.Inline[0].ContentType)
+	assert.Equal(t, 2, strings.Count(msg.HTML, "cid:"+msg.Inline[0].ContentID))
+	assert.Contains(t, msg.HTML, `src="`+boot.Environment.URL+`/assets/missing.png"`)
+	assert.Equal(t, "Logo\n", msg.Text)
+}
+
+func TestFileSender_ListOpen(t *testing.T) {
+	t.Parallel()
+	s := NewFileSender(filepath.Join(t.TempDir(), "mail"))
+
+	names, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	msg := Message{
+		From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Saved", Text: "On disk",
+		HTML:   `<img src="cid:logo@example.com">`,
+		Inline: []Inline{{ContentID: "logo@example.com", ContentType: "image/png", Data: []byte("png")}},
+	}
+	require.NoError(t, s.Send(context.Background(), msg))
+
+	names, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	opened, err := s.Open(names[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Saved", opened.Subject)
+	assert.Equal(t, `<img src="data:image/png;base64,cG5n">`, opened.PreviewHTML())
+
+	for _, name := range []string{"../secret.eml", "missing.eml", "notes.txt"} {
+		_, err := s.Open(name)
+		assert.ErrorIs(t, err, ErrNotFound, name)
+	}
+}
+
+// ——————————————————— BENCHMARKS ———————————————————
+
+func BenchmarkMessage_Bytes(b *testing.B) {
+	msg := Message{From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Bench", Text: "Plain", HTML: "<p>Rich</p>"}
+	for b.Loop() {
+		_, _ = msg.Bytes()
+	}
+}
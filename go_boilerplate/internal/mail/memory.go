@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// MemorySender keeps every message in memory, meant for tests
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(_ context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipient
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Last returns the most recent message sent to address
+func (s *MemorySender) Last(address string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		for _, to := range s.messages[i].To {
+			if to == address {
+				return s.messages[i], true
+			}
+		}
+	}
+	return Message{}, false
+}
+
+// Reset forgets every message sent so far
+func (s *MemorySender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
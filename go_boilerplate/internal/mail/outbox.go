@@ -0,0 +1,172 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+// Outbox statuses, a mail is claimed as sending and leased until its next attempt
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+	OutboxDead    = "dead"
+)
+
+// Enqueue stores msg in the outbox through repo and returns its id. Pass repo.WithTx(tx)
+// so the mail only goes out when the transaction it belongs to commits.
+func Enqueue(ctx context.Context, repo *repository.Queries, msg Message) (uuid.UUID, error) {
+	if len(msg.To) == 0 {
+		return uuid.Nil, ErrNoRecipient
+	}
+	if msg.From == "" {
+		msg.From = defaultFrom
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id := uuid.New()
+	err = repo.EnqueueMail(ctx, repository.EnqueueMailParams{ID: id, Recipients: msg.To, Subject: msg.Subject, Message: payload})
+	return id, err
+}
+
+// Outbox sends the mail queued with Enqueue through the configured sender, retrying with backoff.
+// It is driven by the scheduler, see Job.
+type Outbox struct {
+	repo    *repository.Queries
+	running sync.Mutex
+
+	// Sender defaults to the one configured with Setup
+	Sender      Sender
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+	Lease       time.Duration
+	BatchSize   int32
+	Now         func() time.Time
+	// OnDead is called when a mail has used up its attempts or was refused for good
+	OnDead func(id uuid.UUID, msg Message, err error)
+}
+
+// NewOutbox returns an outbox reading through repo, with default retry settings
+func NewOutbox(repo *repository.Queries) *Outbox {
+	return &Outbox{
+		repo:        repo,
+		MaxAttempts: 8,
+		Backoff:     exponentialBackoff(time.Minute, 6*time.Hour),
+		Lease:       2 * time.Minute,
+		BatchSize:   20,
+		Now:         time.Now,
+	}
+}
+
+// Job returns a task for tools.Func that sends everything due.
+// A run still in progress when the next one fires makes the new run a no-op.
+func (o *Outbox) Job() func() {
+	return func() {
+		if !o.running.TryLock() {
+			return
+		}
+		defer o.running.Unlock()
+
+		for claimed := o.BatchSize; claimed == o.BatchSize; {
+			claimed = int32(o.SendDue(context.Background()))
+		}
+	}
+}
+
+// SendDue claims one batch of due mail and sends it, returning how many were claimed.
+// Mail goes out one at a time, SMTP relays throttle senders opening many connections.
+func (o *Outbox) SendDue(ctx context.Context) int {
+	rows, err := o.repo.ClaimMail(ctx, repository.ClaimMailParams{
+		LeaseUntil: o.Now().Add(o.Lease),
+		Batch:      o.BatchSize,
+	})
+	if err != nil {
+		log.Errorf("Failed to claim mail: %v", err)
+		return 0
+	}
+
+	for _, row := range rows {
+		o.send(ctx, row)
+	}
+	return len(rows)
+}
+
+func (o *Outbox) send(ctx context.Context, row repository.ClaimMailRow) {
+	var msg Message
+	err := json.Unmarshal(row.Message, &msg)
+	if err == nil {
+		sender := o.Sender
+		if sender == nil {
+			sender = Default()
+		}
+		err = sender.Send(ctx, msg)
+	}
+
+	if err == nil {
+		if err := o.repo.CompleteMail(ctx, row.ID); err != nil {
+			log.Errorf("Failed to complete mail %s: %v", row.ID, err)
+		}
+		return
+	}
+
+	status, next := OutboxFailed, o.Now().Add(o.Backoff(int(row.Attempts)))
+	if int(row.Attempts) >= o.MaxAttempts || permanent(err) {
+		status = OutboxDead
+	}
+	if err := o.repo.FailMail(ctx, repository.FailMailParams{ID: row.ID, Status: status, NextAttempt: next, LastError: err.Error()}); err != nil {
+		log.Errorf("Failed to record failure of mail %s: %v", row.ID, err)
+	}
+
+	if status == OutboxDead {
+		log.Errorf("Mail %s %q to %v dead-lettered after %d attempts: %v", row.ID, msg.Subject, msg.To, row.Attempts, err)
+		if o.OnDead != nil {
+			o.OnDead(row.ID, msg, err)
+		}
+		return
+	}
+	log.Warnf("Mail %s attempt %d failed, retrying at %s: %v", row.ID, row.Attempts, next.Format(time.RFC3339), err)
+}
+
+// Cleanup removes sent and dead mail older than 30 days, meant to be scheduled with tools.Func
+func (o *Outbox) Cleanup() {
+	removed, err := o.repo.DeleteOldMail(context.Background(), o.Now().AddDate(0, 0, -30))
+	if err != nil {
+		log.Errorf("Failed to clean up the mail outbox: %v", err)
+		return
+	}
+	log.Debugf("Removed %d mails from the outbox", removed)
+}
+
+// permanent tells mail that can never be delivered, like an unknown mailbox or a corrupt row, from
+// a relay that is down or throttling. SMTP answers 5xx for the former.
+func permanent(err error) bool {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+	var syntaxErr *json.SyntaxError
+	return errors.Is(err, ErrNoRecipient) || errors.As(err, &syntaxErr)
+}
+
+// exponentialBackoff doubles the delay after each attempt, starting at base and capped at max
+func exponentialBackoff(base time.Duration, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
@@ -0,0 +1,145 @@
+// outbox_test.go
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type senderFunc func(ctx context.Context, msg Message) error
+
+func (f senderFunc) Send(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// capture is a pgxmock argument matcher that keeps what it was matched against
+type capture struct{ value *[]byte }
+
+func (c capture) Match(v any) bool {
+	b, ok := v.([]byte)
+	*c.value = b
+	return ok
+}
+
+func TestEnqueue(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	var payload []byte
+	mock.ExpectExec("INSERT INTO mail_outbox").
+		WithArgs(pgxmock.AnyArg(), []string{"a@x.com"}, "Hello", capture{&payload}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	id, err := Enqueue(context.Background(), repository.New(mock), Message{To: []string{"a@x.com"}, Subject: "Hello", Text: "Body"})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, id)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var stored Message
+	require.NoError(t, json.Unmarshal(payload, &stored))
+	assert.Equal(t, "Body", stored.Text)
+	assert.NotEmpty(t, stored.From)
+
+	_, err = Enqueue(context.Background(), repository.New(mock), Message{Subject: "nobody"})
+	assert.ErrorIs(t, err, ErrNoRecipient)
+}
+
+func TestOutbox_SendDue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		sendErr    error
+		attempts   int32
+		wantStatus string
+		wantDead   bool
+	}{
+		{name: "sent", attempts: 1, wantStatus: OutboxSent},
+		{name: "relay down is retried", sendErr: errors.New("connection refused"), attempts: 1, wantStatus: OutboxFailed},
+		{name: "throttled is retried", sendErr: &textproto.Error{Code: 451, Msg: "try later"}, attempts: 2, wantStatus: OutboxFailed},
+		{name: "rejected mailbox is dead-lettered", sendErr: &textproto.Error{Code: 550, Msg: "no such user"}, attempts: 1, wantStatus: OutboxDead, wantDead: true},
+		{name: "last attempt is dead-lettered", sendErr: errors.New("timeout"), attempts: 8, wantStatus: OutboxDead, wantDead: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			var sent []Message
+			o := NewOutbox(repository.New(mock))
+			o.Sender = senderFunc(func(_ context.Context, msg Message) error {
+				sent = append(sent, msg)
+				return tt.sendErr
+			})
+			var dead bool
+			o.OnDead = func(uuid.UUID, Message, error) { dead = true }
+
+			id := uuid.New()
+			payload, err := json.Marshal(Message{From: "no-reply@example.com", To: []string{"a@x.com"}, Subject: "Hi", Text: "x"})
+			require.NoError(t, err)
+			mock.ExpectQuery("UPDATE mail_outbox").WithArgs(pgxmock.AnyArg(), int32(20)).
+				WillReturnRows(pgxmock.NewRows([]string{"id", "message", "attempts"}).AddRow(id, payload, tt.attempts))
+			if tt.wantStatus == OutboxSent {
+				mock.ExpectExec("SET status = 'sent'").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			} else {
+				mock.ExpectExec("SET status = \\$2").WithArgs(id, tt.wantStatus, pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			}
+
+			assert.Equal(t, 1, o.SendDue(context.Background()))
+			require.Len(t, sent, 1)
+			assert.Equal(t, "Hi", sent[0].Subject)
+			assert.Equal(t, tt.wantDead, dead)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOutbox_SendDueDeadLettersCorruptRows(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	o := NewOutbox(repository.New(mock))
+	o.Sender = senderFunc(func(context.Context, Message) error {
+		t.Error("corrupt mail was sent")
+		return nil
+	})
+
+	id := uuid.New()
+	mock.ExpectQuery("UPDATE mail_outbox").WithArgs(pgxmock.AnyArg(), int32(20)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "message", "attempts"}).AddRow(id, []byte("{"), int32(1)))
+	mock.ExpectExec("SET status = \\$2").WithArgs(id, OutboxDead, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	assert.Equal(t, 1, o.SendDue(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := NewOutbox(nil).Backoff
+	assert.Equal(t, time.Minute, backoff(1))
+	assert.Equal(t, 4*time.Minute, backoff(3))
+	assert.Equal(t, 6*time.Hour, backoff(20))
+}
@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/a-h/templ"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// AssetsDir is where images under /assets/ are read from to embed them, like the router serves them
+var AssetsDir = "./static"
+
+// Render turns a templ component, usually wrapped in layouts.Email, into a message ready to address.
+// Mail clients ignore stylesheets and cannot reach relative URLs, so <style> rules are inlined,
+// images under /assets/ are embedded, other relative links made absolute and a plaintext
+// alternative is derived from the same markup.
+func Render(ctx context.Context, subject string, body templ.Component) (Message, error) {
+	var buf bytes.Buffer
+	if err := body.Render(ctx, &buf); err != nil {
+		return Message{}, err
+	}
+
+	doc, err := html.Parse(&buf)
+	if err != nil {
+		return Message{}, err
+	}
+
+	inlineStyles(doc)
+	inline := embedImages(doc)
+	absoluteLinks(doc)
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: subject,
+		Text:    textOf(doc),
+		HTML:    out.String(),
+		Inline:  inline,
+	}, nil
+}
+
+// embedImages swaps the src of local images for cid: references to embedded copies,
+// images that cannot be read are linked absolutely instead
+func embedImages(doc *html.Node) []Inline {
+	var inline []Inline
+	seen := make(map[string]string)
+
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Img {
+			return
+		}
+		src := attr(n, "src")
+		name, ok := strings.CutPrefix(src, "/assets/")
+		if !ok {
+			return
+		}
+
+		if cid, ok := seen[src]; ok {
+			setAttr(n, "src", "cid:"+cid)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(AssetsDir, filepath.FromSlash(path.Clean("/"+name))))
+		if err != nil {
+			setAttr(n, "src", boot.Environment.URL+src)
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		cid := hex.EncodeToString(sum[:8]) + "@" + domain(defaultFrom)
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		inline = append(inline, Inline{ContentID: cid, ContentType: contentType, Data: data})
+		seen[src] = cid
+		setAttr(n, "src", "cid:"+cid)
+	})
+	return inline
+}
+
+// absoluteLinks prefixes relative hrefs with the public URL of the app
+func absoluteLinks(doc *html.Node) {
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.A {
+			return
+		}
+		if href := attr(n, "href"); strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+			setAttr(n, "href", boot.Environment.URL+href)
+		}
+	})
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key string, value string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
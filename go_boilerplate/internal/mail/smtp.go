@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+)
+
+// SMTPSender delivers messages through an SMTP relay, using PLAIN auth when a username is set
+type SMTPSender struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+func NewSMTPSender(addr string, username string, password string) *SMTPSender {
+	return &SMTPSender{Addr: addr, Username: username, Password: password}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	// net/smtp has no context support, so only an already cancelled context is honoured
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// MAIL_FROM may carry a display name, the envelope sender must be the bare address
+	from, err := netmail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(s.Addr, auth, from.Address, msg.To, raw)
+}
@@ -0,0 +1,202 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+// CSRFMode selects how state changing requests prove they come from our own pages
+type CSRFMode string
+
+const (
+	// CSRFDoubleSubmit compares the token in the X-CSRF-Token header or _csrf form field with the csrf cookie
+	CSRFDoubleSubmit CSRFMode = "double-submit"
+	// CSRFOrigin trusts the browser's Sec-Fetch-Site and Origin headers, no token is needed
+	CSRFOrigin CSRFMode = "origin"
+	// CSRFAuto accepts a same origin request on its headers and falls back to the token otherwise
+	CSRFAuto CSRFMode = "auto"
+)
+
+const (
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "_csrf"
+
+	csrfTokenBytes = 32
+	csrfCookieAge  = 86400
+)
+
+type CSRFConfig struct {
+	Mode CSRFMode
+	// Exempt lists route paths, as registered with echo, that are not validated.
+	// A trailing * matches every route below the prefix. Exempt routes still get a token to render.
+	Exempt []string
+	// TrustedOrigins may post cross-site, as "scheme://host[:port]"
+	TrustedOrigins []string
+	CookieSecure   bool
+}
+
+// DefaultCSRFConfig protects with CSRFAuto and trusts only the application's own URL
+func DefaultCSRFConfig() CSRFConfig {
+	return CSRFConfig{
+		Mode:           CSRFAuto,
+		TrustedOrigins: []string{boot.Environment.URL},
+		CookieSecure:   boot.Environment.GoEnv == enums.Environments.PRODUCTION,
+	}
+}
+
+// CSRF issues a token for every request and rejects unsafe requests that fail the configured check.
+// Apply it to a group, or to single routes that live outside of a protected group.
+func CSRF(cfg CSRFConfig) echo.MiddlewareFunc {
+	if cfg.Mode == "" {
+		cfg.Mode = CSRFAuto
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := csrfCookieToken(c, cfg)
+			if err != nil {
+				return err
+			}
+			c.Set(helpers.CSRFKey, token)
+			c.Response().Header().Add(echo.HeaderVary, echo.HeaderCookie)
+
+			if isSafeMethod(c.Request().Method) || cfg.exempt(c.Path()) {
+				return next(c)
+			}
+
+			var ok bool
+			switch cfg.Mode {
+			case CSRFDoubleSubmit:
+				ok = validSubmittedToken(c, token)
+			case CSRFOrigin:
+				ok = cfg.validOrigin(c.Request())
+			default:
+				ok = cfg.validOrigin(c.Request()) || validSubmittedToken(c, token)
+			}
+			if !ok {
+				return csrfError(c)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// CSRFTokenHandler serves the current token for fetch and SPA clients, which send it back in the X-CSRF-Token header
+func CSRFTokenHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, err := helpers.CSRFToken(c)
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "CSRF token unavailable"}, nil)
+		}
+
+		c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+		return c.JSON(http.StatusOK, map[string]string{"token": token, "header": CSRFHeader, "field": CSRFField})
+	}
+}
+
+// csrfCookieToken reuses the token of the csrf cookie, minting and setting a new one when there is none
+func csrfCookieToken(c echo.Context, cfg CSRFConfig) (string, error) {
+	if cookie, err := c.Cookie(CSRFCookie); err == nil && len(cookie.Value) == base64.RawURLEncoding.EncodedLen(csrfTokenBytes) {
+		return cookie.Value, nil
+	}
+
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	c.SetCookie(&http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   csrfCookieAge,
+		Secure:   cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, nil
+}
+
+func validSubmittedToken(c echo.Context, token string) bool {
+	submitted := c.Request().Header.Get(CSRFHeader)
+	if submitted == "" {
+		submitted = c.FormValue(CSRFField)
+	}
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+// validOrigin follows the OWASP fetch metadata guidance, using Origin or Referer when Sec-Fetch-Site is missing.
+// A request carrying none of them is refused, browsers always send at least one on a cross-site post.
+func (cfg CSRFConfig) validOrigin(r *http.Request) bool {
+	origin := r.Header.Get(echo.HeaderOrigin)
+	if origin != "" && cfg.trusted(origin) {
+		return true
+	}
+
+	switch r.Header.Get(echo.HeaderSecFetchSite) {
+	case "same-origin", "none":
+		return true
+	case "same-site", "cross-site":
+		return false
+	}
+
+	if origin == "" {
+		referer, err := url.Parse(r.Referer())
+		if err != nil || referer.Host == "" {
+			return false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	return cfg.trusted(origin) || sameHost(origin, r.Host)
+}
+
+func (cfg CSRFConfig) trusted(origin string) bool {
+	return slices.ContainsFunc(cfg.TrustedOrigins, func(trusted string) bool {
+		return strings.EqualFold(strings.TrimSuffix(trusted, "/"), origin)
+	})
+}
+
+func (cfg CSRFConfig) exempt(path string) bool {
+	return slices.ContainsFunc(cfg.Exempt, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(path, prefix)
+		}
+		return path == pattern
+	})
+}
+
+func sameHost(origin string, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func csrfError(c echo.Context) error {
+	err := apperrors.GenericError{Code: http.StatusForbidden, Message: "CSRF check failed for " + c.Request().Method + " " + c.Request().URL.Path, UserMessage: "Your session expired, please reload the page and try again"}
+	if strings.Contains(c.Request().Header.Get("Accept"), "application/json") {
+		return apperrors.SendReturnedGenericJSONError(c, err, nil)
+	}
+	return apperrors.SendReturnedGenericHTMLError(c, err, nil)
+}
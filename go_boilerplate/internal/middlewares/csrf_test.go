@@ -0,0 +1,190 @@
+// csrf_test.go
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+const testToken = "Y3NyZi10ZXN0LXRva2VuLW9mLTMyLWJ5dGVzLWxvbmc"
+
+func newCSRFServer(cfg CSRFConfig) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	g := e.Group("", CSRF(cfg))
+	g.GET("/csrf", CSRFTokenHandler())
+	g.GET("/page", func(c echo.Context) error {
+		token, err := helpers.CSRFToken(c)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, token)
+	})
+	g.POST("/submit", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	g.POST("/hooks/:provider", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	return e
+}
+
+func TestCSRF(t *testing.T) {
+	t.Parallel()
+
+	cfg := CSRFConfig{TrustedOrigins: []string{"https://app.example.com"}, Exempt: []string{"/hooks/*"}}
+
+	tests := []struct {
+		name       string
+		mode       CSRFMode
+		path       string
+		cookie     bool
+		header     string
+		form       string
+		origin     string
+		fetchSite  string
+		referer    string
+		wantStatus int
+	}{
+		{name: "double-submit: header matches cookie", mode: CSRFDoubleSubmit, cookie: true, header: testToken, wantStatus: http.StatusOK},
+		{name: "double-submit: form field matches cookie", mode: CSRFDoubleSubmit, cookie: true, form: testToken, wantStatus: http.StatusOK},
+		{name: "double-submit: missing token", mode: CSRFDoubleSubmit, cookie: true, wantStatus: http.StatusForbidden},
+		{name: "double-submit: wrong token", mode: CSRFDoubleSubmit, cookie: true, header: strings.Repeat("a", len(testToken)), wantStatus: http.StatusForbidden},
+		{name: "double-submit: no cookie", mode: CSRFDoubleSubmit, header: testToken, wantStatus: http.StatusForbidden},
+		{name: "double-submit: same origin is not enough", mode: CSRFDoubleSubmit, fetchSite: "same-origin", wantStatus: http.StatusForbidden},
+		{name: "origin: same-origin fetch metadata", mode: CSRFOrigin, fetchSite: "same-origin", wantStatus: http.StatusOK},
+		{name: "origin: cross-site fetch metadata", mode: CSRFOrigin, fetchSite: "cross-site", origin: "https://evil.example", wantStatus: http.StatusForbidden},
+		{name: "origin: trusted cross-site origin", mode: CSRFOrigin, fetchSite: "cross-site", origin: "https://app.example.com", wantStatus: http.StatusOK},
+		{name: "origin: origin header of own host", mode: CSRFOrigin, origin: "http://example.com", wantStatus: http.StatusOK},
+		{name: "origin: foreign origin header", mode: CSRFOrigin, origin: "https://evil.example", wantStatus: http.StatusForbidden},
+		{name: "origin: referer fallback", mode: CSRFOrigin, referer: "http://example.com/page", wantStatus: http.StatusOK},
+		{name: "origin: no headers at all", mode: CSRFOrigin, wantStatus: http.StatusForbidden},
+		{name: "origin: token is ignored", mode: CSRFOrigin, cookie: true, header: testToken, wantStatus: http.StatusForbidden},
+		{name: "auto: same origin without token", mode: CSRFAuto, fetchSite: "same-origin", wantStatus: http.StatusOK},
+		{name: "auto: cross-site falls back to token", mode: CSRFAuto, fetchSite: "same-site", cookie: true, header: testToken, wantStatus: http.StatusOK},
+		{name: "auto: cross-site without token", mode: CSRFAuto, fetchSite: "cross-site", wantStatus: http.StatusForbidden},
+		{name: "exempt route", mode: CSRFDoubleSubmit, path: "/hooks/stripe", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := cfg
+			cfg.Mode = tt.mode
+			e := newCSRFServer(cfg)
+
+			path := tt.path
+			if path == "" {
+				path = "/submit"
+			}
+			body := url.Values{}
+			if tt.form != "" {
+				body.Set(CSRFField, tt.form)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: testToken})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.origin != "" {
+				req.Header.Set(echo.HeaderOrigin, tt.origin)
+			}
+			if tt.fetchSite != "" {
+				req.Header.Set(echo.HeaderSecFetchSite, tt.fetchSite)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestCSRF_IssuesAndReusesToken(t *testing.T) {
+	t.Parallel()
+
+	e := newCSRFServer(CSRFConfig{})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, CSRFCookie, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, cookies[0].Value, rec.Body.String(), "the rendered token must match the cookie")
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, cookies[0].Value, rec.Body.String())
+	assert.Empty(t, rec.Result().Cookies(), "an existing token is not reissued")
+}
+
+func TestCSRFTokenHandler(t *testing.T) {
+	t.Parallel()
+
+	e := newCSRFServer(CSRFConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/csrf", nil)
+	req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: testToken})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get(echo.HeaderCacheControl))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, testToken, body["token"])
+	assert.Equal(t, CSRFHeader, body["header"])
+
+	// The token from the endpoint is accepted back by a fetch client
+	post := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	post.AddCookie(&http.Cookie{Name: CSRFCookie, Value: body["token"]})
+	post.Header.Set(CSRFHeader, body["token"])
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, post)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCSRF_RejectsWithJSONForAPIClients(t *testing.T) {
+	t.Parallel()
+
+	e := newCSRFServer(CSRFConfig{Mode: CSRFDoubleSubmit})
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "application/json")
+}
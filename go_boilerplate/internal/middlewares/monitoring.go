@@ -1,9 +1,14 @@
 package middlewares
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
 	"fmt"
@@ -11,8 +16,10 @@ import (
 
 	"github.com/__username__/go_boilerplate/cmd/boot"
 	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/helpers"
 	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 )
 
 // MonitoringMiddleware tracks request metrics and exposes them for Prometheus
@@ -44,43 +51,135 @@ func MonitoringMiddleware() echo.MiddlewareFunc {
 	}
 }
 
-func MetricsAccessMiddleware() echo.MiddlewareFunc {
+// MetricsAccessConfig decides who may scrape /metrics. Scrapers need the secret,
+// as a bearer token or a basic auth password, and must come from an allowed address.
+type MetricsAccessConfig struct {
+	Secret string
+	// Hosts are resolved and their addresses allowed, like the prometheus service name
+	Hosts     []string
+	Allowlist []netip.Prefix
+	// RefreshInterval is how long resolved addresses are cached
+	RefreshInterval time.Duration
+	// LookupHost resolves Hosts, net.DefaultResolver when nil
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+// DefaultMetricsAccessConfig reads METRICS_SECRET, PROMETHEUS and METRICS_ALLOWLIST
+func DefaultMetricsAccessConfig() (MetricsAccessConfig, error) {
+	allowlist, err := helpers.ParseCIDRs(boot.Environment.MetricsAllowlist)
+	if err != nil {
+		return MetricsAccessConfig{}, fmt.Errorf("invalid METRICS_ALLOWLIST: %w", err)
+	}
+
+	var hosts []string
+	for host := range strings.SplitSeq(boot.Environment.Prometheus, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return MetricsAccessConfig{
+		Secret:          boot.Environment.MetricSecret,
+		Hosts:           hosts,
+		Allowlist:       allowlist,
+		RefreshInterval: time.Minute,
+	}, nil
+}
+
+// MetricsAccess guards the metrics endpoint. Without a secret nobody gets in,
+// without hosts and allowlist the secret alone is enough, which suits an internal listener.
+func MetricsAccess(cfg MetricsAccessConfig) echo.MiddlewareFunc {
+	secret := sha256.Sum256([]byte(cfg.Secret))
+	resolver := &hostResolver{hosts: cfg.Hosts, refresh: cfg.RefreshInterval, lookup: cfg.LookupHost}
+	if resolver.lookup == nil {
+		resolver.lookup = net.DefaultResolver.LookupHost
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			auth := c.Request().Header.Get("Authorization")
-			if auth == "" {
-				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: "Forbidden Access Attempt to Metrics without Authorization header", UserMessage: "Resource is not accessible"}, nil)
+			if cfg.Secret == "" {
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: "Metrics requested but METRICS_SECRET is not set", UserMessage: "Resource is not accessible"}, nil)
 			}
 
-			realIP := c.RealIP()
-			var ipStr string
-			var err error
-			if !strings.Contains(realIP, ":") {
-				ipStr = realIP
-			} else {
-				ipStr, _, err = net.SplitHostPort(c.RealIP())
-				if err != nil {
-					return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: fmt.Sprintf("Forbidden Access Attempt to Metrics with invalid IP address at splitting host <-- %v", err.Error()), UserMessage: "Resource is not accessible"}, nil)
-				}
+			// Hashing first keeps the comparison constant time regardless of the length presented
+			presented := sha256.Sum256([]byte(metricsCredential(c.Request())))
+			if subtle.ConstantTimeCompare(presented[:], secret[:]) != 1 {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="metrics"`)
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusUnauthorized, Message: fmt.Sprintf("Metrics access from %s with missing or wrong credentials", c.RealIP()), UserMessage: "Resource is not accessible"}, nil)
 			}
 
-			sourceIP := net.ParseIP(ipStr)
-			if sourceIP == nil {
-				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: "Forbidden Access Attempt to Metrics with invalid source IP address", UserMessage: "Resource is not accessible"}, nil)
+			if len(cfg.Hosts) == 0 && len(cfg.Allowlist) == 0 {
+				return next(c)
 			}
 
-			ips, err := net.LookupHost(boot.Environment.Prometheus)
+			source, err := netip.ParseAddr(c.RealIP())
 			if err != nil {
-				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: fmt.Sprintf("Error resolving Prometheus IP address (%v) <-- %v", boot.Environment.Prometheus, err.Error()), UserMessage: "Server is not accessible to find this resource"}, nil)
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: fmt.Sprintf("Metrics access with invalid source IP address (%v)", c.RealIP()), UserMessage: "Resource is not accessible"}, nil)
 			}
+			source = source.Unmap()
 
-			allowed := slices.Contains(ips, sourceIP.String())
-
-			if !allowed {
-				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: fmt.Sprintf("Forbidden Access Attempt to Metrics with invalid source IP address (%v)", sourceIP), UserMessage: "Resource is not accessible"}, nil)
+			if !helpers.ContainsAddr(cfg.Allowlist, source) && !slices.Contains(resolver.addrs(c.Request().Context()), source) {
+				return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusForbidden, Message: fmt.Sprintf("Forbidden Access Attempt to Metrics with invalid source IP address (%v)", source), UserMessage: "Resource is not accessible"}, nil)
 			}
 
 			return next(c)
 		}
 	}
 }
+
+// metricsCredential returns the bearer token or the basic auth password
+func metricsCredential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		return token
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// hostResolver caches the addresses of hosts for refresh, so scrapes do not wait on DNS.
+// A failed lookup keeps serving the previous addresses until the next refresh.
+type hostResolver struct {
+	hosts   []string
+	refresh time.Duration
+	lookup  func(ctx context.Context, host string) ([]string, error)
+
+	mu       sync.Mutex
+	cached   []netip.Addr
+	resolved time.Time
+}
+
+func (r *hostResolver) addrs(ctx context.Context) []netip.Addr {
+	if len(r.hosts) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.resolved.IsZero() && time.Since(r.resolved) < r.refresh {
+		return r.cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var addrs []netip.Addr
+	for _, host := range r.hosts {
+		resolved, err := r.lookup(ctx, host)
+		if err != nil {
+			log.Warnf("Failed to resolve metrics host %s, keeping %d cached addresses: %v", host, len(r.cached), err)
+			r.resolved = time.Now()
+			return r.cached
+		}
+		for _, value := range resolved {
+			if addr, err := netip.ParseAddr(value); err == nil {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+
+	r.cached, r.resolved = addrs, time.Now()
+	return r.cached
+}
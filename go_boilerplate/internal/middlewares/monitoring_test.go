@@ -0,0 +1,112 @@
+// monitoring_test.go
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveMetrics(cfg MetricsAccessConfig, remote string, header http.Header) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.GET("/metrics", func(c echo.Context) error { return c.String(http.StatusOK, "metrics") }, MetricsAccess(cfg))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = remote
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func staticLookup(addrs map[string][]string) func(context.Context, string) ([]string, error) {
+	return func(_ context.Context, host string) ([]string, error) {
+		if resolved, ok := addrs[host]; ok {
+			return resolved, nil
+		}
+		return nil, errors.New("no such host")
+	}
+}
+
+func TestMetricsAccess(t *testing.T) {
+	t.Parallel()
+
+	bearer := http.Header{"Authorization": {"Bearer s3cret"}}
+	scraper := MetricsAccessConfig{
+		Secret:          "s3cret",
+		Hosts:           []string{"prometheus"},
+		Allowlist:       []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		RefreshInterval: time.Minute,
+		LookupHost:      staticLookup(map[string][]string{"prometheus": {"172.18.0.5"}}),
+	}
+	basic := httptest.NewRequest(http.MethodGet, "/", nil)
+	basic.SetBasicAuth("prometheus", "s3cret")
+
+	tests := []struct {
+		name   string
+		cfg    MetricsAccessConfig
+		remote string
+		header http.Header
+		want   int
+	}{
+		{name: "bearer from resolved host", cfg: scraper, remote: "172.18.0.5:9000", header: bearer, want: http.StatusOK},
+		{name: "basic auth password from allowlist", cfg: scraper, remote: "10.1.4.2:9000", header: basic.Header, want: http.StatusOK},
+		{name: "missing credentials", cfg: scraper, remote: "172.18.0.5:9000", want: http.StatusUnauthorized},
+		{name: "wrong secret", cfg: scraper, remote: "172.18.0.5:9000", header: http.Header{"Authorization": {"Bearer s3cre"}}, want: http.StatusUnauthorized},
+		{name: "right secret from unknown address", cfg: scraper, remote: "203.0.113.9:9000", header: bearer, want: http.StatusForbidden},
+		{name: "secret alone without hosts or allowlist", cfg: MetricsAccessConfig{Secret: "s3cret"}, remote: "203.0.113.9:9000", header: bearer, want: http.StatusOK},
+		{name: "empty secret denies everyone", cfg: MetricsAccessConfig{}, remote: "127.0.0.1:9000", header: http.Header{"Authorization": {"Bearer "}}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := serveMetrics(tt.cfg, tt.remote, tt.header)
+			assert.Equal(t, tt.want, rec.Code)
+			if tt.want == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="metrics"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+func TestHostResolver_CachesUntilRefresh(t *testing.T) {
+	t.Parallel()
+
+	var lookups atomic.Int32
+	fail := atomic.Bool{}
+	r := &hostResolver{
+		hosts:   []string{"prometheus"},
+		refresh: 20 * time.Millisecond,
+		lookup: func(context.Context, string) ([]string, error) {
+			lookups.Add(1)
+			if fail.Load() {
+				return nil, errors.New("temporary failure")
+			}
+			return []string{"::ffff:172.18.0.5"}, nil
+		},
+	}
+	want := []netip.Addr{netip.MustParseAddr("172.18.0.5")}
+
+	assert.Equal(t, want, r.addrs(context.Background()))
+	assert.Equal(t, want, r.addrs(context.Background()))
+	assert.Equal(t, int32(1), lookups.Load())
+
+	// Once stale a failed lookup keeps the addresses that were known
+	time.Sleep(30 * time.Millisecond)
+	fail.Store(true)
+	assert.Equal(t, want, r.addrs(context.Background()))
+	assert.Equal(t, want, r.addrs(context.Background()))
+	assert.Equal(t, int32(2), lookups.Load())
+}
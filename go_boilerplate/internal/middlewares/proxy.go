@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+// CloudflareRanges are the addresses Cloudflare proxies from, as published at https://www.cloudflare.com/ips/
+var CloudflareRanges = []netip.Prefix{
+	netip.MustParsePrefix("173.245.48.0/20"),
+	netip.MustParsePrefix("103.21.244.0/22"),
+	netip.MustParsePrefix("103.22.200.0/22"),
+	netip.MustParsePrefix("103.31.4.0/22"),
+	netip.MustParsePrefix("141.101.64.0/18"),
+	netip.MustParsePrefix("108.162.192.0/18"),
+	netip.MustParsePrefix("190.93.240.0/20"),
+	netip.MustParsePrefix("188.114.96.0/20"),
+	netip.MustParsePrefix("197.234.240.0/22"),
+	netip.MustParsePrefix("198.41.128.0/17"),
+	netip.MustParsePrefix("162.158.0.0/15"),
+	netip.MustParsePrefix("104.16.0.0/13"),
+	netip.MustParsePrefix("104.24.0.0/14"),
+	netip.MustParsePrefix("172.64.0.0/13"),
+	netip.MustParsePrefix("131.0.72.0/22"),
+	netip.MustParsePrefix("2400:cb00::/32"),
+	netip.MustParsePrefix("2606:4700::/32"),
+	netip.MustParsePrefix("2803:f800::/32"),
+	netip.MustParsePrefix("2405:b500::/32"),
+	netip.MustParsePrefix("2405:8100::/32"),
+	netip.MustParsePrefix("2a06:98c0::/29"),
+	netip.MustParsePrefix("2c0f:f248::/32"),
+}
+
+// Headers a proxy may report the client address in
+const (
+	ProxyHeaderXForwardedFor = "X-Forwarded-For"
+	ProxyHeaderForwarded     = "Forwarded"
+	// ProxyHeaderCloudflare also trusts connections from CloudflareRanges
+	ProxyHeaderCloudflare = "CF-Connecting-IP"
+)
+
+// ProxyConfig lists who may tell us the client address, and in which header. Headers from anyone
+// else are ignored, otherwise clients could pick their own address and dodge rate limits.
+type ProxyConfig struct {
+	Trusted []netip.Prefix
+	// Header is the one the trusted proxies write, X-Forwarded-For when empty. The others are
+	// ignored, as a proxy passes on whatever the client sent in them.
+	Header string
+}
+
+// IPExtractor returns the client address for e.IPExtractor, so c.RealIP() is safe to use everywhere.
+// The forwarding chain from the configured header is walked from the nearest hop outwards and the
+// first address that is not a trusted proxy is the client.
+func IPExtractor(cfg ProxyConfig) echo.IPExtractor {
+	header := http.CanonicalHeaderKey(cfg.Header)
+	if header == "" {
+		header = ProxyHeaderXForwardedFor
+	}
+	cloudflare := header == http.CanonicalHeaderKey(ProxyHeaderCloudflare)
+	trusted := func(addr netip.Addr) bool {
+		return helpers.ContainsAddr(cfg.Trusted, addr) || cloudflare && helpers.ContainsAddr(CloudflareRanges, addr)
+	}
+
+	return func(r *http.Request) string {
+		peer, ok := remoteAddr(r.RemoteAddr)
+		if !ok {
+			return r.RemoteAddr
+		}
+		if !trusted(peer) {
+			return peer.String()
+		}
+
+		if cloudflare {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(ProxyHeaderCloudflare))); err == nil {
+				return addr.Unmap().String()
+			}
+			return peer.String()
+		}
+
+		hops := forwardedFor(r.Header, header)
+		client := peer
+		for i := len(hops) - 1; i >= 0 && trusted(client); i-- {
+			if !hops[i].valid {
+				// An obfuscated or garbled hop ends what we can vouch for
+				break
+			}
+			client = hops[i].addr
+		}
+		return client.String()
+	}
+}
+
+// forwardedFor lists the client addresses proxies recorded in header, Forwarded (RFC 7239) or
+// X-Forwarded-For, oldest first. Entries that are no address, like "unknown" or obfuscated
+// identifiers, are kept as invalid.
+func forwardedFor(header http.Header, name string) []hop {
+	var hops []hop
+	if name == ProxyHeaderForwarded {
+		for _, value := range header.Values(ProxyHeaderForwarded) {
+			for element := range strings.SplitSeq(value, ",") {
+				for pair := range strings.SplitSeq(element, ";") {
+					key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+					if strings.EqualFold(key, "for") {
+						hops = append(hops, parseHop(strings.Trim(value, `"`)))
+					}
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range header.Values(echo.HeaderXForwardedFor) {
+		for entry := range strings.SplitSeq(value, ",") {
+			hops = append(hops, parseHop(entry))
+		}
+	}
+	return hops
+}
+
+type hop struct {
+	addr  netip.Addr
+	valid bool
+}
+
+// parseHop reads "1.2.3.4", "1.2.3.4:80", "[2001:db8::1]:80" or "2001:db8::1"
+func parseHop(value string) hop {
+	value = strings.TrimSpace(value)
+	if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		return hop{addr: addr.Unmap(), valid: true}
+	}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return hop{addr: addrPort.Addr().Unmap(), valid: true}
+	}
+	return hop{}
+}
+
+func remoteAddr(value string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		host = value
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,152 @@
+// proxy_test.go
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPExtractor(t *testing.T) {
+	t.Parallel()
+
+	behindProxy := ProxyConfig{Trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	behindForwardedProxy := ProxyConfig{Trusted: behindProxy.Trusted, Header: ProxyHeaderForwarded}
+
+	tests := []struct {
+		name    string
+		cfg     ProxyConfig
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "no proxies trusted ignores forwarding headers",
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "spoofed header from an untrusted peer",
+			cfg:     behindProxy,
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted proxy reports the client",
+			cfg:     behindProxy,
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client supplied entries before the first untrusted hop are ignored",
+			cfg:     behindProxy,
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.3"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "split headers are one chain",
+			cfg:     behindProxy,
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "garbled hop stops the walk",
+			cfg:     behindProxy,
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, not-an-ip"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:   "forged forwarded alongside a proxy appended x-forwarded-for",
+			cfg:    behindProxy,
+			remote: "10.0.0.2:4000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=1.2.3.4"},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:   "forwarded proxy ignores x-forwarded-for",
+			cfg:    behindForwardedProxy,
+			remote: "10.0.0.2:4000",
+			headers: map[string][]string{
+				"Forwarded":       {`for=1.1.1.1, for="[2001:db8:cafe::17]:4711";proto=https;by=10.0.0.2`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded with port and obfuscated identifier",
+			cfg:     behindForwardedProxy,
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"Forwarded": {`For="198.51.100.1:8080"`, "for=_hidden"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "cloudflare connecting ip",
+			cfg:     ProxyConfig{Header: ProxyHeaderCloudflare},
+			remote:  "173.245.48.10:4000",
+			headers: map[string][]string{"Cf-Connecting-Ip": {"198.51.100.1"}, "X-Forwarded-For": {"1.1.1.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "cloudflare header from elsewhere is ignored",
+			cfg:     ProxyConfig{Header: ProxyHeaderCloudflare},
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"Cf-Connecting-Ip": {"198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "cloudflare header is the only one read",
+			cfg:     ProxyConfig{Header: ProxyHeaderCloudflare},
+			remote:  "173.245.48.10:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:    "173.245.48.10",
+		},
+		{
+			name:    "ipv4 mapped peer",
+			cfg:     behindProxy,
+			remote:  "[::ffff:10.0.0.2]:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+
+			assert.Equal(t, tt.want, IPExtractor(tt.cfg)(req))
+		})
+	}
+}
+
+// ——————————————————— BENCHMARKS ———————————————————
+
+func BenchmarkIPExtractor(b *testing.B) {
+	extract := IPExtractor(ProxyConfig{Trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 198.51.100.1, 10.0.0.3")
+
+	for b.Loop() {
+		_ = extract(req)
+	}
+}
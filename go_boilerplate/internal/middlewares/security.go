@@ -18,7 +18,7 @@ func SecurityHeaders() echo.MiddlewareFunc {
 			if err != nil {
 				return err
 			}
-			c.Set("nonce", nonce) // <-- used in templ: {{ .nonce }}
+			c.Set(helpers.NonceKey, nonce) // <-- used in templ: {{ .nonce }}
 
 			// ---- 2. Environment flag (only for HSTS / X-Frame-Options) ----
 			isDev := boot.Environment.GoEnv == enums.Environments.DEVELOPMENT
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/monitoring"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// ServerTimingConfig decides who sees the breakdown, it is collected for every request
+type ServerTimingConfig struct {
+	// Expose reports whether the Server-Timing header is sent for this request
+	Expose func(c echo.Context) bool
+	// SlowThreshold logs the breakdown of requests taking longer, zero disables the log
+	SlowThreshold time.Duration
+}
+
+// ServerTiming puts a monitoring.Timings collector into the request context, fed by the
+// instrumented database, the renderer and anything calling monitoring.Time.
+// Sent as a Server-Timing header it shows up in the network tab of browser devtools.
+func ServerTiming(cfg ServerTimingConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			ctx, timings := monitoring.WithTimings(c.Request().Context())
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			// Headers are final once the status is written, so the total is taken right before
+			c.Response().Before(func() {
+				if cfg.Expose != nil && cfg.Expose(c) {
+					c.Response().Header().Set("Server-Timing", timings.Header(time.Since(start)))
+				}
+			})
+
+			err := next(c)
+
+			if elapsed := time.Since(start); cfg.SlowThreshold > 0 && elapsed >= cfg.SlowThreshold {
+				log.Warnf("Slow request %s %s took %s: %s", c.Request().Method, c.Path(), elapsed.Round(time.Millisecond), timings.Header(elapsed))
+			}
+			return err
+		}
+	}
+}
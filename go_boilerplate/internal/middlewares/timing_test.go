@@ -0,0 +1,73 @@
+// timing_test.go
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/monitoring"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTiming(t *testing.T) {
+	t.Parallel()
+
+	handler := func(c echo.Context) error {
+		ctx := c.Request().Context()
+		monitoring.AddTiming(ctx, "db", 3*time.Millisecond)
+		monitoring.AddTiming(ctx, "db", 2*time.Millisecond)
+		monitoring.AddTiming(ctx, "render", 1500*time.Microsecond)
+		// Nothing records this, it is the handler's own share
+		time.Sleep(10 * time.Millisecond)
+		return c.String(http.StatusOK, "ok")
+	}
+
+	tests := []struct {
+		name   string
+		expose bool
+		want   *regexp.Regexp
+	}{
+		{name: "exposed", expose: true, want: regexp.MustCompile(`^db;dur=5\.0;desc="2 calls", render;dur=1\.5, handler;dur=(\d+\.\d), total;dur=(\d+\.\d)$`)},
+		{name: "hidden", expose: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := echo.New()
+			e.Use(ServerTiming(ServerTimingConfig{Expose: func(echo.Context) bool { return tt.expose }}))
+			e.GET("/", handler)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			if tt.want == nil {
+				assert.Empty(t, rec.Header().Get("Server-Timing"))
+				return
+			}
+			match := tt.want.FindStringSubmatch(rec.Header().Get("Server-Timing"))
+			require.NotNil(t, match, rec.Header().Get("Server-Timing"))
+			handler, _ := strconv.ParseFloat(match[1], 64)
+			total, _ := strconv.ParseFloat(match[2], 64)
+			assert.GreaterOrEqual(t, handler, 3.5)
+			// The handler share is what is left of the total after db and render, give or take rounding
+			assert.InDelta(t, total-6.5, handler, 0.2)
+		})
+	}
+}
+
+func TestTimings_OutsideRequest(t *testing.T) {
+	t.Parallel()
+
+	ctx := httptest.NewRequest(http.MethodGet, "/", nil).Context()
+	assert.Nil(t, monitoring.TimingsFrom(ctx))
+	assert.NotPanics(t, func() { monitoring.Time(ctx, "db")() })
+}
@@ -0,0 +1,42 @@
+package monitoring
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Version is stamped at build time, see the Dockerfile:
+// -ldflags "-X github.com/__username__/go_boilerplate/internal/monitoring.Version=v1.2.3"
+var Version = "dev"
+
+// buildInfo is always 1, the build is described by its labels
+var buildInfo = promauto.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Name:        "app_build_info",
+		Help:        "Version, VCS revision and Go version the server was built from",
+		ConstLabels: buildLabels(),
+	},
+	func() float64 { return 1 },
+)
+
+func buildLabels() prometheus.Labels {
+	labels := prometheus.Labels{
+		"version":    Version,
+		"revision":   "unknown",
+		"go_version": runtime.Version(),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return labels
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			labels["revision"] = setting.Value
+		}
+	}
+	return labels
+}
@@ -39,6 +39,14 @@ var (
 		[]string{"query_type"},
 	)
 
+	dbQueryErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Total number of failed database queries",
+		},
+		[]string{"query_type"},
+	)
+
 	// Example custom metric: Business event counter
 	businessEventsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -56,6 +64,90 @@ var (
 		},
 		[]string{"error_code"},
 	)
+
+	// Scheduled job metrics, labelled with the ID jobs are added under
+	jobRunsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cron_job_runs_total",
+			Help: "Total number of scheduled job runs",
+		},
+		[]string{"job"},
+	)
+
+	jobFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cron_job_failures_total",
+			Help: "Total number of scheduled job runs that panicked",
+		},
+		[]string{"job"},
+	)
+
+	jobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cron_job_duration_seconds",
+			Help:    "Duration of scheduled job runs in seconds",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300},
+		},
+		[]string{"job"},
+	)
+
+	jobLastSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_job_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of a scheduled job",
+		},
+		[]string{"job"},
+	)
+
+	// Background queue metrics, labelled with the kind of job
+	queueJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_jobs_total",
+			Help: "Total number of queued job attempts by kind and outcome (succeeded, retried, dead)",
+		},
+		[]string{"kind", "outcome"},
+	)
+
+	queueJobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_job_duration_seconds",
+			Help:    "Duration of queued job attempts in seconds",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300},
+		},
+		[]string{"kind"},
+	)
+
+	// Websocket metrics, the gauges per room are collected from the connection manager itself
+	websocketEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_events_total",
+			Help: "Total number of websocket events by direction (in, out) and type",
+		},
+		[]string{"direction", "type"},
+	)
+
+	websocketDroppedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_dropped_messages_total",
+			Help: "Total number of outgoing websocket messages that never reached the client",
+		},
+		[]string{"reason"},
+	)
+
+	notificationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notifications_total",
+			Help: "Total number of notifications by sink and outcome (sent, failed, dropped)",
+		},
+		[]string{"sink", "outcome"},
+	)
+
+	websocketDisconnectsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_disconnects_total",
+			Help: "Total number of websocket clients that disconnected",
+		},
+	)
 )
 
 func IncreaseHTTPRequestCount(method, path string, status int) {
@@ -72,11 +164,53 @@ func RecordDBQueryLatency(queryType string, start time.Time) {
 	dbQueryDuration.WithLabelValues(queryType).Observe(duration)
 }
 
+// RecordDBQueryError counts a failed query
+func RecordDBQueryError(queryType string) {
+	dbQueryErrorsTotal.WithLabelValues(queryType).Inc()
+}
+
 // RecordBusinessEvent is a helper to record a business event
 func RecordBusinessEvent(eventType string) {
 	businessEventsTotal.WithLabelValues(eventType).Inc()
 }
 
+// RecordJobRun records a scheduled job run that started at start
+func RecordJobRun(job string, start time.Time, failed bool) {
+	jobRunsTotal.WithLabelValues(job).Inc()
+	jobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if failed {
+		jobFailuresTotal.WithLabelValues(job).Inc()
+		return
+	}
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
+
+// RecordQueueJob records an attempt at a queued job that started at start
+func RecordQueueJob(kind string, start time.Time, outcome string) {
+	queueJobsTotal.WithLabelValues(kind, outcome).Inc()
+	queueJobDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+// RecordWebsocketEvent counts an event received ("in") or sent ("out")
+func RecordWebsocketEvent(direction, eventType string) {
+	websocketEventsTotal.WithLabelValues(direction, eventType).Inc()
+}
+
+// RecordWebsocketDropped counts outgoing messages lost for reason
+func RecordWebsocketDropped(reason string, count int) {
+	websocketDroppedTotal.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordNotification counts a notification that left the queue of sink, or never got into it
+func RecordNotification(sink, outcome string) {
+	notificationsTotal.WithLabelValues(sink, outcome).Inc()
+}
+
+// RecordWebsocketDisconnect counts a client leaving, a wave of them usually means a network problem
+func RecordWebsocketDisconnect() {
+	websocketDisconnectsTotal.Inc()
+}
+
 var (
 	errorCount int64 // production counter
 )
@@ -0,0 +1,90 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type timingsKey struct{}
+
+// Timings adds up where the time of one request went, by name like "db" or "render".
+// It is safe for concurrent use, handlers may fan out queries.
+type Timings struct {
+	mu      sync.Mutex
+	entries []timing
+}
+
+type timing struct {
+	name  string
+	total time.Duration
+	count int
+}
+
+// WithTimings returns a context carrying a fresh collector, see middlewares.ServerTiming
+func WithTimings(ctx context.Context) (context.Context, *Timings) {
+	t := &Timings{}
+	return context.WithValue(ctx, timingsKey{}, t), t
+}
+
+// TimingsFrom returns the collector of the request ctx belongs to, nil outside of one
+func TimingsFrom(ctx context.Context) *Timings {
+	t, _ := ctx.Value(timingsKey{}).(*Timings)
+	return t
+}
+
+// AddTiming adds d under name to the request of ctx, doing nothing outside of a request
+func AddTiming(ctx context.Context, name string, d time.Duration) {
+	if t := TimingsFrom(ctx); t != nil {
+		t.Add(name, d)
+	}
+}
+
+// Time measures until the returned func is called, meant for defer monitoring.Time(ctx, "geocode")()
+func Time(ctx context.Context, name string) func() {
+	start := time.Now()
+	return func() { AddTiming(ctx, name, time.Since(start)) }
+}
+
+func (t *Timings) Add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.entries {
+		if t.entries[i].name == name {
+			t.entries[i].total += d
+			t.entries[i].count++
+			return
+		}
+	}
+	t.entries = append(t.entries, timing{name: name, total: d, count: 1})
+}
+
+// Header formats the timings as a Server-Timing value in the order they were first added,
+// followed by handler, the part of total nothing was recorded for, and total itself.
+// Repeated entries like queries are summed and their count described.
+func (t *Timings) Header(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics := make([]string, 0, len(t.entries)+2)
+	handler := total
+	for _, entry := range t.entries {
+		metric := fmt.Sprintf("%s;dur=%s", entry.name, milliseconds(entry.total))
+		if entry.count > 1 {
+			metric += fmt.Sprintf(`;desc="%d calls"`, entry.count)
+		}
+		metrics = append(metrics, metric)
+		handler -= entry.total
+	}
+	// Entries overlap when a handler fans out, so they can add up to more than the total
+	metrics = append(metrics, "handler;dur="+milliseconds(max(handler, 0)))
+	metrics = append(metrics, "total;dur="+milliseconds(total))
+	return strings.Join(metrics, ", ")
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000)
+}
@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/labstack/gommon/log"
+)
+
+// LogNotifier writes notifications to the log at a level matching their severity,
+// the fallback when nothing else is configured
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (s *LogNotifier) Notify(_ context.Context, n Notification) error {
+	switch n.Severity {
+	case SeverityCritical:
+		log.Errorf("Notification %q: %s", n.Title, n.Message)
+	case SeverityWarning:
+		log.Warnf("Notification %q: %s", n.Title, n.Message)
+	default:
+		log.Infof("Notification %q: %s", n.Title, n.Message)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/__username__/go_boilerplate/internal/mail"
+)
+
+// MailNotifier emails notifications through the mail package, SMTP when MAIL_BACKEND is smtp
+type MailNotifier struct {
+	To []string
+	// Send defaults to mail.Send, which fills in MAIL_FROM
+	Send func(ctx context.Context, msg mail.Message) error
+}
+
+func NewMailNotifier(to ...string) *MailNotifier {
+	recipients := make([]string, 0, len(to))
+	for _, address := range to {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	return &MailNotifier{To: recipients, Send: mail.Send}
+}
+
+func (s *MailNotifier) Notify(ctx context.Context, n Notification) error {
+	severity := n.Severity
+	if severity == "" {
+		severity = SeverityInfo
+	}
+	subject := n.Title
+	if subject == "" {
+		subject, _, _ = strings.Cut(n.Message, "\n")
+	}
+
+	text := n.Message
+	if n.Click != "" {
+		text += "\n\n" + n.Click
+	}
+	if n.Attachment != nil && n.Attachment.URL != "" {
+		text += "\n\nAttachment: " + n.Attachment.URL
+	}
+
+	return s.Send(ctx, mail.Message{
+		To:      s.To,
+		Subject: "[" + string(severity) + "] " + subject,
+		Text:    text,
+	})
+}
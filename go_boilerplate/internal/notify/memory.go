@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier keeps every notification in memory, meant for tests
+type MemoryNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+	// Err is returned by Notify when set, to exercise failure handling
+	Err error
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (s *MemoryNotifier) Notify(_ context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.notifications = append(s.notifications, n)
+	return nil
+}
+
+// Notifications returns a copy of the notifications sent so far
+func (s *MemoryNotifier) Notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.notifications...)
+}
+
+// Last returns the most recent notification
+func (s *MemoryNotifier) Last() (Notification, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.notifications) == 0 {
+		return Notification{}, false
+	}
+	return s.notifications[len(s.notifications)-1], true
+}
+
+// Reset forgets every notification sent so far
+func (s *MemoryNotifier) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = nil
+}
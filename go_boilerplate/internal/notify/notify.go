@@ -0,0 +1,251 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/labstack/gommon/log"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+var severities = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// AtLeast reports whether s is as urgent as other, an empty severity counts as info
+func (s Severity) AtLeast(other Severity) bool {
+	return severities[s] >= severities[other]
+}
+
+// Priority follows ntfy, from 1 (min) to 5 (urgent)
+type Priority int
+
+const (
+	PriorityMin     Priority = 1
+	PriorityLow     Priority = 2
+	PriorityDefault Priority = 3
+	PriorityHigh    Priority = 4
+	PriorityUrgent  Priority = 5
+)
+
+// Attachment is sent along with a notification, either a URL the receiver fetches or the file itself
+type Attachment struct {
+	URL  string
+	Name string
+	Data []byte
+}
+
+// Notification is a message for whoever runs the app, only Message is required
+type Notification struct {
+	Title    string
+	Message  string
+	Severity Severity
+	// Priority defaults to one matching Severity
+	Priority Priority
+	// Tags are ntfy tags, those matching an emoji short code are shown as one
+	Tags []string
+	// Click is opened when the notification is tapped
+	Click      string
+	Attachment *Attachment
+	Markdown   bool
+}
+
+// priority returns the explicit priority or the one severity implies
+func (n Notification) priority() Priority {
+	if n.Priority != 0 {
+		return n.Priority
+	}
+	switch n.Severity {
+	case SeverityCritical:
+		return PriorityUrgent
+	case SeverityWarning:
+		return PriorityHigh
+	default:
+		return PriorityDefault
+	}
+}
+
+// Notifier delivers notifications, implementations must be safe for concurrent use
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Closer is implemented by notifiers that hold queued notifications
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Route sends notifications at least as severe as Min to Notifier
+type Route struct {
+	Min      Severity
+	Notifier Notifier
+}
+
+// Router fans notifications out to every route they are severe enough for
+type Router struct {
+	routes []Route
+}
+
+func NewRouter(routes ...Route) *Router {
+	return &Router{routes: routes}
+}
+
+func (r *Router) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, route := range r.routes {
+		if !n.Severity.AtLeast(route.Min) {
+			continue
+		}
+		if err := route.Notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close drains every route holding a queue
+func (r *Router) Close(ctx context.Context) error {
+	var errs []error
+	for _, route := range r.routes {
+		if closer, ok := route.Notifier.(Closer); ok {
+			errs = append(errs, closer.Close(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var notifier Notifier = NewLogNotifier()
+
+// Setup replaces the notifier used by Send
+func Setup(n Notifier) {
+	notifier = n
+}
+
+// Default returns the notifier configured with Setup
+func Default() Notifier {
+	return notifier
+}
+
+// Send hands n to the configured notifier. Failures are logged and never returned,
+// a notification that cannot be delivered should not fail what it reports on.
+func Send(ctx context.Context, n Notification) {
+	if err := notifier.Notify(ctx, n); err != nil {
+		log.Warnf("Failed to send notification %q: %v", n.Title, err)
+	}
+}
+
+// Close drains the configured notifier, meant for shutdown
+func Close(ctx context.Context) error {
+	if closer, ok := notifier.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// Configure sets up a router following NOTIFY_ROUTES, every sink behind its own queue
+// so a slow or failing sink neither blocks callers nor holds back the others
+func Configure(cfg *boot.Config) error {
+	routes, err := ParseRoutes(cfg.NotifyRoutes)
+	if err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		routes = map[string]Severity{"log": SeverityInfo}
+		if cfg.NTFY != "" {
+			routes["ntfy"] = SeverityInfo
+		}
+	}
+
+	var configured []Route
+	for sink, severity := range routes {
+		var backend Notifier
+		switch sink {
+		case "ntfy":
+			if cfg.NTFY == "" {
+				return fmt.Errorf("NTFY is required to route notifications to ntfy")
+			}
+			backend = NewNtfyNotifier(cfg.NTFY, cfg.NTFYTopic, cfg.NTFYToken)
+		case "webhook":
+			if cfg.NotifyWebhookURL == "" {
+				return fmt.Errorf("NOTIFY_WEBHOOK_URL is required to route notifications to a webhook")
+			}
+			backend = NewWebhookNotifier(cfg.NotifyWebhookURL)
+		case "email":
+			if cfg.NotifyEmail == "" {
+				return fmt.Errorf("NOTIFY_EMAIL is required to route notifications to email")
+			}
+			backend = NewMailNotifier(strings.Split(cfg.NotifyEmail, ",")...)
+		case "log":
+			// Logging is instant and cannot fail, a queue would only delay it
+			configured = append(configured, Route{Min: severity, Notifier: NewLogNotifier()})
+			continue
+		default:
+			return fmt.Errorf("unknown NOTIFY_ROUTES sink %q", sink)
+		}
+		configured = append(configured, Route{Min: severity, Notifier: NewQueue(sink, backend, DefaultQueueOptions())})
+	}
+
+	Setup(NewRouter(configured...))
+	return nil
+}
+
+// ParseRoutes reads "sink=severity,sink=severity", each sink receiving notifications
+// at least as severe as its severity, like "log=info,ntfy=warning,email=critical"
+func ParseRoutes(value string) (map[string]Severity, error) {
+	routes := make(map[string]Severity)
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		sink, severity, ok := strings.Cut(entry, "=")
+		sink, severity = strings.TrimSpace(sink), strings.TrimSpace(severity)
+		if !ok || sink == "" {
+			return nil, fmt.Errorf("invalid NOTIFY_ROUTES entry: %q", entry)
+		}
+		if _, known := severities[Severity(severity)]; !known {
+			return nil, fmt.Errorf("unknown NOTIFY_ROUTES severity %q in %q", severity, entry)
+		}
+		routes[sink] = Severity(severity)
+	}
+	return routes, nil
+}
+
+// StatusError is returned by HTTP sinks when the receiver answers outside 2xx
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return "receiver answered " + e.Status
+}
+
+// retryable tells transient failures from requests that would fail the same way again
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == 429 || status.Code >= 500
+	}
+	return true
+}
+
+// exponentialBackoff doubles the delay after each attempt, starting at base and capped at max
+func exponentialBackoff(base time.Duration, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
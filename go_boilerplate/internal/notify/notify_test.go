@@ -0,0 +1,151 @@
+// notify_test.go
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/__username__/go_boilerplate/internal/mail"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+func TestParseRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]Severity
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string]Severity{}},
+		{name: "several", value: "log=info, ntfy=warning,email=critical", want: map[string]Severity{"log": SeverityInfo, "ntfy": SeverityWarning, "email": SeverityCritical}},
+		{name: "missing severity", value: "ntfy", wantErr: true},
+		{name: "unknown severity", value: "ntfy=loud", wantErr: true},
+		{name: "missing sink", value: "=info", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseRoutes(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRouter_RoutesBySeverity(t *testing.T) {
+	t.Parallel()
+
+	everything, pager := NewMemoryNotifier(), NewMemoryNotifier()
+	router := NewRouter(Route{Min: SeverityInfo, Notifier: everything}, Route{Min: SeverityCritical, Notifier: pager})
+
+	ctx := context.Background()
+	require.NoError(t, router.Notify(ctx, Notification{Message: "deployed"}))
+	require.NoError(t, router.Notify(ctx, Notification{Message: "slow", Severity: SeverityWarning}))
+	require.NoError(t, router.Notify(ctx, Notification{Message: "down", Severity: SeverityCritical}))
+
+	assert.Len(t, everything.Notifications(), 3)
+	require.Len(t, pager.Notifications(), 1)
+	last, _ := pager.Last()
+	assert.Equal(t, "down", last.Message)
+}
+
+func TestRouter_JoinsErrors(t *testing.T) {
+	t.Parallel()
+
+	failing, working := NewMemoryNotifier(), NewMemoryNotifier()
+	failing.Err = errors.New("unreachable")
+	router := NewRouter(Route{Notifier: failing}, Route{Notifier: working})
+
+	err := router.Notify(context.Background(), Notification{Message: "x"})
+	assert.ErrorIs(t, err, failing.Err)
+	assert.Len(t, working.Notifications(), 1)
+}
+
+func TestNotification_Priority(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, PriorityDefault, Notification{}.priority())
+	assert.Equal(t, PriorityHigh, Notification{Severity: SeverityWarning}.priority())
+	assert.Equal(t, PriorityUrgent, Notification{Severity: SeverityCritical}.priority())
+	assert.Equal(t, PriorityLow, Notification{Severity: SeverityCritical, Priority: PriorityLow}.priority())
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL).Notify(context.Background(), Notification{
+		Title:    "Disk full",
+		Message:  strings.Repeat("x", 2500),
+		Severity: SeverityCritical,
+		Click:    "https://example.com/admin",
+	})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(received["text"].(string), "Disk full\nxxx"))
+	assert.True(t, strings.HasSuffix(received["text"].(string), "\nhttps://example.com/admin"))
+	assert.Len(t, []rune(received["content"].(string)), discordLimit)
+	assert.Equal(t, "critical", received["severity"])
+	assert.Equal(t, float64(PriorityUrgent), received["priority"])
+}
+
+func TestWebhookNotifier_StatusError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL).Notify(context.Background(), Notification{Message: "x"})
+	var status *StatusError
+	require.ErrorAs(t, err, &status)
+	assert.Equal(t, http.StatusNotFound, status.Code)
+	assert.False(t, retryable(err))
+}
+
+func TestMailNotifier(t *testing.T) {
+	t.Parallel()
+
+	sender := mail.NewMemorySender()
+	notifier := NewMailNotifier("ops@example.com", " oncall@example.com", "")
+	notifier.Send = func(ctx context.Context, msg mail.Message) error {
+		msg.From = "app@example.com"
+		return sender.Send(ctx, msg)
+	}
+
+	err := notifier.Notify(context.Background(), Notification{Message: "Queue stuck\nsince 10:00", Severity: SeverityWarning, Click: "https://example.com"})
+	require.NoError(t, err)
+
+	msg, ok := sender.Last("oncall@example.com")
+	require.True(t, ok)
+	assert.Equal(t, []string{"ops@example.com", "oncall@example.com"}, msg.To)
+	assert.Equal(t, "[warning] Queue stuck", msg.Subject)
+	assert.Equal(t, "Queue stuck\nsince 10:00\n\nhttps://example.com", msg.Text)
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NtfyNotifier publishes to an ntfy topic, see https://docs.ntfy.sh/publish/
+type NtfyNotifier struct {
+	URL   string
+	Topic string
+	// Token is an ntfy access token, required by servers that protect the topic
+	Token  string
+	client *http.Client
+}
+
+func NewNtfyNotifier(url string, topic string, token string) *NtfyNotifier {
+	if topic == "" {
+		topic = "go_boilerplate"
+	}
+	return &NtfyNotifier{
+		URL:    strings.TrimSuffix(url, "/"),
+		Topic:  topic,
+		Token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *NtfyNotifier) Notify(ctx context.Context, n Notification) error {
+	method, body := http.MethodPost, []byte(n.Message)
+	// A file is uploaded as the body, the message then travels in a header
+	// where ntfy turns a literal \n back into a line break
+	attachFile := n.Attachment != nil && n.Attachment.Data != nil
+	if attachFile {
+		method, body = http.MethodPut, n.Attachment.Data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+"/"+s.Topic, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "go_boilerplate-notify/1")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	// Headers are ASCII only, ntfy decodes RFC 2047 encoded words
+	if n.Title != "" {
+		req.Header.Set("X-Title", mime.QEncoding.Encode("utf-8", n.Title))
+	}
+	req.Header.Set("X-Priority", strconv.Itoa(int(n.priority())))
+	if len(n.Tags) > 0 {
+		req.Header.Set("X-Tags", strings.Join(n.Tags, ","))
+	}
+	if n.Click != "" {
+		req.Header.Set("X-Click", n.Click)
+	}
+	if n.Markdown {
+		req.Header.Set("X-Markdown", "yes")
+	}
+	if n.Attachment != nil {
+		if attachFile {
+			req.Header.Set("X-Message", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(n.Message, "\n", `\n`)))
+		} else {
+			req.Header.Set("X-Attach", n.Attachment.URL)
+		}
+		if n.Attachment.Name != "" {
+			req.Header.Set("X-Filename", n.Attachment.Name)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
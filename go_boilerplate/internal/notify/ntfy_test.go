@@ -0,0 +1,96 @@
+// ntfy_test.go
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfyNotifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		notification Notification
+		method       string
+		body         string
+		headers      map[string]string
+	}{
+		{
+			name:         "plain",
+			notification: Notification{Message: "Deployed"},
+			method:       http.MethodPost,
+			body:         "Deployed",
+			headers:      map[string]string{"X-Priority": "3", "X-Title": "", "X-Tags": "", "Authorization": "Bearer secret-token"},
+		},
+		{
+			name: "everything",
+			notification: Notification{
+				Title:      "Café down",
+				Message:    "**Down** since 10:00",
+				Severity:   SeverityCritical,
+				Tags:       []string{"rotating_light", "critical"},
+				Click:      "https://example.com/admin/alerts",
+				Attachment: &Attachment{URL: "https://example.com/graph.png", Name: "graph.png"},
+				Markdown:   true,
+			},
+			method: http.MethodPost,
+			body:   "**Down** since 10:00",
+			headers: map[string]string{
+				"X-Title":    "=?utf-8?q?Caf=C3=A9_down?=",
+				"X-Priority": "5",
+				"X-Tags":     "rotating_light,critical",
+				"X-Click":    "https://example.com/admin/alerts",
+				"X-Attach":   "https://example.com/graph.png",
+				"X-Filename": "graph.png",
+				"X-Markdown": "yes",
+			},
+		},
+		{
+			name:         "uploaded file",
+			notification: Notification{Message: "Report\nattached", Attachment: &Attachment{Name: "report.csv", Data: []byte("a,b\n1,2\n")}},
+			method:       http.MethodPut,
+			body:         "a,b\n1,2\n",
+			headers:      map[string]string{"X-Message": `Report\nattached`, "X-Filename": "report.csv", "X-Attach": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.method, r.Method)
+				assert.Equal(t, "/alerts", r.URL.Path)
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, tt.body, string(body))
+				for name, want := range tt.headers {
+					assert.Equal(t, want, r.Header.Get(name), name)
+				}
+			}))
+			defer server.Close()
+
+			err := NewNtfyNotifier(server.URL+"/", "alerts", "secret-token").Notify(context.Background(), tt.notification)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNtfyNotifier_RateLimited(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	err := NewNtfyNotifier(server.URL, "", "").Notify(context.Background(), Notification{Message: "x"})
+	require.Error(t, err)
+	assert.True(t, retryable(err))
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/monitoring"
+	"github.com/labstack/gommon/log"
+)
+
+// ErrQueueFull is returned when a queue drops a notification instead of blocking the caller
+var ErrQueueFull = errors.New("notify: queue is full")
+
+// ErrQueueClosed is returned for notifications sent after Close
+var ErrQueueClosed = errors.New("notify: queue is closed")
+
+type QueueOptions struct {
+	// Size is how many notifications may wait, more are dropped
+	Size    int
+	Workers int
+	// MaxAttempts per notification, transient failures are retried until then
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+}
+
+// DefaultQueueOptions retry for about a minute, enough to ride out a restart of the receiver
+func DefaultQueueOptions() QueueOptions {
+	return QueueOptions{
+		Size:        100,
+		Workers:     1,
+		MaxAttempts: 5,
+		Backoff:     exponentialBackoff(2*time.Second, 30*time.Second),
+		Timeout:     10 * time.Second,
+	}
+}
+
+// Queue delivers notifications to next in the background, retrying transient failures with backoff.
+// Notify never blocks, a full queue drops the notification.
+type Queue struct {
+	name string
+	next Notifier
+	opts QueueOptions
+	jobs chan Notification
+
+	// ctx is cancelled when Close runs out of time, aborting attempts and backoffs
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewQueue starts the workers, name labels the queue in logs and metrics
+func NewQueue(name string, next Notifier, opts QueueOptions) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		name:   name,
+		next:   next,
+		opts:   opts,
+		jobs:   make(chan Notification, opts.Size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for range max(opts.Workers, 1) {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) Notify(_ context.Context, n Notification) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- n:
+		return nil
+	default:
+		monitoring.RecordNotification(q.name, "dropped")
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting notifications and waits for the queued ones until ctx is done,
+// after that the remaining ones are given up
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for n := range q.jobs {
+		q.deliver(n)
+	}
+}
+
+func (q *Queue) deliver(n Notification) {
+	for attempt := 1; ; attempt++ {
+		if q.ctx.Err() != nil {
+			monitoring.RecordNotification(q.name, "failed")
+			log.Errorf("Notification %q to %s abandoned on shutdown", n.Title, q.name)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(q.ctx, q.opts.Timeout)
+		err := q.next.Notify(ctx, n)
+		cancel()
+		if err == nil {
+			monitoring.RecordNotification(q.name, "sent")
+			return
+		}
+
+		if attempt >= q.opts.MaxAttempts || !retryable(err) {
+			monitoring.RecordNotification(q.name, "failed")
+			log.Errorf("Notification %q to %s failed after %d attempts: %v", n.Title, q.name, attempt, err)
+			return
+		}
+
+		delay := q.opts.Backoff(attempt)
+		log.Warnf("Notification %q to %s attempt %d failed, retrying in %s: %v", n.Title, q.name, attempt, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-q.ctx.Done():
+		}
+	}
+}
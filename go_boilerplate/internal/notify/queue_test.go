@@ -0,0 +1,127 @@
+// queue_test.go
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flaky fails its first failures calls with err
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	calls    int
+	release  chan struct{}
+	MemoryNotifier
+}
+
+func (f *flaky) Notify(ctx context.Context, n Notification) error {
+	if f.release != nil {
+		<-f.release
+	}
+	f.mu.Lock()
+	f.calls++
+	fail := f.calls <= f.failures
+	f.mu.Unlock()
+	if fail {
+		return f.err
+	}
+	return f.MemoryNotifier.Notify(ctx, n)
+}
+
+func (f *flaky) attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func testQueueOptions() QueueOptions {
+	return QueueOptions{Size: 10, Workers: 1, MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Millisecond }, Timeout: time.Second}
+}
+
+func TestQueue_RetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	backend := &flaky{failures: 2, err: errors.New("connection refused")}
+	queue := NewQueue("test", backend, testQueueOptions())
+
+	require.NoError(t, queue.Notify(context.Background(), Notification{Message: "x"}))
+	require.NoError(t, queue.Close(context.Background()))
+
+	assert.Equal(t, 3, backend.attempts())
+	assert.Len(t, backend.Notifications(), 1)
+}
+
+func TestQueue_GivesUp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "after max attempts", err: &StatusError{Code: 503, Status: "503 Service Unavailable"}, want: 3},
+		{name: "on permanent errors", err: &StatusError{Code: 401, Status: "401 Unauthorized"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			backend := &flaky{failures: 10, err: tt.err}
+			queue := NewQueue("test", backend, testQueueOptions())
+
+			require.NoError(t, queue.Notify(context.Background(), Notification{Message: "x"}))
+			require.NoError(t, queue.Close(context.Background()))
+
+			assert.Equal(t, tt.want, backend.attempts())
+			assert.Empty(t, backend.Notifications())
+		})
+	}
+}
+
+func TestQueue_DropsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	backend := &flaky{release: make(chan struct{})}
+	opts := testQueueOptions()
+	opts.Size = 2
+	queue := NewQueue("test", backend, opts)
+
+	// The worker holds one notification while the other two fill the queue
+	require.NoError(t, queue.Notify(context.Background(), Notification{Message: "1"}))
+	require.Eventually(t, func() bool { return len(queue.jobs) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Notify(context.Background(), Notification{Message: "2"}))
+	require.NoError(t, queue.Notify(context.Background(), Notification{Message: "3"}))
+	assert.ErrorIs(t, queue.Notify(context.Background(), Notification{Message: "4"}), ErrQueueFull)
+
+	close(backend.release)
+	require.NoError(t, queue.Close(context.Background()))
+	assert.Len(t, backend.Notifications(), 3)
+	assert.ErrorIs(t, queue.Notify(context.Background(), Notification{Message: "5"}), ErrQueueClosed)
+}
+
+func TestQueue_CloseTimesOut(t *testing.T) {
+	t.Parallel()
+
+	backend := &flaky{failures: 1000, err: errors.New("connection refused")}
+	opts := testQueueOptions()
+	opts.MaxAttempts = 1000
+	opts.Backoff = func(int) time.Duration { return time.Hour }
+	queue := NewQueue("test", backend, opts)
+
+	require.NoError(t, queue.Notify(context.Background(), Notification{Message: "x"}))
+	require.Eventually(t, func() bool { return backend.attempts() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Close(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, backend.attempts())
+}
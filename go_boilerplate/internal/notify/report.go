@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/helpers"
+)
+
+// ReportSink forwards reporter entries of at least Min to the notifier set up with Setup.
+// The same message is only sent once per Every, a failing dependency errors on every request.
+type ReportSink struct {
+	lock sync.Mutex
+	last map[string]time.Time
+
+	// Notifier defaults to the one configured with Setup
+	Notifier Notifier
+	Min      helpers.SeverityType
+	Every    time.Duration
+	Now      func() time.Time
+}
+
+func NewReportSink(min helpers.SeverityType) *ReportSink {
+	return &ReportSink{
+		last:  make(map[string]time.Time),
+		Min:   min,
+		Every: 5 * time.Minute,
+		Now:   time.Now,
+	}
+}
+
+func (s *ReportSink) Write(entry helpers.Entry) error {
+	if !entry.Level.AtLeast(s.Min) || !s.first(entry) {
+		return nil
+	}
+
+	n := Notification{
+		Title:    fmt.Sprintf("%s reported", entry.Level),
+		Message:  entry.Message,
+		Severity: SeverityWarning,
+		Tags:     []string{"warning"},
+	}
+	if entry.Level == helpers.SeverityLevels.PANIC {
+		n.Severity, n.Tags = SeverityCritical, []string{"rotating_light"}
+	}
+	if entry.Route != "" {
+		n.Message = fmt.Sprintf("%s %s (%d, request %s): %s", entry.Method, entry.Route, entry.Status, entry.RequestID, entry.Message)
+	}
+
+	// Configured sinks only queue, so a slow notifier never holds up the request reporting
+	notifier := s.Notifier
+	if notifier == nil {
+		notifier = Default()
+	}
+	return notifier.Notify(context.Background(), n)
+}
+
+// first reports whether the message of entry was not sent within Every, forgetting older ones as it goes
+func (s *ReportSink) first(entry helpers.Entry) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.Now()
+	for message, sent := range s.last {
+		if now.Sub(sent) >= s.Every {
+			delete(s.last, message)
+		}
+	}
+	key := string(entry.Level) + entry.Route + entry.Message
+	if _, ok := s.last[key]; ok {
+		return false
+	}
+	s.last[key] = now
+	return true
+}
@@ -0,0 +1,40 @@
+// report_test.go
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportSink(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	memory := NewMemoryNotifier()
+	sink := NewReportSink(helpers.SeverityLevels.ERROR)
+	sink.Notifier = memory
+	sink.Now = func() time.Time { return now }
+
+	require.NoError(t, sink.Write(helpers.Entry{Level: helpers.SeverityLevels.WARN, Message: "slow"}))
+	assert.Empty(t, memory.Notifications(), "below Min")
+
+	failed := helpers.Entry{Level: helpers.SeverityLevels.ERROR, Message: "db down", Method: "GET", Route: "/users/:id", Status: 500, RequestID: "abc"}
+	require.NoError(t, sink.Write(failed))
+	require.NoError(t, sink.Write(failed))
+	require.Len(t, memory.Notifications(), 1, "repeats are held back")
+	last, _ := memory.Last()
+	assert.Equal(t, SeverityWarning, last.Severity)
+	assert.Equal(t, "GET /users/:id (500, request abc): db down", last.Message)
+
+	require.NoError(t, sink.Write(helpers.Entry{Level: helpers.SeverityLevels.PANIC, Message: "nil map"}))
+	last, _ = memory.Last()
+	assert.Equal(t, SeverityCritical, last.Severity)
+
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, sink.Write(failed))
+	assert.Len(t, memory.Notifications(), 3, "sent again once Every passed")
+}
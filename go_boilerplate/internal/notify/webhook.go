@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// discordLimit is the longest content Discord accepts in a webhook message
+const discordLimit = 2000
+
+// WebhookNotifier POSTs notifications as JSON. The payload carries "text" for Slack and Mattermost
+// incoming webhooks and "content" for Discord next to the structured fields for anything else.
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type webhookPayload struct {
+	Text     string   `json:"text"`
+	Content  string   `json:"content"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+	Priority Priority `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	URL      string   `json:"url,omitempty"`
+}
+
+func (s *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	severity := n.Severity
+	if severity == "" {
+		severity = SeverityInfo
+	}
+	text := n.Message
+	if n.Title != "" {
+		text = n.Title + "\n" + text
+	}
+	if n.Click != "" {
+		text += "\n" + n.Click
+	}
+	content := text
+	if runes := []rune(content); len(runes) > discordLimit {
+		content = string(runes[:discordLimit-1]) + "…"
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Text:     text,
+		Content:  content,
+		Title:    n.Title,
+		Message:  n.Message,
+		Severity: severity,
+		Priority: n.priority(),
+		Tags:     n.Tags,
+		URL:      n.Click,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_boilerplate-notify/1")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
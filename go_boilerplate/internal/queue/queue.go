@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Job statuses. A job is claimed as running and leased until run_at, a failed job waits there for
+// its next attempt and a dead one for Retry.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusDead      = "dead"
+)
+
+// Channel is the LISTEN/NOTIFY channel the queue_jobs trigger wakes workers on
+const Channel = "queue_jobs"
+
+// DefaultMaxAttempts is how many times a job is tried unless enqueued WithMaxAttempts
+const DefaultMaxAttempts = 10
+
+var (
+	// ErrDuplicate is returned by Enqueue when a job of the same kind and unique key is still to run
+	ErrDuplicate = errors.New("job already enqueued")
+	// ErrNotDead is returned by Retry for a job that is missing or still being tried
+	ErrNotDead = errors.New("job is not dead")
+)
+
+// Args are the arguments of a kind of job, stored as JSON. Kind names the handler they go to, it
+// must not change while jobs enqueued under it may still run. Declare it on the value, not the pointer.
+type Args interface {
+	Kind() string
+}
+
+type enqueueOptions struct {
+	priority    int
+	runAt       time.Time
+	uniqueKey   string
+	maxAttempts int
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+// WithPriority runs the job before the due jobs of lower priority, the default is 0
+func WithPriority(priority int) EnqueueOption {
+	return func(o *enqueueOptions) { o.priority = priority }
+}
+
+// WithRunAt keeps the job until t, instead of running it as soon as a worker is free
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// WithUniqueKey drops the job when a job of the same kind and key is pending, running or waiting
+// for a retry. Once that one succeeded or died, the key is free again.
+func WithUniqueKey(key string) EnqueueOption {
+	return func(o *enqueueOptions) { o.uniqueKey = key }
+}
+
+// WithMaxAttempts dead-letters the job after n failed attempts
+func WithMaxAttempts(n int) EnqueueOption {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// Enqueue stores a job through repo and returns its id. Pass repo.WithTx(tx) so the job is only
+// seen once the transaction it belongs to commits, and never if it rolls back.
+func Enqueue(ctx context.Context, repo *repository.Queries, args Args, options ...EnqueueOption) (uuid.UUID, error) {
+	o := enqueueOptions{maxAttempts: DefaultMaxAttempts}
+	for _, option := range options {
+		option(&o)
+	}
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id := uuid.New()
+	params := repository.EnqueueQueueJobParams{
+		ID:          id,
+		Kind:        args.Kind(),
+		Args:        payload,
+		Priority:    int32(o.priority),
+		MaxAttempts: int32(max(o.maxAttempts, 1)),
+		// Jobs without a time are due on the database clock, the one workers claim with
+		RunAt: pgtype.Timestamp{Time: o.runAt.UTC(), Valid: !o.runAt.IsZero()},
+	}
+	if o.uniqueKey != "" {
+		params.UniqueKey = &o.uniqueKey
+	}
+
+	inserted, err := repo.EnqueueQueueJob(ctx, params)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if inserted == 0 {
+		return uuid.Nil, ErrDuplicate
+	}
+	return id, nil
+}
+
+// Retry queues a dead job again, with all its attempts
+func Retry(ctx context.Context, repo *repository.Queries, id uuid.UUID) error {
+	retried, err := repo.RetryQueueJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	if retried == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
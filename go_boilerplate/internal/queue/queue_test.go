@@ -0,0 +1,155 @@
+// queue_test.go
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/gommon/log"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() { log.SetLevel(log.OFF) }
+
+type resizeImage struct {
+	Path  string `json:"path"`
+	Width int    `json:"width"`
+}
+
+func (resizeImage) Kind() string { return "resize_image" }
+
+// capture is a pgxmock argument matcher that keeps what it was matched against
+type capture struct{ value *[]byte }
+
+func (c capture) Match(v any) bool {
+	b, ok := v.([]byte)
+	*c.value = b
+	return ok
+}
+
+func TestEnqueue(t *testing.T) {
+	t.Parallel()
+
+	runAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	key := "avatars/1.png"
+	tests := []struct {
+		name        string
+		options     []EnqueueOption
+		priority    int32
+		maxAttempts int32
+		runAt       pgtype.Timestamp
+		uniqueKey   *string
+	}{
+		{"defaults", nil, 0, DefaultMaxAttempts, pgtype.Timestamp{}, nil},
+		{
+			"options",
+			[]EnqueueOption{WithPriority(5), WithMaxAttempts(3), WithRunAt(runAt), WithUniqueKey(key)},
+			5, 3, pgtype.Timestamp{Time: runAt.UTC(), Valid: true}, &key,
+		},
+		{"at least one attempt", []EnqueueOption{WithMaxAttempts(0)}, 0, 1, pgtype.Timestamp{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			var payload []byte
+			mock.ExpectExec("INSERT INTO queue_jobs").
+				WithArgs(pgxmock.AnyArg(), "resize_image", capture{&payload}, tt.priority, tt.maxAttempts, tt.runAt, tt.uniqueKey).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+			id, err := Enqueue(context.Background(), repository.New(mock), resizeImage{Path: key, Width: 128}, tt.options...)
+			require.NoError(t, err)
+			assert.NotEqual(t, uuid.Nil, id)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			var stored resizeImage
+			require.NoError(t, json.Unmarshal(payload, &stored))
+			assert.Equal(t, resizeImage{Path: key, Width: 128}, stored)
+		})
+	}
+}
+
+func TestEnqueue_Duplicate(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectExec("INSERT INTO queue_jobs").WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	id, err := Enqueue(context.Background(), repository.New(mock), resizeImage{Path: "a.png"}, WithUniqueKey("a.png"))
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.Equal(t, uuid.Nil, id)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueue_InTransaction(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WithArgs("a.png").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO queue_jobs").WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+	tx, err := mock.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, "UPDATE users SET avatar = $1", "a.png")
+	require.NoError(t, err)
+	_, err = Enqueue(ctx, repository.New(mock).WithTx(tx), resizeImage{Path: "a.png"})
+	require.NoError(t, err)
+	// Rolling back drops the job with the change it followed
+	require.NoError(t, tx.Rollback(ctx))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		retried int64
+		wantErr error
+	}{
+		{"dead job", 1, nil},
+		{"job still being tried", 0, ErrNotDead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			id := uuid.New()
+			mock.ExpectExec("UPDATE queue_jobs").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", tt.retried))
+
+			err = Retry(context.Background(), repository.New(mock), id)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
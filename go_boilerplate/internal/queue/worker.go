@@ -0,0 +1,347 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/monitoring"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/gommon/log"
+)
+
+// Job is the attempt a handler is called for
+type Job struct {
+	ID       uuid.UUID
+	Kind     string
+	Priority int
+	// Attempt counts from 1, the job is dead-lettered when attempt MaxAttempts fails
+	Attempt     int
+	MaxAttempts int
+	Created     time.Time
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error no retry can fix, like arguments pointing to a deleted row. The job is
+// dead-lettered right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type handler func(ctx context.Context, job Job, args []byte) error
+
+// Worker runs the queued jobs of the kinds given to Register, up to Concurrency at a time. It looks
+// for due jobs every PollInterval, and right away when Listen hears of a new one.
+type Worker struct {
+	repo     *repository.Queries
+	mu       sync.RWMutex
+	handlers map[string]handler
+
+	Concurrency int
+	// Lease is how long an attempt may take. It is cancelled past it, as another worker may then
+	// claim the job again.
+	Lease        time.Duration
+	PollInterval time.Duration
+	Backoff      func(attempt int) time.Duration
+	Now          func() time.Time
+	// OnDead is called when a job has used up its attempts or failed for good
+	OnDead func(job Job, err error)
+
+	wake     chan struct{}
+	stopping chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	active   atomic.Int32
+	fetching sync.WaitGroup
+	running  sync.WaitGroup
+
+	state   sync.Mutex
+	started bool
+	stopped bool
+}
+
+// NewWorker returns a worker reading through repo, with default settings
+func NewWorker(repo *repository.Queries) *Worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Worker{
+		repo:         repo,
+		handlers:     make(map[string]handler),
+		Concurrency:  4,
+		Lease:        5 * time.Minute,
+		PollInterval: 5 * time.Second,
+		Backoff:      exponentialBackoff(10*time.Second, time.Hour),
+		Now:          time.Now,
+		wake:         make(chan struct{}, 1),
+		stopping:     make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Register hands the jobs enqueued with args of type T to handler. A job whose arguments no longer
+// decode into T is dead-lettered.
+func Register[T Args](w *Worker, handler func(ctx context.Context, job Job, args T) error) {
+	var kind T
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers[kind.Kind()] = func(ctx context.Context, job Job, payload []byte) error {
+		var args T
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return Permanent(fmt.Errorf("failed to decode the arguments: %w", err))
+		}
+		return handler(ctx, job, args)
+	}
+}
+
+// kinds lists the kinds this worker claims, the others are left to workers that know them
+func (w *Worker) kinds() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// Start claims and runs jobs in the background until Stop. Calling it again, or after Stop, does nothing.
+func (w *Worker) Start() {
+	w.state.Lock()
+	defer w.state.Unlock()
+
+	if w.started || w.stopped {
+		return
+	}
+	w.started = true
+	w.fetching.Add(1)
+	go w.fetch()
+}
+
+// Stop stops claiming jobs and waits for the running ones. When ctx ends first, they are cancelled
+// and ctx.Err() is returned. A job whose failure could not be recorded by then is tried again once
+// its lease expires.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.state.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stopping)
+	}
+	w.state.Unlock()
+	w.fetching.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(done)
+	}()
+	defer w.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wake makes the worker look for due jobs now
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Listen wakes the worker whenever a job due right away is enqueued, until ctx ends. It holds one
+// connection of pool, opening it again when lost, the poll covers the gap.
+func (w *Worker) Listen(ctx context.Context, pool *pgxpool.Pool) {
+	for {
+		err := w.listen(ctx, pool)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnf("Lost the job queue notifications, listening again shortly: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) listen(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A wait interrupted by ctx closes the connection, the pool then drops it
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+		w.Wake()
+	}
+}
+
+func (w *Worker) fetch() {
+	defer w.fetching.Done()
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopping:
+			return
+		default:
+		}
+
+		// A full batch means more jobs may be due already
+		free := w.Concurrency - int(w.active.Load())
+		if free > 0 && w.claim(free) == free {
+			continue
+		}
+
+		select {
+		case <-w.stopping:
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim leases up to batch due jobs and starts them, returning how many it claimed
+func (w *Worker) claim(batch int) int {
+	kinds := w.kinds()
+	if len(kinds) == 0 {
+		return 0
+	}
+	rows, err := w.repo.ClaimQueueJobs(w.ctx, repository.ClaimQueueJobsParams{
+		LeaseUntil: w.Now().Add(w.Lease).UTC(),
+		Kinds:      kinds,
+		Batch:      int32(batch),
+	})
+	if err != nil {
+		log.Errorf("Failed to claim queued jobs: %v", err)
+		return 0
+	}
+
+	for _, row := range rows {
+		w.active.Add(1)
+		w.running.Add(1)
+		go func() {
+			defer w.running.Done()
+			w.process(row)
+			w.active.Add(-1)
+			// The free slot may take a job that is already due
+			w.Wake()
+		}()
+	}
+	return len(rows)
+}
+
+// process runs one attempt at a claimed job and records how it went
+func (w *Worker) process(row repository.ClaimQueueJobsRow) {
+	job := Job{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Priority:    int(row.Priority),
+		Attempt:     int(row.Attempts),
+		MaxAttempts: int(row.MaxAttempts),
+		Created:     row.Created,
+	}
+	w.mu.RLock()
+	handle := w.handlers[row.Kind]
+	w.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(w.ctx, w.Lease)
+	defer cancel()
+	start := time.Now()
+	err := call(ctx, handle, job, row.Args)
+
+	// A cancelled attempt is recorded too, the job waits for its retry instead of its lease
+	recordCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		if err := w.repo.CompleteQueueJob(recordCtx, job.ID); err != nil {
+			log.Errorf("Failed to complete queued job %s: %v", job.ID, err)
+		}
+		monitoring.RecordQueueJob(job.Kind, start, "succeeded")
+		return
+	}
+
+	status, next := StatusFailed, w.Now().Add(w.Backoff(job.Attempt))
+	var permanent *permanentError
+	if job.Attempt >= job.MaxAttempts || errors.As(err, &permanent) {
+		status = StatusDead
+	}
+	if err := w.repo.FailQueueJob(recordCtx, repository.FailQueueJobParams{ID: job.ID, Status: status, RunAt: next.UTC(), LastError: err.Error()}); err != nil {
+		log.Errorf("Failed to record failure of queued job %s: %v", job.ID, err)
+	}
+
+	if status == StatusDead {
+		monitoring.RecordQueueJob(job.Kind, start, "dead")
+		log.Errorf("Queued job %s (%s) dead-lettered after %d attempts: %v", job.ID, job.Kind, job.Attempt, err)
+		if w.OnDead != nil {
+			w.OnDead(job, err)
+		}
+		return
+	}
+	monitoring.RecordQueueJob(job.Kind, start, "retried")
+	log.Warnf("Queued job %s (%s) attempt %d failed, retrying at %s: %v", job.ID, job.Kind, job.Attempt, next.Format(time.RFC3339), err)
+}
+
+// call runs handle, turning a panic into the failure of the attempt
+func call(ctx context.Context, handle handler, job Job, args []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Queued job %s (%s) panicked: %v\n%s", job.ID, job.Kind, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if handle == nil {
+		return fmt.Errorf("no handler for kind %s", job.Kind)
+	}
+	return handle(ctx, job, args)
+}
+
+// Cleanup removes succeeded and dead jobs finished over 30 days ago, meant to be scheduled with tools.Func
+func (w *Worker) Cleanup() {
+	removed, err := w.repo.DeleteOldQueueJobs(context.Background(), w.Now().UTC().AddDate(0, 0, -30))
+	if err != nil {
+		log.Errorf("Failed to clean up the job queue: %v", err)
+		return
+	}
+	log.Debugf("Removed %d jobs from the queue", removed)
+}
+
+// exponentialBackoff doubles the delay after each attempt, starting at base and capped at max
+func exponentialBackoff(base time.Duration, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
@@ -0,0 +1,200 @@
+// worker_test.go
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newWorker(t *testing.T) (*Worker, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	w := NewWorker(repository.New(mock))
+	w.Now = func() time.Time { return now }
+	w.Backoff = func(attempt int) time.Duration { return time.Duration(attempt) * time.Minute }
+	return w, mock
+}
+
+func claimedRow(id uuid.UUID, args string, attempts int32) repository.ClaimQueueJobsRow {
+	return repository.ClaimQueueJobsRow{ID: id, Kind: "resize_image", Args: []byte(args), Attempts: attempts, MaxAttempts: 3, Created: now}
+}
+
+func TestWorker_Process(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       string
+		attempts   int32
+		runErr     error
+		panics     bool
+		wantStatus string
+		wantError  string
+	}{
+		{name: "succeeded", args: `{"path":"a.png"}`, attempts: 1},
+		{name: "failure is retried", args: `{"path":"a.png"}`, attempts: 1, runErr: errors.New("disk full"), wantStatus: StatusFailed, wantError: "disk full"},
+		{name: "panic is retried", args: `{"path":"a.png"}`, attempts: 2, panics: true, wantStatus: StatusFailed, wantError: "panic: boom"},
+		{name: "last attempt is dead-lettered", args: `{"path":"a.png"}`, attempts: 3, runErr: errors.New("disk full"), wantStatus: StatusDead, wantError: "disk full"},
+		{name: "permanent failure is dead-lettered", args: `{"path":"a.png"}`, attempts: 1, runErr: Permanent(errors.New("image deleted")), wantStatus: StatusDead, wantError: "image deleted"},
+		{name: "undecodable arguments are dead-lettered", args: `{"path":1}`, attempts: 1, wantStatus: StatusDead, wantError: "failed to decode the arguments: json: cannot unmarshal number into Go struct field resizeImage.path of type string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w, mock := newWorker(t)
+			var dead atomic.Int32
+			w.OnDead = func(job Job, err error) {
+				assert.Equal(t, "resize_image", job.Kind)
+				dead.Add(1)
+			}
+			Register(w, func(ctx context.Context, job Job, args resizeImage) error {
+				assert.Equal(t, "a.png", args.Path)
+				assert.Equal(t, int(tt.attempts), job.Attempt)
+				if tt.panics {
+					panic("boom")
+				}
+				return tt.runErr
+			})
+
+			id := uuid.New()
+			if tt.wantStatus == "" {
+				mock.ExpectExec("UPDATE queue_jobs").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			} else {
+				retryAt := now.Add(time.Duration(tt.attempts) * time.Minute)
+				mock.ExpectExec("UPDATE queue_jobs").WithArgs(id, tt.wantStatus, retryAt, tt.wantError).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			}
+
+			w.process(claimedRow(id, tt.args, tt.attempts))
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			wantDead := int32(0)
+			if tt.wantStatus == StatusDead {
+				wantDead = 1
+			}
+			assert.Equal(t, wantDead, dead.Load())
+		})
+	}
+}
+
+func TestWorker_ClaimsRegisteredKindsOnly(t *testing.T) {
+	t.Parallel()
+
+	w, mock := newWorker(t)
+	assert.Zero(t, w.claim(4), "nothing is claimed without handlers")
+
+	Register(w, func(context.Context, Job, resizeImage) error { return nil })
+	mock.ExpectQuery("UPDATE queue_jobs").
+		WithArgs(now.Add(w.Lease), []string{"resize_image"}, int32(4)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "kind", "args", "priority", "attempts", "max_attempts", "created"}))
+
+	assert.Zero(t, w.claim(4))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorker_StopDrainsRunningJobs(t *testing.T) {
+	t.Parallel()
+
+	w, mock := newWorker(t)
+	w.PollInterval = time.Hour
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished atomic.Bool
+	Register(w, func(ctx context.Context, job Job, args resizeImage) error {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	})
+
+	id := uuid.New()
+	mock.ExpectQuery("UPDATE queue_jobs").WithArgs(pgxmock.AnyArg(), []string{"resize_image"}, int32(4)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "kind", "args", "priority", "attempts", "max_attempts", "created"}).
+			AddRow(id, "resize_image", []byte(`{"path":"a.png"}`), int32(0), int32(1), int32(3), now))
+	mock.ExpectExec("UPDATE queue_jobs").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	w.Start()
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- w.Stop(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-stopped)
+	assert.True(t, finished.Load())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorker_StopCancelsJobsPastTheDeadline(t *testing.T) {
+	t.Parallel()
+
+	w, mock := newWorker(t)
+	w.PollInterval = time.Hour
+	started := make(chan struct{})
+	Register(w, func(ctx context.Context, job Job, args resizeImage) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	id := uuid.New()
+	mock.ExpectQuery("UPDATE queue_jobs").WithArgs(pgxmock.AnyArg(), []string{"resize_image"}, int32(4)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "kind", "args", "priority", "attempts", "max_attempts", "created"}).
+			AddRow(id, "resize_image", []byte(`{"path":"a.png"}`), int32(0), int32(1), int32(3), now))
+	recorded := make(chan struct{})
+	mock.ExpectExec("UPDATE queue_jobs").WithArgs(id, StatusFailed, now.Add(time.Minute), context.Canceled.Error()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	w.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Stop(ctx), context.DeadlineExceeded)
+
+	// The cancelled attempt is still recorded, to be retried
+	go func() {
+		w.running.Wait()
+		close(recorded)
+	}()
+	<-recorded
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorker_WakeDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	w := NewWorker(nil)
+	for range 3 {
+		w.Wake()
+	}
+	assert.Len(t, w.wake, 1)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := exponentialBackoff(10*time.Second, time.Minute)
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 20: time.Minute} {
+		assert.Equal(t, want, backoff(attempt), "attempt %d", attempt)
+	}
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/cmd/boot"
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// Limiter applies named policies against one store
+type Limiter struct {
+	store     Store
+	policies  map[string]Policy
+	allowlist []netip.Prefix
+	Now       func() time.Time
+}
+
+// New returns a limiter enforcing policies, requests from allowlist bypass all of them
+func New(store Store, policies map[string]Policy, allowlist []netip.Prefix) (*Limiter, error) {
+	for _, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Limiter{store: store, policies: policies, allowlist: allowlist, Now: time.Now}, nil
+}
+
+// FromConfig builds a limiter from RATE_LIMITS and RATE_LIMIT_ALLOWLIST,
+// configured policies replace the default policy of the same name
+func FromConfig(cfg *boot.Config, store Store) (*Limiter, error) {
+	policies := DefaultPolicies(cfg.GoEnv)
+	configured, err := ParsePolicies(cfg.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(policies, configured)
+
+	allowlist, err := ParseAllowlist(cfg.RateLimitAllowlist)
+	if err != nil {
+		return nil, err
+	}
+	return New(store, policies, allowlist)
+}
+
+// Limit returns a middleware enforcing the named policy, attach it to a group or a single route.
+// Naming a policy that does not exist is a programming error and panics at startup.
+func (l *Limiter) Limit(name string) echo.MiddlewareFunc {
+	policy, ok := l.policies[name]
+	if !ok {
+		panic("ratelimit: unknown policy " + name)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.allowed(c.RealIP()) {
+				return next(c)
+			}
+
+			result, err := l.store.Take(c.Request().Context(), policy.Name+":"+policy.Key(c), policy, l.Now())
+			if err != nil {
+				// A broken store must not take the site down with it
+				log.Errorf("Rate limit store failed for policy %s, letting request through: %v", policy.Name, err)
+				return next(c)
+			}
+
+			setHeaders(c.Response().Header(), policy, result)
+			if !result.Allowed {
+				return deny(c, policy, result)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (l *Limiter) allowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return helpers.ContainsAddr(l.allowlist, addr)
+}
+
+// setHeaders writes the IETF RateLimit header fields, times are rounded up to whole seconds
+func setHeaders(header http.Header, policy Policy, result Result) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", seconds(result.Reset))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s;burst=%d", policy.Rate, seconds(policy.Window), policy.Burst))
+}
+
+func deny(c echo.Context, policy Policy, result Result) error {
+	retryIn := seconds(result.RetryAfter)
+	c.Response().Header().Set("Retry-After", retryIn)
+
+	if strings.Contains(c.Request().Header.Get("Accept"), "application/json") {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{
+			"error":   "Rate limit exceeded",
+			"retryIn": retryIn,
+		})
+	}
+	return apperrors.SendReturnedGenericHTMLError(c, apperrors.GenericError{Code: http.StatusTooManyRequests, Message: fmt.Sprintf("Rate limit %s exceeded by %s", policy.Name, c.RealIP()), UserMessage: "Too many requests, try again in " + retryIn + " seconds"}, nil)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,109 @@
+// limiter_test.go
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Policy, time.Time) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+func newTestServer(t *testing.T, store Store, allowlist []netip.Prefix) *echo.Echo {
+	t.Helper()
+
+	limiter, err := New(store, map[string]Policy{"test": testPolicy}, allowlist)
+	require.NoError(t, err)
+	limiter.Now = func() time.Time { return testNow }
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, limiter.Limit("test"))
+	return e
+}
+
+func request(e *echo.Echo, ip string, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":5678"
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	t.Parallel()
+
+	e := newTestServer(t, NewMemoryStore(), nil)
+
+	rec := request(e, "1.2.3.4", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "6", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "10;w=60;burst=3", rec.Header().Get("RateLimit-Policy"))
+
+	request(e, "1.2.3.4", "")
+	request(e, "1.2.3.4", "")
+
+	rec = request(e, "1.2.3.4", "application/json")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "6", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.JSONEq(t, `{"error":"Rate limit exceeded","retryIn":"6"}`, rec.Body.String())
+
+	rec = request(e, "1.2.3.4", "text/html")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Too many requests")
+
+	assert.Equal(t, http.StatusOK, request(e, "5.6.7.8", "").Code, "other clients are unaffected")
+}
+
+func TestLimiter_Allowlist(t *testing.T) {
+	t.Parallel()
+
+	e := newTestServer(t, NewMemoryStore(), []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	for range 10 {
+		rec := request(e, "10.1.2.3", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestLimiter_FailsOpen(t *testing.T) {
+	t.Parallel()
+
+	e := newTestServer(t, failingStore{}, nil)
+
+	assert.Equal(t, http.StatusOK, request(e, "1.2.3.4", "").Code)
+}
+
+func TestLimiter_UnknownPolicyPanics(t *testing.T) {
+	t.Parallel()
+
+	limiter, err := New(NewMemoryStore(), DefaultPolicies("production"), nil)
+	require.NoError(t, err)
+
+	assert.Panics(t, func() { limiter.Limit("missing") })
+}
+
+func TestNew_RejectsInvalidPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(NewMemoryStore(), map[string]Policy{"broken": {Name: "broken", Rate: 1, Window: time.Second, Burst: 1}}, nil)
+	assert.Error(t, err)
+}
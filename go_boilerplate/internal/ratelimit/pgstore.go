@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+)
+
+// PostgresStore keeps buckets in the rate_limits table, so all instances share one allowance.
+// Each request costs a round trip, attach it to the policies that need a cluster wide limit.
+type PostgresStore struct {
+	repo *repository.Queries
+}
+
+func NewPostgresStore(repo *repository.Queries) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	// Timestamps go in as UTC wall clock, so every instance writes the same representation
+	now = now.UTC()
+
+	tat, err := s.repo.TakeRateLimit(ctx, repository.TakeRateLimitParams{
+		Key:       key,
+		Now:       now,
+		Interval:  policy.interval().Seconds(),
+		Tolerance: policy.tolerance().Seconds(),
+	})
+	if err == nil {
+		return allowed(tat, now, policy), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Result{}, err
+	}
+
+	// The update was refused, read the bucket back to tell the client when to retry
+	tat, err = s.repo.GetRateLimit(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	return denied(tat, now, policy), nil
+}
+
+// CleanupRateLimits removes buckets that refilled, meant to be scheduled with tools.Func
+func CleanupRateLimits() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteExpiredRateLimits(context.Background(), time.Now().UTC())
+	if err != nil {
+		log.Errorf("Failed to clean up rate limits: %v", err)
+		return
+	}
+	log.Debugf("Removed %d rate limit buckets", removed)
+}
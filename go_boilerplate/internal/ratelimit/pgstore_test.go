@@ -0,0 +1,48 @@
+// pgstore_test.go
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStore_Take(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	s := NewPostgresStore(repository.New(mock))
+	now := testNow.UTC()
+
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("test:k", now, 6.0, 18.0).
+		WillReturnRows(pgxmock.NewRows([]string{"tat"}).AddRow(now.Add(6 * time.Second)))
+
+	result, err := s.Take(context.Background(), "test:k", testPolicy, testNow)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+
+	// A refused update returns no row, the bucket is read back for Retry-After
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("test:k", now, 6.0, 18.0).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("SELECT tat FROM rate_limits").WithArgs("test:k").
+		WillReturnRows(pgxmock.NewRows([]string{"tat"}).AddRow(now.Add(18 * time.Second)))
+
+	result, err = s.Take(context.Background(), "test:k", testPolicy, testNow)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 6*time.Second, result.RetryAfter)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
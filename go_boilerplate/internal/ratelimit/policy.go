@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+// Policy allows Rate requests per Window, of which up to Burst may arrive at once
+type Policy struct {
+	Name   string
+	Rate   int
+	Window time.Duration
+	Burst  int
+	// Key names the bucket a request is counted in
+	Key KeyFunc
+}
+
+// KeyFunc returns the bucket for a request, requests with the same key share one allowance
+type KeyFunc func(c echo.Context) string
+
+// Keys are the key functions policies can name in configuration
+var Keys = map[string]KeyFunc{
+	"ip":     ByIP,
+	"user":   ByUser,
+	"apikey": ByAPIKey,
+	"route":  ByRoute,
+}
+
+// ByIP counts requests per client address
+func ByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// ByUser counts requests per signed in user, anonymous requests per address
+func ByUser(c echo.Context) string {
+	if user, ok := c.Get(helpers.UserKey).(string); ok && user != "" {
+		return "user:" + user
+	}
+	return ByIP(c)
+}
+
+// ByAPIKey counts requests per X-API-Key or bearer token, falling back to the address.
+// Keys are hashed so stores never hold usable credentials.
+func ByAPIKey(c echo.Context) string {
+	key := c.Request().Header.Get("X-API-Key")
+	if key == "" {
+		key, _ = strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	}
+	if key == "" {
+		return ByIP(c)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "apikey:" + hex.EncodeToString(sum[:16])
+}
+
+// ByRoute shares one allowance between all clients of a route, for protecting expensive endpoints
+func ByRoute(c echo.Context) string {
+	return "route:" + c.Request().Method + " " + c.Path()
+}
+
+// interval is the time one request takes to be refilled
+func (p Policy) interval() time.Duration {
+	return p.Window / time.Duration(p.Rate)
+}
+
+// tolerance is how far ahead of the steady rate a client may get, which is the burst
+func (p Policy) tolerance() time.Duration {
+	return p.interval() * time.Duration(p.Burst)
+}
+
+func (p Policy) validate() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("rate limit policy without a name")
+	case p.Rate <= 0 || p.Window <= 0:
+		return fmt.Errorf("rate limit policy %s needs a positive rate and window", p.Name)
+	case p.Burst <= 0:
+		return fmt.Errorf("rate limit policy %s needs a positive burst", p.Name)
+	case p.Key == nil:
+		return fmt.Errorf("rate limit policy %s has no key function", p.Name)
+	}
+	return nil
+}
+
+// DefaultPolicies are used for any policy RATE_LIMITS does not define.
+// web covers pages, auth the sign in forms and api the JSON API.
+func DefaultPolicies(env enums.Environment) map[string]Policy {
+	web := Policy{Name: "web", Rate: 50, Window: time.Second, Burst: 80, Key: ByIP}
+	if env == enums.Environments.DEVELOPMENT {
+		// Generous limits for testing
+		web.Rate, web.Burst = 100, 200
+	}
+
+	return map[string]Policy{
+		"web":  web,
+		"auth": {Name: "auth", Rate: 10, Window: time.Minute, Burst: 10, Key: ByIP},
+		"api":  {Name: "api", Rate: 1000, Window: time.Hour, Burst: 100, Key: ByAPIKey},
+	}
+}
+
+// ParsePolicies reads "name:rate/window[:burst[:key]],..." like "auth:10/1m:5:ip,api:1000/1h:100:apikey".
+// Burst defaults to the rate and key to ip.
+func ParsePolicies(value string) (map[string]Policy, error) {
+	policies := make(map[string]Policy)
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 4 {
+			return nil, fmt.Errorf("invalid RATE_LIMITS entry: %q", entry)
+		}
+
+		rate, window, ok := strings.Cut(fields[1], "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid RATE_LIMITS rate in %q, want requests/window", entry)
+		}
+		policy := Policy{Name: fields[0], Key: ByIP}
+		var err error
+		if policy.Rate, err = strconv.Atoi(rate); err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMITS rate in %q: %w", entry, err)
+		}
+		if policy.Window, err = time.ParseDuration(window); err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMITS window in %q: %w", entry, err)
+		}
+		policy.Burst = policy.Rate
+		if len(fields) > 2 && fields[2] != "" {
+			if policy.Burst, err = strconv.Atoi(fields[2]); err != nil {
+				return nil, fmt.Errorf("invalid RATE_LIMITS burst in %q: %w", entry, err)
+			}
+		}
+		if len(fields) > 3 {
+			if policy.Key, ok = Keys[fields[3]]; !ok {
+				return nil, fmt.Errorf("unknown RATE_LIMITS key %q in %q", fields[3], entry)
+			}
+		}
+
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+		policies[policy.Name] = policy
+	}
+	return policies, nil
+}
+
+// ParseAllowlist reads comma separated addresses and CIDR ranges that are never limited
+func ParseAllowlist(value string) ([]netip.Prefix, error) {
+	allowlist, err := helpers.ParseCIDRs(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ALLOWLIST: %w", err)
+	}
+	return allowlist, nil
+}
@@ -0,0 +1,119 @@
+// policy_test.go
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/enums"
+	"github.com/__username__/go_boilerplate/internal/helpers"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+func TestParsePolicies(t *testing.T) {
+	t.Parallel()
+
+	policies, err := ParsePolicies(" auth:5/1m , api:1000/1h:100:apikey,export:2/10s::route ")
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+
+	auth := policies["auth"]
+	assert.Equal(t, 5, auth.Rate)
+	assert.Equal(t, time.Minute, auth.Window)
+	assert.Equal(t, 5, auth.Burst, "burst defaults to the rate")
+
+	api := policies["api"]
+	assert.Equal(t, 100, api.Burst)
+	assert.Equal(t, time.Hour, api.Window)
+
+	assert.Equal(t, 2, policies["export"].Burst)
+
+	empty, err := ParsePolicies("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestParsePolicies_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{
+		"auth",
+		"auth:5",
+		"auth:x/1m",
+		"auth:5/soon",
+		"auth:0/1m",
+		"auth:5/1m:0",
+		"auth:5/1m:5:cookie",
+		"auth:5/1m:5:ip:extra",
+	} {
+		_, err := ParsePolicies(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	t.Parallel()
+
+	allowlist, err := ParseAllowlist("127.0.0.1, 10.1.2.3/8,::1")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}, allowlist)
+
+	_, err = ParseAllowlist("localhost")
+	assert.Error(t, err)
+}
+
+func TestDefaultPolicies(t *testing.T) {
+	t.Parallel()
+
+	for _, env := range []enums.Environment{enums.Environments.DEVELOPMENT, enums.Environments.PRODUCTION} {
+		for name, policy := range DefaultPolicies(env) {
+			assert.Equal(t, name, policy.Name)
+			assert.NoError(t, policy.validate())
+		}
+	}
+	assert.Greater(t, DefaultPolicies(enums.Environments.DEVELOPMENT)["web"].Rate, DefaultPolicies(enums.Environments.PRODUCTION)["web"].Rate)
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	newContext := func(headers map[string]string) echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+		req.RemoteAddr = "1.2.3.4:5678"
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetPath("/items/:id")
+		return c
+	}
+
+	assert.Equal(t, "ip:1.2.3.4", ByIP(newContext(nil)))
+	assert.Equal(t, "route:GET /items/:id", ByRoute(newContext(nil)))
+
+	assert.Equal(t, "ip:1.2.3.4", ByUser(newContext(nil)), "anonymous requests fall back to the address")
+	c := newContext(nil)
+	c.Set(helpers.UserKey, "user-1")
+	assert.Equal(t, "user:user-1", ByUser(c))
+
+	assert.Equal(t, "ip:1.2.3.4", ByAPIKey(newContext(nil)))
+	header := ByAPIKey(newContext(map[string]string{"X-API-Key": "key-1"}))
+	bearer := ByAPIKey(newContext(map[string]string{"Authorization": "Bearer key-1"}))
+	assert.Equal(t, header, bearer)
+	assert.NotContains(t, header, "key-1", "keys are stored hashed")
+}
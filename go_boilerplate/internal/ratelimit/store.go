@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of spending one request from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of every bucket. Take must be atomic per key,
+// so concurrent requests can never spend more than the policy allows.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+}
+
+// gcra applies the generic cell rate algorithm to a bucket whose theoretical arrival time is tat,
+// returning the new tat to store when the request is allowed
+func gcra(tat time.Time, now time.Time, policy Policy) (time.Time, Result) {
+	interval, tolerance := policy.interval(), policy.tolerance()
+
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	if allowAt := next.Add(-tolerance); allowAt.After(now) {
+		return tat, denied(tat, now, policy)
+	}
+	return next, allowed(next, now, policy)
+}
+
+func allowed(tat time.Time, now time.Time, policy Policy) Result {
+	used := tat.Sub(now)
+	return Result{
+		Allowed:   true,
+		Limit:     policy.Burst,
+		Remaining: int((policy.tolerance() - used) / policy.interval()),
+		Reset:     used,
+	}
+}
+
+func denied(tat time.Time, now time.Time, policy Policy) Result {
+	return Result{
+		Limit:      policy.Burst,
+		Reset:      max(tat.Sub(now), 0),
+		RetryAfter: max(tat.Add(policy.interval()-policy.tolerance()).Sub(now), 0),
+	}
+}
+
+// MemoryStore keeps buckets in process, each instance of a deployment counts on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time
+	lastSweep time.Time
+	// SweepInterval is how often full buckets are dropped
+	SweepInterval time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]time.Time), SweepInterval: time.Minute}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	tat, result := gcra(s.buckets[key], now, policy)
+	if result.Allowed {
+		s.buckets[key] = tat
+	}
+	return result, nil
+}
+
+// sweep drops buckets that refilled, they behave exactly like missing ones
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.SweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, tat := range s.buckets {
+		if !tat.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
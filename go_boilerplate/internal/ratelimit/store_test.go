@@ -0,0 +1,103 @@
+// store_test.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testNow    = time.Unix(1_700_000_000, 0)
+	testPolicy = Policy{Name: "test", Rate: 10, Window: time.Minute, Burst: 3, Key: ByIP}
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	// The burst can be spent at once
+	for want := 2; want >= 0; want-- {
+		result, err := s.Take(ctx, "k", testPolicy, testNow)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, want, result.Remaining)
+	}
+
+	result, err := s.Take(ctx, "k", testPolicy, testNow)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 6*time.Second, result.RetryAfter, "one request refills every 6 seconds")
+	assert.Equal(t, 18*time.Second, result.Reset)
+
+	// Other keys have their own allowance
+	result, err = s.Take(ctx, "other", testPolicy, testNow)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = s.Take(ctx, "k", testPolicy, testNow.Add(6*time.Second))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, err = s.Take(ctx, "k", testPolicy, testNow.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "an idle bucket refills completely")
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore()
+	_, err := s.Take(context.Background(), "k", testPolicy, testNow)
+	require.NoError(t, err)
+	require.Len(t, s.buckets, 1)
+
+	_, err = s.Take(context.Background(), "other", testPolicy, testNow.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.NotContains(t, s.buckets, "k")
+}
+
+func TestMemoryStore_ConcurrentTakesNeverExceedBurst(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+
+	for range 50 {
+		wg.Go(func() {
+			result, err := s.Take(context.Background(), "k", testPolicy, testNow)
+			if err == nil && result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, testPolicy.Burst, allowed)
+}
+
+// ——————————————————— BENCHMARKS ———————————————————
+
+func BenchmarkMemoryStore_Take(b *testing.B) {
+	s := NewMemoryStore()
+	policy := Policy{Name: "bench", Rate: 1_000_000, Window: time.Second, Burst: 1_000_000, Key: ByIP}
+	ctx := context.Background()
+
+	for b.Loop() {
+		_, _ = s.Take(ctx, "k", policy, testNow)
+	}
+}
@@ -0,0 +1,215 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_log.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteAuditEntries = `-- name: DeleteAuditEntries :execrows
+DELETE FROM audit_log
+WHERE seq <= (SELECT max(seq) FROM audit_log WHERE occurred < $1::timestamp)
+`
+
+// Cuts by sequence rather than time, so an entry written while the clock stepped back can't leave a gap
+func (q *Queries) DeleteAuditEntries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteAuditEntries, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enableAuditRetention = `-- name: EnableAuditRetention :exec
+SELECT set_config('audit.retention', 'on', true)
+`
+
+func (q *Queries) EnableAuditRetention(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, enableAuditRetention)
+	return err
+}
+
+const getLastAuditHash = `-- name: GetLastAuditHash :one
+SELECT hash FROM audit_log
+ORDER BY seq DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastAuditHash(ctx context.Context) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getLastAuditHash)
+	var hash []byte
+	err := row.Scan(&hash)
+	return hash, err
+}
+
+const insertAuditEntry = `-- name: InsertAuditEntry :exec
+INSERT INTO audit_log (id, occurred, actor_id, actor, action, entity_type, entity_id, diff, ip, user_agent, request_id, prev_hash, hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+`
+
+type InsertAuditEntryParams struct {
+	ID         uuid.UUID `json:"id"`
+	Occurred   time.Time `json:"occurred"`
+	ActorID    string    `json:"actor_id"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	Diff       []byte    `json:"diff"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	RequestID  string    `json:"request_id"`
+	PrevHash   []byte    `json:"prev_hash"`
+	Hash       []byte    `json:"hash"`
+}
+
+func (q *Queries) InsertAuditEntry(ctx context.Context, arg InsertAuditEntryParams) error {
+	_, err := q.db.Exec(ctx, insertAuditEntry,
+		arg.ID,
+		arg.Occurred,
+		arg.ActorID,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Diff,
+		arg.Ip,
+		arg.UserAgent,
+		arg.RequestID,
+		arg.PrevHash,
+		arg.Hash,
+	)
+	return err
+}
+
+const listAuditChain = `-- name: ListAuditChain :many
+SELECT seq, id, occurred, actor_id, actor, action, entity_type, entity_id, diff, ip, user_agent, request_id, prev_hash, hash FROM audit_log
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2
+`
+
+type ListAuditChainParams struct {
+	Seq   int64 `json:"seq"`
+	Limit int32 `json:"limit"`
+}
+
+func (q *Queries) ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditChain, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.Seq,
+			&i.ID,
+			&i.Occurred,
+			&i.ActorID,
+			&i.Actor,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.Diff,
+			&i.Ip,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEntries = `-- name: ListAuditEntries :many
+SELECT seq, id, occurred, actor_id, actor, action, entity_type, entity_id, diff, ip, user_agent, request_id, prev_hash, hash FROM audit_log
+WHERE ($2::text IS NULL OR actor = $2 OR actor_id = $2)
+  AND ($3::text IS NULL OR action = $3)
+  AND ($4::text IS NULL OR entity_type = $4)
+  AND ($5::text IS NULL OR entity_id = $5)
+  AND ($6::timestamp IS NULL OR occurred >= $6)
+  AND ($7::timestamp IS NULL OR occurred < $7)
+  AND ($8::bigint IS NULL OR seq < $8)
+ORDER BY seq DESC
+LIMIT $1
+`
+
+type ListAuditEntriesParams struct {
+	Limit      int32            `json:"limit"`
+	Actor      *string          `json:"actor"`
+	Action     *string          `json:"action"`
+	EntityType *string          `json:"entity_type"`
+	EntityID   *string          `json:"entity_id"`
+	Since      pgtype.Timestamp `json:"since"`
+	Until      pgtype.Timestamp `json:"until"`
+	BeforeSeq  *int64           `json:"before_seq"`
+}
+
+func (q *Queries) ListAuditEntries(ctx context.Context, arg ListAuditEntriesParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditEntries,
+		arg.Limit,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Since,
+		arg.Until,
+		arg.BeforeSeq,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.Seq,
+			&i.ID,
+			&i.Occurred,
+			&i.ActorID,
+			&i.Actor,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.Diff,
+			&i.Ip,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAuditLog = `-- name: LockAuditLog :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_log'))
+`
+
+// Serializes writers until commit, so every entry chains onto the one committed before it
+func (q *Queries) LockAuditLog(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockAuditLog)
+	return err
+}
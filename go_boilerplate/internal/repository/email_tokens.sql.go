@@ -0,0 +1,108 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: email_tokens.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const consumeEmailToken = `-- name: ConsumeEmailToken :one
+UPDATE email_tokens
+SET used = NOW()
+WHERE id = $1 AND purpose = $2 AND used IS NULL AND expires > NOW()
+RETURNING user_id, email
+`
+
+type ConsumeEmailTokenParams struct {
+	ID      string `json:"id"`
+	Purpose string `json:"purpose"`
+}
+
+type ConsumeEmailTokenRow struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+}
+
+func (q *Queries) ConsumeEmailToken(ctx context.Context, arg ConsumeEmailTokenParams) (ConsumeEmailTokenRow, error) {
+	row := q.db.QueryRow(ctx, consumeEmailToken, arg.ID, arg.Purpose)
+	var i ConsumeEmailTokenRow
+	err := row.Scan(&i.UserID, &i.Email)
+	return i, err
+}
+
+const countRecentEmailTokens = `-- name: CountRecentEmailTokens :one
+SELECT COUNT(*) FROM email_tokens
+WHERE email = $1 AND created > $2
+`
+
+type CountRecentEmailTokensParams struct {
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+}
+
+func (q *Queries) CountRecentEmailTokens(ctx context.Context, arg CountRecentEmailTokensParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRecentEmailTokens, arg.Email, arg.Created)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createEmailToken = `-- name: CreateEmailToken :exec
+INSERT INTO email_tokens (id, user_id, purpose, email, expires)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateEmailTokenParams struct {
+	ID      string    `json:"id"`
+	UserID  uuid.UUID `json:"user_id"`
+	Purpose string    `json:"purpose"`
+	Email   string    `json:"email"`
+	Expires time.Time `json:"expires"`
+}
+
+func (q *Queries) CreateEmailToken(ctx context.Context, arg CreateEmailTokenParams) error {
+	_, err := q.db.Exec(ctx, createEmailToken,
+		arg.ID,
+		arg.UserID,
+		arg.Purpose,
+		arg.Email,
+		arg.Expires,
+	)
+	return err
+}
+
+const deleteExpiredEmailTokens = `-- name: DeleteExpiredEmailTokens :execrows
+DELETE FROM email_tokens
+WHERE expires <= NOW()
+`
+
+func (q *Queries) DeleteExpiredEmailTokens(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredEmailTokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUnusedEmailTokens = `-- name: RevokeUnusedEmailTokens :exec
+UPDATE email_tokens
+SET used = NOW()
+WHERE user_id = $1 AND purpose = $2 AND used IS NULL
+`
+
+type RevokeUnusedEmailTokensParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Purpose string    `json:"purpose"`
+}
+
+// Revoked rows are kept until they expire, CountRecentEmailTokens counts them
+func (q *Queries) RevokeUnusedEmailTokens(ctx context.Context, arg RevokeUnusedEmailTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeUnusedEmailTokens, arg.UserID, arg.Purpose)
+	return err
+}
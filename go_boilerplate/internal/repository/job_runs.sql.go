@@ -0,0 +1,214 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: job_runs.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acquireJobLease = `-- name: AcquireJobLease :one
+INSERT INTO job_leases AS l (job, instance, scheduled, expires)
+VALUES ($1, $2, $3::timestamp, NOW() + make_interval(secs => $4::float8))
+ON CONFLICT (job) DO UPDATE
+SET instance = EXCLUDED.instance, scheduled = EXCLUDED.scheduled, expires = EXCLUDED.expires
+WHERE l.expires <= NOW() AND l.scheduled < EXCLUDED.scheduled
+RETURNING expires
+`
+
+type AcquireJobLeaseParams struct {
+	Job       string    `json:"job"`
+	Instance  string    `json:"instance"`
+	Scheduled time.Time `json:"scheduled"`
+	Ttl       float64   `json:"ttl"`
+}
+
+// Claims the run of job due at scheduled, no row comes back when another instance holds the
+// lease or already claimed that run
+func (q *Queries) AcquireJobLease(ctx context.Context, arg AcquireJobLeaseParams) (time.Time, error) {
+	row := q.db.QueryRow(ctx, acquireJobLease,
+		arg.Job,
+		arg.Instance,
+		arg.Scheduled,
+		arg.Ttl,
+	)
+	var expires time.Time
+	err := row.Scan(&expires)
+	return expires, err
+}
+
+const deleteOldJobRuns = `-- name: DeleteOldJobRuns :execrows
+DELETE FROM job_runs
+WHERE started < $1::timestamp
+`
+
+func (q *Queries) DeleteOldJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldJobRuns, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const finishJobRun = `-- name: FinishJobRun :exec
+UPDATE job_runs
+SET finished = NOW(), status = $2, error = $3, log = $4
+WHERE id = $1
+`
+
+type FinishJobRunParams struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	Error  string    `json:"error"`
+	Log    string    `json:"log"`
+}
+
+func (q *Queries) FinishJobRun(ctx context.Context, arg FinishJobRunParams) error {
+	_, err := q.db.Exec(ctx, finishJobRun,
+		arg.ID,
+		arg.Status,
+		arg.Error,
+		arg.Log,
+	)
+	return err
+}
+
+const listJobRuns = `-- name: ListJobRuns :many
+SELECT id, job, instance, manual, scheduled, started, finished, status, error, log FROM job_runs
+WHERE job = $1
+ORDER BY started DESC
+LIMIT $2
+`
+
+type ListJobRunsParams struct {
+	Job   string `json:"job"`
+	Limit int32  `json:"limit"`
+}
+
+func (q *Queries) ListJobRuns(ctx context.Context, arg ListJobRunsParams) ([]JobRun, error) {
+	rows, err := q.db.Query(ctx, listJobRuns, arg.Job, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []JobRun
+	for rows.Next() {
+		var i JobRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.Job,
+			&i.Instance,
+			&i.Manual,
+			&i.Scheduled,
+			&i.Started,
+			&i.Finished,
+			&i.Status,
+			&i.Error,
+			&i.Log,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobSuccesses = `-- name: ListJobSuccesses :many
+SELECT job, MAX(finished)::timestamp AS last_success
+FROM job_runs
+WHERE status = 'succeeded'
+GROUP BY job
+`
+
+type ListJobSuccessesRow struct {
+	Job         string    `json:"job"`
+	LastSuccess time.Time `json:"last_success"`
+}
+
+func (q *Queries) ListJobSuccesses(ctx context.Context) ([]ListJobSuccessesRow, error) {
+	rows, err := q.db.Query(ctx, listJobSuccesses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListJobSuccessesRow
+	for rows.Next() {
+		var i ListJobSuccessesRow
+		if err := rows.Scan(&i.Job, &i.LastSuccess); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseJobLease = `-- name: ReleaseJobLease :exec
+UPDATE job_leases
+SET expires = NOW()
+WHERE job = $1 AND instance = $2
+`
+
+type ReleaseJobLeaseParams struct {
+	Job      string `json:"job"`
+	Instance string `json:"instance"`
+}
+
+func (q *Queries) ReleaseJobLease(ctx context.Context, arg ReleaseJobLeaseParams) error {
+	_, err := q.db.Exec(ctx, releaseJobLease, arg.Job, arg.Instance)
+	return err
+}
+
+const renewJobLease = `-- name: RenewJobLease :execrows
+UPDATE job_leases
+SET expires = NOW() + make_interval(secs => $1::float8)
+WHERE job = $2 AND instance = $3
+`
+
+type RenewJobLeaseParams struct {
+	Ttl      float64 `json:"ttl"`
+	Job      string  `json:"job"`
+	Instance string  `json:"instance"`
+}
+
+func (q *Queries) RenewJobLease(ctx context.Context, arg RenewJobLeaseParams) (int64, error) {
+	result, err := q.db.Exec(ctx, renewJobLease, arg.Ttl, arg.Job, arg.Instance)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const startJobRun = `-- name: StartJobRun :exec
+INSERT INTO job_runs (id, job, instance, manual, scheduled)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type StartJobRunParams struct {
+	ID        uuid.UUID `json:"id"`
+	Job       string    `json:"job"`
+	Instance  string    `json:"instance"`
+	Manual    bool      `json:"manual"`
+	Scheduled time.Time `json:"scheduled"`
+}
+
+func (q *Queries) StartJobRun(ctx context.Context, arg StartJobRunParams) error {
+	_, err := q.db.Exec(ctx, startJobRun,
+		arg.ID,
+		arg.Job,
+		arg.Instance,
+		arg.Manual,
+		arg.Scheduled,
+	)
+	return err
+}
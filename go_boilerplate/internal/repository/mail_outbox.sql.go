@@ -0,0 +1,126 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: mail_outbox.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimMail = `-- name: ClaimMail :many
+UPDATE mail_outbox
+SET status = 'sending', attempts = attempts + 1, next_attempt = $1
+WHERE id IN (
+  SELECT id FROM mail_outbox
+  WHERE status IN ('pending', 'failed', 'sending') AND next_attempt <= NOW()
+  ORDER BY next_attempt
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, message, attempts
+`
+
+type ClaimMailParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Batch      int32     `json:"batch"`
+}
+
+type ClaimMailRow struct {
+	ID       uuid.UUID `json:"id"`
+	Message  []byte    `json:"message"`
+	Attempts int32     `json:"attempts"`
+}
+
+func (q *Queries) ClaimMail(ctx context.Context, arg ClaimMailParams) ([]ClaimMailRow, error) {
+	rows, err := q.db.Query(ctx, claimMail, arg.LeaseUntil, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimMailRow
+	for rows.Next() {
+		var i ClaimMailRow
+		if err := rows.Scan(&i.ID, &i.Message, &i.Attempts); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeMail = `-- name: CompleteMail :exec
+UPDATE mail_outbox
+SET status = 'sent', sent = NOW(), last_error = ''
+WHERE id = $1
+`
+
+func (q *Queries) CompleteMail(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, completeMail, id)
+	return err
+}
+
+const deleteOldMail = `-- name: DeleteOldMail :execrows
+DELETE FROM mail_outbox
+WHERE status IN ('sent', 'dead') AND created < $1::timestamp
+`
+
+func (q *Queries) DeleteOldMail(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldMail, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueMail = `-- name: EnqueueMail :exec
+INSERT INTO mail_outbox (id, recipients, subject, message)
+VALUES ($1, $2, $3, $4)
+`
+
+type EnqueueMailParams struct {
+	ID         uuid.UUID `json:"id"`
+	Recipients []string  `json:"recipients"`
+	Subject    string    `json:"subject"`
+	Message    []byte    `json:"message"`
+}
+
+func (q *Queries) EnqueueMail(ctx context.Context, arg EnqueueMailParams) error {
+	_, err := q.db.Exec(ctx, enqueueMail,
+		arg.ID,
+		arg.Recipients,
+		arg.Subject,
+		arg.Message,
+	)
+	return err
+}
+
+const failMail = `-- name: FailMail :exec
+UPDATE mail_outbox
+SET status = $2, next_attempt = $3, last_error = $4
+WHERE id = $1
+`
+
+type FailMailParams struct {
+	ID          uuid.UUID `json:"id"`
+	Status      string    `json:"status"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+}
+
+func (q *Queries) FailMail(ctx context.Context, arg FailMailParams) error {
+	_, err := q.db.Exec(ctx, failMail,
+		arg.ID,
+		arg.Status,
+		arg.NextAttempt,
+		arg.LastError,
+	)
+	return err
+}
@@ -8,12 +8,198 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditLog struct {
+	Seq        int64     `json:"seq"`
+	ID         uuid.UUID `json:"id"`
+	Occurred   time.Time `json:"occurred"`
+	ActorID    string    `json:"actor_id"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	Diff       []byte    `json:"diff"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	RequestID  string    `json:"request_id"`
+	PrevHash   []byte    `json:"prev_hash"`
+	Hash       []byte    `json:"hash"`
+}
+
+type EmailToken struct {
+	ID      string           `json:"id"`
+	UserID  uuid.UUID        `json:"user_id"`
+	Purpose string           `json:"purpose"`
+	Email   string           `json:"email"`
+	Used    pgtype.Timestamp `json:"used"`
+	Created time.Time        `json:"created"`
+	Expires time.Time        `json:"expires"`
+}
+
+type JobLease struct {
+	Job       string    `json:"job"`
+	Instance  string    `json:"instance"`
+	Scheduled time.Time `json:"scheduled"`
+	Expires   time.Time `json:"expires"`
+}
+
+type JobRun struct {
+	ID        uuid.UUID        `json:"id"`
+	Job       string           `json:"job"`
+	Instance  string           `json:"instance"`
+	Manual    bool             `json:"manual"`
+	Scheduled time.Time        `json:"scheduled"`
+	Started   time.Time        `json:"started"`
+	Finished  pgtype.Timestamp `json:"finished"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error"`
+	Log       string           `json:"log"`
+}
+
+type MailOutbox struct {
+	ID          uuid.UUID        `json:"id"`
+	Recipients  []string         `json:"recipients"`
+	Subject     string           `json:"subject"`
+	Message     []byte           `json:"message"`
+	Status      string           `json:"status"`
+	Attempts    int32            `json:"attempts"`
+	NextAttempt time.Time        `json:"next_attempt"`
+	LastError   string           `json:"last_error"`
+	Created     time.Time        `json:"created"`
+	Sent        pgtype.Timestamp `json:"sent"`
+}
+
+type Passkey struct {
+	ID           uuid.UUID        `json:"id"`
+	UserID       uuid.UUID        `json:"user_id"`
+	CredentialID []byte           `json:"credential_id"`
+	Name         string           `json:"name"`
+	Credential   []byte           `json:"credential"`
+	SignCount    int64            `json:"sign_count"`
+	Created      time.Time        `json:"created"`
+	LastUsed     pgtype.Timestamp `json:"last_used"`
+}
+
+type QueueJob struct {
+	ID          uuid.UUID        `json:"id"`
+	Kind        string           `json:"kind"`
+	Args        []byte           `json:"args"`
+	Priority    int32            `json:"priority"`
+	Status      string           `json:"status"`
+	Attempts    int32            `json:"attempts"`
+	MaxAttempts int32            `json:"max_attempts"`
+	RunAt       time.Time        `json:"run_at"`
+	UniqueKey   *string          `json:"unique_key"`
+	LastError   string           `json:"last_error"`
+	Created     time.Time        `json:"created"`
+	Finished    pgtype.Timestamp `json:"finished"`
+}
+
+type RateLimit struct {
+	Key string    `json:"key"`
+	Tat time.Time `json:"tat"`
+}
+
+type RecoveryCode struct {
+	ID       uuid.UUID        `json:"id"`
+	UserID   uuid.UUID        `json:"user_id"`
+	CodeHash string           `json:"code_hash"`
+	Used     pgtype.Timestamp `json:"used"`
+	Created  time.Time        `json:"created"`
+}
+
+type Session struct {
+	ID          string    `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	MfaVerified bool      `json:"mfa_verified"`
+	MfaAttempts int32     `json:"mfa_attempts"`
+	Ip          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+}
+
+type TrustedDevice struct {
+	ID        string    `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
 type User struct {
-	ID       uuid.UUID `json:"id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Created  time.Time `json:"created"`
-	Updated  time.Time `json:"updated"`
+	ID            uuid.UUID `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+	PasswordHash  string    `json:"password_hash"`
+	IsAdmin       bool      `json:"is_admin"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+type UserTotp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Secret    []byte    `json:"secret"`
+	Confirmed bool      `json:"confirmed"`
+	LastStep  int64     `json:"last_step"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+type WebauthnCeremony struct {
+	ID      string    `json:"id"`
+	Purpose string    `json:"purpose"`
+	Data    []byte    `json:"data"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID        `json:"id"`
+	SubscriptionID uuid.UUID        `json:"subscription_id"`
+	Event          string           `json:"event"`
+	Payload        []byte           `json:"payload"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	NextAttempt    time.Time        `json:"next_attempt"`
+	Created        time.Time        `json:"created"`
+	Delivered      pgtype.Timestamp `json:"delivered"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID         uuid.UUID `json:"id"`
+	DeliveryID uuid.UUID `json:"delivery_id"`
+	StatusCode int32     `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMs int32     `json:"duration_ms"`
+	Created    time.Time `json:"created"`
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID        `json:"id"`
+	Provider    string           `json:"provider"`
+	EventID     string           `json:"event_id"`
+	EventType   string           `json:"event_type"`
+	Payload     []byte           `json:"payload"`
+	Headers     []byte           `json:"headers"`
+	Status      string           `json:"status"`
+	Attempts    int32            `json:"attempts"`
+	LastError   string           `json:"last_error"`
+	Received    time.Time        `json:"received"`
+	NextAttempt time.Time        `json:"next_attempt"`
+	Processed   pgtype.Timestamp `json:"processed"`
+}
+
+type WebhookSubscription struct {
+	ID             uuid.UUID `json:"id"`
+	Url            string    `json:"url"`
+	Secret         []byte    `json:"secret"`
+	Events         []string  `json:"events"`
+	Active         bool      `json:"active"`
+	Failures       int32     `json:"failures"`
+	DisabledReason string    `json:"disabled_reason"`
+	Created        time.Time `json:"created"`
 }
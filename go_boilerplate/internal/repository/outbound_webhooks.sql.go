@@ -0,0 +1,413 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbound_webhooks.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET status = 'sending', attempts = d.attempts + 1, next_attempt = $1
+FROM webhook_subscriptions s
+WHERE s.id = d.subscription_id AND d.id IN (
+  SELECT wd.id FROM webhook_deliveries wd
+  JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id AND ws.active
+  WHERE wd.status IN ('pending', 'failed', 'sending') AND wd.next_attempt <= NOW()
+  ORDER BY wd.next_attempt
+  LIMIT $2
+  FOR UPDATE OF wd SKIP LOCKED
+)
+RETURNING d.id, d.subscription_id, d.event, d.payload, d.attempts, d.created, s.url, s.secret
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Batch      int32     `json:"batch"`
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID             uuid.UUID `json:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Event          string    `json:"event"`
+	Payload        []byte    `json:"payload"`
+	Attempts       int32     `json:"attempts"`
+	Created        time.Time `json:"created"`
+	Url            string    `json:"url"`
+	Secret         []byte    `json:"secret"`
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.Created,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeWebhookDelivery = `-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', delivered = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CompleteWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, completeWebhookDelivery, id)
+	return err
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, events, active, failures, disabled_reason, created
+`
+
+type CreateWebhookSubscriptionParams struct {
+	ID     uuid.UUID `json:"id"`
+	Url    string    `json:"url"`
+	Secret []byte    `json:"secret"`
+	Events []string  `json:"events"`
+}
+
+type CreateWebhookSubscriptionRow struct {
+	ID             uuid.UUID `json:"id"`
+	Url            string    `json:"url"`
+	Events         []string  `json:"events"`
+	Active         bool      `json:"active"`
+	Failures       int32     `json:"failures"`
+	DisabledReason string    `json:"disabled_reason"`
+	Created        time.Time `json:"created"`
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (CreateWebhookSubscriptionRow, error) {
+	row := q.db.QueryRow(ctx, createWebhookSubscription,
+		arg.ID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i CreateWebhookSubscriptionRow
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Events,
+		&i.Active,
+		&i.Failures,
+		&i.DisabledReason,
+		&i.Created,
+	)
+	return i, err
+}
+
+const deleteOldWebhookDeliveries = `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status IN ('succeeded', 'dead') AND created < $1::timestamp
+`
+
+func (q *Queries) DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldWebhookDeliveries, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhookSubscription, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enableWebhookSubscription = `-- name: EnableWebhookSubscription :execrows
+UPDATE webhook_subscriptions
+SET active = TRUE, failures = 0, disabled_reason = ''
+WHERE id = $1
+`
+
+func (q *Queries) EnableWebhookSubscription(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, enableWebhookSubscription, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload)
+SELECT gen_random_uuid(), s.id, $1, $2
+FROM webhook_subscriptions s
+WHERE s.active AND (cardinality(s.events) = 0 OR $1::text = ANY(s.events))
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Event   string `json:"event"`
+	Payload []byte `json:"payload"`
+}
+
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueWebhookDeliveries, arg.Event, arg.Payload)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failWebhookDelivery = `-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt = $3
+WHERE id = $1
+`
+
+type FailWebhookDeliveryParams struct {
+	ID          uuid.UUID `json:"id"`
+	Status      string    `json:"status"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+func (q *Queries) FailWebhookDelivery(ctx context.Context, arg FailWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, failWebhookDelivery, arg.ID, arg.Status, arg.NextAttempt)
+	return err
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, url, events, active, failures, disabled_reason, created
+FROM webhook_subscriptions
+WHERE id = $1
+`
+
+type GetWebhookSubscriptionRow struct {
+	ID             uuid.UUID `json:"id"`
+	Url            string    `json:"url"`
+	Events         []string  `json:"events"`
+	Active         bool      `json:"active"`
+	Failures       int32     `json:"failures"`
+	DisabledReason string    `json:"disabled_reason"`
+	Created        time.Time `json:"created"`
+}
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (GetWebhookSubscriptionRow, error) {
+	row := q.db.QueryRow(ctx, getWebhookSubscription, id)
+	var i GetWebhookSubscriptionRow
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Events,
+		&i.Active,
+		&i.Failures,
+		&i.DisabledReason,
+		&i.Created,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT d.id, d.event, d.status, d.attempts, d.next_attempt, d.created, d.delivered,
+  COALESCE(a.status_code, 0)::int AS last_status_code, COALESCE(a.error, '')::text AS last_error
+FROM webhook_deliveries d
+LEFT JOIN LATERAL (
+  SELECT status_code, error FROM webhook_delivery_attempts
+  WHERE delivery_id = d.id
+  ORDER BY created DESC
+  LIMIT 1
+) a ON TRUE
+WHERE d.subscription_id = $1
+ORDER BY d.created DESC
+LIMIT $2
+`
+
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Limit          int32     `json:"limit"`
+}
+
+type ListWebhookDeliveriesRow struct {
+	ID             uuid.UUID        `json:"id"`
+	Event          string           `json:"event"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	NextAttempt    time.Time        `json:"next_attempt"`
+	Created        time.Time        `json:"created"`
+	Delivered      pgtype.Timestamp `json:"delivered"`
+	LastStatusCode int32            `json:"last_status_code"`
+	LastError      string           `json:"last_error"`
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]ListWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.SubscriptionID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWebhookDeliveriesRow
+	for rows.Next() {
+		var i ListWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Event,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttempt,
+			&i.Created,
+			&i.Delivered,
+			&i.LastStatusCode,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, url, events, active, failures, disabled_reason, created
+FROM webhook_subscriptions
+ORDER BY created
+`
+
+type ListWebhookSubscriptionsRow struct {
+	ID             uuid.UUID `json:"id"`
+	Url            string    `json:"url"`
+	Events         []string  `json:"events"`
+	Active         bool      `json:"active"`
+	Failures       int32     `json:"failures"`
+	DisabledReason string    `json:"disabled_reason"`
+	Created        time.Time `json:"created"`
+}
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context) ([]ListWebhookSubscriptionsRow, error) {
+	rows, err := q.db.Query(ctx, listWebhookSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWebhookSubscriptionsRow
+	for rows.Next() {
+		var i ListWebhookSubscriptionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Events,
+			&i.Active,
+			&i.Failures,
+			&i.DisabledReason,
+			&i.Created,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type RecordWebhookAttemptParams struct {
+	ID         uuid.UUID `json:"id"`
+	DeliveryID uuid.UUID `json:"delivery_id"`
+	StatusCode int32     `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMs int32     `json:"duration_ms"`
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookAttempt,
+		arg.ID,
+		arg.DeliveryID,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const recordWebhookSubscriptionFailure = `-- name: RecordWebhookSubscriptionFailure :one
+UPDATE webhook_subscriptions
+SET failures = failures + 1,
+    active = failures + 1 < $1::int,
+    disabled_reason = CASE WHEN failures + 1 < $1::int THEN '' ELSE $2::text END
+WHERE id = $3 AND active
+RETURNING active
+`
+
+type RecordWebhookSubscriptionFailureParams struct {
+	MaxFailures int32     `json:"max_failures"`
+	Reason      string    `json:"reason"`
+	ID          uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RecordWebhookSubscriptionFailure(ctx context.Context, arg RecordWebhookSubscriptionFailureParams) (bool, error) {
+	row := q.db.QueryRow(ctx, recordWebhookSubscriptionFailure, arg.MaxFailures, arg.Reason, arg.ID)
+	var active bool
+	err := row.Scan(&active)
+	return active, err
+}
+
+const redeliverWebhook = `-- name: RedeliverWebhook :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt = NOW(), delivered = NULL
+WHERE id = $1 AND status <> 'sending'
+RETURNING subscription_id
+`
+
+func (q *Queries) RedeliverWebhook(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, redeliverWebhook, id)
+	var subscription_id uuid.UUID
+	err := row.Scan(&subscription_id)
+	return subscription_id, err
+}
+
+const resetWebhookSubscriptionFailures = `-- name: ResetWebhookSubscriptionFailures :exec
+UPDATE webhook_subscriptions
+SET failures = 0
+WHERE id = $1 AND failures <> 0
+`
+
+func (q *Queries) ResetWebhookSubscriptionFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, resetWebhookSubscriptionFailures, id)
+	return err
+}
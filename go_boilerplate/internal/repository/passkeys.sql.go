@@ -0,0 +1,179 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: passkeys.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const consumeWebAuthnCeremony = `-- name: ConsumeWebAuthnCeremony :one
+DELETE FROM webauthn_ceremonies
+WHERE id = $1 AND purpose = $2 AND expires > NOW()
+RETURNING data
+`
+
+type ConsumeWebAuthnCeremonyParams struct {
+	ID      string `json:"id"`
+	Purpose string `json:"purpose"`
+}
+
+func (q *Queries) ConsumeWebAuthnCeremony(ctx context.Context, arg ConsumeWebAuthnCeremonyParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, consumeWebAuthnCeremony, arg.ID, arg.Purpose)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const createPasskey = `-- name: CreatePasskey :exec
+INSERT INTO passkeys (id, user_id, credential_id, name, credential, sign_count)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreatePasskeyParams struct {
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"user_id"`
+	CredentialID []byte    `json:"credential_id"`
+	Name         string    `json:"name"`
+	Credential   []byte    `json:"credential"`
+	SignCount    int64     `json:"sign_count"`
+}
+
+func (q *Queries) CreatePasskey(ctx context.Context, arg CreatePasskeyParams) error {
+	_, err := q.db.Exec(ctx, createPasskey,
+		arg.ID,
+		arg.UserID,
+		arg.CredentialID,
+		arg.Name,
+		arg.Credential,
+		arg.SignCount,
+	)
+	return err
+}
+
+const createWebAuthnCeremony = `-- name: CreateWebAuthnCeremony :exec
+INSERT INTO webauthn_ceremonies (id, purpose, data, expires)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateWebAuthnCeremonyParams struct {
+	ID      string    `json:"id"`
+	Purpose string    `json:"purpose"`
+	Data    []byte    `json:"data"`
+	Expires time.Time `json:"expires"`
+}
+
+func (q *Queries) CreateWebAuthnCeremony(ctx context.Context, arg CreateWebAuthnCeremonyParams) error {
+	_, err := q.db.Exec(ctx, createWebAuthnCeremony,
+		arg.ID,
+		arg.Purpose,
+		arg.Data,
+		arg.Expires,
+	)
+	return err
+}
+
+const deleteExpiredWebAuthnCeremonies = `-- name: DeleteExpiredWebAuthnCeremonies :execrows
+DELETE FROM webauthn_ceremonies
+WHERE expires <= NOW()
+`
+
+func (q *Queries) DeleteExpiredWebAuthnCeremonies(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredWebAuthnCeremonies)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePasskey = `-- name: DeletePasskey :execrows
+DELETE FROM passkeys
+WHERE id = $1 AND user_id = $2
+`
+
+type DeletePasskeyParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeletePasskey(ctx context.Context, arg DeletePasskeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePasskey, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getPasskeyOwner = `-- name: GetPasskeyOwner :one
+SELECT user_id
+FROM passkeys
+WHERE credential_id = $1
+`
+
+func (q *Queries) GetPasskeyOwner(ctx context.Context, credentialID []byte) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, getPasskeyOwner, credentialID)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const listUserPasskeys = `-- name: ListUserPasskeys :many
+SELECT id, user_id, credential_id, name, credential, sign_count, created, last_used
+FROM passkeys
+WHERE user_id = $1
+ORDER BY created
+`
+
+func (q *Queries) ListUserPasskeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error) {
+	rows, err := q.db.Query(ctx, listUserPasskeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Passkey
+	for rows.Next() {
+		var i Passkey
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CredentialID,
+			&i.Name,
+			&i.Credential,
+			&i.SignCount,
+			&i.Created,
+			&i.LastUsed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updatePasskeyUsage = `-- name: UpdatePasskeyUsage :execrows
+UPDATE passkeys
+SET credential = $2, sign_count = $3, last_used = NOW()
+WHERE credential_id = $1 AND (sign_count < $3 OR (sign_count = 0 AND $3 = 0))
+`
+
+type UpdatePasskeyUsageParams struct {
+	CredentialID []byte `json:"credential_id"`
+	Credential   []byte `json:"credential"`
+	SignCount    int64  `json:"sign_count"`
+}
+
+func (q *Queries) UpdatePasskeyUsage(ctx context.Context, arg UpdatePasskeyUsageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updatePasskeyUsage, arg.CredentialID, arg.Credential, arg.SignCount)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
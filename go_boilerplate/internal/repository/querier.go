@@ -6,17 +6,114 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
+	// Claims the run of job due at scheduled, no row comes back when another instance holds the
+	// lease or already claimed that run
+	AcquireJobLease(ctx context.Context, arg AcquireJobLeaseParams) (time.Time, error)
+	AdvanceTOTPStep(ctx context.Context, arg AdvanceTOTPStepParams) (int64, error)
+	ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (ChangeUserEmailRow, error)
+	ClaimMail(ctx context.Context, arg ClaimMailParams) ([]ClaimMailRow, error)
+	ClaimQueueJobs(ctx context.Context, arg ClaimQueueJobsParams) ([]ClaimQueueJobsRow, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	ClaimWebhookEvents(ctx context.Context, arg ClaimWebhookEventsParams) ([]ClaimWebhookEventsRow, error)
+	CompleteMail(ctx context.Context, id uuid.UUID) error
+	CompleteQueueJob(ctx context.Context, id uuid.UUID) error
+	CompleteWebhookDelivery(ctx context.Context, id uuid.UUID) error
+	CompleteWebhookEvent(ctx context.Context, id uuid.UUID) error
+	ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (int64, error)
+	ConsumeEmailToken(ctx context.Context, arg ConsumeEmailTokenParams) (ConsumeEmailTokenRow, error)
+	ConsumeWebAuthnCeremony(ctx context.Context, arg ConsumeWebAuthnCeremonyParams) ([]byte, error)
+	CountRecentEmailTokens(ctx context.Context, arg CountRecentEmailTokensParams) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error)
 	CountUsers(ctx context.Context) (int64, error)
+	CreateEmailToken(ctx context.Context, arg CreateEmailTokenParams) error
+	CreatePasskey(ctx context.Context, arg CreatePasskeyParams) error
+	CreatePendingTOTP(ctx context.Context, arg CreatePendingTOTPParams) error
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) error
+	CreateTrustedDevice(ctx context.Context, arg CreateTrustedDeviceParams) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error)
+	CreateUserWithPassword(ctx context.Context, arg CreateUserWithPasswordParams) (CreateUserWithPasswordRow, error)
+	CreateWebAuthnCeremony(ctx context.Context, arg CreateWebAuthnCeremonyParams) error
+	CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (int64, error)
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (CreateWebhookSubscriptionRow, error)
+	// Cuts by sequence rather than time, so an entry written while the clock stepped back can't leave a gap
+	DeleteAuditEntries(ctx context.Context, before time.Time) (int64, error)
+	DeleteExpiredEmailTokens(ctx context.Context) (int64, error)
+	DeleteExpiredRateLimits(ctx context.Context, before time.Time) (int64, error)
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	DeleteExpiredWebAuthnCeremonies(ctx context.Context) (int64, error)
+	DeleteOldJobRuns(ctx context.Context, before time.Time) (int64, error)
+	DeleteOldMail(ctx context.Context, before time.Time) (int64, error)
+	DeleteOldQueueJobs(ctx context.Context, before time.Time) (int64, error)
+	DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+	DeletePasskey(ctx context.Context, arg DeletePasskeyParams) (int64, error)
+	DeleteProcessedWebhookEvents(ctx context.Context, before time.Time) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteTrustedDevices(ctx context.Context, userID uuid.UUID) error
 	DeleteUser(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID) error
+	DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error
+	DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) (int64, error)
+	EnableAuditRetention(ctx context.Context) error
+	EnableWebhookSubscription(ctx context.Context, id uuid.UUID) (int64, error)
+	EnqueueMail(ctx context.Context, arg EnqueueMailParams) error
+	EnqueueQueueJob(ctx context.Context, arg EnqueueQueueJobParams) (int64, error)
+	EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error)
+	FailMail(ctx context.Context, arg FailMailParams) error
+	FailQueueJob(ctx context.Context, arg FailQueueJobParams) error
+	FailWebhookDelivery(ctx context.Context, arg FailWebhookDeliveryParams) error
+	FailWebhookEvent(ctx context.Context, arg FailWebhookEventParams) error
+	FinishJobRun(ctx context.Context, arg FinishJobRunParams) error
 	GetAllUsers(ctx context.Context) ([]GetAllUsersRow, error)
+	GetLastAuditHash(ctx context.Context) ([]byte, error)
+	GetPasskeyOwner(ctx context.Context, credentialID []byte) (uuid.UUID, error)
+	GetRateLimit(ctx context.Context, key string) (time.Time, error)
+	GetSession(ctx context.Context, id string) (GetSessionRow, error)
+	GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error)
+	GetUserCredentials(ctx context.Context, username string) (GetUserCredentialsRow, error)
+	GetUserTOTP(ctx context.Context, userID uuid.UUID) (GetUserTOTPRow, error)
+	GetUserWithTwoFactor(ctx context.Context, id uuid.UUID) (GetUserWithTwoFactorRow, error)
+	GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error)
+	GetWebhookSubscription(ctx context.Context, id uuid.UUID) (GetWebhookSubscriptionRow, error)
+	IncrementSessionMFAAttempts(ctx context.Context, id string) (int32, error)
+	InsertAuditEntry(ctx context.Context, arg InsertAuditEntryParams) error
+	IsTrustedDevice(ctx context.Context, arg IsTrustedDeviceParams) (bool, error)
+	ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditLog, error)
+	ListAuditEntries(ctx context.Context, arg ListAuditEntriesParams) ([]AuditLog, error)
+	ListJobRuns(ctx context.Context, arg ListJobRunsParams) ([]JobRun, error)
+	ListJobSuccesses(ctx context.Context) ([]ListJobSuccessesRow, error)
+	ListUserPasskeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error)
+	ListUsersWithTwoFactor(ctx context.Context) ([]ListUsersWithTwoFactorRow, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]ListWebhookDeliveriesRow, error)
+	ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]ListWebhookEventsRow, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]ListWebhookSubscriptionsRow, error)
+	// Serializes writers until commit, so every entry chains onto the one committed before it
+	LockAuditLog(ctx context.Context) error
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error
+	RecordWebhookSubscriptionFailure(ctx context.Context, arg RecordWebhookSubscriptionFailureParams) (bool, error)
+	RedeliverWebhook(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	ReleaseJobLease(ctx context.Context, arg ReleaseJobLeaseParams) error
+	RenewJobLease(ctx context.Context, arg RenewJobLeaseParams) (int64, error)
+	ReplayWebhookEvent(ctx context.Context, id uuid.UUID) (int64, error)
+	ResetWebhookSubscriptionFailures(ctx context.Context, id uuid.UUID) error
+	RetryQueueJob(ctx context.Context, id uuid.UUID) (int64, error)
+	// Revoked rows are kept until they expire, CountRecentEmailTokens counts them
+	RevokeUnusedEmailTokens(ctx context.Context, arg RevokeUnusedEmailTokensParams) error
+	StartJobRun(ctx context.Context, arg StartJobRunParams) error
+	// Spends one request atomically, no row comes back when the bucket is empty
+	TakeRateLimit(ctx context.Context, arg TakeRateLimitParams) (time.Time, error)
+	UpdatePasskeyUsage(ctx context.Context, arg UpdatePasskeyUsageParams) (int64, error)
 	UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (UpdateUserEmailRow, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
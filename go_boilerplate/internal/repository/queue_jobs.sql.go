@@ -0,0 +1,167 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: queue_jobs.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimQueueJobs = `-- name: ClaimQueueJobs :many
+UPDATE queue_jobs
+SET status = 'running', attempts = attempts + 1, run_at = $1
+WHERE id IN (
+  SELECT id FROM queue_jobs
+  WHERE status IN ('pending', 'failed', 'running') AND run_at <= NOW() AND kind = ANY($2::text[])
+  ORDER BY priority DESC, run_at
+  LIMIT $3
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, args, priority, attempts, max_attempts, created
+`
+
+type ClaimQueueJobsParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Kinds      []string  `json:"kinds"`
+	Batch      int32     `json:"batch"`
+}
+
+type ClaimQueueJobsRow struct {
+	ID          uuid.UUID `json:"id"`
+	Kind        string    `json:"kind"`
+	Args        []byte    `json:"args"`
+	Priority    int32     `json:"priority"`
+	Attempts    int32     `json:"attempts"`
+	MaxAttempts int32     `json:"max_attempts"`
+	Created     time.Time `json:"created"`
+}
+
+func (q *Queries) ClaimQueueJobs(ctx context.Context, arg ClaimQueueJobsParams) ([]ClaimQueueJobsRow, error) {
+	rows, err := q.db.Query(ctx, claimQueueJobs, arg.LeaseUntil, arg.Kinds, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimQueueJobsRow
+	for rows.Next() {
+		var i ClaimQueueJobsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Args,
+			&i.Priority,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.Created,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeQueueJob = `-- name: CompleteQueueJob :exec
+UPDATE queue_jobs
+SET status = 'succeeded', finished = NOW(), last_error = ''
+WHERE id = $1
+`
+
+func (q *Queries) CompleteQueueJob(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, completeQueueJob, id)
+	return err
+}
+
+const deleteOldQueueJobs = `-- name: DeleteOldQueueJobs :execrows
+DELETE FROM queue_jobs
+WHERE status IN ('succeeded', 'dead') AND finished < $1::timestamp
+`
+
+func (q *Queries) DeleteOldQueueJobs(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldQueueJobs, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueQueueJob = `-- name: EnqueueQueueJob :execrows
+INSERT INTO queue_jobs (id, kind, args, priority, max_attempts, run_at, unique_key)
+VALUES (
+  $1, $2, $3, $4, $5,
+  COALESCE($6::timestamp, NOW()), $7
+)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'failed', 'running') DO NOTHING
+`
+
+type EnqueueQueueJobParams struct {
+	ID          uuid.UUID        `json:"id"`
+	Kind        string           `json:"kind"`
+	Args        []byte           `json:"args"`
+	Priority    int32            `json:"priority"`
+	MaxAttempts int32            `json:"max_attempts"`
+	RunAt       pgtype.Timestamp `json:"run_at"`
+	UniqueKey   *string          `json:"unique_key"`
+}
+
+func (q *Queries) EnqueueQueueJob(ctx context.Context, arg EnqueueQueueJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueQueueJob,
+		arg.ID,
+		arg.Kind,
+		arg.Args,
+		arg.Priority,
+		arg.MaxAttempts,
+		arg.RunAt,
+		arg.UniqueKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failQueueJob = `-- name: FailQueueJob :exec
+UPDATE queue_jobs
+SET status = $2, run_at = $3, last_error = $4, finished = CASE WHEN $2 = 'dead' THEN NOW() END
+WHERE id = $1
+`
+
+type FailQueueJobParams struct {
+	ID        uuid.UUID `json:"id"`
+	Status    string    `json:"status"`
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error"`
+}
+
+func (q *Queries) FailQueueJob(ctx context.Context, arg FailQueueJobParams) error {
+	_, err := q.db.Exec(ctx, failQueueJob,
+		arg.ID,
+		arg.Status,
+		arg.RunAt,
+		arg.LastError,
+	)
+	return err
+}
+
+const retryQueueJob = `-- name: RetryQueueJob :execrows
+UPDATE queue_jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished = NULL
+WHERE id = $1 AND status = 'dead'
+`
+
+func (q *Queries) RetryQueueJob(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, retryQueueJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,65 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: rate_limits.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+const deleteExpiredRateLimits = `-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM rate_limits
+WHERE tat < $1::timestamp
+`
+
+func (q *Queries) DeleteExpiredRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredRateLimits, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getRateLimit = `-- name: GetRateLimit :one
+SELECT tat FROM rate_limits
+WHERE key = $1
+`
+
+func (q *Queries) GetRateLimit(ctx context.Context, key string) (time.Time, error) {
+	row := q.db.QueryRow(ctx, getRateLimit, key)
+	var tat time.Time
+	err := row.Scan(&tat)
+	return tat, err
+}
+
+const takeRateLimit = `-- name: TakeRateLimit :one
+INSERT INTO rate_limits AS r (key, tat)
+VALUES ($1, $2::timestamp + make_interval(secs => $3::float8))
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(r.tat, $2::timestamp) + make_interval(secs => $3::float8)
+WHERE GREATEST(r.tat, $2::timestamp) + make_interval(secs => $3::float8 - $4::float8) <= $2::timestamp
+RETURNING tat
+`
+
+type TakeRateLimitParams struct {
+	Key       string    `json:"key"`
+	Now       time.Time `json:"now"`
+	Interval  float64   `json:"interval"`
+	Tolerance float64   `json:"tolerance"`
+}
+
+// Spends one request atomically, no row comes back when the bucket is empty
+func (q *Queries) TakeRateLimit(ctx context.Context, arg TakeRateLimitParams) (time.Time, error) {
+	row := q.db.QueryRow(ctx, takeRateLimit,
+		arg.Key,
+		arg.Now,
+		arg.Interval,
+		arg.Tolerance,
+	)
+	var tat time.Time
+	err := row.Scan(&tat)
+	return tat, err
+}
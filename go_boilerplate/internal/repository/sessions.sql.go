@@ -0,0 +1,127 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: sessions.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (id, user_id, mfa_verified, ip, user_agent, expires)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreateSessionParams struct {
+	ID          string    `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	MfaVerified bool      `json:"mfa_verified"`
+	Ip          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	Expires     time.Time `json:"expires"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.Exec(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.MfaVerified,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Expires,
+	)
+	return err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires <= NOW()
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteSession, id)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserSessions, userID)
+	return err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT s.id, s.user_id, s.mfa_verified, s.mfa_attempts, s.expires,
+       u.username, u.email, u.email_verified, u.is_admin,
+       COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+LEFT JOIN user_totp t ON t.user_id = s.user_id
+WHERE s.id = $1 AND s.expires > NOW()
+`
+
+type GetSessionRow struct {
+	ID            string    `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	MfaVerified   bool      `json:"mfa_verified"`
+	MfaAttempts   int32     `json:"mfa_attempts"`
+	Expires       time.Time `json:"expires"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	IsAdmin       bool      `json:"is_admin"`
+	TwoFactor     bool      `json:"two_factor"`
+}
+
+func (q *Queries) GetSession(ctx context.Context, id string) (GetSessionRow, error) {
+	row := q.db.QueryRow(ctx, getSession, id)
+	var i GetSessionRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.MfaVerified,
+		&i.MfaAttempts,
+		&i.Expires,
+		&i.Username,
+		&i.Email,
+		&i.EmailVerified,
+		&i.IsAdmin,
+		&i.TwoFactor,
+	)
+	return i, err
+}
+
+const incrementSessionMFAAttempts = `-- name: IncrementSessionMFAAttempts :one
+UPDATE sessions
+SET mfa_attempts = mfa_attempts + 1
+WHERE id = $1
+RETURNING mfa_attempts
+`
+
+func (q *Queries) IncrementSessionMFAAttempts(ctx context.Context, id string) (int32, error) {
+	row := q.db.QueryRow(ctx, incrementSessionMFAAttempts, id)
+	var mfa_attempts int32
+	err := row.Scan(&mfa_attempts)
+	return mfa_attempts, err
+}
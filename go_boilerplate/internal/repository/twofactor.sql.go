@@ -0,0 +1,212 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: twofactor.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const advanceTOTPStep = `-- name: AdvanceTOTPStep :execrows
+UPDATE user_totp
+SET last_step = $2
+WHERE user_id = $1 AND last_step < $2
+`
+
+type AdvanceTOTPStepParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	LastStep int64     `json:"last_step"`
+}
+
+func (q *Queries) AdvanceTOTPStep(ctx context.Context, arg AdvanceTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, advanceTOTPStep, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const confirmUserTOTP = `-- name: ConfirmUserTOTP :execrows
+UPDATE user_totp
+SET confirmed = TRUE, last_step = $2
+WHERE user_id = $1 AND confirmed = FALSE
+`
+
+type ConfirmUserTOTPParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	LastStep int64     `json:"last_step"`
+}
+
+func (q *Queries) ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (int64, error) {
+	result, err := q.db.Exec(ctx, confirmUserTOTP, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*)
+FROM recovery_codes
+WHERE user_id = $1 AND used IS NULL
+`
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createPendingTOTP = `-- name: CreatePendingTOTP :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO NOTHING
+`
+
+type CreatePendingTOTPParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Secret []byte    `json:"secret"`
+}
+
+func (q *Queries) CreatePendingTOTP(ctx context.Context, arg CreatePendingTOTPParams) error {
+	_, err := q.db.Exec(ctx, createPendingTOTP, arg.UserID, arg.Secret)
+	return err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, user_id, code_hash)
+VALUES ($1, $2, $3)
+`
+
+type CreateRecoveryCodeParams struct {
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createRecoveryCode, arg.ID, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createTrustedDevice = `-- name: CreateTrustedDevice :exec
+INSERT INTO trusted_devices (id, user_id, user_agent, expires)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateTrustedDeviceParams struct {
+	ID        string    `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	Expires   time.Time `json:"expires"`
+}
+
+func (q *Queries) CreateTrustedDevice(ctx context.Context, arg CreateTrustedDeviceParams) error {
+	_, err := q.db.Exec(ctx, createTrustedDevice,
+		arg.ID,
+		arg.UserID,
+		arg.UserAgent,
+		arg.Expires,
+	)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteTrustedDevices = `-- name: DeleteTrustedDevices :exec
+DELETE FROM trusted_devices
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteTrustedDevices(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTrustedDevices, userID)
+	return err
+}
+
+const deleteUserTOTP = `-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserTOTP, userID)
+	return err
+}
+
+const getUserTOTP = `-- name: GetUserTOTP :one
+SELECT user_id, secret, confirmed, last_step
+FROM user_totp
+WHERE user_id = $1
+`
+
+type GetUserTOTPRow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Secret    []byte    `json:"secret"`
+	Confirmed bool      `json:"confirmed"`
+	LastStep  int64     `json:"last_step"`
+}
+
+func (q *Queries) GetUserTOTP(ctx context.Context, userID uuid.UUID) (GetUserTOTPRow, error) {
+	row := q.db.QueryRow(ctx, getUserTOTP, userID)
+	var i GetUserTOTPRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.Confirmed,
+		&i.LastStep,
+	)
+	return i, err
+}
+
+const isTrustedDevice = `-- name: IsTrustedDevice :one
+SELECT EXISTS (
+  SELECT 1
+  FROM trusted_devices
+  WHERE id = $1 AND user_id = $2 AND expires > NOW()
+)
+`
+
+type IsTrustedDeviceParams struct {
+	ID     string    `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) IsTrustedDevice(ctx context.Context, arg IsTrustedDeviceParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isTrustedDevice, arg.ID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -12,6 +12,37 @@ import (
 	"github.com/google/uuid"
 )
 
+const changeUserEmail = `-- name: ChangeUserEmail :one
+UPDATE users
+SET email = $2, email_verified = TRUE
+WHERE id = $1
+RETURNING id, username, email, created
+`
+
+type ChangeUserEmailParams struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+}
+
+type ChangeUserEmailRow struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+}
+
+func (q *Queries) ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (ChangeUserEmailRow, error) {
+	row := q.db.QueryRow(ctx, changeUserEmail, arg.ID, arg.Email)
+	var i ChangeUserEmailRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Created,
+	)
+	return i, err
+}
+
 const countUsers = `-- name: CountUsers :one
 SELECT COUNT(*) FROM users
 `
@@ -54,6 +85,43 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 	return i, err
 }
 
+const createUserWithPassword = `-- name: CreateUserWithPassword :one
+INSERT INTO users (id, username, email, password_hash)
+VALUES ($1, $2, $3, $4)
+RETURNING id, username, email, created
+`
+
+type CreateUserWithPasswordParams struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+type CreateUserWithPasswordRow struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+}
+
+func (q *Queries) CreateUserWithPassword(ctx context.Context, arg CreateUserWithPasswordParams) (CreateUserWithPasswordRow, error) {
+	row := q.db.QueryRow(ctx, createUserWithPassword,
+		arg.ID,
+		arg.Username,
+		arg.Email,
+		arg.PasswordHash,
+	)
+	var i CreateUserWithPasswordRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Created,
+	)
+	return i, err
+}
+
 const deleteUser = `-- name: DeleteUser :execrows
 DELETE FROM users
 WHERE id = $1
@@ -105,6 +173,31 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]GetAllUsersRow, error) {
 	return items, nil
 }
 
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, email, email_verified
+FROM users
+WHERE email = $1
+`
+
+type GetUserByEmailRow struct {
+	ID            uuid.UUID `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i GetUserByEmailRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.EmailVerified,
+	)
+	return i, err
+}
+
 const getUserByID = `-- name: GetUserByID :one
 SELECT id, username, email, created
 FROM users
@@ -130,6 +223,102 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow
 	return i, err
 }
 
+const getUserCredentials = `-- name: GetUserCredentials :one
+SELECT id, username, email, password_hash, is_admin
+FROM users
+WHERE username = $1 OR email = $1
+`
+
+type GetUserCredentialsRow struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	IsAdmin      bool      `json:"is_admin"`
+}
+
+func (q *Queries) GetUserCredentials(ctx context.Context, username string) (GetUserCredentialsRow, error) {
+	row := q.db.QueryRow(ctx, getUserCredentials, username)
+	var i GetUserCredentialsRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserWithTwoFactor = `-- name: GetUserWithTwoFactor :one
+SELECT u.id, u.username, u.email, u.is_admin, COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM users u
+LEFT JOIN user_totp t ON t.user_id = u.id
+WHERE u.id = $1
+`
+
+type GetUserWithTwoFactorRow struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	IsAdmin   bool      `json:"is_admin"`
+	TwoFactor bool      `json:"two_factor"`
+}
+
+func (q *Queries) GetUserWithTwoFactor(ctx context.Context, id uuid.UUID) (GetUserWithTwoFactorRow, error) {
+	row := q.db.QueryRow(ctx, getUserWithTwoFactor, id)
+	var i GetUserWithTwoFactorRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.IsAdmin,
+		&i.TwoFactor,
+	)
+	return i, err
+}
+
+const listUsersWithTwoFactor = `-- name: ListUsersWithTwoFactor :many
+SELECT u.id, u.username, u.email, u.is_admin, COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM users u
+LEFT JOIN user_totp t ON t.user_id = u.id
+ORDER BY u.created DESC
+`
+
+type ListUsersWithTwoFactorRow struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	IsAdmin   bool      `json:"is_admin"`
+	TwoFactor bool      `json:"two_factor"`
+}
+
+func (q *Queries) ListUsersWithTwoFactor(ctx context.Context) ([]ListUsersWithTwoFactorRow, error) {
+	rows, err := q.db.Query(ctx, listUsersWithTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersWithTwoFactorRow
+	for rows.Next() {
+		var i ListUsersWithTwoFactorRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.IsAdmin,
+			&i.TwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUserEmail = `-- name: UpdateUserEmail :one
 UPDATE users
 SET email = $1
@@ -160,3 +349,22 @@ func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams
 	)
 	return i, err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified = TRUE
+WHERE id = $1 AND email = $2
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
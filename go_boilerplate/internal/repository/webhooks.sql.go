@@ -0,0 +1,244 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookEvents = `-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, next_attempt = $1
+WHERE id IN (
+  SELECT id FROM webhook_events
+  WHERE status IN ('pending', 'failed', 'processing') AND next_attempt <= NOW()
+  ORDER BY next_attempt
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, provider, event_id, event_type, payload, headers, attempts
+`
+
+type ClaimWebhookEventsParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Batch      int32     `json:"batch"`
+}
+
+type ClaimWebhookEventsRow struct {
+	ID        uuid.UUID `json:"id"`
+	Provider  string    `json:"provider"`
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+	Payload   []byte    `json:"payload"`
+	Headers   []byte    `json:"headers"`
+	Attempts  int32     `json:"attempts"`
+}
+
+func (q *Queries) ClaimWebhookEvents(ctx context.Context, arg ClaimWebhookEventsParams) ([]ClaimWebhookEventsRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookEvents, arg.LeaseUntil, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookEventsRow
+	for rows.Next() {
+		var i ClaimWebhookEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Headers,
+			&i.Attempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeWebhookEvent = `-- name: CompleteWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'done', last_error = '', processed = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CompleteWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, completeWebhookEvent, id)
+	return err
+}
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :execrows
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, headers)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, event_id) DO NOTHING
+`
+
+type CreateWebhookEventParams struct {
+	ID        uuid.UUID `json:"id"`
+	Provider  string    `json:"provider"`
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+	Payload   []byte    `json:"payload"`
+	Headers   []byte    `json:"headers"`
+}
+
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createWebhookEvent,
+		arg.ID,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.Headers,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteProcessedWebhookEvents = `-- name: DeleteProcessedWebhookEvents :execrows
+DELETE FROM webhook_events
+WHERE status = 'done' AND processed < $1::timestamp
+`
+
+func (q *Queries) DeleteProcessedWebhookEvents(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteProcessedWebhookEvents, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failWebhookEvent = `-- name: FailWebhookEvent :exec
+UPDATE webhook_events
+SET status = $2, last_error = $3, next_attempt = $4
+WHERE id = $1
+`
+
+type FailWebhookEventParams struct {
+	ID          uuid.UUID `json:"id"`
+	Status      string    `json:"status"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+func (q *Queries) FailWebhookEvent(ctx context.Context, arg FailWebhookEventParams) error {
+	_, err := q.db.Exec(ctx, failWebhookEvent,
+		arg.ID,
+		arg.Status,
+		arg.LastError,
+		arg.NextAttempt,
+	)
+	return err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, provider, event_id, event_type, payload, headers, status, attempts, last_error, received, next_attempt, processed
+FROM webhook_events
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRow(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Headers,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.Received,
+		&i.NextAttempt,
+		&i.Processed,
+	)
+	return i, err
+}
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+SELECT id, provider, event_id, event_type, status, attempts, last_error, received, processed
+FROM webhook_events
+WHERE ($2::text IS NULL OR status = $2)
+  AND ($3::text IS NULL OR provider = $3)
+ORDER BY received DESC
+LIMIT $1
+`
+
+type ListWebhookEventsParams struct {
+	Limit    int32   `json:"limit"`
+	Status   *string `json:"status"`
+	Provider *string `json:"provider"`
+}
+
+type ListWebhookEventsRow struct {
+	ID        uuid.UUID        `json:"id"`
+	Provider  string           `json:"provider"`
+	EventID   string           `json:"event_id"`
+	EventType string           `json:"event_type"`
+	Status    string           `json:"status"`
+	Attempts  int32            `json:"attempts"`
+	LastError string           `json:"last_error"`
+	Received  time.Time        `json:"received"`
+	Processed pgtype.Timestamp `json:"processed"`
+}
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]ListWebhookEventsRow, error) {
+	rows, err := q.db.Query(ctx, listWebhookEvents, arg.Limit, arg.Status, arg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWebhookEventsRow
+	for rows.Next() {
+		var i ListWebhookEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.Received,
+			&i.Processed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replayWebhookEvent = `-- name: ReplayWebhookEvent :execrows
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, last_error = '', next_attempt = NOW(), processed = NULL
+WHERE id = $1 AND status <> 'processing'
+`
+
+func (q *Queries) ReplayWebhookEvent(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, replayWebhookEvent, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "Every year on January 1 at 00:00",
+	"@annually": "Every year on January 1 at 00:00",
+	"@monthly":  "Every month on day 1 at 00:00",
+	"@weekly":   "Every Sunday at 00:00",
+	"@daily":    "Every day at 00:00",
+	"@midnight": "Every day at 00:00",
+	"@hourly":   "Every hour",
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// Describe puts a cron spec in words, like "At 03:15 every day" for "0 15 3 * * *". seconds tells
+// whether the spec starts with a seconds field. Specs too involved to say simply give "".
+func Describe(spec string, seconds bool) string {
+	fields := strings.Fields(spec)
+	zone := ""
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "TZ=") || strings.HasPrefix(fields[0], "CRON_TZ=")) {
+		_, name, _ := strings.Cut(fields[0], "=")
+		zone = " (" + name + ")"
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	if description, ok := descriptors[fields[0]]; ok && len(fields) == 1 {
+		return description + zone
+	}
+	if fields[0] == "@every" && len(fields) == 2 {
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return ""
+		}
+		return "Every " + d.String() + zone
+	}
+
+	if !seconds {
+		fields = append([]string{"0"}, fields...)
+	}
+	if len(fields) != 6 {
+		return ""
+	}
+
+	clock, daily := describeTime(fields[0], fields[1], fields[2])
+	if clock == "" {
+		return ""
+	}
+	days, ok := describeDays(fields[3], fields[4], fields[5])
+	if !ok {
+		return ""
+	}
+	if days == "" && daily {
+		days = " every day"
+	}
+	return clock + days + zone
+}
+
+// describeTime says when in a day the job runs, daily is set when that is once a day
+func describeTime(second, minute, hour string) (clock string, daily bool) {
+	s, sOK := number(second)
+	m, mOK := number(minute)
+	h, hOK := number(hour)
+
+	switch {
+	case second == "*" && minute == "*" && hour == "*":
+		return "Every second", false
+	case minute == "*" && hour == "*":
+		if n, ok := step(second); ok {
+			return fmt.Sprintf("Every %d seconds", n), false
+		}
+		if sOK && s == 0 {
+			return "Every minute", false
+		}
+		if sOK {
+			return fmt.Sprintf("Every minute at second %d", s), false
+		}
+	case !sOK || s != 0:
+		// Past the minute, only a fixed time of day reads well
+		if mOK && hOK {
+			return fmt.Sprintf("At %02d:%02d:%02d", h, m, s), true
+		}
+	case hour == "*":
+		if n, ok := step(minute); ok {
+			return fmt.Sprintf("Every %d minutes", n), false
+		}
+		if mOK && m == 0 {
+			return "Every hour", false
+		}
+		if mOK {
+			return fmt.Sprintf("Every hour at minute %d", m), false
+		}
+	case mOK && hOK:
+		return fmt.Sprintf("At %02d:%02d", h, m), true
+	case mOK:
+		if n, ok := step(hour); ok {
+			if m == 0 {
+				return fmt.Sprintf("Every %d hours", n), false
+			}
+			return fmt.Sprintf("Every %d hours at minute %d", n, m), false
+		}
+	}
+	return "", false
+}
+
+// describeDays says on which days the job runs, "" for every day, ok is false past what it can say
+func describeDays(dom, month, dow string) (days string, ok bool) {
+	if dom == "?" {
+		dom = "*"
+	}
+	if dow == "?" {
+		dow = "*"
+	}
+
+	if dow != "*" {
+		names, ok := describeList(dow, weekdays, 0, "Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday")
+		if !ok {
+			return "", false
+		}
+		days += " on " + names
+	}
+	if dom != "*" {
+		n, ok := number(dom)
+		if !ok {
+			return "", false
+		}
+		days += fmt.Sprintf(" on day %d of the month", n)
+	}
+	if month != "*" {
+		names, ok := describeList(month, months, 1, "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December")
+		if !ok {
+			return "", false
+		}
+		days += " in " + names
+	}
+	return days, true
+}
+
+// describeList names the values of a list or range field, like "Monday through Friday".
+// Values are numbers from first or the abbreviations in short.
+func describeList(field string, short []string, first int, names ...string) (string, bool) {
+	value := func(v string) (string, bool) {
+		n, ok := number(v)
+		if !ok {
+			for i, abbreviation := range short {
+				if strings.EqualFold(v, abbreviation) {
+					n, ok = i+first, true
+				}
+			}
+		}
+		if !ok || n < first || n-first >= len(names) {
+			return "", false
+		}
+		return names[n-first], true
+	}
+
+	if from, to, isRange := strings.Cut(field, "-"); isRange {
+		a, aOK := value(from)
+		b, bOK := value(to)
+		return a + " through " + b, aOK && bOK
+	}
+
+	var values []string
+	for v := range strings.SplitSeq(field, ",") {
+		name, ok := value(v)
+		if !ok {
+			return "", false
+		}
+		values = append(values, name)
+	}
+	if len(values) == 1 {
+		return values[0], true
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " and " + values[len(values)-1], true
+}
+
+func number(field string) (int, bool) {
+	n, err := strconv.Atoi(field)
+	return n, err == nil && n >= 0
+}
+
+// step reads "*/n" and "0/n"
+func step(field string) (int, bool) {
+	base, every, ok := strings.Cut(field, "/")
+	if !ok || (base != "*" && base != "0") {
+		return 0, false
+	}
+	n, ok := number(every)
+	return n, ok && n > 0
+}
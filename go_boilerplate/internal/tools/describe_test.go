@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec    string
+		seconds bool
+		want    string
+	}{
+		{"@hourly", true, "Every hour"},
+		{"@daily", false, "Every day at 00:00"},
+		{"@every 90s", true, "Every 1m30s"},
+		{"* * * * * *", true, "Every second"},
+		{"*/10 * * * * *", true, "Every 10 seconds"},
+		{"0 * * * * *", true, "Every minute"},
+		{"30 * * * * *", true, "Every minute at second 30"},
+		{"* * * * *", false, "Every minute"},
+		{"0 */10 * * * *", true, "Every 10 minutes"},
+		{"0 0 * * * *", true, "Every hour"},
+		{"0 30 * * * *", true, "Every hour at minute 30"},
+		{"0 0 */6 * * *", true, "Every 6 hours"},
+		{"0 15 */2 * * *", true, "Every 2 hours at minute 15"},
+		{"0 15 3 * * *", true, "At 03:15 every day"},
+		{"45 15 3 * * *", true, "At 03:15:45 every day"},
+		{"0 9 * * 1-5", false, "At 09:00 on Monday through Friday"},
+		{"0 9 * * mon,wed,FRI", false, "At 09:00 on Monday, Wednesday and Friday"},
+		{"0 0 1 * *", false, "At 00:00 on day 1 of the month"},
+		{"0 0 1 jan *", false, "At 00:00 on day 1 of the month in January"},
+		{"*/5 * * * 0", false, "Every 5 minutes on Sunday"},
+		{"CRON_TZ=Europe/Paris 0 9 * * *", false, "At 09:00 every day (Europe/Paris)"},
+		{"0 9,17 * * *", false, ""},
+		{"0 9 1,15 * *", false, ""},
+		{"0 9 * * 1-9", false, ""},
+		{"* * * *", false, ""},
+		{"@every soon", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, Describe(tt.spec, tt.seconds))
+		})
+	}
+}
@@ -1,101 +1,819 @@
-// cron.go
 package tools
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/__username__/go_boilerplate/internal/monitoring"
 	"github.com/labstack/gommon/log"
 	"github.com/robfig/cron/v3"
 )
 
-type Job struct {
-	ID      string
-	EntryID cron.EntryID
-}
-
 var (
-	cronScheduler *cron.Cron
-	jobs          = make(map[string]Job)
-	jobMutex      sync.Mutex
-	once          sync.Once
+	ErrJobExists        = errors.New("job already exists")
+	ErrJobNotFound      = errors.New("job not found")
+	ErrSchedulerStopped = errors.New("scheduler stopped")
+	// ErrSkipped is returned by a middleware that decided against running the job this time,
+	// the run is then left out of the metrics and the job's last run
+	ErrSkipped = errors.New("run skipped")
 )
 
-// init — YOUR ORIGINAL, PERFECT, IDIOMATIC GO
-func init() {
-	once.Do(func() {
-		log.Info("Initializing cron scheduler...")
-		cronScheduler = cron.New(cron.WithSeconds()) // ← seconds enabled!
-		cronScheduler.Start()
-	})
+// JobError tells which job an ErrJobExists, ErrJobNotFound or schedule parsing error is about
+type JobError struct {
+	ID  string
+	Err error
 }
 
-// AddJob — YOUR EXACT API, NOW 100% SAFE
-func AddJob(id string, schedule string, task func()) error {
-	jobMutex.Lock()
-	defer jobMutex.Unlock()
+func (e *JobError) Error() string {
+	return fmt.Sprintf("job %s: %v", e.ID, e.Err)
+}
 
-	if _, exists := jobs[id]; exists {
-		log.Warnf("Job with ID %s already exists, skipping", id)
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// JobFunc is one run of a job, ctx is cancelled when the job times out or the scheduler stops
+type JobFunc func(ctx context.Context) error
+
+// Func adapts a task that takes no context and cannot fail
+func Func(task func()) JobFunc {
+	return func(context.Context) error {
+		task()
 		return nil
 	}
+}
+
+// Run is one run of a job, as seen by middlewares
+type Run struct {
+	Job string
+	// Scheduled is the time the run was due, the time RunNow was called for manual runs
+	Scheduled time.Time
+	Manual    bool
+}
+
+// Middleware wraps the runs of a job, calling next to go on with the run
+type Middleware func(ctx context.Context, run Run, next JobFunc) error
+
+type runLogKey struct{}
+
+// runLog collects what a run wrote with Logf
+type runLog struct {
+	mu     sync.Mutex
+	job    string
+	logger Logger
+	lines  strings.Builder
+}
+
+// Logf writes a line to the log of the run ctx belongs to, which middlewares can keep with
+// RunLog, and to the scheduler's logger. Outside a run it only logs.
+func Logf(ctx context.Context, format string, args ...any) {
+	l, ok := ctx.Value(runLogKey{}).(*runLog)
+	if !ok {
+		log.Infof(format, args...)
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	l.logger.Infof("Job %s: %s", l.job, message)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines.WriteString(time.Now().Format("15:04:05.000 "))
+	l.lines.WriteString(message)
+	l.lines.WriteByte('\n')
+}
+
+// RunLog returns what the run ctx belongs to wrote with Logf so far
+func RunLog(ctx context.Context) string {
+	l, ok := ctx.Value(runLogKey{}).(*runLog)
+	if !ok {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lines.String()
+}
+
+// Overlap decides what happens when a job is due while its previous run is still going
+type Overlap int
+
+const (
+	// OverlapSkip drops the run, the job runs again at its next time
+	OverlapSkip Overlap = iota
+	// OverlapDelay starts the run as soon as the previous one is done. Only one run waits, the
+	// runs due while it does are dropped.
+	OverlapDelay
+)
 
-	entryID, err := cronScheduler.AddFunc(schedule, task)
+func (o Overlap) String() string {
+	if o == OverlapDelay {
+		return "delay"
+	}
+	return "skip"
+}
+
+func (o Overlap) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// PanicPolicy decides what a panicking job does to the process
+type PanicPolicy int
+
+const (
+	// PanicRecover logs the panic with its stack and counts the run as failed
+	PanicRecover PanicPolicy = iota
+	// PanicCrash lets the panic through once the run is recorded, for deployments that restart on crashes
+	PanicCrash
+)
+
+// Clock is the time source of a Scheduler, replaced in tests to drive it without waiting.
+// At returns a channel that receives once t is reached.
+type Clock interface {
+	Now() time.Time
+	At(t time.Time) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) At(t time.Time) <-chan time.Time { return time.After(time.Until(t)) }
+
+// Logger is what a Scheduler reports to, satisfied by a gommon *log.Logger
+type Logger interface {
+	Infof(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Infof(format string, args ...any)  { log.Infof(format, args...) }
+func (defaultLogger) Errorf(format string, args ...any) { log.Errorf(format, args...) }
+
+type Option func(s *Scheduler)
+
+// WithLocation evaluates schedules in loc instead of the local time zone
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) { s.location = loc }
+}
+
+func WithLogger(logger Logger) Option {
+	return func(s *Scheduler) { s.logger = logger }
+}
+
+// WithSeconds reads schedules with a leading seconds field, "0 */5 * * * *" instead of "*/5 * * * *"
+func WithSeconds() Option {
+	return func(s *Scheduler) {
+		s.parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		s.seconds = true
+	}
+}
+
+// WithOverlap sets the overlap policy of jobs added without their own, OverlapSkip by default
+func WithOverlap(overlap Overlap) Option {
+	return func(s *Scheduler) { s.overlap = overlap }
+}
+
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(s *Scheduler) { s.panics = policy }
+}
+
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) { s.clock = clock }
+}
+
+// WithMiddleware wraps the runs of every job, inside the middlewares of the job itself
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(s *Scheduler) { s.middlewares = append(s.middlewares, middlewares...) }
+}
+
+type JobOption func(j *job)
+
+// WithTimeout cancels the context of a run after d
+func WithTimeout(d time.Duration) JobOption {
+	return func(j *job) { j.timeout = d }
+}
+
+// WithJobOverlap overrides the scheduler's overlap policy for one job
+func WithJobOverlap(overlap Overlap) JobOption {
+	return func(j *job) { j.overlap = overlap }
+}
+
+// WithJobMiddleware wraps the runs of one job, outside the middlewares of the scheduler
+func WithJobMiddleware(middlewares ...Middleware) JobOption {
+	return func(j *job) { j.middlewares = append(j.middlewares, middlewares...) }
+}
+
+// JobInfo describes a job, as returned by List. Durations are in nanoseconds in JSON.
+type JobInfo struct {
+	ID string `json:"id"`
+	// Spec is the cron spec of the job, "@every d" for Every and "@at t" for RunAt
+	Spec string `json:"spec"`
+	// Description is Spec in words, empty when Describe cannot tell
+	Description string        `json:"description"`
+	Timeout     time.Duration `json:"timeout"`
+	Overlap     Overlap       `json:"overlap"`
+	Paused      bool          `json:"paused"`
+	Running     bool          `json:"running"`
+	// Next and Interval, the time between the next two runs, are zero while the job is paused.
+	// A one-shot job has no Interval, Every reports its interval without the jitter.
+	Next         time.Time     `json:"next"`
+	Interval     time.Duration `json:"interval"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error"`
+}
+
+type job struct {
+	id          string
+	spec        string
+	description string
+	schedule    cron.Schedule
+	task        JobFunc
+	timeout     time.Duration
+	overlap     Overlap
+	// middlewares run outermost first
+	middlewares []Middleware
+	paused      bool
+	next        time.Time
+	// running is held for the length of a run, the overlap policy tries or waits for it
+	running sync.Mutex
+	active  bool
+	// pending is set while a delayed run waits for running
+	pending bool
+
+	// once jobs leave the scheduler when their run starts
+	once bool
+
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastError    string
+}
+
+// onceSchedule is the schedule of RunAt, it has no run after at
+type onceSchedule struct {
+	at time.Time
+}
+
+func (o onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(o.at) {
+		return o.at
+	}
+	return time.Time{}
+}
+
+// intervalSchedule is the schedule of Every and of "@every" specs
+type intervalSchedule struct {
+	interval time.Duration
+	jitter   time.Duration
+}
+
+func (i intervalSchedule) Next(t time.Time) time.Time {
+	next := t.Add(i.interval)
+	if i.jitter > 0 {
+		next = next.Add(rand.N(i.jitter))
+	}
+	return next
+}
+
+// Scheduler runs jobs on cron schedules, at intervals or once. Jobs can be added, changed and removed at any time,
+// before or after Start.
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     map[string]*job
+	parser   cron.Parser
+	seconds  bool
+	location *time.Location
+	logger   Logger
+	overlap  Overlap
+	panics   PanicPolicy
+	clock    Clock
+	// middlewares run after the ones of the job
+	middlewares []Middleware
+	// observers are called from one goroutine with the changes queued on changes
+	observers []func(JobInfo)
+	changes   chan JobInfo
+
+	// wake interrupts the wait for the next job after a change
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	stopped chan struct{}
+	runs    sync.WaitGroup
+}
+
+func NewScheduler(options ...Option) *Scheduler {
+	s := &Scheduler{
+		jobs:     make(map[string]*job),
+		parser:   cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor),
+		location: time.Local,
+		logger:   defaultLogger{},
+		clock:    realClock{},
+		wake:     make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+func (s *Scheduler) parse(id, spec string) (cron.Schedule, error) {
+	// cron rounds intervals to whole seconds, Every and these keep them as given
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		if interval, err := time.ParseDuration(every); err == nil {
+			if interval <= 0 {
+				return nil, &JobError{ID: id, Err: fmt.Errorf("interval %s is not positive", interval)}
+			}
+			return intervalSchedule{interval: interval}, nil
+		}
+	}
+	schedule, err := s.parser.Parse(spec)
+	if err != nil {
+		return nil, &JobError{ID: id, Err: err}
+	}
+	return schedule, nil
+}
+
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().In(s.location)
+}
+
+// notify wakes the loop to look at the jobs again, a pending wake up already does
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// AddJob schedules task under id, which must not be taken yet
+func (s *Scheduler) AddJob(id string, spec string, task JobFunc, options ...JobOption) error {
+	schedule, err := s.parse(id, spec)
 	if err != nil {
 		return err
 	}
+	return s.add(&job{id: id, spec: spec, description: Describe(spec, s.seconds), schedule: schedule, task: task}, options)
+}
+
+// RunAt runs task once at at, right away if at has passed. The job leaves the scheduler when its
+// run starts, so id can be used again. Removing it before then cancels the run.
+func (s *Scheduler) RunAt(id string, at time.Time, task JobFunc, options ...JobOption) error {
+	return s.add(&job{
+		id:          id,
+		spec:        "@at " + at.Format(time.RFC3339Nano),
+		description: "Once at " + at.In(s.location).Format("2006-01-02 15:04:05"),
+		schedule:    onceSchedule{at: at},
+		task:        task,
+		once:        true,
+		next:        at,
+	}, options)
+}
+
+// RunAfter runs task once, d from now, see RunAt
+func (s *Scheduler) RunAfter(id string, d time.Duration, task JobFunc, options ...JobOption) error {
+	return s.RunAt(id, s.now().Add(d), task, options...)
+}
+
+// Every runs task every interval, delaying each run by a random part of jitter so that instances
+// started together spread their runs. The first run is an interval from now.
+func (s *Scheduler) Every(id string, interval time.Duration, task JobFunc, jitter time.Duration, options ...JobOption) error {
+	if interval <= 0 || jitter < 0 {
+		return &JobError{ID: id, Err: fmt.Errorf("interval %s and jitter %s must be positive", interval, jitter)}
+	}
+	spec := "@every " + interval.String()
+	description := Describe(spec, s.seconds)
+	if jitter > 0 {
+		description += ", up to " + jitter.String() + " later"
+	}
+	return s.add(&job{id: id, spec: spec, description: description, schedule: intervalSchedule{interval: interval, jitter: jitter}, task: task}, options)
+}
+
+// add registers j, its first run is the next of its schedule unless it is set already
+func (s *Scheduler) add(j *job, options []JobOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	jobs[id] = Job{
-		ID:      id,
-		EntryID: entryID,
+	if _, exists := s.jobs[j.id]; exists {
+		return &JobError{ID: j.id, Err: ErrJobExists}
 	}
-	log.Infof("Scheduled job ID: %s", id)
+	j.overlap = s.overlap
+	for _, option := range options {
+		option(j)
+	}
+	if j.next.IsZero() {
+		j.next = j.schedule.Next(s.now())
+	}
+	s.jobs[j.id] = j
+	s.notify()
+	s.publish(j)
+
+	s.logger.Infof("Scheduled job %s (%s), next run %s", j.id, j.spec, j.next.Format(time.RFC3339))
 	return nil
 }
 
-func UpdateJob(id string, schedule string, task func()) error {
-	jobMutex.Lock()
-	defer jobMutex.Unlock()
+// UpdateJob replaces the schedule, task and options of a job, a run in progress finishes as it started
+func (s *Scheduler) UpdateJob(id string, spec string, task JobFunc, options ...JobOption) error {
+	schedule, err := s.parse(id, spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	job, exists := jobs[id]
-	if !exists {
-		log.Errorf("Job with ID %s not found", id)
-		return nil
+	j, ok := s.jobs[id]
+	if !ok {
+		return &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	j.spec, j.description, j.schedule, j.task, j.once = spec, Describe(spec, s.seconds), schedule, task, false
+	j.timeout, j.overlap, j.middlewares = 0, s.overlap, nil
+	for _, option := range options {
+		option(j)
 	}
+	if !j.paused {
+		j.next = schedule.Next(s.now())
+	}
+	s.notify()
+	s.publish(j)
 
-	cronScheduler.Remove(job.EntryID)
+	s.logger.Infof("Updated job %s (%s)", id, spec)
+	return nil
+}
 
-	entryID, err := cronScheduler.AddFunc(schedule, task)
+// Reschedule changes the schedule of a job, keeping its task and options
+func (s *Scheduler) Reschedule(id string, spec string) error {
+	schedule, err := s.parse(id, spec)
 	if err != nil {
 		return err
 	}
 
-	jobs[id] = Job{
-		ID:      id,
-		EntryID: entryID,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	j.spec, j.description, j.schedule, j.once = spec, Describe(spec, s.seconds), schedule, false
+	if !j.paused {
+		j.next = schedule.Next(s.now())
+	}
+	s.notify()
+	s.publish(j)
+
+	s.logger.Infof("Rescheduled job %s (%s)", id, spec)
+	return nil
+}
+
+// RemoveJob unschedules a job, a run in progress is not interrupted
+func (s *Scheduler) RemoveJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	delete(s.jobs, id)
+	s.notify()
+
+	s.logger.Infof("Removed job %s", id)
+	return nil
+}
+
+// List describes every job, sorted by ID
+func (s *Scheduler) List() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		infos = append(infos, j.info())
+	}
+	slices.SortFunc(infos, func(a, b JobInfo) int { return strings.Compare(a.ID, b.ID) })
+	return infos
+}
+
+// Info describes one job
+func (s *Scheduler) Info(id string) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return JobInfo{}, &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	return j.info(), nil
+}
+
+// info must be called with the scheduler locked
+func (j *job) info() JobInfo {
+	info := JobInfo{
+		ID:           j.id,
+		Spec:         j.spec,
+		Description:  j.description,
+		Timeout:      j.timeout,
+		Overlap:      j.overlap,
+		Paused:       j.paused,
+		Running:      j.active,
+		LastRun:      j.lastRun,
+		LastDuration: j.lastDuration,
+		LastError:    j.lastError,
+	}
+	if !j.paused && !j.next.IsZero() {
+		info.Next = j.next
+		switch schedule := j.schedule.(type) {
+		case intervalSchedule:
+			// Jitter only moves the runs, the interval stays the configured one
+			info.Interval = schedule.interval
+		default:
+			// A one-shot job has no run after the next
+			if after := j.schedule.Next(j.next); !after.IsZero() {
+				info.Interval = after.Sub(j.next)
+			}
+		}
+	}
+	return info
+}
+
+// Next returns when a job runs next, the zero time while it is paused
+func (s *Scheduler) Next(id string) (time.Time, error) {
+	info, err := s.Info(id)
+	return info.Next, err
+}
+
+// Pause keeps a job from running on schedule until Resume, RunNow still runs it
+func (s *Scheduler) Pause(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return &JobError{ID: id, Err: ErrJobNotFound}
 	}
-	log.Infof("Updated job ID: %s", id)
+	j.paused = true
+	s.notify()
+	s.publish(j)
 	return nil
 }
 
-func RemoveJob(id string) {
-	jobMutex.Lock()
-	defer jobMutex.Unlock()
+// Resume schedules a paused job again from now on, the runs it missed are not made up
+func (s *Scheduler) Resume(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	job, ok := jobs[id]
+	j, ok := s.jobs[id]
 	if !ok {
-		log.Errorf("Job with ID %s not found", id)
+		return &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	if j.paused {
+		j.paused = false
+		j.next = j.schedule.Next(s.now())
+		s.notify()
+		s.publish(j)
+	}
+	return nil
+}
+
+// RunNow starts a run of a job in the background, subject to its overlap policy like a scheduled run
+func (s *Scheduler) RunNow(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return &JobError{ID: id, Err: ErrJobNotFound}
+	}
+	if !s.dispatch(j, Run{Job: id, Scheduled: s.now(), Manual: true}) {
+		return ErrSchedulerStopped
+	}
+	return nil
+}
+
+// Observe calls observer with the new state of a job whenever it is added, changed, starts or
+// ends a run. Observers are called one at a time, in order, away from the scheduler; changes
+// are dropped while they fall too far behind.
+func (s *Scheduler) Observe(observer func(JobInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observers = append(s.observers, observer)
+	if s.changes == nil {
+		s.changes = make(chan JobInfo, 64)
+		go s.deliver()
+	}
+}
+
+func (s *Scheduler) deliver() {
+	for {
+		select {
+		case info := <-s.changes:
+			s.mu.Lock()
+			observers := slices.Clone(s.observers)
+			s.mu.Unlock()
+			for _, observer := range observers {
+				observer(info)
+			}
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// publish queues the state of j for the observers, it must be called with the scheduler locked
+func (s *Scheduler) publish(j *job) {
+	if s.changes == nil {
+		return
+	}
+	select {
+	case s.changes <- j.info():
+	default:
+	}
+}
+
+// Start runs the scheduling loop in the background. Calling it again, or after Stop, does nothing.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started || s.isStopped() {
 		return
 	}
+	s.started = true
+	go s.loop()
+}
+
+// Stop ends the scheduling loop and waits for the runs in progress. Once ctx is done their
+// contexts are cancelled, and Stop returns ctx.Err() without waiting any longer.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.isStopped() {
+		close(s.stopped)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
 
-	cronScheduler.Remove(job.EntryID)
-	delete(jobs, id)
-	log.Infof("Removed job ID: %s", id)
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) isStopped() bool {
+	select {
+	case <-s.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Scheduler) loop() {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var next time.Time
+		for _, j := range s.jobs {
+			// A schedule with no run left has a zero next
+			if j.paused || j.next.IsZero() {
+				continue
+			}
+			if !j.next.After(now) {
+				s.dispatch(j, Run{Job: j.id, Scheduled: j.next})
+				if j.once {
+					delete(s.jobs, j.id)
+					continue
+				}
+				j.next = j.schedule.Next(now)
+			}
+			if next.IsZero() || j.next.Before(next) {
+				next = j.next
+			}
+		}
+		s.mu.Unlock()
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = s.clock.At(next)
+		}
+		select {
+		case <-timer:
+		case <-s.wake:
+		case <-s.stopped:
+			return
+		}
+	}
 }
 
-// Graceful shutdown — call this in main() or tests
-func ShutdownCron() {
-	if cronScheduler != nil {
-		log.Info("Shutting down cron scheduler...")
-		cronScheduler.Stop()
+// dispatch starts a run of j in the background unless the scheduler stopped, it must be called
+// with the scheduler locked
+func (s *Scheduler) dispatch(j *job, run Run) bool {
+	if s.isStopped() {
+		return false
 	}
+	task, timeout, overlap := j.task, j.timeout, j.overlap
+	if overlap == OverlapDelay {
+		if j.pending {
+			s.logger.Infof("Job %s already has a run waiting, skipping this run", j.id)
+			return true
+		}
+		j.pending = true
+	}
+	middlewares := slices.Concat(j.middlewares, s.middlewares)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		middleware, next := middlewares[i], task
+		task = func(ctx context.Context) error { return middleware(ctx, run, next) }
+	}
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+
+		switch overlap {
+		case OverlapSkip:
+			if !j.running.TryLock() {
+				s.logger.Infof("Job %s is still running, skipping this run", j.id)
+				return
+			}
+		case OverlapDelay:
+			j.running.Lock()
+		}
+		defer j.running.Unlock()
+
+		s.mu.Lock()
+		if overlap == OverlapDelay {
+			j.pending = false
+			// Stop waits for every run, a backlog would hold it up
+			if s.isStopped() {
+				s.mu.Unlock()
+				s.logger.Infof("Scheduler stopped, dropping the delayed run of job %s", j.id)
+				return
+			}
+		}
+		j.active = true
+		started := s.now()
+		s.publish(j)
+		s.mu.Unlock()
+
+		err := s.run(run, task, timeout)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.active = false
+		if !errors.Is(err, ErrSkipped) {
+			j.lastRun, j.lastDuration, j.lastError = started, s.now().Sub(started), ""
+			if err != nil {
+				j.lastError = err.Error()
+			}
+		}
+		s.publish(j)
+	}()
+	return true
+}
+
+// run calls task, recording it in the cron_job_* metrics unless it was skipped. A panic fails
+// the run and is recovered or not according to the panic policy.
+func (s *Scheduler) run(run Run, task JobFunc, timeout time.Duration) (err error) {
+	ctx, cancel := s.ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	ctx = context.WithValue(ctx, runLogKey{}, &runLog{job: run.Job, logger: s.logger})
+
+	start := time.Now()
+	defer func() {
+		r := recover()
+		switch {
+		case r != nil:
+			err = fmt.Errorf("panic: %v", r)
+			s.logger.Errorf("Job %s panicked: %v\n%s", run.Job, r, debug.Stack())
+		case errors.Is(err, ErrSkipped):
+			return
+		case err != nil:
+			s.logger.Errorf("Job %s failed: %v", run.Job, err)
+		}
+		monitoring.RecordJobRun(run.Job, start, err != nil)
+		if r != nil && s.panics == PanicCrash {
+			panic(r)
+		}
+	}()
+
+	return task(ctx)
 }
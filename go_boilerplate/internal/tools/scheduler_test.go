@@ -1,232 +1,880 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/labstack/gommon/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// func TestAddJob_Works_WithInit(t *testing.T) {
-// 	t.Parallel()
-// 	defer ShutdownCron()
+func init() { log.SetLevel(log.OFF) }
 
-// 	var ran bool
-// 	err := AddJob("test", "* * * * * *", func() { ran = true })
-// 	require.NoError(t, err)
+// fakeClock only moves when a test advances it
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
 
-// 	time.Sleep(1200 * time.Millisecond)
-// 	assert.True(t, ran)
-// }
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
 
-func TestConcurrent_Safety(t *testing.T) {
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) At(t time.Time) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if !t.After(c.now) {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{t, ch})
+	return ch
+}
+
+// waiting counts the timers not yet fired
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// recordingLogger keeps what the scheduler logs
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Infof(format string, args ...any)  { l.add(format, args...) }
+func (l *recordingLogger) Errorf(format string, args ...any) { l.add(format, args...) }
+
+func (l *recordingLogger) add(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) contains(text string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func stop(t *testing.T, s *Scheduler) {
+	t.Helper()
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+func TestAddJob_DuplicateID_ReturnsErrJobExists(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
 
-	var wg sync.WaitGroup
-	for i := range 100 {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			id := fmt.Sprintf("job-%d", i)
-			_ = AddJob(id, "* * * * * *", func() {})
-			time.Sleep(10 * time.Millisecond)
-			RemoveJob(id)
-		}(i)
+	s := NewScheduler(WithClock(newFakeClock()))
+	require.NoError(t, s.AddJob("dup", "* * * * *", Func(func() {})))
+
+	err := s.AddJob("dup", "* * * * *", Func(func() {}))
+	assert.ErrorIs(t, err, ErrJobExists)
+	var jobErr *JobError
+	require.ErrorAs(t, err, &jobErr)
+	assert.Equal(t, "dup", jobErr.ID)
+	assert.Len(t, s.List(), 1)
+}
+
+func TestMissingJob_ReturnsErrJobNotFound(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	defer stop(t, s)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"UpdateJob", func() error { return s.UpdateJob("ghost", "* * * * *", Func(func() {})) }},
+		{"RemoveJob", func() error { return s.RemoveJob("ghost") }},
+		{"Pause", func() error { return s.Pause("ghost") }},
+		{"Resume", func() error { return s.Resume("ghost") }},
+		{"RunNow", func() error { return s.RunNow("ghost") }},
+		{"Next", func() error { _, err := s.Next("ghost"); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.ErrorIs(t, err, ErrJobNotFound)
+			var jobErr *JobError
+			require.ErrorAs(t, err, &jobErr)
+			assert.Equal(t, "ghost", jobErr.ID)
+		})
+	}
+}
+
+func TestInvalidSpec_ReturnsError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options []Option
+		spec    string
+		valid   bool
+	}{
+		{"garbage", nil, "invalid-spec-here", false},
+		{"five fields", nil, "*/5 * * * *", true},
+		{"seconds without WithSeconds", nil, "0 */5 * * * *", false},
+		{"seconds", []Option{WithSeconds()}, "0 */5 * * * *", true},
+		{"five fields with WithSeconds", []Option{WithSeconds()}, "*/5 * * * *", false},
+		{"descriptor", []Option{WithSeconds()}, "@hourly", true},
+		{"sub-second interval", nil, "@every 250ms", true},
+		{"negative interval", nil, "@every -1s", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewScheduler(tt.options...)
+			err := s.AddJob("job", tt.spec, Func(func() {}))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "job job:")
+			}
+		})
 	}
-	wg.Wait()
 }
 
-func TestAddJob_DuplicateID_Handled(t *testing.T) {
+func TestScheduler_RunsOnSchedule(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
 
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC))
 	var count atomic.Int32
-	task := func() { count.Add(1) }
+	require.NoError(t, s.AddJob("minutely", "* * * * *", Func(func() { count.Add(1) })))
+	s.Start()
 
-	err1 := AddJob("dup", "* * * * * *", task)
-	err2 := AddJob("dup", "* * * * * *", task)
-	require.NoError(t, err1)
-	require.NoError(t, err2) // should not error
+	clock.Advance(30 * time.Second)
+	assert.Never(t, func() bool { return count.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
 
-	time.Sleep(1200 * time.Millisecond)
-	// Should run ONLY ONCE per second, not twice
-	assert.LessOrEqual(t, count.Load(), int32(2)) // ~1-2 runs
+	clock.Advance(30 * time.Second)
+	assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return count.Load() == 2 }, time.Second, 5*time.Millisecond)
+	stop(t, s)
+
+	info, err := s.Info("minutely")
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(time.Minute), info.Next)
+	assert.Equal(t, clock.Now(), info.LastRun)
+	assert.Empty(t, info.LastError)
 }
 
-// func TestUpdateJob_ReplacesTask(t *testing.T) {
-// 	t.Parallel()
-// 	defer ShutdownCron()
+func TestNext_UsesLocation(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
 
-// 	var phase atomic.Int32
-// 	err := AddJob("update", "* * * * * *", func() { phase.Add(1) })
-// 	require.NoError(t, err)
+	// 12:00 UTC is 07:00 in New York
+	clock := newFakeClock()
+	clock.Advance(12 * time.Hour)
 
-// 	time.Sleep(1200 * time.Millisecond)
-// 	before := phase.Load()
+	tests := []struct {
+		name     string
+		location *time.Location
+		want     time.Time
+	}{
+		{"utc", time.UTC, time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"new york", newYork, time.Date(2026, 1, 1, 14, 0, 0, 0, time.UTC)},
+	}
 
-// 	err = UpdateJob("update", "* * * * * *", func() { phase.Add(10) })
-// 	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
-// 	time.Sleep(1200 * time.Millisecond)
-// 	after := phase.Load()
+			s := NewScheduler(WithClock(clock), WithLocation(tt.location))
+			require.NoError(t, s.AddJob("morning", "0 9 * * *", Func(func() {})))
 
-// 	assert.Greater(t, after-before, int32(8)) // new task ran
-// }
+			next, err := s.Next("morning")
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(next), "next run %s", next)
+		})
+	}
+}
 
-func TestUpdateJob_NonExistent_NoPanic(t *testing.T) {
+func TestList_SortedByID(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
 
-	err := UpdateJob("ghost", "* * * * * *", func() {})
-	assert.NoError(t, err) // should not panic
+	s := NewScheduler(WithClock(newFakeClock()))
+	require.NoError(t, s.AddJob("b", "@daily", Func(func() {}), WithTimeout(time.Minute)))
+	require.NoError(t, s.AddJob("c", "@hourly", Func(func() {})))
+	require.NoError(t, s.AddJob("a", "@weekly", Func(func() {}), WithJobOverlap(OverlapDelay)))
+	require.NoError(t, s.RemoveJob("c"))
+
+	infos := s.List()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "a", infos[0].ID)
+	assert.Equal(t, "@weekly", infos[0].Spec)
+	assert.Equal(t, OverlapDelay, infos[0].Overlap)
+	assert.Equal(t, "b", infos[1].ID)
+	assert.Equal(t, time.Minute, infos[1].Timeout)
+	assert.Equal(t, OverlapSkip, infos[1].Overlap)
 }
 
-func TestRemoveJob_NonExistent_NoPanic(t *testing.T) {
+func TestUpdateJob_ReplacesScheduleAndTask(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
 
-	RemoveJob("ghost") // should not panic
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC))
+	var phase atomic.Int32
+	require.NoError(t, s.AddJob("update", "@daily", Func(func() { phase.Add(1) }), WithTimeout(time.Minute)))
+	s.Start()
+	defer stop(t, s)
+
+	require.NoError(t, s.UpdateJob("update", "* * * * *", Func(func() { phase.Add(10) })))
+	info, err := s.Info("update")
+	require.NoError(t, err)
+	assert.Zero(t, info.Timeout, "options are replaced too")
+	assert.Equal(t, clock.Now().Add(time.Minute), info.Next)
+
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return phase.Load() == 10 }, time.Second, 5*time.Millisecond)
 }
 
-func TestInvalidSpec_ReturnsError(t *testing.T) {
+func TestReschedule_KeepsTaskAndOptions(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
 
-	err := AddJob("bad", "invalid-spec-here", func() {})
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC), WithSeconds())
+	require.NoError(t, s.AddJob("cleanup", "0 0 * * * *", Func(func() {}), WithTimeout(time.Minute)))
+
+	err := s.Reschedule("cleanup", "every day")
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "expected")
+	var jobErr *JobError
+	require.ErrorAs(t, err, &jobErr)
+	assert.Equal(t, "cleanup", jobErr.ID)
+
+	require.NoError(t, s.Reschedule("cleanup", "0 15 3 * * *"))
+	info, err := s.Info("cleanup")
+	require.NoError(t, err)
+	assert.Equal(t, "0 15 3 * * *", info.Spec)
+	assert.Equal(t, "At 03:15 every day", info.Description)
+	assert.Equal(t, time.Minute, info.Timeout)
+	assert.Equal(t, time.Date(2026, 1, 1, 3, 15, 0, 0, time.UTC), info.Next)
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	changes := make(chan JobInfo, 16)
+	s.Observe(func(info JobInfo) { changes <- info })
+	defer stop(t, s)
+
+	next := func() JobInfo {
+		select {
+		case info := <-changes:
+			return info
+		case <-time.After(time.Second):
+			t.Fatal("no change observed")
+			return JobInfo{}
+		}
+	}
+
+	require.NoError(t, s.AddJob("observed", "@hourly", Func(func() {})))
+	assert.Equal(t, "observed", next().ID)
+
+	require.NoError(t, s.Pause("observed"))
+	assert.True(t, next().Paused)
+
+	require.NoError(t, s.RunNow("observed"))
+	assert.True(t, next().Running)
+	finished := next()
+	assert.False(t, finished.Running)
+	assert.False(t, finished.LastRun.IsZero())
+}
+
+func TestLogf_CollectsRunLog(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	var collected string
+	require.NoError(t, s.AddJob("chatty", "@yearly", func(ctx context.Context) error {
+		Logf(ctx, "removed %d rows", 3)
+		Logf(ctx, "done")
+		collected = RunLog(ctx)
+		return nil
+	}))
+
+	require.NoError(t, s.RunNow("chatty"))
+	stop(t, s)
+
+	lines := strings.Split(strings.TrimSpace(collected), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], " removed 3 rows"))
+	assert.True(t, strings.HasSuffix(lines[1], " done"))
+	assert.Empty(t, RunLog(context.Background()))
+}
+
+func TestPauseResume(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC))
+	var count atomic.Int32
+	require.NoError(t, s.AddJob("pausable", "* * * * *", Func(func() { count.Add(1) })))
+	s.Start()
+	defer stop(t, s)
+
+	require.NoError(t, s.Pause("pausable"))
+	next, err := s.Next("pausable")
+	require.NoError(t, err)
+	assert.True(t, next.IsZero())
+
+	clock.Advance(2 * time.Minute)
+	assert.Never(t, func() bool { return count.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	// Missed runs are not made up, the next one is counted from the resume
+	clock.Advance(30 * time.Second)
+	require.NoError(t, s.Resume("pausable"))
+	next, err = s.Next("pausable")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 3, 0, 0, time.UTC), next)
+
+	clock.Advance(30 * time.Second)
+	assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, 5*time.Millisecond)
 }
 
-// func TestRemoveJob_StopsExecution(t *testing.T) {
-// 	t.Parallel()
-// 	defer ShutdownCron()
+func TestRunNow(t *testing.T) {
+	t.Parallel()
 
-// 	var ran atomic.Bool
-// 	err := AddJob("stop", "* * * * * *", func() { ran.Store(true) })
+	s := NewScheduler(WithClock(newFakeClock()))
+	ran := make(chan struct{}, 1)
+	require.NoError(t, s.AddJob("manual", "@yearly", Func(func() { ran <- struct{}{} })))
+	require.NoError(t, s.Pause("manual"))
+
+	// Runs without Start, and while paused
+	require.NoError(t, s.RunNow("manual"))
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
 
-// 	require.NoError(t, err)
+	stop(t, s)
+	assert.ErrorIs(t, s.RunNow("manual"), ErrSchedulerStopped)
+}
 
-// 	time.Sleep(1200 * time.Millisecond)
-// 	assert.True(t, ran.Load())
+func TestRunAt_RunsOnceThenLeaves(t *testing.T) {
+	t.Parallel()
 
-// 	ran.Store(false)
-// 	RemoveJob("stop")
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC))
+	defer stop(t, s)
+	var count atomic.Int32
+	at := clock.Now().Add(5 * time.Minute)
+	require.NoError(t, s.RunAt("expire-otp", at, Func(func() { count.Add(1) })))
+
+	info, err := s.Info("expire-otp")
+	require.NoError(t, err)
+	assert.Equal(t, at, info.Next)
+	assert.Zero(t, info.Interval)
+	assert.Equal(t, "Once at 2026-01-01 00:05:00", info.Description)
+	s.Start()
+
+	clock.Advance(4 * time.Minute)
+	assert.Never(t, func() bool { return count.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, 5*time.Millisecond)
+	_, err = s.Info("expire-otp")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	clock.Advance(time.Hour)
+	assert.Never(t, func() bool { return count.Load() > 1 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	// The id is free again
+	require.NoError(t, s.RunAfter("expire-otp", 30*time.Second, Func(func() { count.Add(1) })))
+	clock.Advance(30 * time.Second)
+	assert.Eventually(t, func() bool { return count.Load() == 2 }, time.Second, 5*time.Millisecond)
+}
 
-// 	time.Sleep(1200 * time.Millisecond)
-// 	assert.False(t, ran.Load(), "job should not run after remove")
-// }
+func TestRunAt_PastTimeRunsRightAway(t *testing.T) {
+	t.Parallel()
 
-// func TestFullLifecycle(t *testing.T) {
-// 	t.Parallel()
-// 	defer ShutdownCron()
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock))
+	defer stop(t, s)
+	ran := make(chan struct{}, 1)
+	require.NoError(t, s.RunAt("late", clock.Now().Add(-time.Hour), Func(func() { ran <- struct{}{} })))
+	s.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+}
 
-// 	var count atomic.Int32
-// 	id := "lifecycle"
+func TestRemoveJob_CancelsRunAfter(t *testing.T) {
+	t.Parallel()
 
-// 	// Add
-// 	err := AddJob(id, "* * * * * *", func() { count.Add(1) })
-// 	require.NoError(t, err)
-// 	time.Sleep(1200 * time.Millisecond)
-// 	assert.Greater(t, count.Load(), int32(0))
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock))
+	defer stop(t, s)
+	var count atomic.Int32
+	require.NoError(t, s.RunAfter("retry-notify", 30*time.Second, Func(func() { count.Add(1) })))
+	s.Start()
 
-// 	// Update
-// 	err = UpdateJob(id, "*/2 * * * * *", func() { count.Add(10) })
-// 	require.NoError(t, err)
-// 	time.Sleep(2200 * time.Millisecond)
-// 	assert.Greater(t, count.Load(), int32(10))
+	require.NoError(t, s.RemoveJob("retry-notify"))
+	clock.Advance(time.Minute)
+	assert.Never(t, func() bool { return count.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+}
 
-// 	// Remove
-// 	RemoveJob(id)
-// 	time.Sleep(1200 * time.Millisecond)
-// 	// Should not increase significantly
-// 	before := count.Load()
-// 	time.Sleep(1200 * time.Millisecond)
-// 	assert.LessOrEqual(t, count.Load()-before, int32(1))
-// }
+func TestEvery(t *testing.T) {
+	t.Parallel()
 
-func TestNoDataRace_UnderHeavyContention(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock))
+	defer stop(t, s)
+	var count atomic.Int32
+	require.NoError(t, s.Every("poll", 250*time.Millisecond, Func(func() { count.Add(1) }), 0))
+
+	info, err := s.Info("poll")
+	require.NoError(t, err)
+	assert.Equal(t, "@every 250ms", info.Spec)
+	assert.Equal(t, "Every 250ms", info.Description)
+	assert.Equal(t, 250*time.Millisecond, info.Interval)
+	s.Start()
+
+	for want := int32(1); want <= 3; want++ {
+		clock.Advance(250 * time.Millisecond)
+		assert.Eventually(t, func() bool { return count.Load() == want }, time.Second, 5*time.Millisecond)
+	}
+
+	assert.ErrorIs(t, s.Every("poll", time.Second, Func(func() {}), 0), ErrJobExists)
+	assert.Error(t, s.Every("never", 0, Func(func() {}), 0))
+	assert.Error(t, s.Every("backwards", time.Second, Func(func() {}), -time.Second))
+}
+
+func TestEvery_Jitter(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	require.NoError(t, s.Every("spread", time.Minute, Func(func() {}), 10*time.Second))
+	info, err := s.Info("spread")
+	require.NoError(t, err)
+	assert.Equal(t, "Every 1m0s, up to 10s later", info.Description)
+	for range 20 {
+		info, err := s.Info("spread")
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, info.Interval)
+	}
+
+	schedule := intervalSchedule{interval: time.Minute, jitter: 10 * time.Second}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for range 100 {
+		delay := schedule.Next(now).Sub(now)
+		assert.GreaterOrEqual(t, delay, time.Minute)
+		assert.Less(t, delay, time.Minute+10*time.Second)
+	}
+}
+
+func TestOverlapSkip_DropsRunWhileRunning(t *testing.T) {
+	t.Parallel()
+
+	logger := &recordingLogger{}
+	s := NewScheduler(WithClock(newFakeClock()), WithLogger(logger))
+	release := make(chan struct{})
+	var started atomic.Int32
+	require.NoError(t, s.AddJob("slow", "@yearly", Func(func() {
+		started.Add(1)
+		<-release
+	})))
+
+	require.NoError(t, s.RunNow("slow"))
+	require.NoError(t, s.RunNow("slow"))
+	assert.Eventually(t, func() bool { return logger.contains("Job slow is still running") }, time.Second, 5*time.Millisecond)
+
+	close(release)
+	stop(t, s)
+	assert.Equal(t, int32(1), started.Load())
+}
+
+func TestOverlapDelay_RunsOneAfterAnother(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()), WithOverlap(OverlapDelay))
+	var running, overlapped, count atomic.Int32
+	require.NoError(t, s.AddJob("queued", "@yearly", Func(func() {
+		if running.Add(1) > 1 {
+			overlapped.Store(1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		count.Add(1)
+	})))
+
+	require.NoError(t, s.RunNow("queued"))
+	assert.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, s.RunNow("queued"))
+	assert.Eventually(t, func() bool { return count.Load() == 2 }, time.Second, time.Millisecond)
+	stop(t, s)
+
+	assert.Zero(t, overlapped.Load())
+}
+
+func TestOverlapDelay_KeepsOneRunWaiting(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock), WithOverlap(OverlapDelay))
+	release := make(chan struct{})
+	var started atomic.Int32
+	require.NoError(t, s.AddJob("stuck", "@every 1m", Func(func() {
+		if started.Add(1) == 1 {
+			<-release
+		}
+	})))
+	s.Start()
+
+	// Each tick is dispatched once the loop waits on the clock again
+	armed := func() bool { return clock.waiting() > 0 }
+	require.Eventually(t, armed, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool { return started.Load() == 1 }, time.Second, time.Millisecond)
+	for range 5 {
+		require.Eventually(t, armed, time.Second, time.Millisecond)
+		clock.Advance(time.Minute)
+	}
+	require.Eventually(t, armed, time.Second, time.Millisecond)
+
+	close(release)
+	assert.Eventually(t, func() bool { return started.Load() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop(t, s)
+	assert.Equal(t, int32(2), started.Load(), "the ticks during the stuck run make one delayed run")
+}
+
+func TestOverlapDelay_StopDropsWaitingRun(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()), WithOverlap(OverlapDelay))
+	release := make(chan struct{})
+	var started atomic.Int32
+	require.NoError(t, s.AddJob("stuck", "@yearly", Func(func() {
+		started.Add(1)
+		<-release
+	})))
+
+	require.NoError(t, s.RunNow("stuck"))
+	require.Eventually(t, func() bool { return started.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, s.RunNow("stuck"))
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(context.Background()) }()
+	require.Eventually(t, func() bool { return errors.Is(s.RunNow("stuck"), ErrSchedulerStopped) }, time.Second, time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for the delayed run")
+	}
+	assert.Equal(t, int32(1), started.Load())
+}
+
+func TestWithTimeout_CancelsJobContext(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	require.NoError(t, s.AddJob("stuck", "@yearly", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(10*time.Millisecond)))
+
+	require.NoError(t, s.RunNow("stuck"))
+	stop(t, s)
+
+	info, err := s.Info("stuck")
+	require.NoError(t, err)
+	assert.Contains(t, info.LastError, context.DeadlineExceeded.Error())
+	assert.False(t, info.Running)
+}
+
+func TestJobError_IsRecorded(t *testing.T) {
+	t.Parallel()
+
+	logger := &recordingLogger{}
+	s := NewScheduler(WithClock(newFakeClock()), WithLogger(logger))
+	require.NoError(t, s.AddJob("failing", "@yearly", func(context.Context) error { return errors.New("disk full") }))
+
+	require.NoError(t, s.RunNow("failing"))
+	stop(t, s)
+
+	info, err := s.Info("failing")
+	require.NoError(t, err)
+	assert.Equal(t, "disk full", info.LastError)
+	assert.True(t, logger.contains("Job failing failed: disk full"))
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	var mu sync.Mutex
+	var calls []string
+	var runs []Run
+	trace := func(name string) Middleware {
+		return func(ctx context.Context, run Run, next JobFunc) error {
+			mu.Lock()
+			calls, runs = append(calls, name), append(runs, run)
+			mu.Unlock()
+			return next(ctx)
+		}
+	}
+
+	s := NewScheduler(WithClock(clock), WithLocation(time.UTC), WithMiddleware(trace("scheduler")))
+	require.NoError(t, s.AddJob("wrapped", "* * * * *", Func(func() {
+		mu.Lock()
+		calls = append(calls, "task")
+		mu.Unlock()
+	}), WithJobMiddleware(trace("job"))))
+	s.Start()
+
+	clock.Advance(90 * time.Second)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == 3
+	}, time.Second, 5*time.Millisecond)
+	stop(t, s)
+
+	assert.Equal(t, []string{"job", "scheduler", "task"}, calls)
+	assert.Equal(t, Run{Job: "wrapped", Scheduled: time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC)}, runs[0])
+}
+
+func TestErrSkipped_LeavesLastRun(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	var ran atomic.Bool
+	require.NoError(t, s.AddJob("elsewhere", "@yearly", Func(func() { ran.Store(true) }), WithJobMiddleware(
+		func(ctx context.Context, run Run, next JobFunc) error {
+			assert.True(t, run.Manual)
+			return ErrSkipped
+		},
+	)))
+
+	require.NoError(t, s.RunNow("elsewhere"))
+	stop(t, s)
+
+	info, err := s.Info("elsewhere")
+	require.NoError(t, err)
+	assert.False(t, ran.Load())
+	assert.True(t, info.LastRun.IsZero())
+	assert.Empty(t, info.LastError)
+	assert.Equal(t, 365*24*time.Hour, info.Interval)
+}
+
+func TestPanicPolicy(t *testing.T) {
+	t.Parallel()
+
+	panicking := Func(func() { panic("boom") })
+
+	recovering := NewScheduler(WithClock(newFakeClock()))
+	require.NoError(t, recovering.AddJob("panics", "@yearly", panicking))
+	require.NoError(t, recovering.RunNow("panics"))
+	stop(t, recovering)
+	info, err := recovering.Info("panics")
+	require.NoError(t, err)
+	assert.Equal(t, "panic: boom", info.LastError)
+
+	crashing := NewScheduler(WithClock(newFakeClock()), WithPanicPolicy(PanicCrash))
+	assert.PanicsWithValue(t, "boom", func() { _ = crashing.run(Run{Job: "panics"}, panicking, 0) })
+}
+
+func TestStop_CancelsRunsWhenContextDone(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	cancelled := make(chan struct{})
+	require.NoError(t, s.AddJob("long", "@yearly", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}))
+	s.Start()
+	require.NoError(t, s.RunNow("long"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("job context was not cancelled")
+	}
+}
+
+func TestConcurrent_Safety(t *testing.T) {
 	t.Parallel()
-	defer ShutdownCron()
+
+	clock := newFakeClock()
+	s := NewScheduler(WithClock(clock))
+	s.Start()
+	defer stop(t, s)
 
 	var wg sync.WaitGroup
 	for i := range 100 {
-		wg.Add(3)
-
+		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			id := fmt.Sprintf("race-%d", i%10)
-			_ = AddJob(id, "@hourly", func() {})
+			id := fmt.Sprintf("job-%d", i)
+			_ = s.AddJob(id, "* * * * *", Func(func() {}))
+			clock.Advance(time.Minute)
+			_ = s.RemoveJob(id)
 		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, s.List())
+}
 
-		go func(i int) {
+func TestNoDataRace_UnderHeavyContention(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(WithClock(newFakeClock()))
+	s.Start()
+	defer stop(t, s)
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(5)
+		id := fmt.Sprintf("race-%d", i%10)
+
+		go func() {
+			defer wg.Done()
+			_ = s.AddJob(id, "@hourly", Func(func() {}))
+		}()
+
+		go func() {
 			defer wg.Done()
-			id := fmt.Sprintf("race-%d", i%10)
 			time.Sleep(5 * time.Millisecond)
-			RemoveJob(id)
-		}(i)
+			_ = s.RemoveJob(id)
+		}()
 
-		go func(i int) {
+		go func() {
 			defer wg.Done()
-			id := fmt.Sprintf("race-%d", i%10)
-			_ = UpdateJob(id, "@daily", func() {})
-		}(i)
+			_ = s.UpdateJob(id, "@daily", Func(func() {}))
+		}()
+
+		go func() {
+			defer wg.Done()
+			_ = s.Pause(id)
+			_ = s.Resume(id)
+		}()
+
+		go func() {
+			defer wg.Done()
+			_ = s.RunNow(id)
+			_ = s.List()
+		}()
 	}
 	wg.Wait()
 }
 
 // ——————— BENCHMARK: AddJob ———————
 func BenchmarkAddJob(b *testing.B) {
-	// Reset timer to exclude init()
+	s := NewScheduler()
 
-	// Run b.N times — this is the gold standard
 	for b.Loop() {
 		id := "bench-job"
-		_ = AddJob(id, "@every 1h", func() {}) // cold schedule, no execution
-		RemoveJob(id)                          // clean up
+		_ = s.AddJob(id, "@every 1h", Func(func() {})) // cold schedule, no execution
+		_ = s.RemoveJob(id)                            // clean up
 	}
 }
 
 // ——————— BENCHMARK: AddJob (hot path, no mutex contention) ———————
 func BenchmarkAddJob_UniqueIDs(b *testing.B) {
+	s := NewScheduler()
 
 	for i := 0; b.Loop(); i++ {
-		_ = AddJob(string(rune(i)), "@daily", func() {})
+		_ = s.AddJob(string(rune(i)), "@daily", Func(func() {}))
 	}
 }
 
 // ——————— BENCHMARK: RemoveJob ———————
 func BenchmarkRemoveJob(b *testing.B) {
+	s := NewScheduler()
 	id := "bench-remove"
-	_ = AddJob(id, "@yearly", func() {})
+	_ = s.AddJob(id, "@yearly", Func(func() {}))
 
 	for b.Loop() {
-		RemoveJob(id)
+		_ = s.RemoveJob(id)
 		// Re-add to keep map entry
-		_ = AddJob(id, "@yearly", func() {})
+		_ = s.AddJob(id, "@yearly", Func(func() {}))
 	}
 }
 
 // ——————— BENCHMARK: UpdateJob ———————
 func BenchmarkUpdateJob(b *testing.B) {
+	s := NewScheduler()
 	id := "bench-update"
-	_ = AddJob(id, "@hourly", func() {})
+	_ = s.AddJob(id, "@hourly", Func(func() {}))
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_ = UpdateJob(id, "@daily", func() {})
+	for b.Loop() {
+		_ = s.UpdateJob(id, "@daily", Func(func() {}))
 	}
 }
 
 // ——————— BENCHMARK: Concurrent Add/Remove/Update ———————
 func BenchmarkConcurrent_Ops(b *testing.B) {
-	// Pre-warm the scheduler
-	_ = AddJob("warmup", "@yearly", func() {})
-	RemoveJob("warmup")
+	s := NewScheduler()
+	s.Start()
+	defer func() { _ = s.Stop(context.Background()) }()
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
@@ -236,33 +884,33 @@ func BenchmarkConcurrent_Ops(b *testing.B) {
 			id := "bench-concurrent"
 			switch op {
 			case 0:
-				_ = AddJob(id, "@hourly", func() {})
+				_ = s.AddJob(id, "@hourly", Func(func() {}))
 			case 1:
-				_ = UpdateJob(id, "@daily", func() {})
+				_ = s.UpdateJob(id, "@daily", Func(func() {}))
 			case 2:
-				RemoveJob(id)
+				_ = s.RemoveJob(id)
 			}
 			counter++
 		}
 	})
 }
 
-// ——————— BENCHMARK: Real job execution (every 1ms) ———————
-func BenchmarkJobExecution_1ms(b *testing.B) {
+// ——————— BENCHMARK: Real job execution (every second) ———————
+func BenchmarkJobExecution_1s(b *testing.B) {
 	var count atomic.Int32
-	id := "fast-job"
+	s := NewScheduler(WithSeconds())
+	s.Start()
+	defer func() { _ = s.Stop(context.Background()) }()
 
-	// Schedule a job that runs ~1000 times per second
-	err := AddJob(id, "*/1 * * * * *", func() {
+	err := s.AddJob("fast-job", "* * * * * *", Func(func() {
 		count.Add(1)
-	})
+	}))
 	if err != nil {
 		b.Fatal(err)
 	}
 
 	// Let it run for ~1 second
 	time.Sleep(1200 * time.Millisecond)
-	RemoveJob(id)
 
 	// Report executions per second
 	b.ReportMetric(float64(count.Load())/1.2, "executions/sec")
@@ -271,9 +919,10 @@ func BenchmarkJobExecution_1ms(b *testing.B) {
 // ——————— BENCHMARK: Memory allocations ———————
 func BenchmarkAddJob_Allocations(b *testing.B) {
 	b.ReportAllocs()
+	s := NewScheduler()
 
 	for b.Loop() {
-		_ = AddJob("alloc-test", "@daily", func() {})
-		RemoveJob("alloc-test")
+		_ = s.AddJob("alloc-test", "@daily", Func(func() {}))
+		_ = s.RemoveJob("alloc-test")
 	}
 }
@@ -0,0 +1,276 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/gommon/log"
+)
+
+// Delivery statuses of outbound webhooks
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+	DeliveryDead      = "dead"
+)
+
+// Domain events sent to subscribers
+const (
+	EventUserCreated = "user.created"
+	EventUserDeleted = "user.deleted"
+)
+
+// Events lists what subscriptions can choose from, add new domain events here
+var Events = []string{EventUserCreated, EventUserDeleted}
+
+var ErrNotRedeliverable = errors.New("webhook delivery not found or still sending")
+
+// Envelope is the JSON body of every outbound delivery
+type Envelope struct {
+	Type    string          `json:"type"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Dispatch queues event for every active subscription listening to it.
+// Pass a repository bound to the caller's transaction, so the event is only sent when the change commits.
+func Dispatch(ctx context.Context, repo *repository.Queries, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(Envelope{Type: event, Created: time.Now().UTC(), Data: encoded})
+	if err != nil {
+		return err
+	}
+
+	queued, err := repo.EnqueueWebhookDeliveries(ctx, repository.EnqueueWebhookDeliveriesParams{Event: event, Payload: payload})
+	if err != nil {
+		return err
+	}
+	log.Debugf("Queued %s for %d webhook subscriptions", event, queued)
+	return nil
+}
+
+// Subscribe stores a subscription for url and returns the signing secret, which is only shown this once.
+// An empty events list subscribes to everything.
+func Subscribe(ctx context.Context, repo *repository.Queries, url string, events []string) (repository.CreateWebhookSubscriptionRow, string, error) {
+	secret, err := auth.NewToken()
+	if err != nil {
+		return repository.CreateWebhookSubscriptionRow{}, "", err
+	}
+	sealed, err := auth.Seal([]byte(secret))
+	if err != nil {
+		return repository.CreateWebhookSubscriptionRow{}, "", err
+	}
+	if events == nil {
+		events = []string{}
+	}
+
+	subscription, err := repo.CreateWebhookSubscription(ctx, repository.CreateWebhookSubscriptionParams{
+		ID:     uuid.New(),
+		Url:    url,
+		Secret: sealed,
+		Events: events,
+	})
+	if err != nil {
+		return repository.CreateWebhookSubscriptionRow{}, "", err
+	}
+	return subscription, secret, nil
+}
+
+// Dispatcher sends queued deliveries, signed like the Signed provider expects them.
+// It is driven by the scheduler, see Job.
+type Dispatcher struct {
+	repo    *repository.Queries
+	client  *http.Client
+	running sync.Mutex
+
+	// MaxAttempts per delivery before it is dead-lettered
+	MaxAttempts int
+	// MaxFailures in a row across a subscription's deliveries before it is disabled
+	MaxFailures int
+	Backoff     func(attempt int) time.Duration
+	Lease       time.Duration
+	BatchSize   int32
+	Now         func() time.Time
+	// OnDisabled is called when a subscription is switched off for failing too often
+	OnDisabled func(subscription uuid.UUID, url string, err error)
+}
+
+// NewDispatcher returns a dispatcher with a 10 second request timeout and default retry settings
+func NewDispatcher(repo *repository.Queries) *Dispatcher {
+	return &Dispatcher{
+		repo:        repo,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 10,
+		MaxFailures: 20,
+		Backoff:     ExponentialBackoff(time.Minute, 12*time.Hour),
+		Lease:       time.Minute,
+		BatchSize:   25,
+		Now:         time.Now,
+	}
+}
+
+// Job returns a task for tools.Func that delivers everything due.
+// A run still in progress when the next one fires makes the new run a no-op.
+func (d *Dispatcher) Job() func() {
+	return func() {
+		if !d.running.TryLock() {
+			return
+		}
+		defer d.running.Unlock()
+
+		// Full batches mean more deliveries are due, so keep going until the backlog is drained
+		for claimed := d.BatchSize; claimed == d.BatchSize; {
+			claimed = int32(d.DeliverDue(context.Background()))
+		}
+	}
+}
+
+// DeliverDue claims one batch of due deliveries and sends them, returning how many were claimed
+func (d *Dispatcher) DeliverDue(ctx context.Context) int {
+	rows, err := d.repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		LeaseUntil: d.Now().Add(d.Lease),
+		Batch:      d.BatchSize,
+	})
+	if err != nil {
+		log.Errorf("Failed to claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, row := range rows {
+		wg.Go(func() { d.deliver(ctx, row) })
+	}
+	wg.Wait()
+	return len(rows)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, row repository.ClaimWebhookDeliveriesRow) {
+	started := d.Now()
+	code, err := d.send(ctx, row)
+	duration := d.Now().Sub(started)
+
+	attempt := repository.RecordWebhookAttemptParams{
+		ID:         uuid.New(),
+		DeliveryID: row.ID,
+		StatusCode: int32(code),
+		DurationMs: int32(duration.Milliseconds()),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := d.repo.RecordWebhookAttempt(ctx, attempt); err != nil {
+		log.Errorf("Failed to record attempt for webhook delivery %s: %v", row.ID, err)
+	}
+
+	if err == nil {
+		if err := d.repo.CompleteWebhookDelivery(ctx, row.ID); err != nil {
+			log.Errorf("Failed to complete webhook delivery %s: %v", row.ID, err)
+		}
+		if err := d.repo.ResetWebhookSubscriptionFailures(ctx, row.SubscriptionID); err != nil {
+			log.Errorf("Failed to reset failures of webhook subscription %s: %v", row.SubscriptionID, err)
+		}
+		return
+	}
+
+	status, next := DeliveryFailed, d.Now().Add(d.Backoff(int(row.Attempts)))
+	if int(row.Attempts) >= d.MaxAttempts {
+		status = DeliveryDead
+	}
+	if err := d.repo.FailWebhookDelivery(ctx, repository.FailWebhookDeliveryParams{ID: row.ID, Status: status, NextAttempt: next}); err != nil {
+		log.Errorf("Failed to record webhook delivery failure for %s: %v", row.ID, err)
+	}
+
+	active, failErr := d.repo.RecordWebhookSubscriptionFailure(ctx, repository.RecordWebhookSubscriptionFailureParams{
+		MaxFailures: int32(d.MaxFailures),
+		Reason:      fmt.Sprintf("Disabled after %d failed deliveries in a row, last: %v", d.MaxFailures, err),
+		ID:          row.SubscriptionID,
+	})
+	// No row means the subscription was already disabled, by a concurrent delivery or an admin
+	if failErr != nil && !errors.Is(failErr, pgx.ErrNoRows) {
+		log.Errorf("Failed to count failure of webhook subscription %s: %v", row.SubscriptionID, failErr)
+	} else if failErr == nil && !active {
+		log.Errorf("Webhook subscription %s to %s disabled: %v", row.SubscriptionID, row.Url, err)
+		if d.OnDisabled != nil {
+			d.OnDisabled(row.SubscriptionID, row.Url, err)
+		}
+	}
+
+	if status == DeliveryDead {
+		log.Errorf("Webhook delivery %s of %s to %s dead-lettered after %d attempts: %v", row.ID, row.Event, row.Url, row.Attempts, err)
+		return
+	}
+	log.Warnf("Webhook delivery %s attempt %d failed, retrying at %s: %v", row.ID, row.Attempts, next.Format(time.RFC3339), err)
+}
+
+// send POSTs the payload and returns the response code, 0 when no response arrived.
+// Anything outside 2xx is a failure.
+func (d *Dispatcher) send(ctx context.Context, row repository.ClaimWebhookDeliveriesRow) (int, error) {
+	secret, err := auth.Open(row.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("unsealing secret: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(d.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, row.Url, bytes.NewReader(row.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_boilerplate-webhooks/1")
+	req.Header.Set("X-Webhook-ID", row.ID.String())
+	req.Header.Set("X-Webhook-Event", row.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(secret, signedPayload(timestamp, row.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little so the connection can be reused, receivers have nothing to tell us
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Redeliver queues a delivery again with a fresh set of attempts and returns its subscription.
+// Deliveries of a disabled subscription wait until it is enabled again.
+func Redeliver(ctx context.Context, repo *repository.Queries, id uuid.UUID) (uuid.UUID, error) {
+	subscription, err := repo.RedeliverWebhook(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNotRedeliverable
+	}
+	return subscription, err
+}
+
+// CleanupWebhookDeliveries removes finished deliveries older than 30 days, meant to be scheduled with tools.Func
+func CleanupWebhookDeliveries() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteOldWebhookDeliveries(context.Background(), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		log.Errorf("Failed to clean up webhook deliveries: %v", err)
+		return
+	}
+	log.Debugf("Removed %d webhook deliveries", removed)
+}
@@ -0,0 +1,196 @@
+// outbound_test.go
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/auth"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deliveryColumns = []string{"id", "subscription_id", "event", "payload", "attempts", "created", "url", "secret"}
+
+type received struct {
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// newTestSubscriber starts a receiver that checks signatures the way the Signed provider does
+func newTestSubscriber(t *testing.T, status int) (*httptest.Server, chan received) {
+	t.Helper()
+
+	verifier := Signed("app", string(testSecret)).Verifier
+	deliveries := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- received{header: r.Header.Clone(), body: body, err: verifier.Verify(r, body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, deliveries
+}
+
+func newTestDispatcher(t *testing.T) (*Dispatcher, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	return NewDispatcher(repository.New(mock)), mock
+}
+
+func claimedDelivery(t *testing.T, id uuid.UUID, subscription uuid.UUID, url string, attempts int32) *pgxmock.Rows {
+	t.Helper()
+
+	sealed, err := auth.Seal(testSecret)
+	require.NoError(t, err)
+	return pgxmock.NewRows(deliveryColumns).
+		AddRow(id, subscription, EventUserCreated, testBody, attempts, time.Now(), url, sealed)
+}
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int32
+		wantStatus   string
+		stillActive  bool
+		wantDisabled bool
+	}{
+		{name: "success", status: http.StatusNoContent, attempts: 1, wantStatus: DeliverySucceeded},
+		{name: "error response is retried", status: http.StatusInternalServerError, attempts: 1, wantStatus: DeliveryFailed, stillActive: true},
+		{name: "last attempt is dead-lettered", status: http.StatusBadGateway, attempts: 10, wantStatus: DeliveryDead, stillActive: true},
+		{name: "repeated failures disable the subscription", status: http.StatusGone, attempts: 3, wantStatus: DeliveryFailed, wantDisabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, deliveries := newTestSubscriber(t, tt.status)
+			d, mock := newTestDispatcher(t)
+			var disabled bool
+			d.OnDisabled = func(uuid.UUID, string, error) { disabled = true }
+
+			id, subscription := uuid.New(), uuid.New()
+			mock.ExpectQuery("UPDATE webhook_deliveries d").WithArgs(pgxmock.AnyArg(), int32(25)).
+				WillReturnRows(claimedDelivery(t, id, subscription, server.URL, tt.attempts))
+			mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+				WithArgs(pgxmock.AnyArg(), id, int32(tt.status), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			if tt.wantStatus == DeliverySucceeded {
+				mock.ExpectExec("SET status = 'succeeded'").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mock.ExpectExec("SET failures = 0").WithArgs(subscription).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			} else {
+				mock.ExpectExec("SET status = \\$2").WithArgs(id, tt.wantStatus, pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mock.ExpectQuery("SET failures = failures \\+ 1").WithArgs(int32(20), pgxmock.AnyArg(), subscription).
+					WillReturnRows(pgxmock.NewRows([]string{"active"}).AddRow(tt.stillActive))
+			}
+
+			claimed := d.DeliverDue(context.Background())
+
+			assert.Equal(t, 1, claimed)
+			got := <-deliveries
+			require.NoError(t, got.err)
+			assert.Equal(t, testBody, got.body)
+			assert.Equal(t, id.String(), got.header.Get("X-Webhook-ID"))
+			assert.Equal(t, EventUserCreated, got.header.Get("X-Webhook-Event"))
+			assert.Equal(t, tt.wantDisabled, disabled)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDispatcher_DeliverDueRecordsUnreachableReceiver(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	d, mock := newTestDispatcher(t)
+	id, subscription := uuid.New(), uuid.New()
+	mock.ExpectQuery("UPDATE webhook_deliveries d").WithArgs(pgxmock.AnyArg(), int32(25)).
+		WillReturnRows(claimedDelivery(t, id, subscription, url, 1))
+	mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+		WithArgs(pgxmock.AnyArg(), id, int32(0), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("SET status = \\$2").WithArgs(id, DeliveryFailed, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	// Already disabled by another delivery, so no second notification
+	mock.ExpectQuery("SET failures = failures \\+ 1").WithArgs(int32(20), pgxmock.AnyArg(), subscription).
+		WillReturnError(pgx.ErrNoRows)
+
+	d.OnDisabled = func(uuid.UUID, string, error) { t.Error("subscription reported disabled twice") }
+
+	assert.Equal(t, 1, d.DeliverDue(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// capture is a pgxmock argument matcher that keeps what it was matched against
+type capture struct{ value *[]byte }
+
+func (c capture) Match(v any) bool {
+	b, ok := v.([]byte)
+	*c.value = b
+	return ok
+}
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	var payload []byte
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs(EventUserDeleted, capture{&payload}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+	require.NoError(t, Dispatch(context.Background(), repository.New(mock), EventUserDeleted, map[string]string{"id": "42"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(payload, &envelope))
+	assert.Equal(t, EventUserDeleted, envelope.Type)
+	assert.False(t, envelope.Created.IsZero())
+	assert.JSONEq(t, `{"id":"42"}`, string(envelope.Data))
+}
+
+func TestRedeliver(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	id, subscription := uuid.New(), uuid.New()
+	mock.ExpectQuery("SET status = 'pending'").WithArgs(id).
+		WillReturnRows(pgxmock.NewRows([]string{"subscription_id"}).AddRow(subscription))
+	mock.ExpectQuery("SET status = 'pending'").WithArgs(id).WillReturnError(pgx.ErrNoRows)
+
+	got, err := Redeliver(context.Background(), repository.New(mock), id)
+	require.NoError(t, err)
+	assert.Equal(t, subscription, got)
+
+	_, err = Redeliver(context.Background(), repository.New(mock), id)
+	assert.ErrorIs(t, err, ErrNotRedeliverable)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
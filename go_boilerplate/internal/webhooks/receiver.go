@@ -0,0 +1,383 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/apperrors"
+	"github.com/__username__/go_boilerplate/internal/database"
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+	StatusDead       = "dead"
+
+	MaxPayload = 1 << 20
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown webhook provider")
+	ErrNotReplayable   = errors.New("webhook event not found or still processing")
+)
+
+// Event is a verified delivery as handed to a Handler
+type Event struct {
+	ID       uuid.UUID
+	Provider string
+	EventID  string
+	Type     string
+	Payload  []byte
+	Headers  http.Header
+	Attempt  int
+}
+
+// Handler processes an event. Returning an error schedules a retry, until the event is dead-lettered.
+// Deliveries are retried by providers too, so handlers must tolerate seeing an event again after a replay.
+type Handler func(ctx context.Context, event Event) error
+
+// Provider describes one webhook sender, mounted at /webhook/<Name>
+type Provider struct {
+	Name     string
+	Verifier Verifier
+	// Identify returns the provider's unique event ID and type, the ID deduplicates redeliveries
+	Identify func(r *http.Request, body []byte) (id string, eventType string, err error)
+	// Headers lists the request headers kept with the payload for handlers and the admin view
+	Headers []string
+}
+
+type Receiver struct {
+	repo      *repository.Queries
+	providers map[string]Provider
+	handlers  map[string]Handler
+	wake      chan struct{}
+
+	MaxAttempts  int
+	Backoff      func(attempt int) time.Duration
+	Lease        time.Duration
+	PollInterval time.Duration
+	BatchSize    int32
+	// OnDeadLetter is called when an event has used up its attempts
+	OnDeadLetter func(event Event, err error)
+}
+
+// NewReceiver returns a receiver storing its inbox through repo, with default retry settings
+func NewReceiver(repo *repository.Queries) *Receiver {
+	return &Receiver{
+		repo:         repo,
+		providers:    make(map[string]Provider),
+		handlers:     make(map[string]Handler),
+		wake:         make(chan struct{}, 1),
+		MaxAttempts:  8,
+		Backoff:      ExponentialBackoff(30*time.Second, 6*time.Hour),
+		Lease:        5 * time.Minute,
+		PollInterval: 5 * time.Second,
+		BatchSize:    10,
+	}
+}
+
+// Register mounts provider and sets the handler its events are delivered to.
+// Register everything before Run and before the server starts.
+func (r *Receiver) Register(provider Provider, handler Handler) {
+	r.providers[provider.Name] = provider
+	r.handlers[provider.Name] = handler
+}
+
+// ExponentialBackoff doubles the delay after each attempt, starting at base and capped at max
+func ExponentialBackoff(base time.Duration, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
+
+// Handle verifies and stores a delivery for POST /webhook/:provider, answering before the handler runs.
+// Senders only learn whether the delivery was accepted, so slow handlers never cause provider timeouts.
+func (r *Receiver) Handle() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("provider")
+		provider, ok := r.providers[name]
+		if !ok {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusNotFound, Message: ErrUnknownProvider.Error() + ": " + name, UserMessage: "Unknown webhook"}, nil)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, MaxPayload+1))
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Could not read payload"}, nil)
+		}
+		if len(body) > MaxPayload {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusRequestEntityTooLarge, Message: "Webhook payload for " + name + " over limit", UserMessage: "Payload too large"}, nil)
+		}
+
+		if err := provider.Verifier.Verify(c.Request(), body); err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusUnauthorized, Message: fmt.Sprintf("Rejected %s webhook from %s: %v", name, c.RealIP(), err), UserMessage: "Invalid signature"}, nil)
+		}
+
+		eventID, eventType, err := provider.Identify(c.Request(), body)
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusBadRequest, Message: err.Error(), UserMessage: "Could not identify event"}, nil)
+		}
+
+		headers := make(http.Header)
+		for _, key := range append([]string{echo.HeaderContentType}, provider.Headers...) {
+			if values := c.Request().Header.Values(key); len(values) > 0 {
+				headers[http.CanonicalHeaderKey(key)] = values
+			}
+		}
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not store event"}, nil)
+		}
+
+		stored, err := r.repo.CreateWebhookEvent(c.Request().Context(), repository.CreateWebhookEventParams{
+			ID:        uuid.New(),
+			Provider:  name,
+			EventID:   eventID,
+			EventType: eventType,
+			Payload:   body,
+			Headers:   encoded,
+		})
+		if err != nil {
+			return apperrors.SendReturnedGenericJSONError(c, apperrors.GenericError{Code: http.StatusInternalServerError, Message: err.Error(), UserMessage: "Could not store event"}, nil)
+		}
+
+		// A redelivery of an event we already have is acknowledged so the provider stops retrying
+		if stored == 0 {
+			log.Debugf("Duplicate %s webhook %s ignored", name, eventID)
+			return c.JSON(http.StatusOK, map[string]string{"status": "duplicate"})
+		}
+
+		r.Wake()
+		return c.JSON(http.StatusAccepted, map[string]string{"status": "accepted"})
+	}
+}
+
+// Wake makes Run look for due events right away instead of at the next poll
+func (r *Receiver) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due events to their handlers until ctx is cancelled
+func (r *Receiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Full batches mean more events are due, so keep going before waiting
+		for claimed := r.BatchSize; claimed == r.BatchSize && ctx.Err() == nil; {
+			claimed = int32(r.ProcessDue(ctx))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// ProcessDue claims one batch of due events and runs them, returning how many were claimed
+func (r *Receiver) ProcessDue(ctx context.Context) int {
+	rows, err := r.repo.ClaimWebhookEvents(ctx, repository.ClaimWebhookEventsParams{
+		LeaseUntil: time.Now().Add(r.Lease),
+		Batch:      r.BatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("Failed to claim webhook events: %v", err)
+		}
+		return 0
+	}
+
+	for _, row := range rows {
+		r.process(ctx, row)
+	}
+	return len(rows)
+}
+
+func (r *Receiver) process(ctx context.Context, row repository.ClaimWebhookEventsRow) {
+	event := Event{
+		ID:       row.ID,
+		Provider: row.Provider,
+		EventID:  row.EventID,
+		Type:     row.EventType,
+		Payload:  row.Payload,
+		Attempt:  int(row.Attempts),
+	}
+	if err := json.Unmarshal(row.Headers, &event.Headers); err != nil {
+		log.Warnf("Unreadable headers on webhook event %s: %v", row.ID, err)
+	}
+
+	// The handler must finish within the lease, or another worker may pick the event up again
+	handlerCtx, cancel := context.WithTimeout(ctx, r.Lease)
+	err := r.run(handlerCtx, event)
+	cancel()
+
+	if err == nil {
+		if err := r.repo.CompleteWebhookEvent(ctx, event.ID); err != nil {
+			log.Errorf("Failed to complete webhook event %s: %v", event.ID, err)
+		}
+		return
+	}
+
+	status, next := StatusFailed, time.Now().Add(r.Backoff(event.Attempt))
+	if event.Attempt >= r.MaxAttempts {
+		status = StatusDead
+	}
+
+	if err := r.repo.FailWebhookEvent(ctx, repository.FailWebhookEventParams{
+		ID:          event.ID,
+		Status:      status,
+		LastError:   err.Error(),
+		NextAttempt: next,
+	}); err != nil {
+		log.Errorf("Failed to record webhook failure for %s: %v", event.ID, err)
+	}
+
+	if status == StatusDead {
+		log.Errorf("Webhook %s %s (%s) dead-lettered after %d attempts: %v", event.Provider, event.EventID, event.ID, event.Attempt, err)
+		if r.OnDeadLetter != nil {
+			r.OnDeadLetter(event, err)
+		}
+		return
+	}
+	log.Warnf("Webhook %s %s attempt %d failed, retrying at %s: %v", event.Provider, event.EventID, event.Attempt, next.Format(time.RFC3339), err)
+}
+
+func (r *Receiver) run(ctx context.Context, event Event) (err error) {
+	handler, ok := r.handlers[event.Provider]
+	if !ok {
+		return ErrUnknownProvider
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("webhook handler panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+// Replay queues a stored event again with a fresh set of attempts
+func Replay(ctx context.Context, repo *repository.Queries, id uuid.UUID) error {
+	replayed, err := repo.ReplayWebhookEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+	if replayed == 0 {
+		return ErrNotReplayable
+	}
+	return nil
+}
+
+// CleanupWebhookEvents removes events processed more than 30 days ago, meant to be scheduled with tools.Func
+func CleanupWebhookEvents() {
+	repo := repository.New(database.DB())
+	removed, err := repo.DeleteProcessedWebhookEvents(context.Background(), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		log.Errorf("Failed to clean up webhook events: %v", err)
+		return
+	}
+	log.Debugf("Removed %d processed webhook events", removed)
+}
+
+// ProviderFor picks the provider matching name, known senders get their own scheme and anything else uses Signed
+func ProviderFor(name string, secret string) Provider {
+	switch name {
+	case "stripe":
+		return Stripe(secret)
+	case "github":
+		return GitHub(secret)
+	default:
+		return Signed(name, secret)
+	}
+}
+
+// LogHandler only logs events, register real handlers in its place
+func LogHandler(_ context.Context, event Event) error {
+	log.Infof("Received %s webhook %s of type %q", event.Provider, event.EventID, event.Type)
+	return nil
+}
+
+// Stripe identifies events by the id and type of the JSON event object
+func Stripe(secret string) Provider {
+	return Provider{
+		Name:     "stripe",
+		Verifier: &StripeVerifier{Secret: []byte(secret)},
+		Identify: func(_ *http.Request, body []byte) (string, string, error) {
+			var event struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(body, &event); err != nil {
+				return "", "", err
+			}
+			if event.ID == "" {
+				return "", "", errors.New("stripe event without id")
+			}
+			return event.ID, event.Type, nil
+		},
+		Headers: []string{"Stripe-Signature"},
+	}
+}
+
+// GitHub identifies events by the delivery GUID, which GitHub keeps when redelivering
+func GitHub(secret string) Provider {
+	return Provider{
+		Name:     "github",
+		Verifier: GitHubVerifier([]byte(secret)),
+		Identify: HeaderIdentity("X-GitHub-Delivery", "X-GitHub-Event"),
+		Headers:  []string{"X-GitHub-Delivery", "X-GitHub-Event", "X-GitHub-Hook-ID", "X-Hub-Signature-256"},
+	}
+}
+
+// Signed is a provider for senders we control, signing "<timestamp>.<body>" into X-Webhook-Signature
+func Signed(name string, secret string) Provider {
+	return Provider{
+		Name: name,
+		Verifier: &HMACVerifier{
+			Secret:          []byte(secret),
+			SignatureHeader: "X-Webhook-Signature",
+			Prefix:          "sha256=",
+			TimestampHeader: "X-Webhook-Timestamp",
+		},
+		Identify: HeaderIdentity("X-Webhook-ID", "X-Webhook-Event"),
+		Headers:  []string{"X-Webhook-ID", "X-Webhook-Event", "X-Webhook-Timestamp"},
+	}
+}
+
+// HeaderIdentity reads the event ID and type from headers.
+// Without an ID header the SHA-256 of the body is used, so only byte identical deliveries are deduplicated.
+func HeaderIdentity(idHeader string, typeHeader string) func(r *http.Request, body []byte) (string, string, error) {
+	return func(r *http.Request, body []byte) (string, string, error) {
+		id := r.Header.Get(idHeader)
+		if id == "" {
+			sum := sha256.Sum256(body)
+			id = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		if len(id) > 255 {
+			return "", "", errors.New("webhook event id too long")
+		}
+		return id, r.Header.Get(typeHeader), nil
+	}
+}
@@ -0,0 +1,221 @@
+// receiver_test.go
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/__username__/go_boilerplate/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.OFF)
+}
+
+var claimColumns = []string{"id", "provider", "event_id", "event_type", "payload", "headers", "attempts"}
+
+func newTestReceiver(t *testing.T, handler Handler) (*Receiver, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	r := NewReceiver(repository.New(mock))
+	r.Register(GitHub(string(testSecret)), handler)
+	return r, mock
+}
+
+func deliver(r *Receiver, provider string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.POST("/webhook/:provider", r.Handle())
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/"+provider, bytes.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func githubHeaders(body []byte) map[string]string {
+	return map[string]string{
+		"Content-Type":        "application/json",
+		"X-GitHub-Delivery":   "delivery-1",
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + Sign(testSecret, body),
+		"Authorization":       "Bearer not-for-storage",
+	}
+}
+
+func TestReceiver_Handle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		provider   string
+		headers    map[string]string
+		inserted   int64
+		expectSave bool
+		wantStatus int
+	}{
+		{name: "accepted", provider: "github", headers: githubHeaders(testBody), inserted: 1, expectSave: true, wantStatus: http.StatusAccepted},
+		{name: "duplicate delivery", provider: "github", headers: githubHeaders(testBody), inserted: 0, expectSave: true, wantStatus: http.StatusOK},
+		{name: "bad signature", provider: "github", headers: githubHeaders([]byte("other")), wantStatus: http.StatusUnauthorized},
+		{name: "unknown provider", provider: "stripe", headers: githubHeaders(testBody), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, mock := newTestReceiver(t, LogHandler)
+			if tt.expectSave {
+				mock.ExpectExec("INSERT INTO webhook_events").
+					WithArgs(pgxmock.AnyArg(), "github", "delivery-1", "push", testBody,
+						[]byte(`{"Content-Type":["application/json"],"X-Github-Delivery":["delivery-1"],"X-Github-Event":["push"],"X-Hub-Signature-256":["sha256=`+Sign(testSecret, testBody)+`"]}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", tt.inserted))
+			}
+
+			rec := deliver(r, tt.provider, testBody, tt.headers)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReceiver_HandleRejectsOversizedPayload(t *testing.T) {
+	t.Parallel()
+
+	r, mock := newTestReceiver(t, LogHandler)
+	body := bytes.Repeat([]byte("a"), MaxPayload+1)
+
+	rec := deliver(r, "github", body, githubHeaders(body))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiver_ProcessDue(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.New("downstream unavailable")
+
+	tests := []struct {
+		name       string
+		attempts   int32
+		handler    Handler
+		wantStatus string
+		wantDead   bool
+	}{
+		{name: "success", attempts: 1, handler: func(context.Context, Event) error { return nil }, wantStatus: StatusDone},
+		{name: "failure is retried", attempts: 1, handler: func(context.Context, Event) error { return failure }, wantStatus: StatusFailed},
+		{name: "last attempt is dead-lettered", attempts: 8, handler: func(context.Context, Event) error { return failure }, wantStatus: StatusDead, wantDead: true},
+		{name: "panic counts as failure", attempts: 1, handler: func(context.Context, Event) error { panic("boom") }, wantStatus: StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var seen Event
+			r, mock := newTestReceiver(t, func(ctx context.Context, event Event) error {
+				seen = event
+				return tt.handler(ctx, event)
+			})
+			var dead bool
+			r.OnDeadLetter = func(Event, error) { dead = true }
+
+			id := uuid.New()
+			mock.ExpectQuery("UPDATE webhook_events").WithArgs(pgxmock.AnyArg(), int32(10)).
+				WillReturnRows(pgxmock.NewRows(claimColumns).
+					AddRow(id, "github", "delivery-1", "push", testBody, []byte(`{"X-Github-Event":["push"]}`), tt.attempts))
+			if tt.wantStatus == StatusDone {
+				mock.ExpectExec("SET status = 'done'").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			} else {
+				mock.ExpectExec("SET status = \\$2").WithArgs(id, tt.wantStatus, pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			}
+
+			claimed := r.ProcessDue(context.Background())
+
+			assert.Equal(t, 1, claimed)
+			assert.Equal(t, "push", seen.Headers.Get("X-GitHub-Event"))
+			assert.Equal(t, int(tt.attempts), seen.Attempt)
+			assert.Equal(t, tt.wantDead, dead)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	id := uuid.New()
+	mock.ExpectExec("SET status = 'pending'").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("SET status = 'pending'").WithArgs(id).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	assert.NoError(t, Replay(context.Background(), repository.New(mock), id))
+	assert.ErrorIs(t, Replay(context.Background(), repository.New(mock), id), ErrNotReplayable)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := ExponentialBackoff(30*time.Second, 10*time.Minute)
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{40, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, backoff(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestHeaderIdentity_FallsBackToBodyHash(t *testing.T) {
+	t.Parallel()
+
+	identify := HeaderIdentity("X-Webhook-ID", "X-Webhook-Event")
+
+	first, _, err := identify(signedRequest(nil), testBody)
+	require.NoError(t, err)
+	second, _, err := identify(signedRequest(nil), testBody)
+	require.NoError(t, err)
+	other, _, err := identify(signedRequest(nil), []byte("other"))
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+
+	id, eventType, err := identify(signedRequest(map[string]string{"X-Webhook-ID": "abc", "X-Webhook-Event": "ping"}), testBody)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "ping", eventType)
+}
@@ -0,0 +1,168 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("webhook signature missing")
+	ErrInvalidSignature = errors.New("webhook signature invalid")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside of tolerance")
+)
+
+// Verifier authenticates an inbound delivery before anything is stored
+type Verifier interface {
+	Verify(r *http.Request, body []byte) error
+}
+
+// HMACVerifier checks a hex encoded HMAC-SHA256 of the body.
+// With a TimestampHeader the signed payload is "<timestamp>.<body>" and old deliveries are refused,
+// which keeps a captured request from being replayed later.
+type HMACVerifier struct {
+	Secret          []byte
+	SignatureHeader string
+	// Prefix is stripped from the header value, like "sha256=" for GitHub
+	Prefix          string
+	TimestampHeader string
+	Tolerance       time.Duration
+	Now             func() time.Time
+}
+
+func (v *HMACVerifier) Verify(r *http.Request, body []byte) error {
+	signature, ok := strings.CutPrefix(r.Header.Get(v.SignatureHeader), v.Prefix)
+	if !ok || signature == "" {
+		return ErrMissingSignature
+	}
+
+	payload := body
+	if v.TimestampHeader != "" {
+		timestamp := r.Header.Get(v.TimestampHeader)
+		if err := checkTimestamp(timestamp, v.Tolerance, v.Now); err != nil {
+			return err
+		}
+		payload = signedPayload(timestamp, body)
+	}
+
+	if !validMAC(v.Secret, payload, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// StripeVerifier checks the Stripe-Signature header, "t=<unix>,v1=<hex>[,v1=<hex>]".
+// Several v1 entries appear while a secret is being rolled, any of them may match.
+type StripeVerifier struct {
+	Secret    []byte
+	Tolerance time.Duration
+	Now       func() time.Time
+}
+
+func (v *StripeVerifier) Verify(r *http.Request, body []byte) error {
+	header := r.Header.Get("Stripe-Signature")
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for part := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	if err := checkTimestamp(timestamp, v.Tolerance, v.Now); err != nil {
+		return err
+	}
+
+	payload := signedPayload(timestamp, body)
+	for _, signature := range signatures {
+		if validMAC(v.Secret, payload, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// GitHubVerifier checks the X-Hub-Signature-256 header GitHub signs deliveries with
+func GitHubVerifier(secret []byte) *HMACVerifier {
+	return &HMACVerifier{Secret: secret, SignatureHeader: "X-Hub-Signature-256", Prefix: "sha256="}
+}
+
+// SharedSecretVerifier accepts deliveries that carry the secret itself in Header,
+// for providers that can not sign. Only use it over TLS.
+type SharedSecretVerifier struct {
+	Header string
+	Secret []byte
+}
+
+func (v *SharedSecretVerifier) Verify(r *http.Request, _ []byte) error {
+	value := r.Header.Get(v.Header)
+	if value == "" {
+		return ErrMissingSignature
+	}
+	if subtle.ConstantTimeCompare([]byte(value), v.Secret) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of payload, as providers compute it
+func Sign(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validMAC(secret []byte, payload []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func signedPayload(timestamp string, body []byte) []byte {
+	payload := make([]byte, 0, len(timestamp)+1+len(body))
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
+
+func checkTimestamp(timestamp string, tolerance time.Duration, now func() time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	age := now().Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// verify_test.go
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSecret = []byte("whsec_test")
+	testBody   = []byte(`{"id":"evt_1","type":"invoice.paid"}`)
+	testNow    = time.Unix(1_700_000_000, 0)
+)
+
+func fixedNow() time.Time { return testNow }
+
+func signedRequest(headers map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhook/test", nil)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	return r
+}
+
+func stripeHeader(timestamp time.Time, secret []byte, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + Sign(secret, signedPayload(ts, body))
+}
+
+func TestStripeVerifier(t *testing.T) {
+	t.Parallel()
+
+	v := &StripeVerifier{Secret: testSecret, Now: fixedNow}
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{"valid", stripeHeader(testNow, testSecret, testBody), testBody, nil},
+		{"rolled secret, second v1 matches", stripeHeader(testNow, testSecret, testBody) + ",v1=" + Sign([]byte("old"), testBody), testBody, nil},
+		{"old secret listed first", "t=1700000000,v1=" + Sign([]byte("old"), testBody) + ",v1=" + Sign(testSecret, signedPayload("1700000000", testBody)), testBody, nil},
+		{"wrong secret", stripeHeader(testNow, []byte("other"), testBody), testBody, ErrInvalidSignature},
+		{"tampered body", stripeHeader(testNow, testSecret, testBody), []byte(`{"id":"evt_2"}`), ErrInvalidSignature},
+		{"too old", stripeHeader(testNow.Add(-6*time.Minute), testSecret, testBody), testBody, ErrStaleTimestamp},
+		{"from the future", stripeHeader(testNow.Add(6*time.Minute), testSecret, testBody), testBody, ErrStaleTimestamp},
+		{"missing header", "", testBody, ErrMissingSignature},
+		{"no v1", "t=" + strconv.FormatInt(testNow.Unix(), 10), testBody, ErrMissingSignature},
+		{"not hex", "t=" + strconv.FormatInt(testNow.Unix(), 10) + ",v1=zz", testBody, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := v.Verify(signedRequest(map[string]string{"Stripe-Signature": tt.header}), tt.body)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHMACVerifier(t *testing.T) {
+	t.Parallel()
+
+	ts := strconv.FormatInt(testNow.Unix(), 10)
+	stale := strconv.FormatInt(testNow.Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name     string
+		verifier *HMACVerifier
+		headers  map[string]string
+		wantErr  error
+	}{
+		{
+			name:     "github style",
+			verifier: GitHubVerifier(testSecret),
+			headers:  map[string]string{"X-Hub-Signature-256": "sha256=" + Sign(testSecret, testBody)},
+		},
+		{
+			name:     "github without prefix",
+			verifier: GitHubVerifier(testSecret),
+			headers:  map[string]string{"X-Hub-Signature-256": Sign(testSecret, testBody)},
+			wantErr:  ErrMissingSignature,
+		},
+		{
+			name:     "github wrong signature",
+			verifier: GitHubVerifier(testSecret),
+			headers:  map[string]string{"X-Hub-Signature-256": "sha256=" + Sign([]byte("other"), testBody)},
+			wantErr:  ErrInvalidSignature,
+		},
+		{
+			name:     "timestamped",
+			verifier: Signed("billing", string(testSecret)).Verifier.(*HMACVerifier),
+			headers: map[string]string{
+				"X-Webhook-Timestamp": ts,
+				"X-Webhook-Signature": "sha256=" + Sign(testSecret, signedPayload(ts, testBody)),
+			},
+		},
+		{
+			name:     "timestamp is part of the signature",
+			verifier: Signed("billing", string(testSecret)).Verifier.(*HMACVerifier),
+			headers: map[string]string{
+				"X-Webhook-Timestamp": ts,
+				"X-Webhook-Signature": "sha256=" + Sign(testSecret, testBody),
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:     "stale timestamp",
+			verifier: Signed("billing", string(testSecret)).Verifier.(*HMACVerifier),
+			headers: map[string]string{
+				"X-Webhook-Timestamp": stale,
+				"X-Webhook-Signature": "sha256=" + Sign(testSecret, signedPayload(stale, testBody)),
+			},
+			wantErr: ErrStaleTimestamp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := *tt.verifier
+			v.Now = fixedNow
+			assert.ErrorIs(t, v.Verify(signedRequest(tt.headers), testBody), tt.wantErr)
+		})
+	}
+}
+
+func TestSharedSecretVerifier(t *testing.T) {
+	t.Parallel()
+
+	v := &SharedSecretVerifier{Header: "X-Webhook-Token", Secret: testSecret}
+
+	assert.NoError(t, v.Verify(signedRequest(map[string]string{"X-Webhook-Token": string(testSecret)}), testBody))
+	assert.ErrorIs(t, v.Verify(signedRequest(map[string]string{"X-Webhook-Token": "guess"}), testBody), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify(signedRequest(nil), testBody), ErrMissingSignature)
+}
+
+// ——————————————————— BENCHMARKS ———————————————————
+
+func BenchmarkStripeVerifier(b *testing.B) {
+	v := &StripeVerifier{Secret: testSecret, Now: fixedNow}
+	r := signedRequest(map[string]string{"Stripe-Signature": stripeHeader(testNow, testSecret, testBody)})
+
+	for b.Loop() {
+		_ = v.Verify(r, testBody)
+	}
+}
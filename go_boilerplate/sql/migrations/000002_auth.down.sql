@@ -0,0 +1,14 @@
+-- Drop the two-factor tables
+DROP TABLE IF EXISTS trusted_devices;
+DROP TABLE IF EXISTS recovery_codes;
+
+-- Drop the trigger applied to the user_totp table
+DROP TRIGGER IF EXISTS trigger_update_updated_user_totp ON user_totp;
+DROP TABLE IF EXISTS user_totp;
+
+-- Drop the sessions table
+DROP TABLE IF EXISTS sessions;
+
+-- Drop the credential columns added to users
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
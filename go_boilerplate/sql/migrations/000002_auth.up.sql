@@ -0,0 +1,51 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Sessions are looked up by the SHA-256 of the cookie token, never by the token itself
+CREATE TABLE IF NOT EXISTS sessions(
+  id VARCHAR(64) NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  mfa_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  mfa_attempts INTEGER NOT NULL DEFAULT 0,
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires TIMESTAMP NOT NULL,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- The TOTP secret is sealed with SECRET_KEY before it is stored
+CREATE TABLE IF NOT EXISTS user_totp(
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  secret BYTEA NOT NULL,
+  confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step BIGINT NOT NULL DEFAULT 0,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY(user_id)
+);
+
+SELECT apply_update_trigger('user_totp');
+
+CREATE TABLE IF NOT EXISTS recovery_codes(
+  id UUID NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash VARCHAR(64) NOT NULL,
+  used TIMESTAMP,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY(id),
+  UNIQUE(user_id, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS trusted_devices(
+  id VARCHAR(64) NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires TIMESTAMP NOT NULL,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices(user_id);
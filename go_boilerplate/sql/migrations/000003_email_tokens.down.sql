@@ -0,0 +1,5 @@
+-- Drop the email tokens table
+DROP TABLE IF EXISTS email_tokens;
+
+-- Drop the verification flag added to users
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Email tokens are looked up by the SHA-256 of the emailed token and can be used once
+CREATE TABLE IF NOT EXISTS email_tokens(
+  id VARCHAR(64) NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  purpose VARCHAR(20) NOT NULL,
+  email VARCHAR(30) NOT NULL,
+  used TIMESTAMP,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires TIMESTAMP NOT NULL,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user_id ON email_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_email_created ON email_tokens(email, created);
@@ -0,0 +1,3 @@
+-- Drop the passkey tables
+DROP TABLE IF EXISTS webauthn_ceremonies;
+DROP TABLE IF EXISTS passkeys;
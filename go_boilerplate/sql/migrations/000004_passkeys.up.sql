@@ -0,0 +1,25 @@
+-- credential holds the full go-webauthn credential record, sign_count is kept beside it for atomic clone checks
+CREATE TABLE IF NOT EXISTS passkeys(
+  id UUID NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  credential_id BYTEA NOT NULL,
+  name VARCHAR(64) NOT NULL DEFAULT '',
+  credential JSONB NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_used TIMESTAMP,
+  PRIMARY KEY(id),
+  UNIQUE(credential_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkeys_user_id ON passkeys(user_id);
+
+-- Pending registration and login ceremonies, looked up by the SHA-256 of the ceremony cookie
+CREATE TABLE IF NOT EXISTS webauthn_ceremonies(
+  id VARCHAR(64) NOT NULL,
+  purpose VARCHAR(20) NOT NULL,
+  data JSONB NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires TIMESTAMP NOT NULL,
+  PRIMARY KEY(id)
+);
@@ -0,0 +1,2 @@
+-- Drop the webhook inbox
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,21 @@
+-- Verified inbound webhooks, deduplicated per provider by the provider's own event ID.
+-- next_attempt doubles as the processing lease, so events claimed by a crashed worker become due again.
+CREATE TABLE IF NOT EXISTS webhook_events(
+  id UUID NOT NULL,
+  provider VARCHAR(32) NOT NULL,
+  event_id VARCHAR(255) NOT NULL,
+  event_type VARCHAR(255) NOT NULL DEFAULT '',
+  payload BYTEA NOT NULL,
+  headers JSONB NOT NULL DEFAULT '{}',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  received TIMESTAMP NOT NULL DEFAULT NOW(),
+  next_attempt TIMESTAMP NOT NULL DEFAULT NOW(),
+  processed TIMESTAMP,
+  PRIMARY KEY(id),
+  UNIQUE(provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_due ON webhook_events(next_attempt) WHERE status IN ('pending', 'failed', 'processing');
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received ON webhook_events(received);
@@ -0,0 +1,42 @@
+-- Endpoints subscribed to our domain events. secret is sealed with SECRET_KEY,
+-- failures counts consecutive failed attempts and disables the subscription past the limit.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions(
+  id UUID NOT NULL,
+  url TEXT NOT NULL,
+  secret BYTEA NOT NULL,
+  events TEXT[] NOT NULL DEFAULT '{}',
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  failures INT NOT NULL DEFAULT 0,
+  disabled_reason TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY(id)
+);
+
+-- One row per event and subscription, next_attempt doubles as the sending lease like the inbox
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+  id UUID NOT NULL,
+  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  event VARCHAR(64) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt TIMESTAMP NOT NULL DEFAULT NOW(),
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  delivered TIMESTAMP,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt) WHERE status IN ('pending', 'failed', 'sending');
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts(
+  id UUID NOT NULL,
+  delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+  status_code INT NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  duration_ms INT NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id);
@@ -0,0 +1,2 @@
+-- Drop the rate limit buckets
+DROP TABLE IF EXISTS rate_limits;
@@ -0,0 +1,9 @@
+-- Shared rate limit buckets for multi-instance deployments.
+-- tat is the GCRA theoretical arrival time, a bucket whose tat has passed is full and can be dropped.
+CREATE TABLE IF NOT EXISTS rate_limits(
+  key TEXT NOT NULL,
+  tat TIMESTAMP NOT NULL,
+  PRIMARY KEY(key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_tat ON rate_limits(tat);
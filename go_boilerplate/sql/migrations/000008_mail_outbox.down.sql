@@ -0,0 +1,2 @@
+-- Drop the mail outbox
+DROP TABLE IF EXISTS mail_outbox;
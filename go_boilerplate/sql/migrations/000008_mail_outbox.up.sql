@@ -0,0 +1,17 @@
+-- Mail written in the same transaction as the change it reports on, sent by the outbox worker.
+-- message holds the rendered mail.Message, next_attempt doubles as the sending lease like webhook deliveries.
+CREATE TABLE IF NOT EXISTS mail_outbox(
+  id UUID NOT NULL,
+  recipients TEXT[] NOT NULL,
+  subject TEXT NOT NULL,
+  message JSONB NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_error TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  sent TIMESTAMP,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_outbox_due ON mail_outbox(next_attempt) WHERE status IN ('pending', 'failed', 'sending');
@@ -0,0 +1,44 @@
+-- Who changed what, written in the same transaction as the change. Every row carries the hash of the
+-- row before it, so an edited or removed row breaks the chain from there on. diff is JSON rather than
+-- JSONB because the hash covers its exact bytes.
+CREATE TABLE IF NOT EXISTS audit_log(
+  seq BIGSERIAL NOT NULL,
+  id UUID NOT NULL UNIQUE,
+  occurred TIMESTAMP NOT NULL,
+  actor_id TEXT NOT NULL DEFAULT '',
+  actor TEXT NOT NULL DEFAULT '',
+  action VARCHAR(64) NOT NULL,
+  entity_type VARCHAR(64) NOT NULL,
+  entity_id TEXT NOT NULL,
+  diff JSON NOT NULL,
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  prev_hash BYTEA NOT NULL,
+  hash BYTEA NOT NULL,
+  PRIMARY KEY(seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred ON audit_log(occurred);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+
+-- Rows are never updated, and only deleted by the retention job, which sets audit.retention for its transaction
+CREATE OR REPLACE FUNCTION audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' AND current_setting('audit.retention', true) = 'on' THEN
+    RETURN OLD;
+  END IF;
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER trigger_audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT
+EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,3 @@
+-- Drop the job leases and run history
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS job_leases;
@@ -0,0 +1,27 @@
+-- Jobs that run on exactly one instance. The row is the lease: it is held by instance until
+-- expires, which the holder keeps pushing back while the run lasts, and scheduled is the last
+-- run claimed so a replica firing the same tick later does not run it again.
+CREATE TABLE IF NOT EXISTS job_leases(
+  job TEXT NOT NULL,
+  instance TEXT NOT NULL,
+  scheduled TIMESTAMP NOT NULL,
+  expires TIMESTAMP NOT NULL,
+  PRIMARY KEY(job)
+);
+
+-- One row per run on any instance, finished is set once the run ends
+CREATE TABLE IF NOT EXISTS job_runs(
+  id UUID NOT NULL,
+  job TEXT NOT NULL,
+  instance TEXT NOT NULL,
+  manual BOOLEAN NOT NULL DEFAULT FALSE,
+  scheduled TIMESTAMP NOT NULL,
+  started TIMESTAMP NOT NULL DEFAULT NOW(),
+  finished TIMESTAMP,
+  status VARCHAR(16) NOT NULL DEFAULT 'running',
+  error TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, started);
+CREATE INDEX IF NOT EXISTS idx_job_runs_started ON job_runs(started);
@@ -0,0 +1,2 @@
+-- Drop the job run logs
+ALTER TABLE job_runs DROP COLUMN IF EXISTS log;
@@ -0,0 +1,2 @@
+-- What a run wrote with tools.Logf, and the stack of a panic, shown with the run history
+ALTER TABLE job_runs ADD COLUMN IF NOT EXISTS log TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Drop the job queue
+DROP TABLE IF EXISTS queue_jobs;
+DROP FUNCTION IF EXISTS notify_queue_jobs();
@@ -0,0 +1,33 @@
+-- One-off background jobs enqueued with queue.Enqueue, often in the transaction of the change they
+-- follow, and run by queue.Worker. run_at doubles as the lease of a running job like the outbox.
+-- unique_key dedupes the jobs of a kind that are still to run.
+CREATE TABLE IF NOT EXISTS queue_jobs(
+  id UUID NOT NULL,
+  kind VARCHAR(64) NOT NULL,
+  args JSONB NOT NULL,
+  priority INT NOT NULL DEFAULT 0,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL,
+  run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  unique_key TEXT,
+  last_error TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  finished TIMESTAMP,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_jobs_due ON queue_jobs(priority DESC, run_at) WHERE status IN ('pending', 'failed', 'running');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_queue_jobs_unique ON queue_jobs(kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'failed', 'running');
+
+-- Wakes the workers listening on queue_jobs when a job is due right away, once the insert commits
+CREATE OR REPLACE FUNCTION notify_queue_jobs() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('queue_jobs', NEW.kind);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS queue_jobs_notify ON queue_jobs;
+CREATE TRIGGER queue_jobs_notify AFTER INSERT ON queue_jobs
+FOR EACH ROW WHEN (NEW.run_at <= NOW()) EXECUTE FUNCTION notify_queue_jobs();
@@ -0,0 +1,38 @@
+-- name: LockAuditLog :exec
+-- Serializes writers until commit, so every entry chains onto the one committed before it
+SELECT pg_advisory_xact_lock(hashtext('audit_log'));
+
+-- name: GetLastAuditHash :one
+SELECT hash FROM audit_log
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: InsertAuditEntry :exec
+INSERT INTO audit_log (id, occurred, actor_id, actor, action, entity_type, entity_id, diff, ip, user_agent, request_id, prev_hash, hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+
+-- name: ListAuditEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(actor)::text IS NULL OR actor = sqlc.narg(actor) OR actor_id = sqlc.narg(actor))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(entity_type)::text IS NULL OR entity_type = sqlc.narg(entity_type))
+  AND (sqlc.narg(entity_id)::text IS NULL OR entity_id = sqlc.narg(entity_id))
+  AND (sqlc.narg(since)::timestamp IS NULL OR occurred >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamp IS NULL OR occurred < sqlc.narg(until))
+  AND (sqlc.narg(before_seq)::bigint IS NULL OR seq < sqlc.narg(before_seq))
+ORDER BY seq DESC
+LIMIT $1;
+
+-- name: ListAuditChain :many
+SELECT * FROM audit_log
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2;
+
+-- name: EnableAuditRetention :exec
+SELECT set_config('audit.retention', 'on', true);
+
+-- name: DeleteAuditEntries :execrows
+-- Cuts by sequence rather than time, so an entry written while the clock stepped back can't leave a gap
+DELETE FROM audit_log
+WHERE seq <= (SELECT max(seq) FROM audit_log WHERE occurred < sqlc.arg(before)::timestamp);
@@ -0,0 +1,23 @@
+-- name: CreateEmailToken :exec
+INSERT INTO email_tokens (id, user_id, purpose, email, expires)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeEmailToken :one
+UPDATE email_tokens
+SET used = NOW()
+WHERE id = $1 AND purpose = $2 AND used IS NULL AND expires > NOW()
+RETURNING user_id, email;
+
+-- name: CountRecentEmailTokens :one
+SELECT COUNT(*) FROM email_tokens
+WHERE email = $1 AND created > $2;
+
+-- name: RevokeUnusedEmailTokens :exec
+-- Revoked rows are kept until they expire, CountRecentEmailTokens counts them
+UPDATE email_tokens
+SET used = NOW()
+WHERE user_id = $1 AND purpose = $2 AND used IS NULL;
+
+-- name: DeleteExpiredEmailTokens :execrows
+DELETE FROM email_tokens
+WHERE expires <= NOW();
@@ -0,0 +1,44 @@
+-- name: AcquireJobLease :one
+-- Claims the run of job due at scheduled, no row comes back when another instance holds the
+-- lease or already claimed that run
+INSERT INTO job_leases AS l (job, instance, scheduled, expires)
+VALUES (sqlc.arg(job), sqlc.arg(instance), sqlc.arg(scheduled)::timestamp, NOW() + make_interval(secs => sqlc.arg(ttl)::float8))
+ON CONFLICT (job) DO UPDATE
+SET instance = EXCLUDED.instance, scheduled = EXCLUDED.scheduled, expires = EXCLUDED.expires
+WHERE l.expires <= NOW() AND l.scheduled < EXCLUDED.scheduled
+RETURNING expires;
+
+-- name: RenewJobLease :execrows
+UPDATE job_leases
+SET expires = NOW() + make_interval(secs => sqlc.arg(ttl)::float8)
+WHERE job = sqlc.arg(job) AND instance = sqlc.arg(instance);
+
+-- name: ReleaseJobLease :exec
+UPDATE job_leases
+SET expires = NOW()
+WHERE job = $1 AND instance = $2;
+
+-- name: StartJobRun :exec
+INSERT INTO job_runs (id, job, instance, manual, scheduled)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: FinishJobRun :exec
+UPDATE job_runs
+SET finished = NOW(), status = $2, error = $3, log = $4
+WHERE id = $1;
+
+-- name: ListJobRuns :many
+SELECT * FROM job_runs
+WHERE job = $1
+ORDER BY started DESC
+LIMIT $2;
+
+-- name: ListJobSuccesses :many
+SELECT job, MAX(finished)::timestamp AS last_success
+FROM job_runs
+WHERE status = 'succeeded'
+GROUP BY job;
+
+-- name: DeleteOldJobRuns :execrows
+DELETE FROM job_runs
+WHERE started < sqlc.arg(before)::timestamp;
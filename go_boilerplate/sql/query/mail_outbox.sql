@@ -0,0 +1,29 @@
+-- name: EnqueueMail :exec
+INSERT INTO mail_outbox (id, recipients, subject, message)
+VALUES ($1, $2, $3, $4);
+
+-- name: ClaimMail :many
+UPDATE mail_outbox
+SET status = 'sending', attempts = attempts + 1, next_attempt = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT id FROM mail_outbox
+  WHERE status IN ('pending', 'failed', 'sending') AND next_attempt <= NOW()
+  ORDER BY next_attempt
+  LIMIT sqlc.arg(batch)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, message, attempts;
+
+-- name: CompleteMail :exec
+UPDATE mail_outbox
+SET status = 'sent', sent = NOW(), last_error = ''
+WHERE id = $1;
+
+-- name: FailMail :exec
+UPDATE mail_outbox
+SET status = $2, next_attempt = $3, last_error = $4
+WHERE id = $1;
+
+-- name: DeleteOldMail :execrows
+DELETE FROM mail_outbox
+WHERE status IN ('sent', 'dead') AND created < sqlc.arg(before)::timestamp;
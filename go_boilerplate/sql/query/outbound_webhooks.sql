@@ -0,0 +1,94 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, events, active, failures, disabled_reason, created;
+
+-- name: ListWebhookSubscriptions :many
+SELECT id, url, events, active, failures, disabled_reason, created
+FROM webhook_subscriptions
+ORDER BY created;
+
+-- name: GetWebhookSubscription :one
+SELECT id, url, events, active, failures, disabled_reason, created
+FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: EnableWebhookSubscription :execrows
+UPDATE webhook_subscriptions
+SET active = TRUE, failures = 0, disabled_reason = ''
+WHERE id = $1;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload)
+SELECT gen_random_uuid(), s.id, sqlc.arg(event), sqlc.arg(payload)
+FROM webhook_subscriptions s
+WHERE s.active AND (cardinality(s.events) = 0 OR sqlc.arg(event)::text = ANY(s.events));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET status = 'sending', attempts = d.attempts + 1, next_attempt = sqlc.arg(lease_until)
+FROM webhook_subscriptions s
+WHERE s.id = d.subscription_id AND d.id IN (
+  SELECT wd.id FROM webhook_deliveries wd
+  JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id AND ws.active
+  WHERE wd.status IN ('pending', 'failed', 'sending') AND wd.next_attempt <= NOW()
+  ORDER BY wd.next_attempt
+  LIMIT sqlc.arg(batch)
+  FOR UPDATE OF wd SKIP LOCKED
+)
+RETURNING d.id, d.subscription_id, d.event, d.payload, d.attempts, d.created, s.url, s.secret;
+
+-- name: RecordWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', delivered = NOW()
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt = $3
+WHERE id = $1;
+
+-- name: ResetWebhookSubscriptionFailures :exec
+UPDATE webhook_subscriptions
+SET failures = 0
+WHERE id = $1 AND failures <> 0;
+
+-- name: RecordWebhookSubscriptionFailure :one
+UPDATE webhook_subscriptions
+SET failures = failures + 1,
+    active = failures + 1 < sqlc.arg(max_failures)::int,
+    disabled_reason = CASE WHEN failures + 1 < sqlc.arg(max_failures)::int THEN '' ELSE sqlc.arg(reason)::text END
+WHERE id = sqlc.arg(id) AND active
+RETURNING active;
+
+-- name: RedeliverWebhook :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt = NOW(), delivered = NULL
+WHERE id = $1 AND status <> 'sending'
+RETURNING subscription_id;
+
+-- name: ListWebhookDeliveries :many
+SELECT d.id, d.event, d.status, d.attempts, d.next_attempt, d.created, d.delivered,
+  COALESCE(a.status_code, 0)::int AS last_status_code, COALESCE(a.error, '')::text AS last_error
+FROM webhook_deliveries d
+LEFT JOIN LATERAL (
+  SELECT status_code, error FROM webhook_delivery_attempts
+  WHERE delivery_id = d.id
+  ORDER BY created DESC
+  LIMIT 1
+) a ON TRUE
+WHERE d.subscription_id = $1
+ORDER BY d.created DESC
+LIMIT $2;
+
+-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status IN ('succeeded', 'dead') AND created < sqlc.arg(before)::timestamp;
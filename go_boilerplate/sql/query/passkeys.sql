@@ -0,0 +1,36 @@
+-- name: CreatePasskey :exec
+INSERT INTO passkeys (id, user_id, credential_id, name, credential, sign_count)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ListUserPasskeys :many
+SELECT id, user_id, credential_id, name, credential, sign_count, created, last_used
+FROM passkeys
+WHERE user_id = $1
+ORDER BY created;
+
+-- name: GetPasskeyOwner :one
+SELECT user_id
+FROM passkeys
+WHERE credential_id = $1;
+
+-- name: UpdatePasskeyUsage :execrows
+UPDATE passkeys
+SET credential = $2, sign_count = $3, last_used = NOW()
+WHERE credential_id = $1 AND (sign_count < $3 OR (sign_count = 0 AND $3 = 0));
+
+-- name: DeletePasskey :execrows
+DELETE FROM passkeys
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebAuthnCeremony :exec
+INSERT INTO webauthn_ceremonies (id, purpose, data, expires)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeWebAuthnCeremony :one
+DELETE FROM webauthn_ceremonies
+WHERE id = $1 AND purpose = $2 AND expires > NOW()
+RETURNING data;
+
+-- name: DeleteExpiredWebAuthnCeremonies :execrows
+DELETE FROM webauthn_ceremonies
+WHERE expires <= NOW();
@@ -0,0 +1,38 @@
+-- name: EnqueueQueueJob :execrows
+INSERT INTO queue_jobs (id, kind, args, priority, max_attempts, run_at, unique_key)
+VALUES (
+  sqlc.arg(id), sqlc.arg(kind), sqlc.arg(args), sqlc.arg(priority), sqlc.arg(max_attempts),
+  COALESCE(sqlc.narg(run_at)::timestamp, NOW()), sqlc.narg(unique_key)
+)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'failed', 'running') DO NOTHING;
+
+-- name: ClaimQueueJobs :many
+UPDATE queue_jobs
+SET status = 'running', attempts = attempts + 1, run_at = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT id FROM queue_jobs
+  WHERE status IN ('pending', 'failed', 'running') AND run_at <= NOW() AND kind = ANY(sqlc.arg(kinds)::text[])
+  ORDER BY priority DESC, run_at
+  LIMIT sqlc.arg(batch)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, args, priority, attempts, max_attempts, created;
+
+-- name: CompleteQueueJob :exec
+UPDATE queue_jobs
+SET status = 'succeeded', finished = NOW(), last_error = ''
+WHERE id = $1;
+
+-- name: FailQueueJob :exec
+UPDATE queue_jobs
+SET status = $2, run_at = $3, last_error = $4, finished = CASE WHEN $2 = 'dead' THEN NOW() END
+WHERE id = $1;
+
+-- name: RetryQueueJob :execrows
+UPDATE queue_jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished = NULL
+WHERE id = $1 AND status = 'dead';
+
+-- name: DeleteOldQueueJobs :execrows
+DELETE FROM queue_jobs
+WHERE status IN ('succeeded', 'dead') AND finished < sqlc.arg(before)::timestamp;
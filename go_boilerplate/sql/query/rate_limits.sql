@@ -0,0 +1,16 @@
+-- name: TakeRateLimit :one
+-- Spends one request atomically, no row comes back when the bucket is empty
+INSERT INTO rate_limits AS r (key, tat)
+VALUES (sqlc.arg(key), sqlc.arg(now)::timestamp + make_interval(secs => sqlc.arg(interval)::float8))
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(r.tat, sqlc.arg(now)::timestamp) + make_interval(secs => sqlc.arg(interval)::float8)
+WHERE GREATEST(r.tat, sqlc.arg(now)::timestamp) + make_interval(secs => sqlc.arg(interval)::float8 - sqlc.arg(tolerance)::float8) <= sqlc.arg(now)::timestamp
+RETURNING tat;
+
+-- name: GetRateLimit :one
+SELECT tat FROM rate_limits
+WHERE key = $1;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM rate_limits
+WHERE tat < sqlc.arg(before)::timestamp;
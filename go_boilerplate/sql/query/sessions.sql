@@ -0,0 +1,30 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (id, user_id, mfa_verified, ip, user_agent, expires)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetSession :one
+SELECT s.id, s.user_id, s.mfa_verified, s.mfa_attempts, s.expires,
+       u.username, u.email, u.email_verified, u.is_admin,
+       COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+LEFT JOIN user_totp t ON t.user_id = s.user_id
+WHERE s.id = $1 AND s.expires > NOW();
+
+-- name: IncrementSessionMFAAttempts :one
+UPDATE sessions
+SET mfa_attempts = mfa_attempts + 1
+WHERE id = $1
+RETURNING mfa_attempts;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE id = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires <= NOW();
@@ -0,0 +1,56 @@
+-- name: CreatePendingTOTP :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetUserTOTP :one
+SELECT user_id, secret, confirmed, last_step
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: ConfirmUserTOTP :execrows
+UPDATE user_totp
+SET confirmed = TRUE, last_step = $2
+WHERE user_id = $1 AND confirmed = FALSE;
+
+-- name: AdvanceTOTPStep :execrows
+UPDATE user_totp
+SET last_step = $2
+WHERE user_id = $1 AND last_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, user_id, code_hash)
+VALUES ($1, $2, $3);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*)
+FROM recovery_codes
+WHERE user_id = $1 AND used IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateTrustedDevice :exec
+INSERT INTO trusted_devices (id, user_id, user_agent, expires)
+VALUES ($1, $2, $3, $4);
+
+-- name: IsTrustedDevice :one
+SELECT EXISTS (
+  SELECT 1
+  FROM trusted_devices
+  WHERE id = $1 AND user_id = $2 AND expires > NOW()
+);
+
+-- name: DeleteTrustedDevices :exec
+DELETE FROM trusted_devices
+WHERE user_id = $1;
@@ -25,3 +25,41 @@ WHERE id = $1;
 
 -- name: CountUsers :one
 SELECT COUNT(*) FROM users;
+
+-- name: GetUserCredentials :one
+SELECT id, username, email, password_hash, is_admin
+FROM users
+WHERE username = $1 OR email = $1;
+
+-- name: CreateUserWithPassword :one
+INSERT INTO users (id, username, email, password_hash)
+VALUES ($1, $2, $3, $4)
+RETURNING id, username, email, created;
+
+-- name: ListUsersWithTwoFactor :many
+SELECT u.id, u.username, u.email, u.is_admin, COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM users u
+LEFT JOIN user_totp t ON t.user_id = u.id
+ORDER BY u.created DESC;
+
+-- name: GetUserWithTwoFactor :one
+SELECT u.id, u.username, u.email, u.is_admin, COALESCE(t.confirmed, FALSE)::BOOLEAN AS two_factor
+FROM users u
+LEFT JOIN user_totp t ON t.user_id = u.id
+WHERE u.id = $1;
+
+-- name: GetUserByEmail :one
+SELECT id, username, email, email_verified
+FROM users
+WHERE email = $1;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified = TRUE
+WHERE id = $1 AND email = $2;
+
+-- name: ChangeUserEmail :one
+UPDATE users
+SET email = $2, email_verified = TRUE
+WHERE id = $1
+RETURNING id, username, email, created;
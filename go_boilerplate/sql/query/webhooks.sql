@@ -0,0 +1,48 @@
+-- name: CreateWebhookEvent :execrows
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, headers)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, event_id) DO NOTHING;
+
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, next_attempt = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT id FROM webhook_events
+  WHERE status IN ('pending', 'failed', 'processing') AND next_attempt <= NOW()
+  ORDER BY next_attempt
+  LIMIT sqlc.arg(batch)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, provider, event_id, event_type, payload, headers, attempts;
+
+-- name: CompleteWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'done', last_error = '', processed = NOW()
+WHERE id = $1;
+
+-- name: FailWebhookEvent :exec
+UPDATE webhook_events
+SET status = $2, last_error = $3, next_attempt = $4
+WHERE id = $1;
+
+-- name: ReplayWebhookEvent :execrows
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, last_error = '', next_attempt = NOW(), processed = NULL
+WHERE id = $1 AND status <> 'processing';
+
+-- name: GetWebhookEvent :one
+SELECT id, provider, event_id, event_type, payload, headers, status, attempts, last_error, received, next_attempt, processed
+FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT id, provider, event_id, event_type, status, attempts, last_error, received, processed
+FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(provider)::text IS NULL OR provider = sqlc.narg(provider))
+ORDER BY received DESC
+LIMIT $1;
+
+-- name: DeleteProcessedWebhookEvents :execrows
+DELETE FROM webhook_events
+WHERE status = 'done' AND processed < sqlc.arg(before)::timestamp;
@@ -180,7 +180,16 @@ fn dir_builder(
     injects: &Injectables,
 ) -> Result<HashSet<String>, ScaffError> {
     if dir.dirname == "connections" && !injects.ws
-        || (dir.dirname == "database" || dir.dirname == "sql" || dir.dirname == "repository")
+        || (dir.dirname == "database"
+            || dir.dirname == "sql"
+            || dir.dirname == "repository"
+            || dir.dirname == "auth"
+            || dir.dirname == "account"
+            || dir.dirname == "admin"
+            || dir.dirname == "webhooks"
+            || dir.dirname == "audit"
+            || dir.dirname == "cluster"
+            || dir.dirname == "queue")
             && !injects.db
     {
         return Ok(HashSet::new());
@@ -199,7 +208,13 @@ fn dir_builder(
     for mut prj_file in dir.files.unwrap_or(vec![]) {
         if (prj_file.filename == "sqlc.yml".to_string()
             || prj_file.filename == "user-item.templ".to_string()
-            || prj_file.filename == "user-list.templ".to_string())
+            || prj_file.filename == "user-list.templ".to_string()
+            || prj_file.filename == "account.go".to_string()
+            || prj_file.filename == "admin.go".to_string()
+            || prj_file.filename == "pgstore.go".to_string()
+            || prj_file.filename == "pgstore_test.go".to_string()
+            || prj_file.filename == "outbox.go".to_string()
+            || prj_file.filename == "outbox_test.go".to_string())
             && !injects.db
         {
             continue;